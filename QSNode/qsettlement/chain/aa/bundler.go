@@ -0,0 +1,319 @@
+package aa
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// bundleOverheadGas covers the EntryPoint's own work per handleOps call
+const bundleOverheadGas = 50000
+
+// defaultMaxKnownOps bounds the user op hashes kept for duplicate detection
+const defaultMaxKnownOps = 4096
+
+// entryPointABI is the handleOps entry of the v0.6 EntryPoint
+const entryPointABI = `[{"type":"function","name":"handleOps","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"ops","type":"tuple[]","components":[
+		{"name":"sender","type":"address"},
+		{"name":"nonce","type":"uint256"},
+		{"name":"initCode","type":"bytes"},
+		{"name":"callData","type":"bytes"},
+		{"name":"callGasLimit","type":"uint256"},
+		{"name":"verificationGasLimit","type":"uint256"},
+		{"name":"preVerificationGas","type":"uint256"},
+		{"name":"maxFeePerGas","type":"uint256"},
+		{"name":"maxPriorityFeePerGas","type":"uint256"},
+		{"name":"paymasterAndData","type":"bytes"},
+		{"name":"signature","type":"bytes"}]},
+	{"name":"beneficiary","type":"address"}]}]`
+
+var (
+	// ErrInvalidUserOpSignature is returned when the PQ signature does not verify
+	ErrInvalidUserOpSignature = errors.New("invalid PQ user operation signature")
+	// ErrUserOpKeyMismatch is returned when the signing key is not the account's key
+	ErrUserOpKeyMismatch = errors.New("user operation key does not match account")
+	// ErrUserOpKnown is returned for duplicate user operations
+	ErrUserOpKnown = errors.New("user operation already known")
+	// ErrUserOpGasOverflow is returned when an operation's gas limits cannot fit in a bundle
+	ErrUserOpGasOverflow = errors.New("user operation gas overflows a bundle")
+	// ErrBundlerFull is returned when MaxKnownOps operations are still pending
+	ErrBundlerFull = errors.New("bundler is full")
+)
+
+// AccountReader resolves the key hash (see PQKeyHash) stored in a PQSmartAccount
+type AccountReader interface {
+	PQKeyHash(account common.Address) (common.Hash, error)
+}
+
+// BundlerConfig configures the PQ bundler
+type BundlerConfig struct {
+	EntryPoint         common.Address
+	ChainID            *big.Int
+	Beneficiary        common.Address // Receives the EntryPoint gas refunds
+	MaxBundleOps       int
+	MaxVerificationGas uint64
+	MaxKnownOps        int                 // Op hashes remembered until Included; 0 uses 4096
+	SigAlgos           *tx.SigAlgoRegistry // Verifies user op signatures; nil uses tx.SigAlgos()
+}
+
+// Bundler collects PQ-signed user operations, validates them off-chain
+// against the PQSigAlgo registry and packs them into handleOps transactions
+type Bundler struct {
+	mu       sync.Mutex
+	config   BundlerConfig
+	accounts AccountReader
+	algos    *tx.SigAlgoRegistry
+	handleOp abi.ABI
+
+	pending []pendingOp
+
+	// known maps op hashes to whether the op is still pending; knownOrder
+	// holds them oldest first so bundled ops can be forgotten at the cap
+	known      map[common.Hash]bool
+	knownOrder []common.Hash
+}
+
+// pendingOp is a validated user operation waiting for a bundle
+type pendingOp struct {
+	op   *UserOperation
+	hash common.Hash
+}
+
+// NewBundler creates a bundler. accounts may be nil to skip the key-hash
+// check (the EntryPoint still enforces it on-chain).
+func NewBundler(config BundlerConfig, accounts AccountReader) (*Bundler, error) {
+	parsed, err := abi.JSON(strings.NewReader(entryPointABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse EntryPoint ABI: %w", err)
+	}
+
+	algos := config.SigAlgos
+	if algos == nil {
+		algos = tx.SigAlgos()
+	}
+	if config.MaxKnownOps <= 0 {
+		config.MaxKnownOps = defaultMaxKnownOps
+	}
+
+	return &Bundler{
+		config:   config,
+		accounts: accounts,
+		algos:    algos,
+		handleOp: parsed,
+		known:    make(map[common.Hash]bool),
+	}, nil
+}
+
+// ValidateUserOperation checks fields, the account key binding and the PQ
+// signature, returning the userOpHash
+func (b *Bundler) ValidateUserOperation(op *UserOperation) (common.Hash, error) {
+	if err := op.validateFields(); err != nil {
+		return common.Hash{}, err
+	}
+
+	if op.TotalGas() > math.MaxUint64-bundleOverheadGas {
+		return common.Hash{}, ErrUserOpGasOverflow
+	}
+
+	if b.config.MaxVerificationGas > 0 && op.VerificationGasLimit.Cmp(new(big.Int).SetUint64(b.config.MaxVerificationGas)) > 0 {
+		return common.Hash{}, fmt.Errorf("verificationGasLimit %s exceeds bundler limit %d",
+			op.VerificationGasLimit, b.config.MaxVerificationGas)
+	}
+
+	opHash, err := op.Hash(b.config.EntryPoint, b.config.ChainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	algo, pubKey, sig, err := DecodePQSignature(op.Signature)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if b.accounts != nil {
+		keyHash, err := b.accounts.PQKeyHash(op.Sender)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("failed to read account key: %w", err)
+		}
+		if PQKeyHash(algo, pubKey) != keyHash {
+			return common.Hash{}, ErrUserOpKeyMismatch
+		}
+	}
+
+	// Verify with the registry the PqVerify precompile is backed by, so the
+	// off-chain check is the one PQSmartAccount makes on-chain through 0x0101
+	// with the algorithm its key hash commits to
+	if err := b.algos.Verify(algo, pubKey, opHash.Bytes(), sig); err != nil {
+		return common.Hash{}, fmt.Errorf("%w: %v", ErrInvalidUserOpSignature, err)
+	}
+
+	return opHash, nil
+}
+
+// AddUserOperation validates op and queues it for the next bundle
+func (b *Bundler) AddUserOperation(op *UserOperation) (common.Hash, error) {
+	opHash, err := b.ValidateUserOperation(op)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.known[opHash]; exists {
+		return common.Hash{}, ErrUserOpKnown
+	}
+	if err := b.makeRoomLocked(); err != nil {
+		return common.Hash{}, err
+	}
+
+	b.known[opHash] = true
+	b.knownOrder = append(b.knownOrder, opHash)
+	b.pending = append(b.pending, pendingOp{op: op, hash: opHash})
+
+	log.Printf("[Bundler] Accepted user operation %s from %s (nonce %s)\n",
+		opHash.Hex(), op.Sender.Hex(), op.Nonce)
+
+	return opHash, nil
+}
+
+// Pending returns the number of queued user operations
+func (b *Bundler) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// BuildBundle packs up to MaxBundleOps queued operations (one per sender)
+// into an EntryPoint.handleOps transaction signed by the ECDSA executor key
+func (b *Bundler) BuildBundle(executor *ecdsa.PrivateKey, nonce uint64, gasTipCap, gasFeeCap *big.Int) (*types.Transaction, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		return nil, errors.New("no pending user operations")
+	}
+
+	var (
+		ops       []UserOperation
+		bundled   []common.Hash
+		remaining []pendingOp
+		senders   = make(map[common.Address]bool)
+		gas       = uint64(bundleOverheadGas)
+	)
+	for _, p := range b.pending {
+		// The EntryPoint validates ops sequentially; keep one per sender so
+		// nonce ordering within a bundle cannot fail validation. Ops that
+		// would overflow the bundle gas wait for the next bundle.
+		full := b.config.MaxBundleOps > 0 && len(ops) >= b.config.MaxBundleOps
+		opGas := p.op.TotalGas()
+		if full || senders[p.op.Sender] || opGas > math.MaxUint64-gas {
+			remaining = append(remaining, p)
+			continue
+		}
+		senders[p.op.Sender] = true
+		ops = append(ops, *p.op)
+		bundled = append(bundled, p.hash)
+		gas += opGas
+	}
+
+	data, err := b.handleOp.Pack("handleOps", ops, b.config.Beneficiary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack handleOps: %w", err)
+	}
+
+	entryPoint := b.config.EntryPoint
+	bundle, err := types.SignNewTx(executor, types.LatestSignerForChainID(b.config.ChainID), &types.DynamicFeeTx{
+		ChainID:   b.config.ChainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gas,
+		To:        &entryPoint,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign bundle: %w", err)
+	}
+
+	b.pending = remaining
+	for _, hash := range bundled {
+		b.known[hash] = false
+	}
+
+	log.Printf("[Bundler] Built bundle %s with %d user operations (%d remaining, gas %d)\n",
+		bundle.Hash().Hex(), len(ops), len(remaining), gas)
+
+	return bundle, nil
+}
+
+// Included forgets the user operations of a bundle once it is in a block.
+// Bundled operations are otherwise remembered, to reject resubmissions,
+// until MaxKnownOps newer ones push them out.
+func (b *Bundler) Included(bundle *types.Transaction) error {
+	method := b.handleOp.Methods["handleOps"]
+	data := bundle.Data()
+	if len(data) < 4 || !bytes.Equal(data[:4], method.ID) {
+		return errors.New("not a handleOps transaction")
+	}
+
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return fmt.Errorf("failed to unpack handleOps: %w", err)
+	}
+	ops := *abi.ConvertType(args[0], new([]UserOperation)).(*[]UserOperation)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range ops {
+		hash, err := ops[i].Hash(b.config.EntryPoint, b.config.ChainID)
+		if err != nil {
+			return err
+		}
+		if pending, exists := b.known[hash]; exists && !pending {
+			delete(b.known, hash)
+		}
+	}
+
+	kept := b.knownOrder[:0]
+	for _, hash := range b.knownOrder {
+		if _, exists := b.known[hash]; exists {
+			kept = append(kept, hash)
+		}
+	}
+	b.knownOrder = kept
+
+	return nil
+}
+
+// makeRoomLocked forgets the oldest bundled operations until another op
+// fits under MaxKnownOps. Pending operations are never forgotten, so a full
+// queue rejects new ones. Caller holds the lock.
+func (b *Bundler) makeRoomLocked() error {
+	for len(b.known) >= b.config.MaxKnownOps {
+		i := 0
+		for i < len(b.knownOrder) && b.known[b.knownOrder[i]] {
+			i++
+		}
+		if i == len(b.knownOrder) {
+			return fmt.Errorf("%w: %d operations pending", ErrBundlerFull, len(b.pending))
+		}
+		delete(b.known, b.knownOrder[i])
+		b.knownOrder = append(b.knownOrder[:i], b.knownOrder[i+1:]...)
+	}
+	return nil
+}
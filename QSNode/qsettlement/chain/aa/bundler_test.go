@@ -0,0 +1,308 @@
+package aa
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"testing"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// staticAccounts maps smart accounts to their PQ key hash
+type staticAccounts map[common.Address]common.Hash
+
+func (a staticAccounts) PQKeyHash(account common.Address) (common.Hash, error) {
+	return a[account], nil
+}
+
+func newTestBundler(t *testing.T, accounts AccountReader, algos *tx.SigAlgoRegistry) *Bundler {
+	t.Helper()
+	return newTestBundlerWithConfig(t, BundlerConfig{SigAlgos: algos}, accounts)
+}
+
+func newTestBundlerWithConfig(t *testing.T, config BundlerConfig, accounts AccountReader) *Bundler {
+	t.Helper()
+
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	config.EntryPoint = common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+	config.ChainID = big.NewInt(9357)
+	b, err := NewBundler(config, accounts)
+	if err != nil {
+		t.Fatalf("NewBundler: %v", err)
+	}
+	return b
+}
+
+// testKey is an account key of any PQSigAlgo with a circl scheme
+type testKey struct {
+	algo   uint8
+	scheme sign.Scheme
+	pub    []byte
+	priv   sign.PrivateKey
+}
+
+func newTestKey(t *testing.T, algo uint8, scheme sign.Scheme) *testKey {
+	t.Helper()
+
+	pk, sk, err := scheme.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := pk.MarshalBinary()
+	return &testKey{algo: algo, scheme: scheme, pub: pub, priv: sk}
+}
+
+func newMLDSA44Key(t *testing.T) *testKey {
+	return newTestKey(t, tx.SigAlgoDilithium2, mldsa44.Scheme())
+}
+
+// keyHash returns the hash a PQSmartAccount holding k stores
+func (k *testKey) keyHash() common.Hash {
+	return PQKeyHash(k.algo, k.pub)
+}
+
+// newOp returns an unsigned user operation from sender
+func newOp(sender common.Address, nonce int64) *UserOperation {
+	return &UserOperation{
+		Sender:               sender,
+		Nonce:                big.NewInt(nonce),
+		CallData:             []byte{0xde, 0xad, 0xbe, 0xef},
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(150000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         big.NewInt(20e9),
+		MaxPriorityFeePerGas: big.NewInt(1e9),
+	}
+}
+
+// signOp signs op with k for bundler b
+func signOp(t *testing.T, b *Bundler, op *UserOperation, k *testKey) *UserOperation {
+	t.Helper()
+
+	opHash, err := op.Hash(b.config.EntryPoint, b.config.ChainID)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	op.Signature, err = EncodePQSignature(k.algo, k.pub, k.scheme.Sign(k.priv, opHash.Bytes(), nil))
+	if err != nil {
+		t.Fatalf("EncodePQSignature: %v", err)
+	}
+	return op
+}
+
+// newSignedOp returns a user operation from sender signed with k
+func newSignedOp(t *testing.T, b *Bundler, sender common.Address, k *testKey) *UserOperation {
+	return signOp(t, b, newOp(sender, 0), k)
+}
+
+func TestValidateUserOperation(t *testing.T) {
+	key := newMLDSA44Key(t)
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	b := newTestBundler(t, staticAccounts{sender: key.keyHash()}, nil)
+
+	op := newSignedOp(t, b, sender, key)
+	if _, err := b.AddUserOperation(op); err != nil {
+		t.Fatalf("valid user operation rejected: %v", err)
+	}
+
+	// A well-formed but forged signature must not pass
+	forged := *op
+	forged.Nonce = big.NewInt(1)
+	if _, err := b.ValidateUserOperation(&forged); !errors.Is(err, ErrInvalidUserOpSignature) {
+		t.Fatalf("forged signature: got %v, want ErrInvalidUserOpSignature", err)
+	}
+
+	// Arbitrary bytes of plausible length must not pass either
+	junk, _ := EncodePQSignature(key.algo, key.pub, make([]byte, mldsa44.SignatureSize))
+	forged.Nonce = big.NewInt(0)
+	forged.Signature = junk
+	if _, err := b.ValidateUserOperation(&forged); !errors.Is(err, ErrInvalidUserOpSignature) {
+		t.Fatalf("junk signature: got %v, want ErrInvalidUserOpSignature", err)
+	}
+
+	// A valid signature by a key the account does not hold
+	if _, err := b.ValidateUserOperation(newSignedOp(t, b, sender, newMLDSA44Key(t))); !errors.Is(err, ErrUserOpKeyMismatch) {
+		t.Fatalf("foreign key: got %v, want ErrUserOpKeyMismatch", err)
+	}
+}
+
+func TestValidateUserOperationFollowsRegistry(t *testing.T) {
+	key := newMLDSA44Key(t)
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a2")
+
+	algos := tx.NewSigAlgoRegistry(config.DefaultChainConfig())
+	b := newTestBundler(t, nil, algos)
+	op := newSignedOp(t, b, sender, key)
+
+	if _, err := b.ValidateUserOperation(op); err != nil {
+		t.Fatalf("valid user operation rejected: %v", err)
+	}
+
+	if err := algos.SetEnabled(tx.SigAlgoDilithium2, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ValidateUserOperation(op); !errors.Is(err, ErrInvalidUserOpSignature) {
+		t.Fatalf("disabled algorithm: got %v, want ErrInvalidUserOpSignature", err)
+	}
+}
+
+func TestValidateUserOperationUsesSignatureAlgo(t *testing.T) {
+	key := newTestKey(t, tx.SigAlgoDilithium3, mldsa65.Scheme())
+	sender := common.HexToAddress("0x00000000000000000000000000000000000000a3")
+	b := newTestBundler(t, staticAccounts{sender: key.keyHash()}, nil)
+
+	op := newSignedOp(t, b, sender, key)
+	if _, err := b.ValidateUserOperation(op); err != nil {
+		t.Fatalf("ML-DSA-65 user operation rejected: %v", err)
+	}
+
+	// The key hash commits to the algorithm, so relabelling the same key
+	// and signature is a key mismatch
+	_, pub, sig, err := DecodePQSignature(op.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relabelled := *op
+	relabelled.Signature, _ = EncodePQSignature(tx.SigAlgoDilithium2, pub, sig)
+	if _, err := b.ValidateUserOperation(&relabelled); !errors.Is(err, ErrUserOpKeyMismatch) {
+		t.Fatalf("relabelled algorithm: got %v, want ErrUserOpKeyMismatch", err)
+	}
+
+	// Without an account reader the signature still has to verify under
+	// the algorithm it names
+	unchecked := newTestBundler(t, nil, nil)
+	if _, err := unchecked.ValidateUserOperation(&relabelled); !errors.Is(err, ErrInvalidUserOpSignature) {
+		t.Fatalf("relabelled algorithm without accounts: got %v, want ErrInvalidUserOpSignature", err)
+	}
+}
+
+func TestBundleGasDoesNotOverflow(t *testing.T) {
+	b := newTestBundler(t, nil, nil)
+	key := newMLDSA44Key(t)
+
+	// Gas limits summing past a uint64 cannot be bundled at all
+	op := newOp(common.HexToAddress("0x00000000000000000000000000000000000000b0"), 0)
+	op.CallGasLimit = new(big.Int).Lsh(big.NewInt(1), 64)
+	if _, err := b.ValidateUserOperation(signOp(t, b, op, key)); !errors.Is(err, ErrUserOpGasOverflow) {
+		t.Fatalf("gas above uint64: got %v, want %v", err, ErrUserOpGasOverflow)
+	}
+
+	// Two ops that fit alone but not together go in separate bundles
+	half := new(big.Int).Lsh(big.NewInt(1), 63)
+	for i := range 2 {
+		op := newOp(common.BigToAddress(big.NewInt(int64(0xb1+i))), 0)
+		op.CallGasLimit = half
+		if _, err := b.AddUserOperation(signOp(t, b, op, key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	executor, _ := crypto.GenerateKey()
+	for i := range 2 {
+		bundle, err := b.BuildBundle(executor, uint64(i), big.NewInt(1e9), big.NewInt(20e9))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bundle.Gas() < half.Uint64() {
+			t.Fatalf("bundle %d gas %d wrapped around", i, bundle.Gas())
+		}
+		if want := 1 - i; b.Pending() != want {
+			t.Fatalf("after bundle %d: %d pending, want %d", i, b.Pending(), want)
+		}
+	}
+}
+
+func TestBundlerForgetsIncludedOps(t *testing.T) {
+	b := newTestBundlerWithConfig(t, BundlerConfig{MaxKnownOps: 2}, nil)
+	key := newMLDSA44Key(t)
+	executor, _ := crypto.GenerateKey()
+
+	senders := []common.Address{{0xc1}, {0xc2}, {0xc3}}
+	ops := make([]*UserOperation, len(senders))
+	for i, sender := range senders {
+		ops[i] = newSignedOp(t, b, sender, key)
+	}
+
+	for _, op := range ops[:2] {
+		if _, err := b.AddUserOperation(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pending ops are never forgotten, so the cap rejects a third
+	if _, err := b.AddUserOperation(ops[2]); !errors.Is(err, ErrBundlerFull) {
+		t.Fatalf("third op with two pending: got %v, want %v", err, ErrBundlerFull)
+	}
+
+	bundle, err := b.BuildBundle(executor, 0, big.NewInt(1e9), big.NewInt(20e9))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bundled ops are still known until included
+	if _, err := b.AddUserOperation(ops[0]); !errors.Is(err, ErrUserOpKnown) {
+		t.Fatalf("resubmitted bundled op: got %v, want %v", err, ErrUserOpKnown)
+	}
+
+	// At the cap the oldest bundled op makes room for a new one
+	if _, err := b.AddUserOperation(ops[2]); err != nil {
+		t.Fatalf("third op after bundling: %v", err)
+	}
+	if len(b.known) != 2 || len(b.knownOrder) != 2 {
+		t.Fatalf("known %d, order %d, want 2", len(b.known), len(b.knownOrder))
+	}
+
+	if err := b.Included(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.known) != 1 || len(b.knownOrder) != 1 {
+		t.Fatalf("after inclusion: known %d, order %d, want 1", len(b.known), len(b.knownOrder))
+	}
+
+	// An included op may be submitted again; the EntryPoint rejects its
+	// reused nonce on-chain
+	if _, err := b.AddUserOperation(ops[1]); err != nil {
+		t.Fatalf("op resubmitted after inclusion: %v", err)
+	}
+
+	if err := b.Included(types.NewTx(&types.LegacyTx{Data: []byte{0x01}})); err == nil {
+		t.Fatal("Included accepted a non-handleOps transaction")
+	}
+}
+
+func TestEncodePQSignatureRoundTrip(t *testing.T) {
+	pub, sig := []byte{1, 2, 3}, []byte{4, 5}
+	enc, err := EncodePQSignature(tx.SigAlgoSLHDSASHAKE128s, pub, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	algo, gotPub, gotSig, err := DecodePQSignature(enc)
+	if err != nil || algo != tx.SigAlgoSLHDSASHAKE128s || string(gotPub) != string(pub) || string(gotSig) != string(sig) {
+		t.Fatalf("decoded (0x%02x, %x, %x, %v)", algo, gotPub, gotSig, err)
+	}
+
+	// The algorithm word must fit a uint8
+	enc[30] = 0x01
+	if _, _, _, err := DecodePQSignature(enc); err == nil {
+		t.Fatal("algorithm word above 255 decoded")
+	}
+
+	if _, _, _, err := DecodePQSignature(enc[:64]); err == nil {
+		t.Fatal("truncated signature decoded")
+	}
+}
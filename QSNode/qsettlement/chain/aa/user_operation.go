@@ -0,0 +1,142 @@
+package aa
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// UserOperation is an ERC-4337 (EntryPoint v0.6) user operation. For PQ
+// smart accounts, Signature is abi.encode(uint8 algo, bytes pubKey, bytes sig)
+// where algo is the PQSigAlgo ID of the account key.
+type UserOperation struct {
+	Sender               common.Address `json:"sender"`
+	Nonce                *big.Int       `json:"nonce"`
+	InitCode             []byte         `json:"initCode"`
+	CallData             []byte         `json:"callData"`
+	CallGasLimit         *big.Int       `json:"callGasLimit"`
+	VerificationGasLimit *big.Int       `json:"verificationGasLimit"`
+	PreVerificationGas   *big.Int       `json:"preVerificationGas"`
+	MaxFeePerGas         *big.Int       `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *big.Int       `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     []byte         `json:"paymasterAndData"`
+	Signature            []byte         `json:"signature"`
+}
+
+var (
+	addressType, _ = abi.NewType("address", "", nil)
+	uint8Type, _   = abi.NewType("uint8", "", nil)
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+	bytes32Type, _ = abi.NewType("bytes32", "", nil)
+	bytesType, _   = abi.NewType("bytes", "", nil)
+
+	// abi.encode layout of the v0.6 UserOperationLib.pack()
+	packedOpArgs = abi.Arguments{
+		{Type: addressType}, {Type: uint256Type}, {Type: bytes32Type}, {Type: bytes32Type},
+		{Type: uint256Type}, {Type: uint256Type}, {Type: uint256Type}, {Type: uint256Type},
+		{Type: uint256Type}, {Type: bytes32Type},
+	}
+
+	// abi.encode(keccak256(pack(op)), entryPoint, chainId)
+	opHashArgs = abi.Arguments{{Type: bytes32Type}, {Type: addressType}, {Type: uint256Type}}
+
+	// abi.encode(uint8 algo, bytes pubKey, bytes sig)
+	pqSignatureArgs = abi.Arguments{{Type: uint8Type}, {Type: bytesType}, {Type: bytesType}}
+)
+
+// validateFields checks that all numeric fields are present
+func (op *UserOperation) validateFields() error {
+	if op.Sender == (common.Address{}) {
+		return errors.New("user operation sender is empty")
+	}
+
+	for name, v := range map[string]*big.Int{
+		"nonce":                op.Nonce,
+		"callGasLimit":         op.CallGasLimit,
+		"verificationGasLimit": op.VerificationGasLimit,
+		"preVerificationGas":   op.PreVerificationGas,
+		"maxFeePerGas":         op.MaxFeePerGas,
+		"maxPriorityFeePerGas": op.MaxPriorityFeePerGas,
+	} {
+		if v == nil || v.Sign() < 0 {
+			return fmt.Errorf("user operation field %s is missing or negative", name)
+		}
+	}
+
+	if op.MaxPriorityFeePerGas.Cmp(op.MaxFeePerGas) > 0 {
+		return errors.New("maxPriorityFeePerGas exceeds maxFeePerGas")
+	}
+
+	return nil
+}
+
+// Hash computes the userOpHash the smart account signs, as computed by
+// EntryPoint.getUserOpHash: keccak256(abi.encode(keccak256(pack(op)), entryPoint, chainId))
+func (op *UserOperation) Hash(entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	packed, err := packedOpArgs.Pack(
+		op.Sender,
+		op.Nonce,
+		[32]byte(crypto.Keccak256Hash(op.InitCode)),
+		[32]byte(crypto.Keccak256Hash(op.CallData)),
+		op.CallGasLimit,
+		op.VerificationGasLimit,
+		op.PreVerificationGas,
+		op.MaxFeePerGas,
+		op.MaxPriorityFeePerGas,
+		[32]byte(crypto.Keccak256Hash(op.PaymasterAndData)),
+	)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to pack user operation: %w", err)
+	}
+
+	enc, err := opHashArgs.Pack([32]byte(crypto.Keccak256Hash(packed)), entryPoint, chainID)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to encode user operation hash: %w", err)
+	}
+
+	return crypto.Keccak256Hash(enc), nil
+}
+
+// PQKeyHash returns the key hash a PQSmartAccount stores for a key:
+// keccak256(abi.encodePacked(uint8 algo, bytes pubKey)). Committing to the
+// algorithm stops a signature being checked under another algorithm.
+func PQKeyHash(algo uint8, pubKey []byte) common.Hash {
+	return crypto.Keccak256Hash([]byte{algo}, pubKey)
+}
+
+// EncodePQSignature builds the UserOperation.signature for a PQ smart account
+func EncodePQSignature(algo uint8, pubKey, sig []byte) ([]byte, error) {
+	return pqSignatureArgs.Pack(algo, pubKey, sig)
+}
+
+// DecodePQSignature splits a UserOperation.signature into algorithm, public
+// key and signature
+func DecodePQSignature(signature []byte) (algo uint8, pubKey []byte, sig []byte, err error) {
+	values, err := pqSignatureArgs.Unpack(signature)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to decode PQ signature: %w", err)
+	}
+
+	algo, ok0 := values[0].(uint8)
+	pubKey, ok1 := values[1].([]byte)
+	sig, ok2 := values[2].([]byte)
+	if !ok0 || !ok1 || !ok2 {
+		return 0, nil, nil, errors.New("malformed PQ signature encoding")
+	}
+
+	return algo, pubKey, sig, nil
+}
+
+// TotalGas returns the gas the EntryPoint may spend on the operation
+func (op *UserOperation) TotalGas() uint64 {
+	total := new(big.Int).Add(op.CallGasLimit, op.VerificationGasLimit)
+	total.Add(total, op.PreVerificationGas)
+	if !total.IsUint64() {
+		return ^uint64(0)
+	}
+	return total.Uint64()
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"math/big"
+)
+
+// DefaultChainID is the EVM chain ID reported by eth_chainConfig
+const DefaultChainID = 9357
+
+// ChainConfig holds the chain-level switches for post-quantum features.
+// It mirrors the pqcrypto section of validators.yaml for the Go node.
+type ChainConfig struct {
+	ChainID *big.Int
+
+	// PQSigAlgos maps a PQSigAlgo ID (0x79 tx field) to its activation flag.
+	// IDs missing from the map are treated as disabled.
+	PQSigAlgos map[uint8]bool
+
+	// PQPrecompileBlock activates the PQ precompiles (0x0101-0x0107) in the
+	// EVM from this block on. nil means never active.
+	PQPrecompileBlock *big.Int
+
+	// UnsafeKyberDecEnabled registers the KyberDec precompile (0x0103), which
+	// takes a private key as calldata. Only for local devnets.
+	UnsafeKyberDecEnabled bool
+
+	// PrecompileGas overrides the PQ precompile gas schedule; nil uses
+	// DefaultPrecompileGas
+	PrecompileGas *PrecompileGas
+}
+
+// DefaultChainConfig returns the mainnet configuration: the Dilithium
+// (ML-DSA) levels and SLH-DSA are active, Falcon stays off until audited.
+// The PQ precompiles stay off until their gas schedule is calibrated on
+// reference hardware; networks opt in by setting PQPrecompileBlock.
+func DefaultChainConfig() *ChainConfig {
+	return &ChainConfig{
+		ChainID: big.NewInt(DefaultChainID),
+		PQSigAlgos: map[uint8]bool{
+			0x01: true,  // Dilithium2 / ML-DSA-44
+			0x02: true,  // Dilithium3 / ML-DSA-65
+			0x03: true,  // Dilithium5 / ML-DSA-87
+			0x04: false, // Falcon-512
+			0x05: false, // Falcon-1024
+			0x10: true,  // SLH-DSA-SHA2-128s
+			0x11: true,  // SLH-DSA-SHAKE-128s
+			0x20: true,  // M-of-N multisig over the above
+		},
+		PQPrecompileBlock:     nil,
+		UnsafeKyberDecEnabled: false,
+	}
+}
+
+// IsPQPrecompiles reports whether the PQ precompiles are active at block num
+func (c *ChainConfig) IsPQPrecompiles(num *big.Int) bool {
+	if c == nil || c.PQPrecompileBlock == nil || num == nil {
+		return false
+	}
+	return c.PQPrecompileBlock.Cmp(num) <= 0
+}
+
+// IsPQSigAlgoEnabled reports whether transactions may use the given PQSigAlgo
+func (c *ChainConfig) IsPQSigAlgoEnabled(id uint8) bool {
+	if c == nil || c.PQSigAlgos == nil {
+		return false
+	}
+	return c.PQSigAlgos[id]
+}
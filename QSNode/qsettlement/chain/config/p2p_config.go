@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// P2PConfig holds the peer-to-peer networking settings.
+// It mirrors the p2p section of validators.yaml for the Go node; see
+// LoadP2PConfig.
+type P2PConfig struct {
+	// ListenAddress is where inbound peers connect, e.g. "tcp://0.0.0.0:26656"
+	ListenAddress string `yaml:"listen_address"`
+
+	// ExternalAddress is the address advertised to peers; empty means the
+	// listen address
+	ExternalAddress string `yaml:"external_address"`
+
+	// PersistentPeers and Seeds are comma-separated "nodeID@host:port" lists.
+	// Persistent peers are always redialed; seeds are only used to find peers.
+	PersistentPeers string `yaml:"persistent_peers"`
+	Seeds           string `yaml:"seeds"`
+
+	// NodeKeyFile holds the Dilithium node key; the node ID is derived from it
+	NodeKeyFile string `yaml:"node_key_file"`
+
+	// PexReactor enables peer exchange; AddrBookFile persists learned
+	// addresses. In SeedMode the node only crawls the network and hands out
+	// addresses, disconnecting peers once they have been served.
+	PexReactor   bool   `yaml:"pex"`
+	AddrBookFile string `yaml:"addr_book_file"`
+	SeedMode     bool   `yaml:"seed_mode"`
+
+	MaxNumInboundPeers  int `yaml:"max_num_inbound_peers"`
+	MaxNumOutboundPeers int `yaml:"max_num_outbound_peers"`
+
+	// HandshakeTimeout bounds the PQ handshake, DialTimeout the TCP connect
+	HandshakeTimeout time.Duration `yaml:"handshake_timeout"`
+	DialTimeout      time.Duration `yaml:"dial_timeout"`
+
+	// SendRate and RecvRate limit each peer connection in bytes per second.
+	// Messages are sent in packets of MaxPacketMsgPayloadSize bytes.
+	SendRate                int64 `yaml:"send_rate"`
+	RecvRate                int64 `yaml:"recv_rate"`
+	MaxPacketMsgPayloadSize int   `yaml:"max_packet_msg_payload_size"`
+
+	// PingInterval is how often peers are pinged; a peer that does not
+	// answer within PongTimeout is disconnected
+	PingInterval time.Duration `yaml:"ping_interval"`
+	PongTimeout  time.Duration `yaml:"pong_timeout"`
+}
+
+// DefaultP2PConfig returns the settings shipped in validators.yaml
+func DefaultP2PConfig() *P2PConfig {
+	return &P2PConfig{
+		ListenAddress:       "tcp://0.0.0.0:26656",
+		NodeKeyFile:         "config/node_key.json",
+		PexReactor:          true,
+		AddrBookFile:        "data/addrbook.json",
+		MaxNumInboundPeers:  40,
+		MaxNumOutboundPeers: 10,
+		HandshakeTimeout:    20 * time.Second,
+		DialTimeout:         3 * time.Second,
+
+		SendRate:                5120000,
+		RecvRate:                5120000,
+		MaxPacketMsgPayloadSize: 1024,
+
+		PingInterval: 60 * time.Second,
+		PongTimeout:  45 * time.Second,
+	}
+}
+
+// LoadP2PConfig reads the p2p section of a validators.yaml file. Keys the
+// file leaves out keep their DefaultP2PConfig values.
+func LoadP2PConfig(path string) (*P2PConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	file := struct {
+		P2P *P2PConfig `yaml:"p2p"`
+	}{P2P: DefaultP2PConfig()}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return file.P2P, nil
+}
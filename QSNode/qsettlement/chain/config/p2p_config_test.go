@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadP2PConfigMatchesDefaults(t *testing.T) {
+	cfg, err := LoadP2PConfig("validators.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DefaultP2PConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("validators.yaml p2p section\n got %+v\nwant %+v", cfg, want)
+	}
+}
+
+func TestLoadP2PConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "validators.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`
+network:
+  name: "devnet"
+p2p:
+  seeds: "abc@10.0.0.1:26656"
+  seed_mode: true
+  handshake_timeout: "5s"
+  max_num_inbound_peers: 8
+`)
+	cfg, err := LoadP2PConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DefaultP2PConfig()
+	want.Seeds = "abc@10.0.0.1:26656"
+	want.SeedMode = true
+	want.HandshakeTimeout = 5 * time.Second
+	want.MaxNumInboundPeers = 8
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v\nwant %+v", cfg, want)
+	}
+
+	write("p2p:\n  dial_timeout: \"soon\"\n")
+	if _, err := LoadP2PConfig(path); err == nil {
+		t.Fatal("invalid duration accepted")
+	}
+
+	if _, err := LoadP2PConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("missing file accepted")
+	}
+}
@@ -1,14 +1,13 @@
 package consensus
 
 import (
-	"crypto/sha3"
 	"errors"
 	"fmt"
 	"log"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/sha3"
 
 	// Import Dilithium from QSmart (path adjusted based on actual module layout)
 	// "github.com/mchawda/qsmart/pqcrypto/dilithium"
@@ -0,0 +1,583 @@
+// Code generated by pqgen from evm.PQLibrarySpec. DO NOT EDIT.
+
+// Package bindings provides Go callers for the PQ precompiles that use the
+// same input and output layouts as the generated PQ Solidity library.
+package bindings
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PQABI is the ABI of the PQ Solidity library
+const PQABI = `[
+  {
+    "inputs": [
+      {
+        "internalType": "uint8",
+        "name": "algo",
+        "type": "uint8"
+      },
+      {
+        "internalType": "bytes",
+        "name": "pubKey",
+        "type": "bytes"
+      },
+      {
+        "internalType": "bytes32",
+        "name": "msgHash",
+        "type": "bytes32"
+      },
+      {
+        "internalType": "bytes",
+        "name": "signature",
+        "type": "bytes"
+      }
+    ],
+    "name": "verifyWithAlgo",
+    "outputs": [
+      {
+        "internalType": "bool",
+        "name": "valid",
+        "type": "bool"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  },
+  {
+    "inputs": [
+      {
+        "internalType": "bytes",
+        "name": "pubKey",
+        "type": "bytes"
+      },
+      {
+        "internalType": "bytes32",
+        "name": "seed",
+        "type": "bytes32"
+      }
+    ],
+    "name": "encapsulateRevealed",
+    "outputs": [
+      {
+        "internalType": "bytes",
+        "name": "ciphertext",
+        "type": "bytes"
+      },
+      {
+        "internalType": "bytes",
+        "name": "sharedSecret",
+        "type": "bytes"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  },
+  {
+    "inputs": [
+      {
+        "internalType": "uint8",
+        "name": "algo",
+        "type": "uint8"
+      },
+      {
+        "internalType": "bytes[]",
+        "name": "pubKeys",
+        "type": "bytes[]"
+      },
+      {
+        "internalType": "bytes32[]",
+        "name": "msgHashes",
+        "type": "bytes32[]"
+      },
+      {
+        "internalType": "bytes[]",
+        "name": "signatures",
+        "type": "bytes[]"
+      }
+    ],
+    "name": "verifyBatch",
+    "outputs": [
+      {
+        "internalType": "bool",
+        "name": "allValid",
+        "type": "bool"
+      },
+      {
+        "internalType": "uint256",
+        "name": "bitmap",
+        "type": "uint256"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  },
+  {
+    "inputs": [
+      {
+        "internalType": "bytes",
+        "name": "data",
+        "type": "bytes"
+      },
+      {
+        "internalType": "uint256",
+        "name": "outLen",
+        "type": "uint256"
+      }
+    ],
+    "name": "shake128",
+    "outputs": [
+      {
+        "internalType": "bytes",
+        "name": "digest",
+        "type": "bytes"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  },
+  {
+    "inputs": [
+      {
+        "internalType": "bytes",
+        "name": "data",
+        "type": "bytes"
+      },
+      {
+        "internalType": "uint256",
+        "name": "outLen",
+        "type": "uint256"
+      }
+    ],
+    "name": "shake256",
+    "outputs": [
+      {
+        "internalType": "bytes",
+        "name": "digest",
+        "type": "bytes"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  },
+  {
+    "inputs": [
+      {
+        "internalType": "uint8",
+        "name": "paramSet",
+        "type": "uint8"
+      },
+      {
+        "internalType": "bytes",
+        "name": "pubKey",
+        "type": "bytes"
+      },
+      {
+        "internalType": "bytes32",
+        "name": "msgHash",
+        "type": "bytes32"
+      },
+      {
+        "internalType": "bytes",
+        "name": "signature",
+        "type": "bytes"
+      }
+    ],
+    "name": "verifySlhDsa",
+    "outputs": [
+      {
+        "internalType": "bool",
+        "name": "valid",
+        "type": "bool"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  },
+  {
+    "inputs": [
+      {
+        "internalType": "bytes",
+        "name": "pubKey",
+        "type": "bytes"
+      },
+      {
+        "internalType": "bytes32",
+        "name": "msgHash",
+        "type": "bytes32"
+      },
+      {
+        "internalType": "bytes",
+        "name": "signature",
+        "type": "bytes"
+      }
+    ],
+    "name": "verify",
+    "outputs": [
+      {
+        "internalType": "bool",
+        "name": "valid",
+        "type": "bool"
+      }
+    ],
+    "stateMutability": "view",
+    "type": "function"
+  }
+]`
+
+// Library constants
+const (
+	PqVerifyModeAbi           = 0x01 // PqVerify input mode for abi.encode(bytes, bytes, bytes) bodies
+	AlgoDilithium2      uint8 = 0x01 // ML-DSA-44
+	AlgoDilithium3      uint8 = 0x02 // ML-DSA-65
+	AlgoDilithium5      uint8 = 0x03 // ML-DSA-87
+	AlgoSlhdsaSha2128s  uint8 = 0x10 // SLH-DSA-SHA2-128s
+	AlgoSlhdsaShake128s uint8 = 0x11 // SLH-DSA-SHAKE-128s
+)
+
+// Precompile addresses
+var (
+	PqVerifyAddress         = common.HexToAddress("0x0000000000000000000000000000000000000101")
+	KyberEncRevealedAddress = common.HexToAddress("0x0000000000000000000000000000000000000102")
+	PqBatchVerifyAddress    = common.HexToAddress("0x0000000000000000000000000000000000000104")
+	Shake128Address         = common.HexToAddress("0x0000000000000000000000000000000000000105")
+	Shake256Address         = common.HexToAddress("0x0000000000000000000000000000000000000106")
+	SlhDsaVerifyAddress     = common.HexToAddress("0x0000000000000000000000000000000000000107")
+)
+
+// PQCaller calls the PQ precompiles through an eth_call backend
+type PQCaller struct {
+	contract bind.ContractCaller
+}
+
+// NewPQCaller creates a caller bound to backend
+func NewPQCaller(backend bind.ContractCaller) *PQCaller {
+	return &PQCaller{contract: backend}
+}
+
+// EncodeVerifyWithAlgoInput builds the PQ_VERIFY precompile input
+func EncodeVerifyWithAlgoInput(algo uint8, pubKey []byte, msgHash [32]byte, signature []byte) ([]byte, error) {
+	var input []byte
+	input = append(input, headerWord(0x01, algo)...)
+
+	body, err := bytesArgs(3).Pack(pubKey, msgHash[:], signature)
+	if err != nil {
+		return nil, err
+	}
+	input = append(input, body...)
+
+	return input, nil
+}
+
+// DecodeVerifyWithAlgoOutput parses the PQ_VERIFY precompile output
+func DecodeVerifyWithAlgoOutput(output []byte) (valid bool, err error) {
+	offset := 0
+	var w []byte
+	if w, offset, err = readWord(output, offset); err != nil {
+		return
+	}
+	valid = new(big.Int).SetBytes(w).Cmp(common.Big1) == 0
+
+	return
+}
+
+// VerifyWithAlgo calls the PQ_VERIFY precompile at 0x0000000000000000000000000000000000000101
+func (c *PQCaller) VerifyWithAlgo(opts *bind.CallOpts, algo uint8, pubKey []byte, msgHash [32]byte, signature []byte) (bool, error) {
+	input, err := EncodeVerifyWithAlgoInput(algo, pubKey, msgHash, signature)
+	if err != nil {
+		return false, err
+	}
+
+	output, err := c.call(opts, PqVerifyAddress, input)
+	if err != nil {
+		return false, err
+	}
+
+	return DecodeVerifyWithAlgoOutput(output)
+}
+
+// EncodeEncapsulateRevealedInput builds the KYBER_ENC_REVEALED precompile input
+func EncodeEncapsulateRevealedInput(pubKey []byte, seed [32]byte) ([]byte, error) {
+	var input []byte
+	input = append(input, lengthPrefixed(pubKey)...)
+	input = append(input, seed[:]...)
+
+	return input, nil
+}
+
+// DecodeEncapsulateRevealedOutput parses the KYBER_ENC_REVEALED precompile output
+func DecodeEncapsulateRevealedOutput(output []byte) (ciphertext []byte, sharedSecret []byte, err error) {
+	offset := 0
+	if ciphertext, offset, err = readBytes(output, offset); err != nil {
+		return
+	}
+	if sharedSecret, offset, err = readBytes(output, offset); err != nil {
+		return
+	}
+
+	return
+}
+
+// EncapsulateRevealed calls the KYBER_ENC_REVEALED precompile at 0x0000000000000000000000000000000000000102
+func (c *PQCaller) EncapsulateRevealed(opts *bind.CallOpts, pubKey []byte, seed [32]byte) ([]byte, []byte, error) {
+	input, err := EncodeEncapsulateRevealedInput(pubKey, seed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := c.call(opts, KyberEncRevealedAddress, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return DecodeEncapsulateRevealedOutput(output)
+}
+
+// EncodeVerifyBatchInput builds the PQ_BATCH_VERIFY precompile input
+func EncodeVerifyBatchInput(algo uint8, pubKeys [][]byte, msgHashes [][32]byte, signatures [][]byte) ([]byte, error) {
+	if len(msgHashes) != len(pubKeys) || len(signatures) != len(pubKeys) {
+		return nil, ErrBatchLengthMismatch
+	}
+
+	var input []byte
+	input = append(input, word(uint64(algo))...)
+
+	input = append(input, word(uint64(len(pubKeys)))...)
+	for i := range pubKeys {
+		input = append(input, lengthPrefixed(pubKeys[i])...)
+		input = append(input, lengthPrefixed(msgHashes[i][:])...)
+		input = append(input, lengthPrefixed(signatures[i])...)
+	}
+
+	return input, nil
+}
+
+// DecodeVerifyBatchOutput parses the PQ_BATCH_VERIFY precompile output
+func DecodeVerifyBatchOutput(output []byte) (allValid bool, bitmap *big.Int, err error) {
+	offset := 0
+	var w []byte
+	if w, offset, err = readWord(output, offset); err != nil {
+		return
+	}
+	allValid = new(big.Int).SetBytes(w).Cmp(common.Big1) == 0
+	if w, offset, err = readWord(output, offset); err != nil {
+		return
+	}
+	bitmap = new(big.Int).SetBytes(w)
+
+	return
+}
+
+// VerifyBatch calls the PQ_BATCH_VERIFY precompile at 0x0000000000000000000000000000000000000104
+func (c *PQCaller) VerifyBatch(opts *bind.CallOpts, algo uint8, pubKeys [][]byte, msgHashes [][32]byte, signatures [][]byte) (bool, *big.Int, error) {
+	input, err := EncodeVerifyBatchInput(algo, pubKeys, msgHashes, signatures)
+	if err != nil {
+		return false, nil, err
+	}
+
+	output, err := c.call(opts, PqBatchVerifyAddress, input)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return DecodeVerifyBatchOutput(output)
+}
+
+// EncodeShake128Input builds the SHAKE128 precompile input
+func EncodeShake128Input(data []byte, outLen *big.Int) ([]byte, error) {
+	var input []byte
+	input = append(input, bigWord(outLen)...)
+	input = append(input, data...)
+
+	return input, nil
+}
+
+// DecodeShake128Output parses the SHAKE128 precompile output
+func DecodeShake128Output(output []byte) (digest []byte, err error) {
+	offset := 0
+	digest = output[offset:]
+
+	return
+}
+
+// Shake128 calls the SHAKE128 precompile at 0x0000000000000000000000000000000000000105
+func (c *PQCaller) Shake128(opts *bind.CallOpts, data []byte, outLen *big.Int) ([]byte, error) {
+	input, err := EncodeShake128Input(data, outLen)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := c.call(opts, Shake128Address, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeShake128Output(output)
+}
+
+// EncodeShake256Input builds the SHAKE256 precompile input
+func EncodeShake256Input(data []byte, outLen *big.Int) ([]byte, error) {
+	var input []byte
+	input = append(input, bigWord(outLen)...)
+	input = append(input, data...)
+
+	return input, nil
+}
+
+// DecodeShake256Output parses the SHAKE256 precompile output
+func DecodeShake256Output(output []byte) (digest []byte, err error) {
+	offset := 0
+	digest = output[offset:]
+
+	return
+}
+
+// Shake256 calls the SHAKE256 precompile at 0x0000000000000000000000000000000000000106
+func (c *PQCaller) Shake256(opts *bind.CallOpts, data []byte, outLen *big.Int) ([]byte, error) {
+	input, err := EncodeShake256Input(data, outLen)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := c.call(opts, Shake256Address, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeShake256Output(output)
+}
+
+// EncodeVerifySlhDsaInput builds the SLH_DSA_VERIFY precompile input
+func EncodeVerifySlhDsaInput(paramSet uint8, pubKey []byte, msgHash [32]byte, signature []byte) ([]byte, error) {
+	var input []byte
+	input = append(input, headerWord(0x01, paramSet)...)
+
+	body, err := bytesArgs(3).Pack(pubKey, msgHash[:], signature)
+	if err != nil {
+		return nil, err
+	}
+	input = append(input, body...)
+
+	return input, nil
+}
+
+// DecodeVerifySlhDsaOutput parses the SLH_DSA_VERIFY precompile output
+func DecodeVerifySlhDsaOutput(output []byte) (valid bool, err error) {
+	offset := 0
+	var w []byte
+	if w, offset, err = readWord(output, offset); err != nil {
+		return
+	}
+	valid = new(big.Int).SetBytes(w).Cmp(common.Big1) == 0
+
+	return
+}
+
+// VerifySlhDsa calls the SLH_DSA_VERIFY precompile at 0x0000000000000000000000000000000000000107
+func (c *PQCaller) VerifySlhDsa(opts *bind.CallOpts, paramSet uint8, pubKey []byte, msgHash [32]byte, signature []byte) (bool, error) {
+	input, err := EncodeVerifySlhDsaInput(paramSet, pubKey, msgHash, signature)
+	if err != nil {
+		return false, err
+	}
+
+	output, err := c.call(opts, SlhDsaVerifyAddress, input)
+	if err != nil {
+		return false, err
+	}
+
+	return DecodeVerifySlhDsaOutput(output)
+}
+
+// Verify a Dilithium2 (ML-DSA-44) signature
+func (c *PQCaller) Verify(opts *bind.CallOpts, pubKey []byte, msgHash [32]byte, signature []byte) (bool, error) {
+	return c.VerifyWithAlgo(opts, AlgoDilithium2, pubKey, msgHash, signature)
+}
+
+// ErrBatchLengthMismatch is returned when parallel batch arrays differ in length
+var ErrBatchLengthMismatch = errors.New("batch length mismatch")
+
+// ErrOutputTooShort is returned when precompile output is truncated
+var ErrOutputTooShort = errors.New("precompile output too short")
+
+var bytesType, _ = abi.NewType("bytes", "", nil)
+
+// bytesArgs returns an abi.Arguments of n bytes values
+func bytesArgs(n int) abi.Arguments {
+	args := make(abi.Arguments, n)
+	for i := range args {
+		args[i] = abi.Argument{Type: bytesType}
+	}
+	return args
+}
+
+// word encodes v as a 32-byte big-endian word
+func word(v uint64) []byte {
+	return common.LeftPadBytes(new(big.Int).SetUint64(v).Bytes(), 32)
+}
+
+// bigWord encodes v (nil = 0) as a 32-byte big-endian word
+func bigWord(v *big.Int) []byte {
+	if v == nil {
+		return make([]byte, 32)
+	}
+	return common.LeftPadBytes(v.Bytes(), 32)
+}
+
+// headerWord encodes mode << 8 | value
+func headerWord(mode uint8, value uint8) []byte {
+	w := make([]byte, 32)
+	w[30] = mode
+	w[31] = value
+	return w
+}
+
+// lengthPrefixed encodes data as [len(32)][data]
+func lengthPrefixed(data []byte) []byte {
+	return append(word(uint64(len(data))), data...)
+}
+
+// readWord reads the 32-byte word at offset
+func readWord(data []byte, offset int) ([]byte, int, error) {
+	if offset > len(data) || len(data)-offset < 32 {
+		return nil, 0, ErrOutputTooShort
+	}
+	return data[offset : offset+32], offset + 32, nil
+}
+
+// readBytes reads a [len(32)][data] field at offset
+func readBytes(data []byte, offset int) ([]byte, int, error) {
+	w, offset, err := readWord(data, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n := new(big.Int).SetBytes(w)
+	if !n.IsUint64() || n.Uint64() > uint64(len(data)-offset) {
+		return nil, 0, ErrOutputTooShort
+	}
+
+	end := offset + int(n.Uint64())
+	return data[offset:end], end, nil
+}
+
+// call executes an eth_call against a precompile
+func (c *PQCaller) call(opts *bind.CallOpts, addr common.Address, input []byte) ([]byte, error) {
+	if opts == nil {
+		opts = new(bind.CallOpts)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	msg := ethereum.CallMsg{From: opts.From, To: &addr, Data: input}
+	return c.contract.CallContract(ctx, msg, opts.BlockNumber)
+}
@@ -0,0 +1,304 @@
+// Command gasbench measures the PQ precompiles against the ecrecover baseline
+// and prints a calibrated gas schedule for config.PrecompileGas.
+//
+// Gas is priced by wall-clock cost relative to ecrecover (0x01, 3000 gas):
+//
+//	gas = ns/op * baselineGas / ecrecover ns/op
+//
+// Run it on reference validator hardware with no other load and record the
+// output in RESULTS.md:
+//
+//	go run ./chain/evm/gasbench -out gas.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"runtime"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/cloudflare/circl/sign/slhdsa"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+const (
+	// batchSize is the PqBatchVerify size used to check the batch curve
+	batchSize = 16
+	// longMsgLen is the message size used to price per-word charges
+	longMsgLen = 4096
+)
+
+// sigAlgos are the PqVerify algorithms with a linked-in implementation
+var sigAlgos = []struct {
+	id     uint8
+	scheme sign.Scheme
+}{
+	{tx.SigAlgoDilithium2, mldsa44.Scheme()},
+	{tx.SigAlgoDilithium3, mldsa65.Scheme()},
+	{tx.SigAlgoDilithium5, mldsa87.Scheme()},
+	{tx.SigAlgoSLHDSASHA2128s, slhdsa.SHA2_128s.Scheme()},
+	{tx.SigAlgoSLHDSASHAKE128s, slhdsa.SHAKE_128s.Scheme()},
+}
+
+// row is one line of the report
+type row struct {
+	name       string
+	nsPerOp    float64
+	currentGas uint64
+	newGas     uint64
+}
+
+// measure checks that run succeeds and returns its cost in ns/op
+func measure(run func() ([]byte, error)) float64 {
+	if _, err := run(); err != nil {
+		log.Fatalf("[GasBench] Benchmark input rejected: %v\n", err)
+	}
+
+	result := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			run()
+		}
+	})
+	return float64(result.T.Nanoseconds()) / float64(result.N)
+}
+
+// word encodes n as a 32-byte big-endian word
+func word(n int) []byte {
+	return common.LeftPadBytes(big.NewInt(int64(n)).Bytes(), 32)
+}
+
+// roundUp rounds v up to a multiple of step
+func roundUp(v float64, step uint64) uint64 {
+	return uint64(math.Ceil(v/float64(step))) * step
+}
+
+// pqVerifyInput signs msg with a fresh key of the given scheme
+func pqVerifyInput(algo uint8, scheme sign.Scheme, msg []byte) []byte {
+	pk, sk, err := scheme.GenerateKey()
+	if err != nil {
+		log.Fatalf("[GasBench] Key generation failed: %v\n", err)
+	}
+
+	pkBytes, err := pk.MarshalBinary()
+	if err != nil {
+		log.Fatalf("[GasBench] Failed to encode public key: %v\n", err)
+	}
+
+	return evm.EncodePqVerifyInput(algo, pkBytes, msg, scheme.Sign(sk, msg, nil))
+}
+
+// pqBatchInput builds a PqBatchVerify input of n Dilithium2 signatures
+func pqBatchInput(n int) []byte {
+	scheme := mldsa44.Scheme()
+	msg := make([]byte, 32)
+
+	input := append(word(int(tx.SigAlgoDilithium2)), word(n)...)
+	for i := 0; i < n; i++ {
+		single := pqVerifyInput(tx.SigAlgoDilithium2, scheme, msg)
+		input = append(input, single[32:]...) // drop the header word
+	}
+	return input
+}
+
+// ecrecoverInput builds a valid ecrecover input: hash, v, r, s
+func ecrecoverInput() []byte {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		log.Fatalf("[GasBench] secp256k1 key generation failed: %v\n", err)
+	}
+
+	hash := crypto.Keccak256([]byte("gasbench"))
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		log.Fatalf("[GasBench] secp256k1 signing failed: %v\n", err)
+	}
+
+	input := make([]byte, 128)
+	copy(input[0:32], hash)
+	input[63] = sig[64] + 27
+	copy(input[64:128], sig[:64])
+	return input
+}
+
+// shakeInput builds a [out_len][data] SHAKE input
+func shakeInput(outLen int, dataLen int) []byte {
+	return append(word(outLen), make([]byte, dataLen)...)
+}
+
+func main() {
+	var (
+		baselineGas = flag.Uint64("baseline-gas", 3000, "gas charged by ecrecover")
+		out         = flag.String("out", "", "write the calibrated schedule as JSON to this file")
+	)
+	flag.Parse()
+
+	current := config.DefaultPrecompileGas()
+	calibrated := config.DefaultPrecompileGas()
+	var rows []row
+
+	// Measure on one core like ecrecover; only the batch curve check below
+	// runs with all cores
+	prevProcs := runtime.GOMAXPROCS(1)
+
+	ecrecover := vm.PrecompiledContractsBerlin[common.BytesToAddress([]byte{0x01})]
+	ecInput := ecrecoverInput()
+	ecNs := measure(func() ([]byte, error) { return ecrecover.Run(ecInput) })
+	gasPerNs := float64(*baselineGas) / ecNs
+	rows = append(rows, row{"ecrecover (baseline)", ecNs, *baselineGas, *baselineGas})
+
+	log.Printf("[GasBench] ecrecover: %.0f ns/op -> %.4f gas/ns (target %.1f Mgas/s)\n", ecNs, gasPerNs, gasPerNs*1e3)
+
+	pqVerify := &evm.PqVerifyPrecompile{}
+
+	// Per message word: slope between a 32-byte and a longMsgLen message
+	shortInput := pqVerifyInput(tx.SigAlgoDilithium2, mldsa44.Scheme(), make([]byte, 32))
+	longInput := pqVerifyInput(tx.SigAlgoDilithium2, mldsa44.Scheme(), make([]byte, longMsgLen))
+	shortNs := measure(func() ([]byte, error) { return pqVerify.Run(shortInput) })
+	longNs := measure(func() ([]byte, error) { return pqVerify.Run(longInput) })
+	perWordNs := math.Max(longNs-shortNs, 0) / float64(longMsgLen/32-1)
+	calibrated.PqVerifyPerMsgWord = roundUp(perWordNs*gasPerNs, 1)
+	rows = append(rows, row{"PqVerify per message word", perWordNs, current.PqVerifyPerMsgWord, calibrated.PqVerifyPerMsgWord})
+
+	// Per algorithm base, measured with a one-word message
+	var singleD2Ns float64
+	for _, algo := range sigAlgos {
+		input := pqVerifyInput(algo.id, algo.scheme, make([]byte, 32))
+		ns := measure(func() ([]byte, error) { return pqVerify.Run(input) })
+		if algo.id == tx.SigAlgoDilithium2 {
+			singleD2Ns = ns
+		}
+
+		gas := roundUp(ns*gasPerNs, 10)
+		if gas > calibrated.PqVerifyPerMsgWord {
+			gas -= calibrated.PqVerifyPerMsgWord
+		}
+		calibrated.PqVerifyAlgo[algo.id] = gas
+
+		info, _ := tx.SigAlgos().Lookup(algo.id)
+		name := fmt.Sprintf("PqVerify 0x%02x", algo.id)
+		if info != nil {
+			name += " " + info.Name
+		}
+		rows = append(rows, row{name, ns, current.PqVerifyAlgo[algo.id], gas})
+	}
+	calibrated.PqVerifyFallback = calibrated.PqVerifyAlgo[tx.SigAlgoDilithium2]
+
+	// Batch: base is the overhead of a one-item batch. The per-signature
+	// curve is fixed by the precompile; a full batch is measured with all
+	// cores (it verifies in parallel) to check the curve covers its time.
+	pqBatch := &evm.PqBatchVerifyPrecompile{}
+	oneInput := pqBatchInput(1)
+	oneNs := measure(func() ([]byte, error) { return pqBatch.Run(oneInput) })
+	calibrated.PqBatchBase = roundUp(math.Max(oneNs-singleD2Ns, 0)*gasPerNs, 10)
+	rows = append(rows, row{"PqBatchVerify base", oneNs - singleD2Ns, current.PqBatchBase, calibrated.PqBatchBase})
+
+	runtime.GOMAXPROCS(prevProcs)
+	batchInput := pqBatchInput(batchSize)
+	batchNs := measure(func() ([]byte, error) { return pqBatch.Run(batchInput) })
+	runtime.GOMAXPROCS(1)
+
+	batchGas := pqBatch.RequiredGas(batchInput)
+	rows = append(rows, row{fmt.Sprintf("PqBatchVerify x%d (measured)", batchSize), batchNs, batchGas, roundUp(batchNs*gasPerNs, 10)})
+	if float64(batchGas) < batchNs*gasPerNs {
+		log.Printf("[GasBench] WARNING: PqBatchVerify x%d is priced below its measured time; the gas curve assumes 4 free cores\n", batchSize)
+	}
+
+	// Kyber768: flat
+	kemScheme := mlkem768.Scheme()
+	kemPk, kemSk, err := kemScheme.GenerateKeyPair()
+	if err != nil {
+		log.Fatalf("[GasBench] ML-KEM key generation failed: %v\n", err)
+	}
+	kemPkBytes, _ := kemPk.MarshalBinary()
+	kemSkBytes, _ := kemSk.MarshalBinary()
+
+	encInput := append(word(len(kemPkBytes)), kemPkBytes...)
+	encInput = append(encInput, make([]byte, evm.KyberSeedSize)...)
+	kyberEnc := &evm.KyberEncRevealedPrecompile{}
+	encNs := measure(func() ([]byte, error) { return kyberEnc.Run(encInput) })
+	calibrated.KyberEnc = roundUp(encNs*gasPerNs, 10)
+	rows = append(rows, row{"KyberEnc", encNs, current.KyberEnc, calibrated.KyberEnc})
+
+	encOutput, err := kyberEnc.Run(encInput)
+	if err != nil {
+		log.Fatalf("[GasBench] KyberEnc failed: %v\n", err)
+	}
+	ciphertext, _, err := evm.ParseKyberEncRevealedOutput(encOutput)
+	if err != nil {
+		log.Fatalf("[GasBench] KyberEnc output invalid: %v\n", err)
+	}
+
+	decInput := append(word(len(kemSkBytes)), kemSkBytes...)
+	decInput = append(decInput, word(len(ciphertext))...)
+	decInput = append(decInput, ciphertext...)
+	kyberDec := &evm.KyberDecPrecompile{}
+	decNs := measure(func() ([]byte, error) { return kyberDec.Run(decInput) })
+	calibrated.KyberDec = roundUp(decNs*gasPerNs, 10)
+	rows = append(rows, row{"KyberDec", decNs, current.KyberDec, calibrated.KyberDec})
+
+	// SHAKE: base from a one-word output over empty input, per word from a long input
+	for _, shake := range []struct {
+		name    string
+		run     func([]byte) ([]byte, error)
+		current uint64
+		perWord *uint64
+	}{
+		{"Shake128", (&evm.Shake128Precompile{}).Run, current.Shake128PerWord, &calibrated.Shake128PerWord},
+		{"Shake256", (&evm.Shake256Precompile{}).Run, current.Shake256PerWord, &calibrated.Shake256PerWord},
+	} {
+		small := shakeInput(32, 0)
+		large := shakeInput(32, longMsgLen)
+		smallNs := measure(func() ([]byte, error) { return shake.run(small) })
+		largeNs := measure(func() ([]byte, error) { return shake.run(large) })
+
+		wordNs := math.Max(largeNs-smallNs, 0) / float64(longMsgLen/32)
+		*shake.perWord = roundUp(wordNs*gasPerNs, 1)
+		rows = append(rows, row{shake.name + " per word", wordNs, shake.current, *shake.perWord})
+
+		if shake.name == "Shake128" {
+			calibrated.ShakeBase = roundUp(smallNs*gasPerNs, 10)
+			rows = append(rows, row{"Shake base", smallNs, current.ShakeBase, calibrated.ShakeBase})
+		}
+	}
+
+	runtime.GOMAXPROCS(prevProcs)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "precompile\tns/op\tcurrent gas\tcalibrated gas\t")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%.0f\t%d\t%d\t\n", r.name, r.nsPerOp, r.currentGas, r.newGas)
+	}
+	w.Flush()
+
+	schedule, err := json.MarshalIndent(calibrated, "", "  ")
+	if err != nil {
+		log.Fatalf("[GasBench] Failed to encode schedule: %v\n", err)
+	}
+
+	if *out == "" {
+		fmt.Println(string(schedule))
+		return
+	}
+
+	if err := os.WriteFile(*out, append(schedule, '\n'), 0o644); err != nil {
+		log.Fatalf("[GasBench] Failed to write %s: %v\n", *out, err)
+	}
+	log.Printf("[GasBench] Wrote calibrated schedule to %s\n", *out)
+}
@@ -0,0 +1,91 @@
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// The PQ precompiles satisfy go-ethereum's PrecompiledContract interface
+// directly, so they can be installed next to the standard set.
+var (
+	_ vm.PrecompiledContract = (*PqVerifyPrecompile)(nil)
+	_ vm.PrecompiledContract = (*KyberEncRevealedPrecompile)(nil)
+	_ vm.PrecompiledContract = (*KyberDecPrecompile)(nil)
+	_ vm.PrecompiledContract = (*PqBatchVerifyPrecompile)(nil)
+	_ vm.PrecompiledContract = (*Shake128Precompile)(nil)
+	_ vm.PrecompiledContract = (*Shake256Precompile)(nil)
+	_ vm.PrecompiledContract = (*SlhDsaVerifyPrecompile)(nil)
+)
+
+// EVMFactory builds go-ethereum EVM instances with the standard Ethereum
+// precompiles for the block's fork rules plus the PQ set once the chain
+// config's PQPrecompileBlock is reached
+type EVMFactory struct {
+	ethConfig   *params.ChainConfig
+	chainConfig *config.ChainConfig
+	registry    *PrecompileRegistry
+}
+
+// NewEVMFactory creates a factory. The PQ registry is built once here so the
+// per-block path does not re-register (and re-log) the precompiles.
+func NewEVMFactory(ethConfig *params.ChainConfig, chainConfig *config.ChainConfig) *EVMFactory {
+	if chainConfig == nil {
+		chainConfig = config.DefaultChainConfig()
+	}
+
+	return &EVMFactory{
+		ethConfig:   ethConfig,
+		chainConfig: chainConfig,
+		registry:    NewPrecompileRegistryWithConfig(chainConfig),
+	}
+}
+
+// rules returns the Ethereum fork rules for a block
+func (f *EVMFactory) rules(blockCtx vm.BlockContext) params.Rules {
+	isMerge := blockCtx.Random != nil
+	return f.ethConfig.Rules(blockCtx.BlockNumber, isMerge, blockCtx.Time)
+}
+
+// Precompiles returns the precompile set active for a block
+func (f *EVMFactory) Precompiles(blockCtx vm.BlockContext) vm.PrecompiledContracts {
+	contracts := vm.ActivePrecompiledContracts(f.rules(blockCtx))
+
+	if f.chainConfig.IsPQPrecompiles(blockCtx.BlockNumber) {
+		for addr, contract := range f.registry.GetAllPrecompiles() {
+			contracts[addr] = contract
+		}
+	}
+
+	return contracts
+}
+
+// PrecompileAddresses returns the active precompile addresses for a block.
+// Pass these to StateDB.Prepare so the PQ precompiles start warm (EIP-2929)
+// like the standard ones.
+func (f *EVMFactory) PrecompileAddresses(blockCtx vm.BlockContext) []common.Address {
+	contracts := f.Precompiles(blockCtx)
+
+	addrs := make([]common.Address, 0, len(contracts))
+	for addr := range contracts {
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+// IsPQActive reports whether the PQ precompiles are installed at block num
+func (f *EVMFactory) IsPQActive(num *big.Int) bool {
+	return f.chainConfig.IsPQPrecompiles(num)
+}
+
+// NewEVM creates an EVM for the block with the active precompile set installed
+func (f *EVMFactory) NewEVM(blockCtx vm.BlockContext, statedb vm.StateDB, vmConfig vm.Config) *vm.EVM {
+	evm := vm.NewEVM(blockCtx, statedb, f.ethConfig, vmConfig)
+	evm.SetPrecompiles(f.Precompiles(blockCtx))
+	return evm
+}
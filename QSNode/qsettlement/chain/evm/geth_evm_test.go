@@ -0,0 +1,271 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// testForkBlock is the PQPrecompileBlock used by the fork tests
+const testForkBlock = 10
+
+var (
+	testCaller   = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	testContract = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+)
+
+// forwarderCode returns runtime bytecode that does what the PQ library's
+// _call does: STATICCALL precompile with the calldata, revert with the
+// return data on failure, otherwise return it
+func forwarderCode(precompile common.Address) []byte {
+	code, _ := hex.DecodeString(
+		"36600060003760006000366000" + // calldatacopy(0, 0, calldatasize); retSize, retOffset, argsSize, argsOffset
+			"61" + hex.EncodeToString(precompile.Bytes()[18:]) + // push2 precompile
+			"5afa" + // gas, staticcall
+			"3d600060003e" + // returndatacopy(0, 0, returndatasize)
+			"601f57" + // jumpi(0x1f, success)
+			"3d6000fd" + // revert(0, returndatasize)
+			"5b3d6000f3") // jumpdest; return(0, returndatasize)
+	return code
+}
+
+// newForkTestFactory returns a factory whose PQ precompiles activate at
+// testForkBlock
+func newForkTestFactory(tb testing.TB) *EVMFactory {
+	tb.Helper()
+
+	cfg := config.DefaultChainConfig()
+	cfg.PQPrecompileBlock = big.NewInt(testForkBlock)
+	return NewEVMFactory(params.MergedTestChainConfig, cfg)
+}
+
+// newTestEVM creates an EVM at block num with code deployed at testContract
+func newTestEVM(tb testing.TB, factory *EVMFactory, num int64, code []byte) *vm.EVM {
+	tb.Helper()
+
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		tb.Fatalf("state.New: %v", err)
+	}
+	statedb.SetCode(testContract, code, 0)
+
+	blockCtx := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		GasLimit:    30_000_000,
+		BlockNumber: big.NewInt(num),
+		Time:        uint64(num) * 12,
+		Difficulty:  common.Big0,
+		BaseFee:     common.Big0,
+		Random:      &common.Hash{},
+	}
+
+	evm := factory.NewEVM(blockCtx, statedb, vm.Config{})
+	statedb.Prepare(evm.GetRules(), testCaller, common.Address{}, &testContract,
+		factory.PrecompileAddresses(blockCtx), nil)
+	return evm
+}
+
+// callContract calls testContract and returns its output and gas used
+func callContract(evm *vm.EVM, input []byte) ([]byte, uint64, error) {
+	budget := vm.NewGasBudget(5_000_000, 0)
+	out, left, err := evm.Call(testCaller, testContract, input, budget, new(uint256.Int))
+	return out, left.Used(budget), err
+}
+
+func TestPQPrecompilesAcrossForkBlock(t *testing.T) {
+	factory := newForkTestFactory(t)
+
+	pubKey, signer := katVectors[0].keygen(katSeed())
+	valid := EncodePqVerifyInput(tx.SigAlgoDilithium2, pubKey, katMessage, signer(katMessage))
+	badSig := signer(katMessage)
+	badSig[0] ^= 0x01
+	invalid := EncodePqVerifyInput(tx.SigAlgoDilithium2, pubKey, katMessage, badSig)
+	malformed := EncodePqVerifyInput(tx.SigAlgoDilithium2, pubKey, katMessage, badSig[:10])
+
+	code := forwarderCode((&PqVerifyPrecompile{}).Address())
+
+	// Before the fork 0x0101 is an empty account: the call succeeds with no
+	// output, which the library's _readWord rejects
+	for _, num := range []int64{0, testForkBlock - 1} {
+		if factory.IsPQActive(big.NewInt(num)) {
+			t.Fatalf("PQ precompiles active at block %d", num)
+		}
+		out, _, err := callContract(newTestEVM(t, factory, num, code), valid)
+		if err != nil || len(out) != 0 {
+			t.Fatalf("block %d: got (%x, %v), want empty output", num, out, err)
+		}
+	}
+
+	for _, num := range []int64{testForkBlock, testForkBlock + 1000} {
+		evm := newTestEVM(t, factory, num, code)
+
+		out, gasUsed, err := callContract(evm, valid)
+		if err != nil || !bytes.Equal(out, common.LeftPadBytes([]byte{1}, 32)) {
+			t.Fatalf("block %d valid: got (%x, %v), want 1", num, out, err)
+		}
+		if want := (&PqVerifyPrecompile{}).RequiredGas(valid); gasUsed < want {
+			t.Fatalf("block %d: used %d gas, precompile alone costs %d", num, gasUsed, want)
+		}
+
+		if out, _, err := callContract(evm, invalid); err != nil || !bytes.Equal(out, make([]byte, 32)) {
+			t.Fatalf("block %d invalid: got (%x, %v), want 0", num, out, err)
+		}
+
+		// Malformed input fails the precompile, so the library's require reverts
+		if _, _, err := callContract(evm, malformed); !errors.Is(err, vm.ErrExecutionReverted) {
+			t.Fatalf("block %d malformed: got %v, want revert", num, err)
+		}
+	}
+}
+
+func TestDefaultConfigLeavesPrecompilesInactive(t *testing.T) {
+	// The gas schedule is provisional, so networks must opt in
+	factory := NewEVMFactory(params.MergedTestChainConfig, config.DefaultChainConfig())
+	for _, num := range []int64{0, 1 << 40} {
+		if factory.IsPQActive(big.NewInt(num)) {
+			t.Fatalf("PQ precompiles active at block %d by default", num)
+		}
+	}
+}
+
+func TestPrecompileAddressesAcrossForkBlock(t *testing.T) {
+	factory := newForkTestFactory(t)
+
+	for num, want := range map[int64]bool{testForkBlock - 1: false, testForkBlock: true} {
+		blockCtx := vm.BlockContext{BlockNumber: big.NewInt(num), Random: &common.Hash{}}
+
+		found := make(map[common.Address]bool)
+		for _, addr := range factory.PrecompileAddresses(blockCtx) {
+			found[addr] = true
+		}
+
+		for addr := range factory.registry.GetAllPrecompiles() {
+			if found[addr] != want {
+				t.Errorf("block %d: precompile %s warm = %v, want %v", num, addr.Hex(), found[addr], want)
+			}
+		}
+
+		// The standard set is always present
+		if !found[common.BytesToAddress([]byte{0x01})] {
+			t.Errorf("block %d: ecrecover missing", num)
+		}
+	}
+}
+
+// harnessABI is the ABI of PQHarness in testdata/PQHarness.sol, which
+// pqgen generates from the library
+const harnessABI = `[
+	{"type":"function","name":"verifyWithAlgo","stateMutability":"view","inputs":[
+		{"name":"algo","type":"uint8"},{"name":"pubKey","type":"bytes"},
+		{"name":"msgHash","type":"bytes32"},{"name":"signature","type":"bytes"}],
+	 "outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"shake256","stateMutability":"view","inputs":[
+		{"name":"data","type":"bytes"},{"name":"outLen","type":"uint256"}],
+	 "outputs":[{"name":"","type":"bytes"}]}]`
+
+// loadHarness returns the PQHarness runtime code that pqgen -solc compiled
+// from testdata/PQHarness.sol
+func loadHarness(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "PQHarness.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("testdata/PQHarness.json missing; run go run ./pqgen -solc solc")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixture struct {
+		SourceHash common.Hash   `json:"sourceHash"`
+		BinRuntime hexutil.Bytes `json:"binRuntime"`
+	}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		t.Fatalf("failed to parse PQHarness.json: %v", err)
+	}
+
+	// pqgen's tests keep the source current, so a mismatch means the
+	// fixture was not recompiled after the library changed
+	source, err := os.ReadFile(filepath.Join("testdata", "PQHarness.sol"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.Keccak256Hash(source) != fixture.SourceHash {
+		t.Fatal("PQHarness.json is stale; run go run ./pqgen -solc solc")
+	}
+	return fixture.BinRuntime
+}
+
+func TestGeneratedLibraryAcrossForkBlock(t *testing.T) {
+	code := loadHarness(t)
+	factory := newForkTestFactory(t)
+
+	harness, err := abi.JSON(strings.NewReader(harnessABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, signer := katVectors[0].keygen(katSeed())
+	msgHash := crypto.Keccak256Hash(katMessage)
+	sig := signer(msgHash.Bytes())
+
+	verify, _ := harness.Pack("verifyWithAlgo", tx.SigAlgoDilithium2, pubKey, msgHash, sig)
+	shake, _ := harness.Pack("shake256", katMessage, big.NewInt(64))
+
+	// Before the fork the library reverts on the missing output
+	before := newTestEVM(t, factory, testForkBlock-1, code)
+	for name, input := range map[string][]byte{"verifyWithAlgo": verify, "shake256": shake} {
+		if _, _, err := callContract(before, input); !errors.Is(err, vm.ErrExecutionReverted) {
+			t.Fatalf("%s before fork: got %v, want revert", name, err)
+		}
+	}
+
+	after := newTestEVM(t, factory, testForkBlock, code)
+	out, _, err := callContract(after, verify)
+	if err != nil {
+		t.Fatalf("verifyWithAlgo after fork: %v", err)
+	}
+	if res, err := harness.Unpack("verifyWithAlgo", out); err != nil || res[0] != true {
+		t.Fatalf("verifyWithAlgo after fork: got (%v, %v), want true", res, err)
+	}
+
+	sig[0] ^= 0x01
+	badVerify, _ := harness.Pack("verifyWithAlgo", tx.SigAlgoDilithium2, pubKey, msgHash, sig)
+	out, _, err = callContract(after, badVerify)
+	if res, uerr := harness.Unpack("verifyWithAlgo", out); err != nil || uerr != nil || res[0] != false {
+		t.Fatalf("verifyWithAlgo with bad signature: got (%v, %v), want false", res, err)
+	}
+
+	out, _, err = callContract(after, shake)
+	if err != nil {
+		t.Fatalf("shake256 after fork: %v", err)
+	}
+	// SHAKE256(katMessage, 64), computed outside this module
+	want := common.FromHex("d498c88709bbf9ff50aa611b9b71a2e5cb380bfd1dc7b68c273cbe9ccf1eb32a" +
+		"ee50fbc7731b02c5305a69c36e73351ae2e44f8aad9e08cc4c0f1a97287524d4")
+	if res, err := harness.Unpack("shake256", out); err != nil || !bytes.Equal(res[0].([]byte), want) {
+		t.Fatalf("shake256 after fork: got (%x, %v), want %x", res, err, want)
+	}
+}
@@ -0,0 +1,179 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// ─────────────────────────────────────────────────────────────────────────
+// SHAKE128 / SHAKE256 Precompiles (0x0105, 0x0106)
+// ─────────────────────────────────────────────────────────────────────────
+
+// MaxShakeOutputLen bounds the XOF output so gas and memory stay bounded
+const MaxShakeOutputLen = 1 << 14
+
+// ErrShakeMalformed is returned for invalid SHAKE input
+var ErrShakeMalformed = errors.New("malformed SHAKE input")
+
+// parseShakeInput decodes [out_len(32)][data]
+func parseShakeInput(input []byte) (uint64, []byte, error) {
+	outLen, err := readUint64Word(input, 0)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: output length: %v", ErrShakeMalformed, err)
+	}
+	if outLen == 0 || outLen > MaxShakeOutputLen {
+		return 0, nil, fmt.Errorf("%w: output length %d (1-%d)", ErrShakeMalformed, outLen, MaxShakeOutputLen)
+	}
+	return outLen, input[32:], nil
+}
+
+// shakeGas prices absorbing data and squeezing outLen bytes. Malformed input
+// is charged the base cost.
+func shakeGas(input []byte, baseGas, perWordGas uint64) uint64 {
+	outLen, data, err := parseShakeInput(input)
+	if err != nil {
+		return baseGas
+	}
+
+	words := (uint64(len(data))+31)/32 + (outLen+31)/32
+	return baseGas + words*perWordGas
+}
+
+// Shake128Precompile computes SHAKE128 with caller-chosen output length
+// Address: 0x0000000000000000000000000000000000000105
+// Input:  [out_len(32)][data]
+// Output: out_len bytes of SHAKE128(data)
+type Shake128Precompile struct {
+	gas *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+}
+
+// Address returns the precompile address
+func (s *Shake128Precompile) Address() common.Address {
+	return common.HexToAddress("0x0000000000000000000000000000000000000105")
+}
+
+// Name returns the precompile name reported to the EVM tracer
+func (s *Shake128Precompile) Name() string {
+	return "SHAKE128"
+}
+
+// RequiredGas calculates the gas cost
+// Default: 60 gas + 10 gas per 32-byte word of input and of output
+// (SHAKE128 absorbs 168 bytes per permutation)
+func (s *Shake128Precompile) RequiredGas(input []byte) uint64 {
+	g := gasSchedule(s.gas)
+	return shakeGas(input, g.ShakeBase, g.Shake128PerWord)
+}
+
+// Run computes the SHAKE128 digest
+func (s *Shake128Precompile) Run(input []byte) ([]byte, error) {
+	outLen, data, err := parseShakeInput(input)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, outLen)
+	sha3.ShakeSum128(out, data)
+	return out, nil
+}
+
+// Shake256Precompile computes SHAKE256 with caller-chosen output length
+// Address: 0x0000000000000000000000000000000000000106
+// Input:  [out_len(32)][data]
+// Output: out_len bytes of SHAKE256(data)
+type Shake256Precompile struct {
+	gas *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+}
+
+// Address returns the precompile address
+func (s *Shake256Precompile) Address() common.Address {
+	return common.HexToAddress("0x0000000000000000000000000000000000000106")
+}
+
+// Name returns the precompile name reported to the EVM tracer
+func (s *Shake256Precompile) Name() string {
+	return "SHAKE256"
+}
+
+// RequiredGas calculates the gas cost
+// Default: 60 gas + 12 gas per 32-byte word of input and of output
+// (SHAKE256 absorbs 136 bytes per permutation)
+func (s *Shake256Precompile) RequiredGas(input []byte) uint64 {
+	g := gasSchedule(s.gas)
+	return shakeGas(input, g.ShakeBase, g.Shake256PerWord)
+}
+
+// Run computes the SHAKE256 digest
+func (s *Shake256Precompile) Run(input []byte) ([]byte, error) {
+	outLen, data, err := parseShakeInput(input)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, outLen)
+	sha3.ShakeSum256(out, data)
+	return out, nil
+}
+
+// ─────────────────────────────────────────────────────────────────────────
+// SlhDsaVerify Precompile (0x0107)
+// ─────────────────────────────────────────────────────────────────────────
+
+// SlhDsaVerifyPrecompile verifies stateless hash-based (SLH-DSA / SPHINCS+)
+// signatures. It shares the PqVerify input layout and gas schedule but only
+// accepts SLH-DSA parameter sets, so contracts can pin hash-based security.
+// Address: 0x0000000000000000000000000000000000000107
+// Input:  [header(32)][body] as PqVerify, algo = 0x10 (SHA2-128s) or 0x11 (SHAKE-128s)
+// Output: [verified(32)]
+type SlhDsaVerifyPrecompile struct {
+	gas   *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+	algos *tx.SigAlgoRegistry   // nil = algorithms of config.DefaultChainConfig()
+}
+
+// slhDsaAlgos lists the PQSigAlgo IDs accepted by SlhDsaVerify
+var slhDsaAlgos = map[uint8]bool{
+	tx.SigAlgoSLHDSASHA2128s:  true,
+	tx.SigAlgoSLHDSASHAKE128s: true,
+}
+
+// Address returns the precompile address
+func (s *SlhDsaVerifyPrecompile) Address() common.Address {
+	return common.HexToAddress("0x0000000000000000000000000000000000000107")
+}
+
+// Name returns the precompile name reported to the EVM tracer
+func (s *SlhDsaVerifyPrecompile) Name() string {
+	return "SLH_DSA_VERIFY"
+}
+
+// RequiredGas calculates the gas cost (same schedule as PqVerify)
+func (s *SlhDsaVerifyPrecompile) RequiredGas(input []byte) uint64 {
+	return (&PqVerifyPrecompile{gas: s.gas}).RequiredGas(input)
+}
+
+// Run executes SLH-DSA signature verification
+func (s *SlhDsaVerifyPrecompile) Run(input []byte) ([]byte, error) {
+	parsed, err := parsePqVerifyInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slhDsaAlgos[parsed.algo] {
+		return nil, fmt.Errorf("%w: 0x%02x is not an SLH-DSA parameter set", ErrPqVerifyAlgo, parsed.algo)
+	}
+
+	verified, err := verifyPQSignature(sigAlgoRegistry(s.algos), parsed.algo, parsed.pubKey, parsed.msg, parsed.sig)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 32)
+	if verified {
+		result[31] = 1
+	}
+	return result, nil
+}
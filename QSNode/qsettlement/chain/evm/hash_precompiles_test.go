@@ -0,0 +1,154 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// fipsExampleInput is the 1600-bit message of the FIPS 202 SHAKE examples
+var fipsExampleInput = bytes.Repeat([]byte{0xa3}, 200)
+
+// rateInput returns n bytes 0x00, 0x01, ...
+func rateInput(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+// shakeVectors are FIPS 202 known answers. The empty-input and full-block
+// cases end exactly on and just past the 168-byte (SHAKE128) and 136-byte
+// (SHAKE256) rate.
+var shakeVectors = []struct {
+	name   string
+	shake  vm.PrecompiledContract
+	data   []byte
+	outLen int
+	want   string
+}{
+	{
+		name: "SHAKE128 empty input", shake: &Shake128Precompile{}, data: nil, outLen: 32,
+		want: "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26",
+	},
+	{
+		name: "SHAKE128 empty input, one full block", shake: &Shake128Precompile{}, data: nil, outLen: 168,
+		want: "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26" +
+			"3cb1eea988004b93103cfb0aeefd2a686e01fa4a58e8a3639ca8a1e3f9ae57e2" +
+			"35b8cc873c23dc62b8d260169afa2f75ab916a58d974918835d25e6a435085b2" +
+			"badfd6dfaac359a5efbb7bcc4b59d538df9a04302e10c8bc1cbf1a0b3a5120ea" +
+			"17cda7cfad765f5623474d368ccca8af0007cd9f5e4c849f167a580b14aabdef" +
+			"aee7eef47cb0fca9",
+	},
+	{
+		name: "SHAKE128 empty input, into the second block", shake: &Shake128Precompile{}, data: nil, outLen: 169,
+		want: "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26" +
+			"3cb1eea988004b93103cfb0aeefd2a686e01fa4a58e8a3639ca8a1e3f9ae57e2" +
+			"35b8cc873c23dc62b8d260169afa2f75ab916a58d974918835d25e6a435085b2" +
+			"badfd6dfaac359a5efbb7bcc4b59d538df9a04302e10c8bc1cbf1a0b3a5120ea" +
+			"17cda7cfad765f5623474d368ccca8af0007cd9f5e4c849f167a580b14aabdef" +
+			"aee7eef47cb0fca976",
+	},
+	{
+		name: "SHAKE128 one full input block", shake: &Shake128Precompile{}, data: rateInput(168), outLen: 32,
+		want: "f15277eb61c4908d44a2853f3cde071ae2ed7a23461fbe162a1a98cf6875059c",
+	},
+	{
+		name: "SHAKE128 FIPS 202 example, 1600-bit 0xa3", shake: &Shake128Precompile{}, data: fipsExampleInput, outLen: 512,
+		want: "131ab8d2b594946b9c81333f9bb6e0ce75c3b93104fa3469d3917457385da037" +
+			"cf232ef7164a6d1eb448c8908186ad852d3f85a5cf28da1ab6fe343817197846" +
+			"7f1c05d58c7ef38c284c41f6c2221a76f12ab1c04082660250802294fb871802" +
+			"13fdef5b0ecb7df50ca1f8555be14d32e10f6edcde892c09424b29f597afc270" +
+			"c904556bfcb47a7d40778d390923642b3cbd0579e60908d5a000c1d08b98ef93" +
+			"3f806445bf87f8b009ba9e94f7266122ed7ac24e5e266c42a82fa1bbefb7b8db" +
+			"0066e16a85e0493f07df4809aec084a593748ac3dde5a6d7aae1e8b6e5352b2d" +
+			"71efbb47d4caeed5e6d633805d2d323e6fd81b4684b93a2677d45e7421c2c6ae" +
+			"a259b855a698fd7d13477a1fe53e5a4a6197dbec5ce95f505b520bcd9570c4a8" +
+			"265a7e01f89c0c002c59bfec6cd4a5c109258953ee5ee70cd577ee217af21fa7" +
+			"0178f0946c9bf6ca8751793479f6b537737e40b6ed28511d8a2d7e73eb75f8da" +
+			"ac912ff906e0ab955b083bac45a8e5e9b744c8506f37e9b4e749a184b30f43eb" +
+			"188d855f1b70d71ff3e50c537ac1b0f8974f0fe1a6ad295ba42f6aec74d123a7" +
+			"abedde6e2c0711cab36be5acb1a5a11a4b1db08ba6982efccd716929a7741cfc" +
+			"63aa4435e0b69a9063e880795c3dc5ef3272e11c497a91acf699fefee206227a" +
+			"44c9fb359fd56ac0a9a75a743cff6862f17d7259ab075216c0699511643b6439",
+	},
+	{
+		name: "SHAKE256 empty input", shake: &Shake256Precompile{}, data: nil, outLen: 64,
+		want: "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762f" +
+			"d75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be",
+	},
+	{
+		name: "SHAKE256 empty input, one full block", shake: &Shake256Precompile{}, data: nil, outLen: 136,
+		want: "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762f" +
+			"d75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be" +
+			"141e96616fb13957692cc7edd0b45ae3dc07223c8e92937bef84bc0eab862853" +
+			"349ec75546f58fb7c2775c38462c5010d846c185c15111e595522a6bcd16cf86" +
+			"f3d122109e3b1fdd",
+	},
+	{
+		name: "SHAKE256 empty input, into the second block", shake: &Shake256Precompile{}, data: nil, outLen: 137,
+		want: "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762f" +
+			"d75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be" +
+			"141e96616fb13957692cc7edd0b45ae3dc07223c8e92937bef84bc0eab862853" +
+			"349ec75546f58fb7c2775c38462c5010d846c185c15111e595522a6bcd16cf86" +
+			"f3d122109e3b1fdd94",
+	},
+	{
+		name: "SHAKE256 one full input block", shake: &Shake256Precompile{}, data: rateInput(136), outLen: 32,
+		want: "b7ff4073b3f5a8eabd6e17705ca7f6761a31058f9df781a6a47e3a3063b9d67a",
+	},
+	{
+		name: "SHAKE256 FIPS 202 example, 1600-bit 0xa3", shake: &Shake256Precompile{}, data: fipsExampleInput, outLen: 512,
+		want: "cd8a920ed141aa0407a22d59288652e9d9f1a7ee0c1e7c1ca699424da84a904d" +
+			"2d700caae7396ece96604440577da4f3aa22aeb8857f961c4cd8e06f0ae6610b" +
+			"1048a7f64e1074cd629e85ad7566048efc4fb500b486a3309a8f26724c0ed628" +
+			"001a1099422468de726f1061d99eb9e93604d5aa7467d4b1bd6484582a384317" +
+			"d7f47d750b8f5499512bb85a226c4243556e696f6bd072c5aa2d9b69730244b5" +
+			"6853d16970ad817e213e470618178001c9fb56c54fefa5fee67d2da524bb3b0b" +
+			"61ef0e9114a92cdbb6cccb98615cfe76e3510dd88d1cc28ff99287512f24bfaf" +
+			"a1a76877b6f37198e3a641c68a7c42d45fa7acc10dae5f3cefb7b735f12d4e58" +
+			"9f7a456e78c0f5e4c4471fffa5e4fa0514ae974d8c2648513b5db494cea84715" +
+			"6d277ad0e141c24c7839064cd08851bc2e7ca109fd4e251c35bb0a04fb05b364" +
+			"ff8c4d8b59bc303e25328c09a882e952518e1a8ae0ff265d61c465896973d749" +
+			"0499dc639fb8502b39456791b1b6ec5bcc5d9ac36a6df622a070d43fed781f5f" +
+			"149f7b62675e7d1a4d6dec48c1c7164586eae06a51208c0b791244d307726505" +
+			"c3ad4b26b6822377257aa152037560a739714a3ca79bd605547c9b78dd1f596f" +
+			"2d4f1791bc689a0e9b799a37339c04275733740143ef5d2b58b96a363d4e0807" +
+			"6a1a9d7846436e4dca5728b6f760eef0ca92bf0be5615e96959d767197a0beeb",
+	},
+}
+
+func TestShakeKnownAnswers(t *testing.T) {
+	for _, v := range shakeVectors {
+		out, err := v.shake.Run(append(lengthWord(v.outLen), v.data...))
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if got := hex.EncodeToString(out); got != v.want {
+			t.Errorf("%s: got %s, want %s", v.name, got, v.want)
+		}
+	}
+}
+
+func TestShakeRejectsMalformedInput(t *testing.T) {
+	for _, shake := range []vm.PrecompiledContract{&Shake128Precompile{}, &Shake256Precompile{}} {
+		for name, input := range map[string][]byte{
+			"empty":             nil,
+			"short length word": lengthWord(32)[:31],
+			"zero output":       lengthWord(0),
+			"output over limit": lengthWord(MaxShakeOutputLen + 1),
+		} {
+			if _, err := shake.Run(input); !errors.Is(err, ErrShakeMalformed) {
+				t.Errorf("%s %s: got %v, want %v", shake.Name(), name, err, ErrShakeMalformed)
+			}
+		}
+
+		if out, err := shake.Run(lengthWord(MaxShakeOutputLen)); err != nil || len(out) != MaxShakeOutputLen {
+			t.Errorf("%s at the output limit: got %d bytes, %v", shake.Name(), len(out), err)
+		}
+	}
+}
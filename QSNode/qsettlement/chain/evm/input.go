@@ -0,0 +1,78 @@
+package evm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Precompile input helpers. All length and offset words are full 32-byte
+// big-endian uint256 values: any non-zero byte above the low 8 bytes is an
+// overflow, and bounds are checked without computing offset+length so huge
+// values cannot wrap around.
+
+var (
+	// ErrInputOutOfBounds is returned when a field extends past the input
+	ErrInputOutOfBounds = errors.New("input field out of bounds")
+	// ErrWordOverflow is returned when a length/offset word does not fit in 64 bits
+	ErrWordOverflow = errors.New("input word overflows uint64")
+)
+
+// readUint64Word reads the 32-byte word at offset as a uint64
+func readUint64Word(input []byte, offset uint64) (uint64, error) {
+	if offset > uint64(len(input)) || uint64(len(input))-offset < 32 {
+		return 0, fmt.Errorf("%w: word at %d", ErrInputOutOfBounds, offset)
+	}
+
+	word := input[offset : offset+32]
+	for _, b := range word[:24] {
+		if b != 0 {
+			return 0, fmt.Errorf("%w: word at %d", ErrWordOverflow, offset)
+		}
+	}
+
+	return binary.BigEndian.Uint64(word[24:]), nil
+}
+
+// readSlice returns input[offset:offset+n] if it lies within input
+func readSlice(input []byte, offset, n uint64) ([]byte, error) {
+	if offset > uint64(len(input)) || n > uint64(len(input))-offset {
+		return nil, fmt.Errorf("%w: %d bytes at %d", ErrInputOutOfBounds, n, offset)
+	}
+	return input[offset : offset+n], nil
+}
+
+// readLengthPrefixed reads a [len(32)][data] field at offset and returns the
+// data and the offset just past it
+func readLengthPrefixed(input []byte, offset uint64) ([]byte, uint64, error) {
+	n, err := readUint64Word(input, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := readSlice(input, offset+32, n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data, offset + 32 + n, nil
+}
+
+// readABIBytes reads the index'th dynamic bytes argument of an abi.encode'd
+// tuple: the head word holds the offset of a [len(32)][data] tail
+func readABIBytes(args []byte, index int) ([]byte, error) {
+	offset, err := readUint64Word(args, uint64(index)*32)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := readLengthPrefixed(args, offset)
+	return data, err
+}
+
+// lengthWord encodes n as a 32-byte big-endian word
+func lengthWord(n int) []byte {
+	word := make([]byte, 32)
+	binary.BigEndian.PutUint64(word[24:], uint64(n))
+	return word
+}
@@ -0,0 +1,77 @@
+package evm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// samePqVerifyInput reports whether two parsed inputs carry the same call
+func samePqVerifyInput(a, b *pqVerifyInput) bool {
+	return a.algo == b.algo &&
+		bytes.Equal(a.pubKey, b.pubKey) &&
+		bytes.Equal(a.msg, b.msg) &&
+		bytes.Equal(a.sig, b.sig)
+}
+
+// FuzzPqVerifyInput checks that no input panics the verify precompiles and
+// that packed and ABI mode parse the same fields to the same call. Seeds hold
+// full-size keys and signatures, so pass -fuzzminimizetime=1s to keep input
+// minimization from stalling the run.
+func FuzzPqVerifyInput(f *testing.F) {
+	pubKey, signer := katVectors[0].keygen(katSeed())
+	sig := signer(katMessage)
+
+	for _, mode := range inputModes {
+		valid := mode.encode(tx.SigAlgoDilithium2, pubKey, katMessage, sig)
+		f.Add(valid, tx.SigAlgoDilithium2, pubKey, katMessage, sig)
+		f.Add(valid[:len(valid)-1], tx.SigAlgoDilithium3, pubKey[:32], []byte{}, sig[:64])
+	}
+	f.Add([]byte{}, uint8(0), []byte{}, []byte{}, []byte{})
+	f.Add(make([]byte, 32), tx.SigAlgoSLHDSASHA2128s, make([]byte, 32), []byte{0x01}, make([]byte, 16))
+
+	p := &PqVerifyPrecompile{}
+	f.Fuzz(func(t *testing.T, raw []byte, algo uint8, pubKey, msg, sig []byte) {
+		// Arbitrary input must never panic, whatever the gas and result
+		_ = p.RequiredGas(raw)
+		_, _ = p.Run(raw)
+		_ = (&PqBatchVerifyPrecompile{}).RequiredGas(raw)
+		_, _ = (&SlhDsaVerifyPrecompile{}).Run(raw)
+
+		// Input that parses in one mode re-encodes to the same call in both
+		if parsed, err := parsePqVerifyInput(raw); err == nil {
+			for _, mode := range inputModes {
+				again, err := parsePqVerifyInput(mode.encode(parsed.algo, parsed.pubKey, parsed.msg, parsed.sig))
+				if err != nil {
+					t.Fatalf("%s re-encoding of parsed input failed: %v", mode.name, err)
+				}
+				if !samePqVerifyInput(parsed, again) {
+					t.Fatalf("%s re-encoding changed the call", mode.name)
+				}
+			}
+		}
+
+		// The same fields must parse identically in packed and ABI mode
+		packed, packedErr := parsePqVerifyInput(EncodePqVerifyInput(algo, pubKey, msg, sig))
+		abiMode, abiErr := parsePqVerifyInput(encodePqVerifyABI(algo, pubKey, msg, sig))
+		if (packedErr == nil) != (abiErr == nil) {
+			t.Fatalf("modes disagree: packed error %v, ABI error %v", packedErr, abiErr)
+		}
+		if packedErr == nil && !samePqVerifyInput(packed, abiMode) {
+			t.Fatal("packed and ABI mode parsed different calls")
+		}
+		if packedErr == nil && !samePqVerifyInput(packed, &pqVerifyInput{algo: algo, pubKey: pubKey, msg: msg, sig: sig}) {
+			t.Fatal("parsed call does not match the encoded fields")
+		}
+
+		// Gas is the same whichever mode the call uses
+		if packedErr == nil {
+			packedGas := p.RequiredGas(EncodePqVerifyInput(algo, pubKey, msg, sig))
+			abiGas := p.RequiredGas(encodePqVerifyABI(algo, pubKey, msg, sig))
+			if packedGas != abiGas {
+				t.Fatalf("gas differs by mode: packed %d, ABI %d", packedGas, abiGas)
+			}
+		}
+	})
+}
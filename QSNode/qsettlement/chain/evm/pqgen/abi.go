@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+)
+
+// abiParam is a Solidity ABI JSON parameter
+type abiParam struct {
+	InternalType string `json:"internalType"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+}
+
+// abiFunction is a Solidity ABI JSON function entry
+type abiFunction struct {
+	Inputs          []abiParam `json:"inputs"`
+	Name            string     `json:"name"`
+	Outputs         []abiParam `json:"outputs"`
+	StateMutability string     `json:"stateMutability"`
+	Type            string     `json:"type"`
+}
+
+// abiParams converts spec parameters to ABI JSON parameters
+func abiParams(params []evm.ParamSpec) []abiParam {
+	out := make([]abiParam, len(params))
+	for i, p := range params {
+		out[i] = abiParam{InternalType: p.Type, Name: p.Name, Type: p.Type}
+	}
+	return out
+}
+
+// generateABI renders the ABI of the library's functions
+func generateABI(spec *evm.LibrarySpec) ([]byte, error) {
+	var entries []abiFunction
+
+	for i := range spec.Precompiles {
+		p := &spec.Precompiles[i]
+		if p.Function == "" {
+			continue
+		}
+		entries = append(entries, abiFunction{
+			Inputs:          abiParams(orderedParams(p)),
+			Name:            p.Function,
+			Outputs:         abiParams(p.Outputs),
+			StateMutability: "view",
+			Type:            "function",
+		})
+	}
+
+	for _, alias := range spec.Aliases {
+		target, ok := spec.Function(alias.Target)
+		if !ok {
+			continue
+		}
+
+		var params []evm.ParamSpec
+		for _, in := range orderedParams(target) {
+			if _, fixed := alias.Fixed[in.Name]; !fixed {
+				params = append(params, in)
+			}
+		}
+
+		entries = append(entries, abiFunction{
+			Inputs:          abiParams(params),
+			Name:            alias.Function,
+			Outputs:         abiParams(target.Outputs),
+			StateMutability: "view",
+			Type:            "function",
+		})
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+)
+
+// accountHead is PQSmartAccount up to its signature helpers. The account
+// checks UserOperation.signature = abi.encode(uint8 algo, bytes pubKey,
+// bytes sig) against pqKeyHash = keccak256(abi.encodePacked(algo, pubKey)),
+// matching aa.DecodePQSignature and aa.PQKeyHash.
+const accountHead = `
+/**
+ * ERC-4337 (EntryPoint v0.6) user operation
+ */
+struct UserOperation {
+    address sender;
+    uint256 nonce;
+    bytes initCode;
+    bytes callData;
+    uint256 callGasLimit;
+    uint256 verificationGasLimit;
+    uint256 preVerificationGas;
+    uint256 maxFeePerGas;
+    uint256 maxPriorityFeePerGas;
+    bytes paymasterAndData;
+    bytes signature;
+}
+
+/**
+ * PQSmartAccount - ERC-4337 account controlled by a PQ key
+ *
+ * Lets wallets that cannot emit 0x79 envelopes sign with PQ keys; a bundler
+ * submits the operations to the EntryPoint from an ECDSA executor.
+ * UserOperation.signature is abi.encode(uint8 algo, bytes pubKey, bytes sig)
+ * where algo is a PQSigAlgo ID.
+ */
+contract PQSmartAccount {
+    uint256 internal constant SIG_VALIDATION_FAILED = 1;
+    address internal constant %s = %s;
+
+    address public immutable entryPoint;
+    bytes32 public pqKeyHash; // keccak256(abi.encodePacked(uint8 algo, bytes pubKey))
+
+    event PQKeyRotated(bytes32 indexed oldKeyHash, bytes32 indexed newKeyHash);
+
+    modifier onlyEntryPoint() {
+        require(msg.sender == entryPoint, "PQSmartAccount: not from EntryPoint");
+        _;
+    }
+
+    constructor(address _entryPoint, bytes32 _pqKeyHash) {
+        entryPoint = _entryPoint;
+        pqKeyHash = _pqKeyHash;
+    }
+
+    /**
+     * Validate a user operation signed with the account's PQ key. Malformed
+     * signatures and keys fail validation instead of reverting.
+     * @return validationData 0 on success, SIG_VALIDATION_FAILED otherwise
+     */
+    function validateUserOp(
+        UserOperation calldata userOp,
+        bytes32 userOpHash,
+        uint256 missingAccountFunds
+    ) external onlyEntryPoint returns (uint256 validationData) {
+        (bool ok, uint8 algo, bytes calldata pubKey, bytes calldata sig) = _decodeSignature(userOp.signature);
+
+        if (!ok || keccak256(abi.encodePacked(algo, pubKey)) != pqKeyHash || !_verify(algo, pubKey, userOpHash, sig)) {
+            validationData = SIG_VALIDATION_FAILED;
+        }
+
+        if (missingAccountFunds > 0) {
+            (bool paid, ) = payable(msg.sender).call{value: missingAccountFunds}("");
+            (paid);
+        }
+    }
+
+    /**
+     * Execute a call from this account
+     */
+    function execute(address dest, uint256 value, bytes calldata func) external onlyEntryPoint {
+        (bool ok, bytes memory result) = dest.call{value: value}(func);
+        if (!ok) {
+            assembly {
+                revert(add(result, 0x20), mload(result))
+            }
+        }
+    }
+
+    /**
+     * Rotate the PQ key; must be called by the account itself via execute()
+     */
+    function rotatePQKey(bytes32 newKeyHash) external {
+        require(msg.sender == address(this), "PQSmartAccount: only self");
+        emit PQKeyRotated(pqKeyHash, newKeyHash);
+        pqKeyHash = newKeyHash;
+    }
+
+    receive() external payable {}
+
+    /**
+     * Split abi.encode(uint8 algo, bytes pubKey, bytes sig), reporting
+     * out-of-range values instead of reverting like abi.decode
+     */
+    function _decodeSignature(bytes calldata data)
+        private
+        pure
+        returns (bool ok, uint8 algo, bytes calldata pubKey, bytes calldata sig)
+    {
+        pubKey = data[0:0];
+        sig = data[0:0];
+        if (data.length < 96 || uint256(bytes32(data[0:32])) > type(uint8).max) {
+            return (false, 0, pubKey, sig);
+        }
+        algo = uint8(uint256(bytes32(data[0:32])));
+
+        bool keyOk;
+        bool sigOk;
+        (keyOk, pubKey) = _readBytes(data, uint256(bytes32(data[32:64])));
+        (sigOk, sig) = _readBytes(data, uint256(bytes32(data[64:96])));
+        ok = keyOk && sigOk;
+    }
+
+    function _readBytes(bytes calldata data, uint256 offset) private pure returns (bool ok, bytes calldata out) {
+        out = data[0:0];
+        if (offset > data.length || data.length - offset < 32) {
+            return (false, out);
+        }
+        uint256 len = uint256(bytes32(data[offset:offset + 32]));
+        if (len > data.length - offset - 32) {
+            return (false, out);
+        }
+        return (true, data[offset + 32:offset + 32 + len]);
+    }
+`
+
+// generateAccount renders PQSmartAccount, the reference ERC-4337 account.
+// Its signature check builds the PqVerify input from the same spec as the
+// library's verifyWithAlgo, but returns false where the library reverts so
+// validateUserOp can return SIG_VALIDATION_FAILED.
+func generateAccount(spec *evm.LibrarySpec) (string, error) {
+	verify, ok := spec.Function("verifyWithAlgo")
+	if !ok {
+		return "", errors.New("spec has no verifyWithAlgo")
+	}
+
+	var b strings.Builder
+
+	b.WriteString("// SPDX-License-Identifier: MIT\n")
+	fmt.Fprintf(&b, "// %s\n", generatedHeader)
+	// Calldata slices convert to bytes32 from 0.8.5
+	b.WriteString("pragma solidity ^0.8.5;\n")
+	fmt.Fprintf(&b, accountHead, verify.Constant, verify.Address.Hex())
+
+	params := orderedParams(verify)
+	b.WriteString("\n")
+	writeDoc(&b, []string{
+		fmt.Sprintf("Call %s like %s.%s, returning false instead of", verify.Name, spec.Name, verify.Function),
+		"reverting when the precompile rejects the input",
+	}, "", nil, nil)
+	fmt.Fprintf(&b, "    function _verify(\n")
+	for i, p := range params {
+		sep := ","
+		if i == len(params)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "        %s%s\n", solidityDecl(p), sep)
+	}
+	b.WriteString("    ) private view returns (bool valid) {\n")
+	writeInput(&b, verify)
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "        (bool success, bytes memory output) = %s.staticcall(input);\n", verify.Constant)
+	b.WriteString("        valid = success && output.length == 32 && abi.decode(output, (uint256)) == 1;\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+
+	return b.String(), nil
+}
+
+// generateAccountGo wraps the account source in the evm package constant
+func generateAccountGo(solidity string) ([]byte, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// %s\n\n", generatedHeader)
+	b.WriteString("package evm\n\n")
+	b.WriteString("// PQSmartAccountSolidity is the reference ERC-4337 smart account for PQ\n")
+	b.WriteString("// wallets, checking user operation signatures through PqVerify (0x0101)\n")
+	b.WriteString("const PQSmartAccountSolidity = `\n")
+	b.WriteString(solidity)
+	b.WriteString("`\n")
+
+	return format.Source([]byte(b.String()))
+}
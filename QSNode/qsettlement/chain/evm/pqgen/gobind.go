@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+)
+
+// bindingsHelpers are the encode/decode/call helpers of the generated package
+const bindingsHelpers = `
+// ErrBatchLengthMismatch is returned when parallel batch arrays differ in length
+var ErrBatchLengthMismatch = errors.New("batch length mismatch")
+
+// ErrOutputTooShort is returned when precompile output is truncated
+var ErrOutputTooShort = errors.New("precompile output too short")
+
+var bytesType, _ = abi.NewType("bytes", "", nil)
+
+// bytesArgs returns an abi.Arguments of n bytes values
+func bytesArgs(n int) abi.Arguments {
+	args := make(abi.Arguments, n)
+	for i := range args {
+		args[i] = abi.Argument{Type: bytesType}
+	}
+	return args
+}
+
+// word encodes v as a 32-byte big-endian word
+func word(v uint64) []byte {
+	return common.LeftPadBytes(new(big.Int).SetUint64(v).Bytes(), 32)
+}
+
+// bigWord encodes v (nil = 0) as a 32-byte big-endian word
+func bigWord(v *big.Int) []byte {
+	if v == nil {
+		return make([]byte, 32)
+	}
+	return common.LeftPadBytes(v.Bytes(), 32)
+}
+
+// headerWord encodes mode << 8 | value
+func headerWord(mode uint8, value uint8) []byte {
+	w := make([]byte, 32)
+	w[30] = mode
+	w[31] = value
+	return w
+}
+
+// lengthPrefixed encodes data as [len(32)][data]
+func lengthPrefixed(data []byte) []byte {
+	return append(word(uint64(len(data))), data...)
+}
+
+// readWord reads the 32-byte word at offset
+func readWord(data []byte, offset int) ([]byte, int, error) {
+	if offset > len(data) || len(data)-offset < 32 {
+		return nil, 0, ErrOutputTooShort
+	}
+	return data[offset : offset+32], offset + 32, nil
+}
+
+// readBytes reads a [len(32)][data] field at offset
+func readBytes(data []byte, offset int) ([]byte, int, error) {
+	w, offset, err := readWord(data, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n := new(big.Int).SetBytes(w)
+	if !n.IsUint64() || n.Uint64() > uint64(len(data)-offset) {
+		return nil, 0, ErrOutputTooShort
+	}
+
+	end := offset + int(n.Uint64())
+	return data[offset:end], end, nil
+}
+
+// call executes an eth_call against a precompile
+func (c *PQCaller) call(opts *bind.CallOpts, addr common.Address, input []byte) ([]byte, error) {
+	if opts == nil {
+		opts = new(bind.CallOpts)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	msg := ethereum.CallMsg{From: opts.From, To: &addr, Data: input}
+	return c.contract.CallContract(ctx, msg, opts.BlockNumber)
+}
+`
+
+// goName converts a SNAKE_CASE or camelCase name to an exported Go identifier
+func goName(name string) string {
+	if strings.Contains(name, "_") || strings.ToUpper(name) == name {
+		parts := strings.Split(strings.ToLower(name), "_")
+		for i, part := range parts {
+			if part != "" {
+				parts[i] = strings.ToUpper(part[:1]) + part[1:]
+			}
+		}
+		return strings.Join(parts, "")
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// goType maps a Solidity type to its Go binding type
+func goType(solType string) string {
+	switch solType {
+	case "uint8":
+		return "uint8"
+	case "uint256":
+		return "*big.Int"
+	case "bool":
+		return "bool"
+	case "bytes32":
+		return "[32]byte"
+	case "bytes[]":
+		return "[][]byte"
+	case "bytes32[]":
+		return "[][32]byte"
+	default:
+		return "[]byte"
+	}
+}
+
+// goZero returns the zero value literal of a Go binding type
+func goZero(solType string) string {
+	switch solType {
+	case "uint8":
+		return "0"
+	case "bool":
+		return "false"
+	case "bytes32":
+		return "[32]byte{}"
+	default:
+		return "nil"
+	}
+}
+
+// goBytes returns an expression converting a value to []byte
+func goBytes(solType, expr string) string {
+	if solType == "bytes32" {
+		return expr + "[:]"
+	}
+	return expr
+}
+
+// goParams renders a Go parameter list
+func goParams(params []evm.ParamSpec) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.Name + " " + goType(p.Type)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// goArgs renders a Go argument list
+func goArgs(params []evm.ParamSpec) string {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// goResults renders the result types of a call followed by error
+func goResults(outputs []evm.ParamSpec) string {
+	parts := make([]string, 0, len(outputs)+1)
+	for _, out := range outputs {
+		parts = append(parts, goType(out.Type))
+	}
+	parts = append(parts, "error")
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// goZeroReturn renders "return <zeros>, err"
+func goZeroReturn(outputs []evm.ParamSpec) string {
+	parts := make([]string, 0, len(outputs)+1)
+	for _, out := range outputs {
+		parts = append(parts, goZero(out.Type))
+	}
+	parts = append(parts, "err")
+	return "return " + strings.Join(parts, ", ")
+}
+
+// writeGoEncoder writes Encode<Fn>Input
+func writeGoEncoder(b *strings.Builder, p *evm.PrecompileSpec) {
+	fn := goName(p.Function)
+	params := orderedParams(p)
+
+	fmt.Fprintf(b, "// Encode%sInput builds the %s precompile input\n", fn, p.Name)
+	fmt.Fprintf(b, "func Encode%sInput(%s) ([]byte, error) {\n", fn, goParams(params))
+
+	var (
+		abiArgs []string
+		batch   []evm.ParamSpec
+	)
+	for _, in := range p.Inputs {
+		switch in.Layout {
+		case evm.LayoutABI:
+			abiArgs = append(abiArgs, goBytes(in.Type, in.Name))
+		case evm.LayoutBatch:
+			batch = append(batch, in)
+		}
+	}
+
+	if len(batch) > 1 {
+		conds := make([]string, 0, len(batch)-1)
+		for _, in := range batch[1:] {
+			conds = append(conds, fmt.Sprintf("len(%s) != len(%s)", in.Name, batch[0].Name))
+		}
+		fmt.Fprintf(b, "\tif %s {\n\t\treturn nil, ErrBatchLengthMismatch\n\t}\n\n", strings.Join(conds, " || "))
+	}
+
+	b.WriteString("\tvar input []byte\n")
+	for _, in := range p.Inputs {
+		switch in.Layout {
+		case evm.LayoutHeader:
+			fmt.Fprintf(b, "\tinput = append(input, headerWord(0x%02x, %s)...)\n", p.Mode, in.Name)
+		case evm.LayoutWord:
+			switch in.Type {
+			case "uint256":
+				fmt.Fprintf(b, "\tinput = append(input, bigWord(%s)...)\n", in.Name)
+			case "bool":
+				fmt.Fprintf(b, "\tif %s {\n\t\tinput = append(input, word(1)...)\n\t} else {\n\t\tinput = append(input, word(0)...)\n\t}\n", in.Name)
+			default:
+				fmt.Fprintf(b, "\tinput = append(input, word(uint64(%s))...)\n", in.Name)
+			}
+		case evm.LayoutBytes:
+			fmt.Fprintf(b, "\tinput = append(input, lengthPrefixed(%s)...)\n", goBytes(in.Type, in.Name))
+		case evm.LayoutFixed, evm.LayoutRest:
+			fmt.Fprintf(b, "\tinput = append(input, %s...)\n", goBytes(in.Type, in.Name))
+		}
+	}
+
+	if len(abiArgs) > 0 {
+		fmt.Fprintf(b, "\n\tbody, err := bytesArgs(%d).Pack(%s)\n", len(abiArgs), strings.Join(abiArgs, ", "))
+		b.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		b.WriteString("\tinput = append(input, body...)\n")
+	}
+
+	if len(batch) > 0 {
+		fmt.Fprintf(b, "\n\tinput = append(input, word(uint64(len(%s)))...)\n", batch[0].Name)
+		fmt.Fprintf(b, "\tfor i := range %s {\n", batch[0].Name)
+		for _, in := range batch {
+			elem := in.Name + "[i]"
+			if in.Type == "bytes32[]" {
+				elem += "[:]"
+			}
+			fmt.Fprintf(b, "\t\tinput = append(input, lengthPrefixed(%s)...)\n", elem)
+		}
+		b.WriteString("\t}\n")
+	}
+
+	b.WriteString("\n\treturn input, nil\n}\n\n")
+}
+
+// writeGoDecoder writes Decode<Fn>Output
+func writeGoDecoder(b *strings.Builder, p *evm.PrecompileSpec) {
+	fn := goName(p.Function)
+
+	results := make([]string, 0, len(p.Outputs)+1)
+	hasWord := false
+	for _, out := range p.Outputs {
+		results = append(results, out.Name+" "+goType(out.Type))
+		if out.Layout == evm.LayoutWord {
+			hasWord = true
+		}
+	}
+	results = append(results, "err error")
+
+	fmt.Fprintf(b, "// Decode%sOutput parses the %s precompile output\n", fn, p.Name)
+	fmt.Fprintf(b, "func Decode%sOutput(output []byte) (%s) {\n", fn, strings.Join(results, ", "))
+
+	b.WriteString("\toffset := 0\n")
+	if hasWord {
+		b.WriteString("\tvar w []byte\n")
+	}
+
+	for _, out := range p.Outputs {
+		switch out.Layout {
+		case evm.LayoutWord:
+			b.WriteString("\tif w, offset, err = readWord(output, offset); err != nil {\n\t\treturn\n\t}\n")
+			switch out.Type {
+			case "bool":
+				fmt.Fprintf(b, "\t%s = new(big.Int).SetBytes(w).Cmp(common.Big1) == 0\n", out.Name)
+			case "uint256":
+				fmt.Fprintf(b, "\t%s = new(big.Int).SetBytes(w)\n", out.Name)
+			default:
+				fmt.Fprintf(b, "\t%s = w[31]\n", out.Name)
+			}
+		case evm.LayoutBytes:
+			fmt.Fprintf(b, "\tif %s, offset, err = readBytes(output, offset); err != nil {\n\t\treturn\n\t}\n", out.Name)
+		case evm.LayoutRest:
+			fmt.Fprintf(b, "\t%s = output[offset:]\n", out.Name)
+		}
+	}
+
+	b.WriteString("\n\treturn\n}\n\n")
+}
+
+// writeGoCall writes the PQCaller method for a precompile function
+func writeGoCall(b *strings.Builder, p *evm.PrecompileSpec) {
+	fn := goName(p.Function)
+	params := orderedParams(p)
+
+	fmt.Fprintf(b, "// %s calls the %s precompile at %s\n", fn, p.Name, p.Address.Hex())
+	fmt.Fprintf(b, "func (c *PQCaller) %s(opts *bind.CallOpts, %s) %s {\n", fn, goParams(params), goResults(p.Outputs))
+	fmt.Fprintf(b, "\tinput, err := Encode%sInput(%s)\n", fn, goArgs(params))
+	fmt.Fprintf(b, "\tif err != nil {\n\t\t%s\n\t}\n\n", goZeroReturn(p.Outputs))
+	fmt.Fprintf(b, "\toutput, err := c.call(opts, %sAddress, input)\n", goName(p.Name))
+	fmt.Fprintf(b, "\tif err != nil {\n\t\t%s\n\t}\n\n", goZeroReturn(p.Outputs))
+	fmt.Fprintf(b, "\treturn Decode%sOutput(output)\n}\n\n", fn)
+}
+
+// writeGoAlias writes the PQCaller method for an alias
+func writeGoAlias(b *strings.Builder, alias evm.AliasSpec, target *evm.PrecompileSpec) {
+	var (
+		params []evm.ParamSpec
+		args   []string
+	)
+	for _, in := range orderedParams(target) {
+		if expr, fixed := alias.Fixed[in.Name]; fixed {
+			args = append(args, goName(expr))
+			continue
+		}
+		params = append(params, in)
+		args = append(args, in.Name)
+	}
+
+	fn := goName(alias.Function)
+	doc := fmt.Sprintf("calls %s", goName(target.Function))
+	if len(alias.Doc) > 0 {
+		doc = strings.ToLower(alias.Doc[0][:1]) + alias.Doc[0][1:]
+	}
+
+	fmt.Fprintf(b, "// %s %s\n", fn, strings.TrimPrefix(doc, strings.ToLower(fn)+" "))
+	fmt.Fprintf(b, "func (c *PQCaller) %s(opts *bind.CallOpts, %s) %s {\n", fn, goParams(params), goResults(target.Outputs))
+	fmt.Fprintf(b, "\treturn c.%s(opts, %s)\n}\n\n", goName(target.Function), strings.Join(args, ", "))
+}
+
+// generateBindings renders the Go bindings package
+func generateBindings(spec *evm.LibrarySpec, abiJSON []byte) ([]byte, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// %s\n\n", generatedHeader)
+	b.WriteString("// Package bindings provides Go callers for the PQ precompiles that use the\n")
+	b.WriteString("// same input and output layouts as the generated PQ Solidity library.\n")
+	b.WriteString("package bindings\n\n")
+	b.WriteString("import (\n\t\"context\"\n\t\"errors\"\n\t\"math/big\"\n\n")
+	b.WriteString("\tethereum \"github.com/ethereum/go-ethereum\"\n")
+	b.WriteString("\t\"github.com/ethereum/go-ethereum/accounts/abi\"\n")
+	b.WriteString("\t\"github.com/ethereum/go-ethereum/accounts/abi/bind\"\n")
+	b.WriteString("\t\"github.com/ethereum/go-ethereum/common\"\n)\n\n")
+
+	fmt.Fprintf(&b, "// %sABI is the ABI of the %s Solidity library\n", spec.Name, spec.Name)
+	fmt.Fprintf(&b, "const %sABI = `%s`\n\n", spec.Name, strings.TrimSpace(string(abiJSON)))
+
+	b.WriteString("// Library constants\nconst (\n")
+	for _, c := range spec.Constants {
+		typ := ""
+		if c.Type == "uint8" {
+			typ = " uint8"
+		}
+		fmt.Fprintf(&b, "\t%s%s = %s // %s\n", goName(c.Name), typ, c.Value, c.Comment)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// Precompile addresses\nvar (\n")
+	for _, p := range spec.Precompiles {
+		if p.Function == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%sAddress = common.HexToAddress(%q)\n", goName(p.Name), p.Address.Hex())
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// PQCaller calls the PQ precompiles through an eth_call backend\n")
+	b.WriteString("type PQCaller struct {\n\tcontract bind.ContractCaller\n}\n\n")
+	b.WriteString("// NewPQCaller creates a caller bound to backend\n")
+	b.WriteString("func NewPQCaller(backend bind.ContractCaller) *PQCaller {\n\treturn &PQCaller{contract: backend}\n}\n\n")
+
+	for i := range spec.Precompiles {
+		p := &spec.Precompiles[i]
+		if p.Function == "" {
+			continue
+		}
+		writeGoEncoder(&b, p)
+		writeGoDecoder(&b, p)
+		writeGoCall(&b, p)
+	}
+
+	for _, alias := range spec.Aliases {
+		if target, ok := spec.Function(alias.Target); ok {
+			writeGoAlias(&b, alias, target)
+		}
+	}
+
+	b.WriteString(bindingsHelpers)
+
+	return format.Source([]byte(b.String()))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Harness files, relative to the evm package directory. The evm tests run
+// the compiled fixture so they do not need solc.
+var (
+	harnessSourcePath  = filepath.Join("testdata", "PQHarness.sol")
+	harnessFixturePath = filepath.Join("testdata", "PQHarness.json")
+)
+
+// harnessContract exposes the library's internal functions to the evm tests
+const harnessContract = `
+contract PQHarness {
+    function verifyWithAlgo(uint8 algo, bytes memory pubKey, bytes32 msgHash, bytes memory signature) external view returns (bool) {
+        return PQ.verifyWithAlgo(algo, pubKey, msgHash, signature);
+    }
+
+    function shake256(bytes memory data, uint256 outLen) external view returns (bytes memory) {
+        return PQ.shake256(data, outLen);
+    }
+}
+`
+
+// harnessFixture is the compiled harness. SourceHash is keccak256 of the
+// PQHarness.sol it was compiled from, so a stale fixture is detected
+// without solc.
+type harnessFixture struct {
+	Compiler   string        `json:"compiler"`
+	SourceHash string        `json:"sourceHash"`
+	BinRuntime hexutil.Bytes `json:"binRuntime"`
+}
+
+// generateHarness renders the library followed by the harness contract
+func generateHarness(solidity string) []byte {
+	return []byte(solidity + harnessContract)
+}
+
+// compileHarness compiles the harness source with solc into the fixture
+func compileHarness(solc string, source []byte) ([]byte, error) {
+	version, err := exec.Command(solc, "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("solc --version failed: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "pqgen")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "PQHarness.sol")
+	if err := os.WriteFile(src, source, 0o644); err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(solc, "--combined-json", "bin-runtime", src).Output()
+	if err != nil {
+		return nil, fmt.Errorf("solc failed: %w", err)
+	}
+
+	var result struct {
+		Contracts map[string]struct {
+			BinRuntime string `json:"bin-runtime"`
+		} `json:"contracts"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse solc output: %w", err)
+	}
+
+	for name, contract := range result.Contracts {
+		if !strings.HasSuffix(name, ":PQHarness") {
+			continue
+		}
+		fixture, err := json.MarshalIndent(harnessFixture{
+			Compiler:   solcVersion(string(version)),
+			SourceHash: crypto.Keccak256Hash(source).Hex(),
+			BinRuntime: common.FromHex(contract.BinRuntime),
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(fixture, '\n'), nil
+	}
+	return nil, errors.New("PQHarness missing from solc output")
+}
+
+// solcVersion picks the version line out of solc --version
+func solcVersion(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "Version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+		}
+	}
+	return strings.TrimSpace(out)
+}
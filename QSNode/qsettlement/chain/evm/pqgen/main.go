@@ -0,0 +1,119 @@
+// Command pqgen generates the PQ Solidity library, its ABI and Go bindings,
+// and the PQSmartAccount reference contract from evm.PQLibrarySpec. Run it through go generate in the evm package:
+//
+//	go generate ./chain/evm
+//
+// It also writes the library test harness to testdata/PQHarness.sol. With
+// -solc it compiles the harness into testdata/PQHarness.json, which the evm
+// tests execute; rerun with -solc whenever the harness source changes.
+//
+// Gas docs are rendered from config.DefaultPrecompileGas. The package tests
+// verify that the spec matches the precompile registry and that the
+// generated files are up to date.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+)
+
+// generatedHeader marks every output file
+const generatedHeader = "Code generated by pqgen from evm.PQLibrarySpec. DO NOT EDIT."
+
+// output is one generated file, relative to the evm package directory
+type output struct {
+	path string
+	data []byte
+}
+
+// checkRegistry verifies spec against a registry with every precompile
+// enabled, so unsafe ones are still covered by the spec
+func checkRegistry(spec *evm.LibrarySpec) error {
+	cfg := config.DefaultChainConfig()
+	cfg.UnsafeKyberDecEnabled = true
+	return evm.CheckPrecompileSpecs(spec, evm.NewPrecompileRegistryWithConfig(cfg))
+}
+
+// generate renders every output file for spec, documenting gas from g
+func generate(spec *evm.LibrarySpec, g *config.PrecompileGas) ([]output, error) {
+	solidity := generateSolidity(spec, g)
+
+	abiJSON, err := generateABI(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ABI: %w", err)
+	}
+
+	libGo, err := generateLibraryGo(solidity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate library source: %w", err)
+	}
+
+	bindings, err := generateBindings(spec, abiJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Go bindings: %w", err)
+	}
+
+	account, err := generateAccount(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate smart account: %w", err)
+	}
+	accountGo, err := generateAccountGo(account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate smart account source: %w", err)
+	}
+
+	return []output{
+		{"pq_lib_gen.go", libGo},
+		{harnessSourcePath, generateHarness(solidity)},
+		{"pq_account_gen.go", accountGo},
+		{filepath.Join("bindings", "PQ.abi"), abiJSON},
+		{filepath.Join("bindings", "pq_gen.go"), bindings},
+	}, nil
+}
+
+func main() {
+	dir := flag.String("dir", ".", "evm package directory")
+	solc := flag.String("solc", "", "solc binary used to compile the test harness fixture")
+	flag.Parse()
+
+	spec := &evm.PQLibrarySpec
+	if err := checkRegistry(spec); err != nil {
+		log.Fatalf("[pqgen] Spec does not match registry: %v\n", err)
+	}
+
+	outputs, err := generate(spec, config.DefaultPrecompileGas())
+	if err != nil {
+		log.Fatalf("[pqgen] %v\n", err)
+	}
+
+	if *solc != "" {
+		for _, out := range outputs {
+			if out.path != harnessSourcePath {
+				continue
+			}
+			fixture, err := compileHarness(*solc, out.data)
+			if err != nil {
+				log.Fatalf("[pqgen] %v\n", err)
+			}
+			outputs = append(outputs, output{harnessFixturePath, fixture})
+			break
+		}
+	}
+
+	for _, out := range outputs {
+		path := filepath.Join(*dir, out.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Fatalf("[pqgen] Failed to create %s: %v\n", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, out.data, 0o644); err != nil {
+			log.Fatalf("[pqgen] Failed to write %s: %v\n", path, err)
+		}
+		log.Printf("[pqgen] Wrote %s\n", path)
+	}
+}
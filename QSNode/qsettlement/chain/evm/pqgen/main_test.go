@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm/bindings"
+)
+
+// evmDir is the evm package directory holding the generated files
+const evmDir = ".."
+
+func TestSpecMatchesRegistry(t *testing.T) {
+	if err := checkRegistry(&evm.PQLibrarySpec); err != nil {
+		t.Fatalf("spec does not match registry: %v", err)
+	}
+
+	// Dropping any precompile from the spec must be caught
+	for i, p := range evm.PQLibrarySpec.Precompiles {
+		spec := evm.PQLibrarySpec
+		spec.Precompiles = append(append([]evm.PrecompileSpec{}, spec.Precompiles[:i]...), spec.Precompiles[i+1:]...)
+		if err := checkRegistry(&spec); err == nil {
+			t.Errorf("spec without %s passed the registry check", p.Name)
+		}
+	}
+}
+
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	outputs, err := generate(&evm.PQLibrarySpec, config.DefaultPrecompileGas())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, out := range outputs {
+		current, err := os.ReadFile(filepath.Join(evmDir, out.path))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", out.path, err)
+		}
+		if !bytes.Equal(current, out.data) {
+			t.Errorf("%s is out of date; run go generate ./evm", out.path)
+		}
+	}
+}
+
+func TestGeneratedCodeMatchesRegistry(t *testing.T) {
+	cfg := config.DefaultChainConfig()
+	cfg.UnsafeKyberDecEnabled = true
+	registry := evm.NewPrecompileRegistryWithConfig(cfg)
+
+	parsed, err := abi.JSON(strings.NewReader(bindings.PQABI))
+	if err != nil {
+		t.Fatalf("failed to parse generated ABI: %v", err)
+	}
+
+	boundAddrs := map[string]common.Address{
+		"PQ_VERIFY":          bindings.PqVerifyAddress,
+		"KYBER_ENC_REVEALED": bindings.KyberEncRevealedAddress,
+		"PQ_BATCH_VERIFY":    bindings.PqBatchVerifyAddress,
+		"SHAKE128":           bindings.Shake128Address,
+		"SHAKE256":           bindings.Shake256Address,
+		"SLH_DSA_VERIFY":     bindings.SlhDsaVerifyAddress,
+	}
+
+	for _, p := range evm.PQLibrarySpec.Precompiles {
+		if p.Function == "" {
+			continue
+		}
+
+		contract := registry.GetPrecompile(p.Address)
+		if contract == nil || contract.Name() != p.Name {
+			t.Errorf("%s: no matching precompile registered at %s", p.Name, p.Address.Hex())
+			continue
+		}
+
+		if _, ok := parsed.Methods[p.Function]; !ok {
+			t.Errorf("%s: %s missing from the generated ABI", p.Name, p.Function)
+		}
+		if addr, ok := boundAddrs[p.Name]; !ok || addr != p.Address {
+			t.Errorf("%s: bindings address %s, want %s", p.Name, addr.Hex(), p.Address.Hex())
+		}
+		if !strings.Contains(evm.PQLibSolidity, "address constant "+p.Constant+" = "+p.Address.Hex()+";") {
+			t.Errorf("%s: Solidity library lacks %s = %s", p.Name, p.Constant, p.Address.Hex())
+		}
+	}
+
+	for _, alias := range evm.PQLibrarySpec.Aliases {
+		if _, ok := parsed.Methods[alias.Function]; !ok {
+			t.Errorf("alias %s missing from the generated ABI", alias.Function)
+		}
+	}
+
+	if len(parsed.Methods) != len(boundAddrs)+len(evm.PQLibrarySpec.Aliases) {
+		t.Errorf("generated ABI has %d methods, want %d", len(parsed.Methods), len(boundAddrs)+len(evm.PQLibrarySpec.Aliases))
+	}
+}
+
+func TestGasDocsFollowSchedule(t *testing.T) {
+	g := config.DefaultPrecompileGas()
+	g.PqVerifyAlgo[0x01] = 3111
+	g.PqBatchBase = 1222
+	g.KyberEnc = 4333
+	g.Shake256PerWord = 44
+
+	solidity := generateSolidity(&evm.PQLibrarySpec, g)
+	for _, want := range []string{
+		"Gas: algorithm base (Dilithium2 3111 ",
+		"Gas: 1222 + ceil(n^(3/4))",
+		"Gas: flat 4333",
+		"per word of input and output",
+		" + 44 per word",
+	} {
+		if !strings.Contains(solidity, want) {
+			t.Errorf("generated library lacks %q", want)
+		}
+	}
+
+	// Every exposed function documents its gas
+	for _, p := range evm.PQLibrarySpec.Precompiles {
+		if p.Function != "" && p.Gas == nil {
+			t.Errorf("%s has no gas doc", p.Name)
+		}
+	}
+}
+
+func TestAccountValidationDoesNotRevert(t *testing.T) {
+	account, err := generateAccount(&evm.PQLibrarySpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// validateUserOp and the helpers it calls must report a bad signature
+	// as SIG_VALIDATION_FAILED rather than revert
+	start := strings.Index(account, "function validateUserOp(")
+	end := strings.Index(account[start:], "function execute(")
+	if start < 0 || end < 0 {
+		t.Fatal("validateUserOp not found")
+	}
+	validate := account[start : start+end]
+	helpers := account[strings.Index(account, "function _decodeSignature("):]
+
+	for _, body := range []string{validate, helpers} {
+		for _, reverting := range []string{"require(", "revert", "abi.decode(userOp", "PQ.verify", "_call("} {
+			if strings.Contains(solidityCode(body), reverting) {
+				t.Errorf("signature check contains %q", reverting)
+			}
+		}
+	}
+	if !strings.Contains(validate, "validationData = SIG_VALIDATION_FAILED;") {
+		t.Error("validateUserOp does not return SIG_VALIDATION_FAILED")
+	}
+
+	// The PqVerify call is built from the same spec as the library's
+	verify, _ := evm.PQLibrarySpec.Function("verifyWithAlgo")
+	var input strings.Builder
+	writeInput(&input, verify)
+	if !strings.Contains(helpers, input.String()) || !strings.Contains(evm.PQLibSolidity, input.String()) {
+		t.Error("account and library build different PqVerify inputs")
+	}
+	if !strings.Contains(account, "address internal constant "+verify.Constant+" = "+verify.Address.Hex()+";") {
+		t.Errorf("account lacks %s = %s", verify.Constant, verify.Address.Hex())
+	}
+}
+
+// solidityCode drops comment lines from Solidity source
+func solidityCode(src string) string {
+	var code []string
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		code = append(code, line)
+	}
+	return strings.Join(code, "\n")
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/evm"
+)
+
+// solidityHelpers are the private call/decode helpers shared by every
+// generated library function
+const solidityHelpers = `
+    function _call(address precompile, bytes memory input) private view returns (bytes memory output) {
+        bool success;
+        (success, output) = precompile.staticcall(input);
+        require(success, "PQ: precompile call failed");
+    }
+
+    function _readWord(bytes memory data, uint256 offset) private pure returns (uint256 word) {
+        require(data.length >= offset + 32, "PQ: output too short");
+        assembly {
+            word := mload(add(add(data, 0x20), offset))
+        }
+    }
+
+    function _readBytes(bytes memory data, uint256 offset)
+        private
+        pure
+        returns (bytes memory out, uint256 next)
+    {
+        uint256 len = _readWord(data, offset);
+        out = _slice(data, offset + 32, len);
+        next = offset + 32 + len;
+    }
+
+    function _slice(bytes memory data, uint256 start, uint256 len) private pure returns (bytes memory out) {
+        require(data.length >= start + len, "PQ: output too short");
+        out = new bytes(len);
+        for (uint256 i = 0; i < len; i++) {
+            out[i] = data[start + i];
+        }
+    }
+`
+
+// solidityDecl returns a parameter declaration with its data location
+func solidityDecl(p evm.ParamSpec) string {
+	switch p.Type {
+	case "bytes", "bytes[]", "bytes32[]":
+		return p.Type + " memory " + p.Name
+	default:
+		return p.Type + " " + p.Name
+	}
+}
+
+// orderedParams returns the function parameters in signature order
+func orderedParams(p *evm.PrecompileSpec) []evm.ParamSpec {
+	if p.Params == nil {
+		return p.Inputs
+	}
+
+	byName := make(map[string]evm.ParamSpec, len(p.Inputs))
+	for _, in := range p.Inputs {
+		byName[in.Name] = in
+	}
+
+	params := make([]evm.ParamSpec, 0, len(p.Params))
+	for _, name := range p.Params {
+		params = append(params, byName[name])
+	}
+	return params
+}
+
+// writeDoc writes a NatSpec block
+func writeDoc(b *strings.Builder, lines []string, gas string, params, returns []evm.ParamSpec) {
+	b.WriteString("    /**\n")
+	for _, line := range lines {
+		fmt.Fprintf(b, "     * %s\n", line)
+	}
+	if gas != "" {
+		fmt.Fprintf(b, "     * Gas: %s\n", gas)
+	}
+	for _, p := range params {
+		fmt.Fprintf(b, "     * @param %s %s\n", p.Name, p.Doc)
+	}
+	for _, p := range returns {
+		fmt.Fprintf(b, "     * @return %s %s\n", p.Name, p.Doc)
+	}
+	b.WriteString("     */\n")
+}
+
+// writeSignature writes the function header up to the opening brace
+func writeSignature(b *strings.Builder, name string, params, returns []evm.ParamSpec) {
+	fmt.Fprintf(b, "    function %s(\n", name)
+	for i, p := range params {
+		sep := ","
+		if i == len(params)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(b, "        %s%s\n", solidityDecl(p), sep)
+	}
+
+	decls := make([]string, len(returns))
+	for i, r := range returns {
+		decls[i] = solidityDecl(r)
+	}
+	fmt.Fprintf(b, "    ) internal view returns (%s) {\n", strings.Join(decls, ", "))
+}
+
+// packedParts returns the abi.encodePacked arguments for a non-batch input
+func packedParts(p *evm.PrecompileSpec, in evm.ParamSpec) []string {
+	switch in.Layout {
+	case evm.LayoutHeader:
+		return []string{fmt.Sprintf("(uint256(0x%02x) << 8) | uint256(%s)", p.Mode, in.Name)}
+	case evm.LayoutBytes:
+		if in.Type == "bytes32" {
+			return []string{"uint256(32)", in.Name}
+		}
+		return []string{fmt.Sprintf("uint256(%s.length)", in.Name), in.Name}
+	case evm.LayoutWord:
+		return []string{fmt.Sprintf("uint256(%s)", in.Name)}
+	default: // LayoutFixed, LayoutRest
+		return []string{in.Name}
+	}
+}
+
+// writeParts writes an abi.encodePacked(...) argument list
+func writeParts(b *strings.Builder, indent string, parts []string) {
+	for i, part := range parts {
+		sep := ","
+		if i == len(parts)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(b, "%s%s%s\n", indent, part, sep)
+	}
+}
+
+// writeInput writes the statements building the precompile input
+func writeInput(b *strings.Builder, p *evm.PrecompileSpec) {
+	var (
+		parts []string
+		abi   []string
+		batch []evm.ParamSpec
+	)
+	for _, in := range p.Inputs {
+		switch in.Layout {
+		case evm.LayoutABI:
+			if in.Type == "bytes32" {
+				abi = append(abi, fmt.Sprintf("abi.encodePacked(%s)", in.Name))
+			} else {
+				abi = append(abi, in.Name)
+			}
+		case evm.LayoutBatch:
+			batch = append(batch, in)
+		default:
+			parts = append(parts, packedParts(p, in)...)
+		}
+	}
+
+	if len(abi) > 0 {
+		parts = append(parts, fmt.Sprintf("abi.encode(%s)", strings.Join(abi, ", ")))
+	}
+
+	if len(batch) > 0 {
+		first := batch[0].Name
+		if len(batch) > 1 {
+			conds := make([]string, 0, len(batch)-1)
+			for _, in := range batch[1:] {
+				conds = append(conds, fmt.Sprintf("%s.length == %s.length", first, in.Name))
+			}
+			fmt.Fprintf(b, "        require(\n            %s,\n            \"PQ: batch length mismatch\"\n        );\n\n",
+				strings.Join(conds, " && "))
+		}
+		parts = append(parts, fmt.Sprintf("uint256(%s.length)", first))
+	}
+
+	b.WriteString("        bytes memory input = abi.encodePacked(\n")
+	writeParts(b, "            ", parts)
+	b.WriteString("        );\n")
+
+	if len(batch) > 0 {
+		fmt.Fprintf(b, "        for (uint256 i = 0; i < %s.length; i++) {\n", batch[0].Name)
+		elems := []string{"input"}
+		for _, in := range batch {
+			elem := in.Name + "[i]"
+			if in.Type == "bytes32[]" {
+				elems = append(elems, "uint256(32)", elem)
+			} else {
+				elems = append(elems, fmt.Sprintf("uint256(%s.length)", elem), elem)
+			}
+		}
+		b.WriteString("            input = abi.encodePacked(\n")
+		writeParts(b, "                ", elems)
+		b.WriteString("            );\n")
+		b.WriteString("        }\n")
+	}
+}
+
+// writeOutput writes the statements decoding the precompile output
+func writeOutput(b *strings.Builder, p *evm.PrecompileSpec) {
+	fmt.Fprintf(b, "        bytes memory output = _call(%s, input);\n", p.Constant)
+
+	needOffset := len(p.Outputs) > 1
+	if needOffset {
+		b.WriteString("        uint256 offset = 0;\n")
+	}
+	offset := "0"
+	if needOffset {
+		offset = "offset"
+	}
+
+	for i, out := range p.Outputs {
+		last := i == len(p.Outputs)-1
+		switch out.Layout {
+		case evm.LayoutWord:
+			expr := fmt.Sprintf("_readWord(output, %s)", offset)
+			switch out.Type {
+			case "bool":
+				expr += " == 1"
+			case "uint8":
+				expr = fmt.Sprintf("uint8(%s)", expr)
+			}
+			fmt.Fprintf(b, "        %s = %s;\n", out.Name, expr)
+			if !last {
+				b.WriteString("        offset += 32;\n")
+			}
+		case evm.LayoutBytes:
+			if last {
+				fmt.Fprintf(b, "        (%s, ) = _readBytes(output, %s);\n", out.Name, offset)
+			} else {
+				fmt.Fprintf(b, "        (%s, offset) = _readBytes(output, %s);\n", out.Name, offset)
+			}
+		case evm.LayoutRest:
+			if offset == "0" {
+				fmt.Fprintf(b, "        %s = output;\n", out.Name)
+			} else {
+				fmt.Fprintf(b, "        %s = _slice(output, offset, output.length - offset);\n", out.Name)
+			}
+		}
+	}
+}
+
+// generateSolidity renders the Solidity library, documenting gas from g
+func generateSolidity(spec *evm.LibrarySpec, g *config.PrecompileGas) string {
+	var b strings.Builder
+
+	b.WriteString("// SPDX-License-Identifier: MIT\n")
+	fmt.Fprintf(&b, "// %s\n", generatedHeader)
+	b.WriteString("pragma solidity ^0.8.0;\n\n")
+
+	b.WriteString("/**\n")
+	b.WriteString(" * PQ Library - Post-Quantum Cryptographic Operations\n")
+	b.WriteString(" *\n")
+	b.WriteString(" * Provides Solidity interface to PQ precompiles:\n")
+	for _, p := range spec.Precompiles {
+		if p.Function == "" {
+			continue
+		}
+		fmt.Fprintf(&b, " * - 0x%04x: %s (%s)\n", p.Address.Big(), p.Name, p.Function)
+	}
+	b.WriteString(" */\n")
+	fmt.Fprintf(&b, "library %s {\n", spec.Name)
+
+	b.WriteString("    // PQ precompile addresses\n")
+	for _, p := range spec.Precompiles {
+		if p.Function == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "    address constant %s = %s;\n", p.Constant, p.Address.Hex())
+	}
+	b.WriteString("\n")
+
+	for _, c := range spec.Constants {
+		fmt.Fprintf(&b, "    %s constant %s = %s; // %s\n", c.Type, c.Name, c.Value, c.Comment)
+	}
+
+	for i := range spec.Precompiles {
+		p := &spec.Precompiles[i]
+		if p.Function == "" {
+			continue
+		}
+
+		params := orderedParams(p)
+
+		b.WriteString("\n")
+		gas := ""
+		if p.Gas != nil {
+			gas = p.Gas(g)
+		}
+		writeDoc(&b, p.Doc, gas, params, p.Outputs)
+		writeSignature(&b, p.Function, params, p.Outputs)
+		writeInput(&b, p)
+		b.WriteString("\n")
+		writeOutput(&b, p)
+		b.WriteString("    }\n")
+	}
+
+	for _, alias := range spec.Aliases {
+		target, ok := spec.Function(alias.Target)
+		if !ok {
+			continue
+		}
+
+		var (
+			params []evm.ParamSpec
+			args   []string
+		)
+		for _, in := range orderedParams(target) {
+			if expr, fixed := alias.Fixed[in.Name]; fixed {
+				args = append(args, expr)
+				continue
+			}
+			params = append(params, in)
+			args = append(args, in.Name)
+		}
+
+		b.WriteString("\n")
+		writeDoc(&b, alias.Doc, "", params, target.Outputs)
+		writeSignature(&b, alias.Function, params, target.Outputs)
+		fmt.Fprintf(&b, "        return %s(%s);\n", target.Function, strings.Join(args, ", "))
+		b.WriteString("    }\n")
+	}
+
+	b.WriteString(solidityHelpers)
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// generateLibraryGo wraps the Solidity source in the evm package constant
+func generateLibraryGo(solidity string) ([]byte, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// %s\n\n", generatedHeader)
+	b.WriteString("package evm\n\n")
+	b.WriteString("// PQLibSolidity provides the Solidity library code for PQ operations\n")
+	b.WriteString("const PQLibSolidity = `\n")
+	b.WriteString(solidity)
+	b.WriteString("`\n")
+
+	return format.Source([]byte(b.String()))
+}
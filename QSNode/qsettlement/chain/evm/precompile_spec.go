@@ -0,0 +1,336 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+//go:generate go run ./pqgen
+
+// The Solidity library (PQLibSolidity), its ABI and the Go bindings in
+// ./bindings are generated from PQLibrarySpec by ./pqgen. Change the spec
+// below, not the generated files, when a precompile's format changes.
+
+// FieldLayout describes how a parameter is laid out in precompile input or output
+type FieldLayout uint8
+
+const (
+	// LayoutWord is a 32-byte big-endian word (uint8, uint256, bool)
+	LayoutWord FieldLayout = iota
+	// LayoutHeader is a PqVerify-style header word: spec Mode << 8 | value
+	LayoutHeader
+	// LayoutBytes is [len(32)][data]; bytes32 values are written with len 32
+	LayoutBytes
+	// LayoutFixed is a bytes32 value written as-is
+	LayoutFixed
+	// LayoutRest is unprefixed data running to the end (last field only)
+	LayoutRest
+	// LayoutABI fields form a trailing abi.encode(bytes...) body
+	LayoutABI
+	// LayoutBatch fields are parallel arrays: [count(32)] followed by each
+	// index's elements as LayoutBytes
+	LayoutBatch
+)
+
+// ParamSpec is one input or output parameter
+type ParamSpec struct {
+	Name   string      // Solidity/Go parameter name
+	Type   string      // Solidity type: uint8, uint256, bool, bytes, bytes32, bytes[], bytes32[]
+	Layout FieldLayout // Encoding in the precompile input/output
+	Doc    string      // @param / @return text
+}
+
+// PrecompileSpec describes one precompile's calling convention
+type PrecompileSpec struct {
+	Name     string         // Name() reported by the precompile
+	Address  common.Address // Precompile address
+	Constant string         // Solidity address constant name
+	Function string         // Solidity library function; empty = not exposed
+	Doc      []string       // Function doc lines
+	Mode     uint8          // Input mode placed in bits 8-15 of a LayoutHeader word
+	Inputs   []ParamSpec    // In input order
+	Params   []string       // Function parameter order if it differs from Inputs
+	Outputs  []ParamSpec    // In output order
+	Gas      GasDocFunc     // Gas schedule summary
+}
+
+// GasDocFunc renders a precompile's gas summary from a gas schedule, so the
+// generated docs follow config.DefaultPrecompileGas
+type GasDocFunc func(g *config.PrecompileGas) string
+
+// pqVerifyGasDoc summarises the PqVerify schedule shared with SlhDsaVerify
+func pqVerifyGasDoc(g *config.PrecompileGas) string {
+	return fmt.Sprintf("algorithm base (Dilithium2 %d ... SLH-DSA %d) + %d per message word",
+		g.PqVerifyAlgo[tx.SigAlgoDilithium2],
+		max(g.PqVerifyAlgo[tx.SigAlgoSLHDSASHA2128s], g.PqVerifyAlgo[tx.SigAlgoSLHDSASHAKE128s]),
+		g.PqVerifyPerMsgWord)
+}
+
+// AliasSpec is a library function that calls a precompile function with some
+// inputs fixed to constants
+type AliasSpec struct {
+	Function string
+	Target   string            // PrecompileSpec.Function being wrapped
+	Fixed    map[string]string // Input name -> Solidity expression
+	Doc      []string
+}
+
+// ConstantSpec is a Solidity library constant
+type ConstantSpec struct {
+	Name    string
+	Type    string
+	Value   string
+	Comment string
+}
+
+// LibrarySpec is the complete input to the code generator
+type LibrarySpec struct {
+	Name        string
+	Constants   []ConstantSpec
+	Precompiles []PrecompileSpec
+	Aliases     []AliasSpec
+}
+
+// Function returns the spec exposed as the named library function
+func (l *LibrarySpec) Function(name string) (*PrecompileSpec, bool) {
+	for i := range l.Precompiles {
+		if l.Precompiles[i].Function == name {
+			return &l.Precompiles[i], true
+		}
+	}
+	return nil, false
+}
+
+// PQLibrarySpec is the single source of truth for the PQ precompile calling
+// conventions
+var PQLibrarySpec = LibrarySpec{
+	Name: "PQ",
+	Constants: []ConstantSpec{
+		{Name: "PQ_VERIFY_MODE_ABI", Type: "uint256", Value: "0x01", Comment: "PqVerify input mode for abi.encode(bytes, bytes, bytes) bodies"},
+		{Name: "ALGO_DILITHIUM2", Type: "uint8", Value: "0x01", Comment: "ML-DSA-44"},
+		{Name: "ALGO_DILITHIUM3", Type: "uint8", Value: "0x02", Comment: "ML-DSA-65"},
+		{Name: "ALGO_DILITHIUM5", Type: "uint8", Value: "0x03", Comment: "ML-DSA-87"},
+		{Name: "ALGO_SLHDSA_SHA2_128S", Type: "uint8", Value: "0x10", Comment: "SLH-DSA-SHA2-128s"},
+		{Name: "ALGO_SLHDSA_SHAKE_128S", Type: "uint8", Value: "0x11", Comment: "SLH-DSA-SHAKE-128s"},
+	},
+	Precompiles: []PrecompileSpec{
+		{
+			Name:     "PQ_VERIFY",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000101"),
+			Constant: "PQ_VERIFY_ADDR",
+			Function: "verifyWithAlgo",
+			Doc: []string{
+				"Verify a PQ signature with an explicit algorithm",
+				"Reverts if the algorithm is not enabled or the key/signature sizes",
+				"do not match it; returns false only for a wrong signature.",
+			},
+			Mode: 0x01,
+			Inputs: []ParamSpec{
+				{Name: "algo", Type: "uint8", Layout: LayoutHeader, Doc: "The PQSigAlgo ID"},
+				{Name: "pubKey", Type: "bytes", Layout: LayoutABI, Doc: "The public key"},
+				{Name: "msgHash", Type: "bytes32", Layout: LayoutABI, Doc: "The message hash"},
+				{Name: "signature", Type: "bytes", Layout: LayoutABI, Doc: "The signature"},
+			},
+			Outputs: []ParamSpec{
+				{Name: "valid", Type: "bool", Layout: LayoutWord, Doc: "True if signature is valid"},
+			},
+			Gas: pqVerifyGasDoc,
+		},
+		{
+			Name:     "KYBER_ENC_REVEALED",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000102"),
+			Constant: "KYBER_ENC_REVEALED_ADDR",
+			Function: "encapsulateRevealed",
+			Doc: []string{
+				"Recompute a Kyber768 (ML-KEM-768) encapsulation from a revealed seed",
+				"The seed and therefore the shared secret are public. Use this only",
+				"to check an off-chain encapsulation whose seed has been disclosed,",
+				"never to derive a secret.",
+			},
+			Inputs: []ParamSpec{
+				{Name: "pubKey", Type: "bytes", Layout: LayoutBytes, Doc: "The Kyber public key (1184 bytes)"},
+				{Name: "seed", Type: "bytes32", Layout: LayoutFixed, Doc: "The revealed 32-byte encapsulation seed"},
+			},
+			Outputs: []ParamSpec{
+				{Name: "ciphertext", Type: "bytes", Layout: LayoutBytes, Doc: "The encapsulated ciphertext"},
+				{Name: "sharedSecret", Type: "bytes", Layout: LayoutBytes, Doc: "The derived shared secret"},
+			},
+			Gas: func(g *config.PrecompileGas) string { return fmt.Sprintf("flat %d", g.KyberEnc) },
+		},
+		{
+			// Not exposed: decapsulation needs the private key in calldata
+			Name:     "KYBER_DEC",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000103"),
+			Constant: "",
+			Inputs: []ParamSpec{
+				{Name: "privKey", Type: "bytes", Layout: LayoutBytes},
+				{Name: "ciphertext", Type: "bytes", Layout: LayoutBytes},
+			},
+			Outputs: []ParamSpec{
+				{Name: "sharedSecret", Type: "bytes", Layout: LayoutBytes},
+			},
+			Gas: func(g *config.PrecompileGas) string { return fmt.Sprintf("flat %d", g.KyberDec) },
+		},
+		{
+			Name:     "PQ_BATCH_VERIFY",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000104"),
+			Constant: "PQ_BATCH_VERIFY_ADDR",
+			Function: "verifyBatch",
+			Doc: []string{
+				"Verify up to 256 signatures of one algorithm in a single call",
+			},
+			Inputs: []ParamSpec{
+				{Name: "algo", Type: "uint8", Layout: LayoutWord, Doc: "The PQSigAlgo ID shared by all signatures"},
+				{Name: "pubKeys", Type: "bytes[]", Layout: LayoutBatch, Doc: "The public keys"},
+				{Name: "msgHashes", Type: "bytes32[]", Layout: LayoutBatch, Doc: "The signed message hashes"},
+				{Name: "signatures", Type: "bytes[]", Layout: LayoutBatch, Doc: "The signatures"},
+			},
+			Outputs: []ParamSpec{
+				{Name: "allValid", Type: "bool", Layout: LayoutWord, Doc: "True if every signature is valid"},
+				{Name: "bitmap", Type: "uint256", Layout: LayoutWord, Doc: "Bit i is set if signature i is valid"},
+			},
+			Gas: func(g *config.PrecompileGas) string {
+				return fmt.Sprintf("%d + ceil(n^(3/4)) x the PqVerify price for n signatures", g.PqBatchBase)
+			},
+		},
+		{
+			Name:     "SHAKE128",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000105"),
+			Constant: "SHAKE128_ADDR",
+			Function: "shake128",
+			Doc:      []string{"SHAKE128 with arbitrary output length"},
+			Inputs: []ParamSpec{
+				{Name: "outLen", Type: "uint256", Layout: LayoutWord, Doc: "The number of output bytes (1-16384)"},
+				{Name: "data", Type: "bytes", Layout: LayoutRest, Doc: "The input to absorb"},
+			},
+			Params: []string{"data", "outLen"},
+			Outputs: []ParamSpec{
+				{Name: "digest", Type: "bytes", Layout: LayoutRest, Doc: "The SHAKE128 output"},
+			},
+			Gas: func(g *config.PrecompileGas) string {
+				return fmt.Sprintf("%d + %d per word of input and output", g.ShakeBase, g.Shake128PerWord)
+			},
+		},
+		{
+			Name:     "SHAKE256",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000106"),
+			Constant: "SHAKE256_ADDR",
+			Function: "shake256",
+			Doc:      []string{"SHAKE256 with arbitrary output length"},
+			Inputs: []ParamSpec{
+				{Name: "outLen", Type: "uint256", Layout: LayoutWord, Doc: "The number of output bytes (1-16384)"},
+				{Name: "data", Type: "bytes", Layout: LayoutRest, Doc: "The input to absorb"},
+			},
+			Params: []string{"data", "outLen"},
+			Outputs: []ParamSpec{
+				{Name: "digest", Type: "bytes", Layout: LayoutRest, Doc: "The SHAKE256 output"},
+			},
+			Gas: func(g *config.PrecompileGas) string {
+				return fmt.Sprintf("%d + %d per word of input and output", g.ShakeBase, g.Shake256PerWord)
+			},
+		},
+		{
+			Name:     "SLH_DSA_VERIFY",
+			Address:  common.HexToAddress("0x0000000000000000000000000000000000000107"),
+			Constant: "SLH_DSA_VERIFY_ADDR",
+			Function: "verifySlhDsa",
+			Doc:      []string{"Verify an SLH-DSA (SPHINCS+) signature"},
+			Mode:     0x01,
+			Inputs: []ParamSpec{
+				{Name: "paramSet", Type: "uint8", Layout: LayoutHeader, Doc: "ALGO_SLHDSA_SHA2_128S or ALGO_SLHDSA_SHAKE_128S"},
+				{Name: "pubKey", Type: "bytes", Layout: LayoutABI, Doc: "The 32-byte SLH-DSA public key"},
+				{Name: "msgHash", Type: "bytes32", Layout: LayoutABI, Doc: "The message hash"},
+				{Name: "signature", Type: "bytes", Layout: LayoutABI, Doc: "The signature"},
+			},
+			Outputs: []ParamSpec{
+				{Name: "valid", Type: "bool", Layout: LayoutWord, Doc: "True if signature is valid"},
+			},
+			Gas: func(g *config.PrecompileGas) string {
+				return fmt.Sprintf("SHA2-128s %d, SHAKE-128s %d + %d per message word",
+					g.PqVerifyAlgo[tx.SigAlgoSLHDSASHA2128s], g.PqVerifyAlgo[tx.SigAlgoSLHDSASHAKE128s], g.PqVerifyPerMsgWord)
+			},
+		},
+	},
+	Aliases: []AliasSpec{
+		{
+			Function: "verify",
+			Target:   "verifyWithAlgo",
+			Fixed:    map[string]string{"algo": "ALGO_DILITHIUM2"},
+			Doc:      []string{"Verify a Dilithium2 (ML-DSA-44) signature"},
+		},
+	},
+}
+
+// CheckPrecompileSpecs verifies that the library spec and the registry agree:
+// every registered precompile has a spec with the same address and name,
+// and every exposed spec function has a registered precompile.
+func CheckPrecompileSpecs(spec *LibrarySpec, registry *PrecompileRegistry) error {
+	byAddr := make(map[common.Address]*PrecompileSpec, len(spec.Precompiles))
+	for i := range spec.Precompiles {
+		p := &spec.Precompiles[i]
+		if _, dup := byAddr[p.Address]; dup {
+			return fmt.Errorf("duplicate spec for %s", p.Address.Hex())
+		}
+		byAddr[p.Address] = p
+	}
+
+	for addr, contract := range registry.GetAllPrecompiles() {
+		p, ok := byAddr[addr]
+		if !ok {
+			return fmt.Errorf("precompile %s (%s) has no spec", contract.Name(), addr.Hex())
+		}
+		if p.Name != contract.Name() {
+			return fmt.Errorf("spec %s does not match precompile %s at %s", p.Name, contract.Name(), addr.Hex())
+		}
+	}
+
+	for _, p := range spec.Precompiles {
+		if p.Function == "" {
+			continue
+		}
+		if !registry.IsPrecompile(p.Address) {
+			return fmt.Errorf("library function %s targets unregistered precompile %s", p.Function, p.Address.Hex())
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("spec %s: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the layout ordering rules the generator relies on
+func (p *PrecompileSpec) validate() error {
+	var sawABI, sawBatch, sawRest bool
+	for _, in := range p.Inputs {
+		if sawRest {
+			return errors.New("LayoutRest must be the last input")
+		}
+		switch in.Layout {
+		case LayoutABI:
+			sawABI = true
+		case LayoutBatch:
+			sawBatch = true
+		case LayoutRest:
+			sawRest = true
+		default:
+			if sawABI || sawBatch {
+				return fmt.Errorf("input %s follows an ABI/batch body", in.Name)
+			}
+		}
+	}
+	if sawABI && sawBatch {
+		return errors.New("ABI and batch bodies cannot be combined")
+	}
+
+	if p.Params != nil && len(p.Params) != len(p.Inputs) {
+		return errors.New("Params must list every input")
+	}
+
+	return nil
+}
@@ -1,35 +1,41 @@
 package evm
 
 import (
-	"bytes"
-	"crypto/sha3"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"log"
-	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/vm"
 
-	// Import Dilithium & Kyber from QSmart (path adjusted)
-	// "github.com/mchawda/qsmart/pqcrypto/dilithium"
-	// "github.com/mchawda/qsmart/pqcrypto/kyber"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
 )
 
 // PQPrecompiles registers post-quantum cryptographic precompiles for the EVM
 type PQPrecompiles struct {
-	pqVerify   *PqVerifyPrecompile
-	kyberEnc   *KyberEncPrecompile
-	kyberDec   *KyberDecPrecompile
+	pqVerify         *PqVerifyPrecompile
+	kyberEncRevealed *KyberEncRevealedPrecompile
+	kyberDec         *KyberDecPrecompile
+	pqBatchVerify    *PqBatchVerifyPrecompile
+	shake128         *Shake128Precompile
+	shake256         *Shake256Precompile
+	slhDsaVerify     *SlhDsaVerifyPrecompile
 }
 
 // NewPQPrecompiles creates new PQ precompile instances
 func NewPQPrecompiles() *PQPrecompiles {
 	return &PQPrecompiles{
-		pqVerify: &PqVerifyPrecompile{},
-		kyberEnc: &KyberEncPrecompile{},
-		kyberDec: &KyberDecPrecompile{},
+		pqVerify:         &PqVerifyPrecompile{},
+		kyberEncRevealed: &KyberEncRevealedPrecompile{},
+		kyberDec:         &KyberDecPrecompile{},
+
+		pqBatchVerify: &PqBatchVerifyPrecompile{},
+		shake128:      &Shake128Precompile{},
+		shake256:      &Shake256Precompile{},
+		slhDsaVerify:  &SlhDsaVerifyPrecompile{},
 	}
 }
 
@@ -37,274 +43,572 @@ func NewPQPrecompiles() *PQPrecompiles {
 // PqVerify Precompile (0x0101)
 // ─────────────────────────────────────────────────────────────────────────
 
-// PqVerifyPrecompile implements Dilithium2 signature verification
+// PqVerifyPrecompile implements PQ signature verification for the algorithms
+// enabled in the chain config's PQSigAlgo set (Dilithium/ML-DSA levels, SLH-DSA)
 // Address: 0x0000000000000000000000000000000000000101
-// Input:  [pubkey_len(32)][pubkey][msg_len(32)][msg][sig_len(32)][sig]
+// Input:  [header(32)][body]
+//   header: uint256 = mode << 8 | algo
+//   mode 0 (packed): body = [pubkey_len(32)][pubkey][msg_len(32)][msg][sig_len(32)][sig]
+//   mode 1 (ABI):    body = abi.encode(bytes pubkey, bytes msg, bytes sig)
 // Output: [verified(32)] where verified = 1 for true, 0 for false
-type PqVerifyPrecompile struct{}
+//
+// Well-formed input with a bad signature returns 0. Malformed input (unknown
+// or disabled algorithm, key/signature sizes that do not match the algorithm,
+// truncated fields, length words with non-zero high bytes) is an error, so the
+// call fails and consumes its gas instead of returning a result.
+type PqVerifyPrecompile struct {
+	gas   *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+	algos *tx.SigAlgoRegistry   // nil = algorithms of config.DefaultChainConfig()
+}
+
+// PqVerify input modes (second-lowest byte of the header word)
+const (
+	PqVerifyModePacked uint8 = 0x00
+	PqVerifyModeABI    uint8 = 0x01
+)
+
+// MaxPqVerifyMsgLen bounds the message so RequiredGas stays bounded
+const MaxPqVerifyMsgLen = 1 << 16
+
+// pqVerifyAlgos lists the PQSigAlgo IDs PqVerify accepts. Multisig (0x20) is
+// deliberately absent: its cost depends on the key set.
+var pqVerifyAlgos = map[uint8]bool{
+	tx.SigAlgoDilithium2:      true,
+	tx.SigAlgoDilithium3:      true,
+	tx.SigAlgoDilithium5:      true,
+	tx.SigAlgoFalcon512:       true,
+	tx.SigAlgoFalcon1024:      true,
+	tx.SigAlgoSLHDSASHA2128s:  true,
+	tx.SigAlgoSLHDSASHAKE128s: true,
+}
+
+// defaultPrecompileGas prices zero-value precompiles (PqVerifyPrecompile{})
+var defaultPrecompileGas = config.DefaultPrecompileGas()
+
+// gasSchedule returns g, or the default schedule when g is nil
+func gasSchedule(g *config.PrecompileGas) *config.PrecompileGas {
+	if g == nil {
+		return defaultPrecompileGas
+	}
+	return g
+}
+
+// defaultSigAlgos verifies for zero-value precompiles. Precompiles never use
+// the process-wide tx.SigAlgos(): the accepted algorithms are consensus rules
+// and must come from the chain config the precompile was built for.
+var defaultSigAlgos = tx.NewSigAlgoRegistry(config.DefaultChainConfig())
+
+// sigAlgoRegistry returns r, or the default-config registry when r is nil
+func sigAlgoRegistry(r *tx.SigAlgoRegistry) *tx.SigAlgoRegistry {
+	if r == nil {
+		return defaultSigAlgos
+	}
+	return r
+}
+
+// pqVerifyBaseGas returns the per-verification price of algo
+func pqVerifyBaseGas(g *config.PrecompileGas, algo uint8) uint64 {
+	if baseGas, exists := g.PqVerifyAlgo[algo]; exists {
+		return baseGas
+	}
+	return g.PqVerifyFallback
+}
+
+var (
+	// ErrPqVerifyMalformed is returned for input that does not follow the layout
+	ErrPqVerifyMalformed = errors.New("malformed PqVerify input")
+	// ErrPqVerifyAlgo is returned for unknown, disabled or unsupported algorithms
+	ErrPqVerifyAlgo = errors.New("unsupported PqVerify algorithm")
+)
+
+// pqVerifyInput is a parsed PqVerify call
+type pqVerifyInput struct {
+	algo   uint8
+	pubKey []byte
+	msg    []byte
+	sig    []byte
+}
+
+// parsePqVerifyInput decodes either input mode
+func parsePqVerifyInput(input []byte) (*pqVerifyInput, error) {
+	header, err := readUint64Word(input, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrPqVerifyMalformed, err)
+	}
+	if header > 0xffff {
+		return nil, fmt.Errorf("%w: header 0x%x out of range", ErrPqVerifyMalformed, header)
+	}
+
+	parsed := &pqVerifyInput{algo: uint8(header)}
+	if !pqVerifyAlgos[parsed.algo] {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrPqVerifyAlgo, parsed.algo)
+	}
+
+	body := input[32:]
+	switch mode := uint8(header >> 8); mode {
+	case PqVerifyModePacked:
+		var offset uint64
+		fields := []*[]byte{&parsed.pubKey, &parsed.msg, &parsed.sig}
+		for _, field := range fields {
+			if *field, offset, err = readLengthPrefixed(body, offset); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrPqVerifyMalformed, err)
+			}
+		}
+		if offset != uint64(len(body)) {
+			return nil, fmt.Errorf("%w: %d trailing bytes", ErrPqVerifyMalformed, uint64(len(body))-offset)
+		}
+
+	case PqVerifyModeABI:
+		fields := []*[]byte{&parsed.pubKey, &parsed.msg, &parsed.sig}
+		for i, field := range fields {
+			if *field, err = readABIBytes(body, i); err != nil {
+				return nil, fmt.Errorf("%w: argument %d: %v", ErrPqVerifyMalformed, i, err)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown mode 0x%02x", ErrPqVerifyMalformed, mode)
+	}
+
+	if len(parsed.msg) > MaxPqVerifyMsgLen {
+		return nil, fmt.Errorf("%w: message of %d bytes exceeds %d", ErrPqVerifyMalformed, len(parsed.msg), MaxPqVerifyMsgLen)
+	}
+
+	return parsed, nil
+}
 
 // Address returns the address where the precompile is accessible
 func (p *PqVerifyPrecompile) Address() common.Address {
 	return common.HexToAddress("0x0000000000000000000000000000000000000101")
 }
 
+// Name returns the precompile name reported to the EVM tracer
+func (p *PqVerifyPrecompile) Name() string {
+	return "PQ_VERIFY"
+}
+
 // RequiredGas calculates the gas cost
-// Base: 3000 gas + 6 gas per byte of data
+// Algorithm base cost (default 5500 for Dilithium2) + 11 gas per 32-byte word
+// of message, from the chain config gas schedule. Key and signature
+// bytes are not charged per byte since their sizes are fixed by the algorithm.
+// Input that fails to parse is charged the algorithm base (or fallback) cost.
 func (p *PqVerifyPrecompile) RequiredGas(input []byte) uint64 {
-	baseGas := uint64(3000)
-	perByteGas := uint64(6)
-	return baseGas + (uint64(len(input)) * perByteGas)
+	g := gasSchedule(p.gas)
+
+	parsed, err := parsePqVerifyInput(input)
+	if err != nil {
+		if len(input) >= 32 && pqVerifyAlgos[input[31]] {
+			return pqVerifyBaseGas(g, input[31])
+		}
+		return g.PqVerifyFallback
+	}
+
+	msgWords := (uint64(len(parsed.msg)) + 31) / 32
+	return pqVerifyBaseGas(g, parsed.algo) + msgWords*g.PqVerifyPerMsgWord
 }
 
 // Run executes the PQ signature verification
 func (p *PqVerifyPrecompile) Run(input []byte) ([]byte, error) {
-	// Parse input: [pubkey_len:32][pubkey][msg_len:32][msg][sig_len:32][sig]
-	if len(input) < 96 {
-		return nil, errors.New("invalid input length")
+	parsed, err := parsePqVerifyInput(input)
+	if err != nil {
+		return nil, err
 	}
 
-	// Read public key length (first 32 bytes as big-endian uint256)
-	pubKeyLen := binary.BigEndian.Uint32(input[28:32])
-	offset := uint32(32)
+	// Verify the signature with the selected algorithm
+	verified, err := verifyPQSignature(sigAlgoRegistry(p.algos), parsed.algo, parsed.pubKey, parsed.msg, parsed.sig)
+	if err != nil {
+		return nil, err
+	}
 
-	if uint32(len(input)) < offset+pubKeyLen {
-		return nil, errors.New("invalid public key length")
+	// Return result as 32-byte big-endian uint256
+	result := make([]byte, 32)
+	if verified {
+		result[31] = 1
 	}
 
-	pubKey := input[offset : offset+pubKeyLen]
-	offset += pubKeyLen
+	return result, nil
+}
 
-	// Read message length
-	if uint32(len(input)) < offset+32 {
-		return nil, errors.New("message length field out of bounds")
+// verifyPQSignature checks sig with the precompile's algorithm registry.
+// Only an invalid signature yields (false, nil); size and activation errors
+// are returned so the precompile call fails.
+func verifyPQSignature(algos *tx.SigAlgoRegistry, algo uint8, pubKey, msg, sig []byte) (bool, error) {
+	info, err := algos.Lookup(algo)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrPqVerifyAlgo, err)
 	}
 
-	msgLen := binary.BigEndian.Uint32(input[offset+28 : offset+32])
-	offset += 32
+	if err := info.CheckSizes(pubKey, sig); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrPqVerifyMalformed, err)
+	}
 
-	if uint32(len(input)) < offset+msgLen {
-		return nil, errors.New("message data out of bounds")
+	if info.Verify == nil {
+		return false, fmt.Errorf("%w: %s has no verifier", ErrPqVerifyAlgo, info.Name)
 	}
 
-	msg := input[offset : offset+msgLen]
-	offset += msgLen
+	return info.Verify(pubKey, msg, sig), nil
+}
 
-	// Read signature length
-	if uint32(len(input)) < offset+32 {
-		return nil, errors.New("signature length field out of bounds")
+// EncodePqVerifyInput builds a packed-mode PqVerify input for off-chain
+// callers (e.g. the 4337 bundler):
+// [algo(32)][pubkey_len(32)][pubkey][msg_len(32)][msg][sig_len(32)][sig]
+func EncodePqVerifyInput(algo uint8, pubKey, msg, sig []byte) []byte {
+	input := make([]byte, 32, 128+len(pubKey)+len(msg)+len(sig))
+	input[31] = algo
+	for _, field := range [][]byte{pubKey, msg, sig} {
+		input = append(input, lengthWord(len(field))...)
+		input = append(input, field...)
 	}
+	return input
+}
 
-	sigLen := binary.BigEndian.Uint32(input[offset+28 : offset+32])
-	offset += 32
+// ─────────────────────────────────────────────────────────────────────────
+// PqBatchVerify Precompile (0x0104)
+// ─────────────────────────────────────────────────────────────────────────
+
+// PqBatchVerifyPrecompile verifies several signatures of one algorithm in a
+// single call, for multisig wallets and bridge committees
+// Address: 0x0000000000000000000000000000000000000104
+// Input:  [algo(32)][count(32)] then count x
+//         [pubkey_len(32)][pubkey][msg_len(32)][msg][sig_len(32)][sig]
+// Output: [all_valid(32)][bitmap(32)] where bit i of bitmap is set if
+//         signature i verified
+//
+// Malformed input follows PqVerify: the call fails rather than returning 0.
+type PqBatchVerifyPrecompile struct {
+	gas   *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+	algos *tx.SigAlgoRegistry   // nil = algorithms of config.DefaultChainConfig()
+}
 
-	if uint32(len(input)) < offset+sigLen {
-		return nil, errors.New("signature data out of bounds")
+// MaxPqBatchSize is the largest batch; the result bitmap is one uint256
+const MaxPqBatchSize = 256
+
+// pqBatchWorkers bounds the goroutines one PqBatchVerify call may use. The
+// gas curve (see pqBatchUnits) assumes this many cores on a validator.
+const pqBatchWorkers = 4
+
+// pqBatchUnits returns how many PqVerify prices a batch of n signatures
+// costs: ceil(n^(3/4)), the smallest u with u^4 >= n^3 (integer-only so all
+// nodes agree). Run verifies on pqBatchWorkers = 4 workers, so the call takes
+// ceil(n/4) verifications of wall-clock time, and n^(3/4) >= n/4 holds for
+// every n <= 4^4 = MaxPqBatchSize: the discount grows with the batch (16
+// signatures cost 8 units, 256 cost 64) but never prices a batch below the
+// time it blocks execution for.
+func pqBatchUnits(n uint64) uint64 {
+	cube := n * n * n
+	u := uint64(0)
+	for u*u*u*u < cube {
+		u++
 	}
+	return u
+}
 
-	sig := input[offset : offset+sigLen]
+// parsePqBatchInput decodes the algorithm and the signature triples
+func parsePqBatchInput(input []byte) (uint8, []*pqVerifyInput, error) {
+	algoWord, err := readUint64Word(input, 0)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: algorithm: %v", ErrPqVerifyMalformed, err)
+	}
+	if algoWord > 0xff {
+		return 0, nil, fmt.Errorf("%w: algorithm word 0x%x out of range", ErrPqVerifyAlgo, algoWord)
+	}
+	algo := uint8(algoWord)
+	if !pqVerifyAlgos[algo] {
+		return 0, nil, fmt.Errorf("%w: 0x%02x", ErrPqVerifyAlgo, algo)
+	}
 
-	// Verify the signature
-	// TODO: Replace with actual Dilithium2 verification from QSmart
-	// verified := dilithium.Verify(pubKey, msg, sig)
+	count, err := readUint64Word(input, 32)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: count: %v", ErrPqVerifyMalformed, err)
+	}
+	if count == 0 || count > MaxPqBatchSize {
+		return 0, nil, fmt.Errorf("%w: batch size %d (1-%d)", ErrPqVerifyMalformed, count, MaxPqBatchSize)
+	}
 
-	log.Printf("[PqVerify] Verifying signature (pubkey: %d bytes, msg: %d bytes, sig: %d bytes)\n",
-		len(pubKey), len(msg), len(sig))
+	items := make([]*pqVerifyInput, count)
+	offset := uint64(64)
+	for i := range items {
+		item := &pqVerifyInput{algo: algo}
+		for _, field := range []*[]byte{&item.pubKey, &item.msg, &item.sig} {
+			if *field, offset, err = readLengthPrefixed(input, offset); err != nil {
+				return 0, nil, fmt.Errorf("%w: item %d: %v", ErrPqVerifyMalformed, i, err)
+			}
+		}
+		if len(item.msg) > MaxPqVerifyMsgLen {
+			return 0, nil, fmt.Errorf("%w: item %d message exceeds %d bytes", ErrPqVerifyMalformed, i, MaxPqVerifyMsgLen)
+		}
+		items[i] = item
+	}
 
-	// Placeholder: perform basic validation
-	verified := p.verifySignaturePlaceholder(pubKey, msg, sig)
+	if offset != uint64(len(input)) {
+		return 0, nil, fmt.Errorf("%w: %d trailing bytes", ErrPqVerifyMalformed, uint64(len(input))-offset)
+	}
 
-	// Return result as 32-byte big-endian uint256
-	result := make([]byte, 32)
-	if verified {
-		result[31] = 1
+	return algo, items, nil
+}
+
+// Address returns the precompile address
+func (p *PqBatchVerifyPrecompile) Address() common.Address {
+	return common.HexToAddress("0x0000000000000000000000000000000000000104")
+}
+
+// Name returns the precompile name reported to the EVM tracer
+func (p *PqBatchVerifyPrecompile) Name() string {
+	return "PQ_BATCH_VERIFY"
+}
+
+// RequiredGas calculates the gas cost
+// Default: 1500 + ceil(count^(3/4)) * algorithm cost + 11 gas per 32-byte
+// message word (see pqBatchUnits)
+func (p *PqBatchVerifyPrecompile) RequiredGas(input []byte) uint64 {
+	g := gasSchedule(p.gas)
+
+	algo, items, err := parsePqBatchInput(input)
+	if err != nil {
+		return g.PqBatchBase + g.PqVerifyFallback
 	}
 
-	log.Printf("[PqVerify] Result: %v\n", verified)
-	return result, nil
+	gas := g.PqBatchBase + pqBatchUnits(uint64(len(items)))*pqVerifyBaseGas(g, algo)
+	for _, item := range items {
+		gas += ((uint64(len(item.msg)) + 31) / 32) * g.PqVerifyPerMsgWord
+	}
+	return gas
 }
 
-// verifySignaturePlaceholder is a mock verification for demonstration
-func (p *PqVerifyPrecompile) verifySignaturePlaceholder(pubKey, msg, sig []byte) bool {
-	// Placeholder logic for demo purposes
-	// In production: use actual Dilithium2 verification
-	if len(pubKey) == 0 || len(msg) == 0 || len(sig) == 0 {
-		return false
+// Run verifies all triples on up to pqBatchWorkers goroutines and returns
+// the validity bitmap
+func (p *PqBatchVerifyPrecompile) Run(input []byte) ([]byte, error) {
+	_, items, err := parsePqBatchInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	algos := sigAlgoRegistry(p.algos)
+	results := make([]bool, len(items))
+	errs := make([]error, len(items))
+
+	workers := min(pqBatchWorkers, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := items[i]
+				results[i], errs[i] = verifyPQSignature(algos, item.algo, item.pubKey, item.msg, item.sig)
+			}
+		}()
+	}
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Report the first error by index so failures are deterministic
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
 	}
 
-	// Mock: accept if signature is at least 100 bytes (realistic size)
-	return len(sig) >= 100 && len(pubKey) >= 1000
+	// Output: [all_valid:32][bitmap:32], bit i counted from the low end
+	result := make([]byte, 64)
+	allValid := true
+	for i, ok := range results {
+		if !ok {
+			allValid = false
+			continue
+		}
+		result[63-i/8] |= 1 << (i % 8)
+	}
+	if allValid {
+		result[31] = 1
+	}
+
+	return result, nil
 }
 
 // ─────────────────────────────────────────────────────────────────────────
-// KyberEnc Precompile (0x0102)
+// KyberEncRevealed Precompile (0x0102)
 // ─────────────────────────────────────────────────────────────────────────
 
-// KyberEncPrecompile implements Kyber768 encapsulation
+// Kyber768 (ML-KEM-768) sizes
+const (
+	KyberPublicKeySize  = 1184
+	KyberPrivateKeySize = 2400
+	KyberCiphertextSize = 1088
+	KyberSharedKeySize  = 32
+	KyberSeedSize       = 32
+)
+
+// ErrKyberMalformed is returned for invalid Kyber precompile input
+var ErrKyberMalformed = errors.New("malformed Kyber input")
+
+// KyberEncRevealedPrecompile recomputes a Kyber768 (ML-KEM-768)
+// encapsulation from a revealed seed
 // Address: 0x0000000000000000000000000000000000000102
-// Input:  [pubkey_len(32)][pubkey]
+// Input:  [pubkey_len(32)][pubkey][seed(32)]
 // Output: [ciphertext_len(32)][ciphertext][shared_secret_len(32)][shared_secret]
-type KyberEncPrecompile struct{}
+//
+// The seed is in calldata, so the shared secret is public. The only
+// supported use is checking an encapsulation made off-chain once its sender
+// has disclosed the seed, e.g. to prove in a dispute that a ciphertext was
+// built for a given key. Secrets must be encapsulated off-chain with a
+// private seed and recovered with DecapsulateOffChain.
+type KyberEncRevealedPrecompile struct {
+	gas *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+}
 
 // Address returns the precompile address
-func (k *KyberEncPrecompile) Address() common.Address {
+func (k *KyberEncRevealedPrecompile) Address() common.Address {
 	return common.HexToAddress("0x0000000000000000000000000000000000000102")
 }
 
+// Name returns the precompile name reported to the EVM tracer
+func (k *KyberEncRevealedPrecompile) Name() string {
+	return "KYBER_ENC_REVEALED"
+}
+
 // RequiredGas calculates the gas cost
-// Base: 4000 gas + 10 gas per byte
-func (k *KyberEncPrecompile) RequiredGas(input []byte) uint64 {
-	baseGas := uint64(4000)
-	perByteGas := uint64(10)
-	return baseGas + (uint64(len(input)) * perByteGas)
+// Flat, default 4000 gas (Kyber768 key and ciphertext sizes are fixed)
+func (k *KyberEncRevealedPrecompile) RequiredGas(input []byte) uint64 {
+	return gasSchedule(k.gas).KyberEnc
 }
 
 // Run executes Kyber768 encapsulation
-func (k *KyberEncPrecompile) Run(input []byte) ([]byte, error) {
-	if len(input) < 32 {
-		return nil, errors.New("invalid input length")
+func (k *KyberEncRevealedPrecompile) Run(input []byte) ([]byte, error) {
+	pubKey, offset, err := readLengthPrefixed(input, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key: %v", ErrKyberMalformed, err)
 	}
-
-	// Read public key length
-	pubKeyLen := binary.BigEndian.Uint32(input[28:32])
-	offset := uint32(32)
-
-	if uint32(len(input)) < offset+pubKeyLen {
-		return nil, errors.New("invalid public key length")
+	if len(pubKey) != KyberPublicKeySize {
+		return nil, fmt.Errorf("%w: public key must be %d bytes, got %d", ErrKyberMalformed, KyberPublicKeySize, len(pubKey))
 	}
 
-	pubKey := input[offset : offset+pubKeyLen]
+	seed, err := readSlice(input, offset, KyberSeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: seed: %v", ErrKyberMalformed, err)
+	}
+	if offset+KyberSeedSize != uint64(len(input)) {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrKyberMalformed)
+	}
 
-	// Perform encapsulation
-	// TODO: Replace with actual Kyber768 encapsulation from QSmart
-	// ciphertext, sharedSecret, err := kyber.Encapsulate768(pubKey)
+	scheme := mlkem768.Scheme()
+	pk, err := scheme.UnmarshalBinaryPublicKey(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKyberMalformed, err)
+	}
 
-	ciphertext, sharedSecret := k.encapsulatePlaceholder(pubKey)
+	ciphertext, sharedSecret, err := scheme.EncapsulateDeterministically(pk, seed)
+	if err != nil {
+		return nil, fmt.Errorf("encapsulation failed: %w", err)
+	}
 
 	// Build output: [ct_len:32][ciphertext][ss_len:32][shared_secret]
-	result := make([]byte, 32+len(ciphertext)+32+len(sharedSecret))
-
-	// Ciphertext length as big-endian uint256
-	binary.BigEndian.PutUint32(result[28:32], uint32(len(ciphertext)))
-	copy(result[32:32+len(ciphertext)], ciphertext)
-
-	// Shared secret length as big-endian uint256
-	offset = 32 + uint32(len(ciphertext))
-	binary.BigEndian.PutUint32(result[offset+28:offset+32], uint32(len(sharedSecret)))
-	copy(result[offset+32:], sharedSecret)
-
-	log.Printf("[KyberEnc] Generated ciphertext: %d bytes, shared secret: %d bytes\n",
-		len(ciphertext), len(sharedSecret))
+	result := make([]byte, 0, 64+len(ciphertext)+len(sharedSecret))
+	result = append(result, lengthWord(len(ciphertext))...)
+	result = append(result, ciphertext...)
+	result = append(result, lengthWord(len(sharedSecret))...)
+	result = append(result, sharedSecret...)
 
 	return result, nil
 }
 
-// encapsulatePlaceholder generates mock Kyber768 ciphertext and shared secret
-func (k *KyberEncPrecompile) encapsulatePlaceholder(pubKey []byte) ([]byte, []byte) {
-	// Kyber768: ciphertext ~1088 bytes, shared secret 32 bytes
-	ciphertext := make([]byte, 1088)
-	sharedSecret := make([]byte, 32)
+// ParseKyberEncRevealedOutput splits KyberEncRevealed output into ciphertext
+// and shared secret
+func ParseKyberEncRevealedOutput(output []byte) (ciphertext []byte, sharedSecret []byte, err error) {
+	ciphertext, offset, err := readLengthPrefixed(output, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ciphertext field: %w", err)
+	}
+
+	sharedSecret, _, err = readLengthPrefixed(output, offset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid shared secret field: %w", err)
+	}
 
-	// Deterministic generation based on pubkey hash for reproducibility
-	hash := sha3.NewLegacyKeccak256()
-	hash.Write(pubKey)
-	seed := hash.Sum(nil)
+	return ciphertext, sharedSecret, nil
+}
 
-	for i := 0; i < len(ciphertext); i++ {
-		ciphertext[i] = seed[(i+1)%32]
+// DecapsulateOffChain recovers the shared secret for a Kyber768 ciphertext.
+// It runs in the recipient's wallet or service, where the private key lives;
+// the key must never be sent to the chain.
+func DecapsulateOffChain(privKey, ciphertext []byte) ([]byte, error) {
+	scheme := mlkem768.Scheme()
+	sk, err := scheme.UnmarshalBinaryPrivateKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kyber private key: %w", err)
 	}
-	for i := 0; i < len(sharedSecret); i++ {
-		sharedSecret[i] = seed[i]
+
+	if len(ciphertext) != KyberCiphertextSize {
+		return nil, fmt.Errorf("ciphertext must be %d bytes, got %d", KyberCiphertextSize, len(ciphertext))
 	}
 
-	return ciphertext, sharedSecret
+	return scheme.Decapsulate(sk, ciphertext)
 }
 
 // ─────────────────────────────────────────────────────────────────────────
-// KyberDec Precompile (0x0103)
+// KyberDec Precompile (0x0103) - UNSAFE, disabled by default
 // ─────────────────────────────────────────────────────────────────────────
 
 // KyberDecPrecompile implements Kyber768 decapsulation
 // Address: 0x0000000000000000000000000000000000000103
 // Input:  [privkey_len(32)][privkey][ciphertext_len(32)][ciphertext]
 // Output: [shared_secret_len(32)][shared_secret]
-type KyberDecPrecompile struct{}
+//
+// Decapsulation needs the private key in calldata, which publishes it
+// on-chain. The precompile is only registered when the chain config sets
+// UnsafeKyberDecEnabled (local devnets); use DecapsulateOffChain instead.
+type KyberDecPrecompile struct {
+	gas *config.PrecompileGas // nil = config.DefaultPrecompileGas()
+}
 
 // Address returns the precompile address
 func (k *KyberDecPrecompile) Address() common.Address {
 	return common.HexToAddress("0x0000000000000000000000000000000000000103")
 }
 
+// Name returns the precompile name reported to the EVM tracer
+func (k *KyberDecPrecompile) Name() string {
+	return "KYBER_DEC"
+}
+
 // RequiredGas calculates the gas cost
-// Base: 5000 gas + 12 gas per byte
+// Flat, default 5000 gas (Kyber768 key and ciphertext sizes are fixed)
 func (k *KyberDecPrecompile) RequiredGas(input []byte) uint64 {
-	baseGas := uint64(5000)
-	perByteGas := uint64(12)
-	return baseGas + (uint64(len(input)) * perByteGas)
+	return gasSchedule(k.gas).KyberDec
 }
 
 // Run executes Kyber768 decapsulation
 func (k *KyberDecPrecompile) Run(input []byte) ([]byte, error) {
-	if len(input) < 64 {
-		return nil, errors.New("invalid input length")
+	privKey, offset, err := readLengthPrefixed(input, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: private key: %v", ErrKyberMalformed, err)
 	}
 
-	// Read private key length
-	privKeyLen := binary.BigEndian.Uint32(input[28:32])
-	offset := uint32(32)
-
-	if uint32(len(input)) < offset+privKeyLen {
-		return nil, errors.New("invalid private key length")
+	ciphertext, offset, err := readLengthPrefixed(input, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ciphertext: %v", ErrKyberMalformed, err)
 	}
-
-	privKey := input[offset : offset+privKeyLen]
-	offset += privKeyLen
-
-	// Read ciphertext length
-	if uint32(len(input)) < offset+32 {
-		return nil, errors.New("ciphertext length field out of bounds")
+	if offset != uint64(len(input)) {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrKyberMalformed)
 	}
 
-	ctLen := binary.BigEndian.Uint32(input[offset+28 : offset+32])
-	offset += 32
-
-	if uint32(len(input)) < offset+ctLen {
-		return nil, errors.New("ciphertext data out of bounds")
+	sharedSecret, err := DecapsulateOffChain(privKey, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKyberMalformed, err)
 	}
 
-	ciphertext := input[offset : offset+ctLen]
-
-	// Perform decapsulation
-	// TODO: Replace with actual Kyber768 decapsulation from QSmart
-	// sharedSecret, err := kyber.Decapsulate768(privKey, ciphertext)
-
-	sharedSecret := k.decapsulatePlaceholder(privKey, ciphertext)
-
 	// Build output: [ss_len:32][shared_secret]
-	result := make([]byte, 32+len(sharedSecret))
-	binary.BigEndian.PutUint32(result[28:32], uint32(len(sharedSecret)))
-	copy(result[32:], sharedSecret)
-
-	log.Printf("[KyberDec] Recovered shared secret: %d bytes\n", len(sharedSecret))
+	result := make([]byte, 0, 32+len(sharedSecret))
+	result = append(result, lengthWord(len(sharedSecret))...)
+	result = append(result, sharedSecret...)
 
 	return result, nil
 }
 
-// decapsulatePlaceholder recovers the shared secret from ciphertext
-func (k *KyberDecPrecompile) decapsulatePlaceholder(privKey, ciphertext []byte) []byte {
-	// Kyber768 shared secret is 32 bytes
-	sharedSecret := make([]byte, 32)
-
-	// Deterministic recovery based on privkey hash
-	hash := sha3.NewLegacyKeccak256()
-	hash.Write(privKey)
-	hash.Write(ciphertext)
-	result := hash.Sum(nil)
-
-	copy(sharedSecret, result)
-
-	return sharedSecret
-}
-
 // ─────────────────────────────────────────────────────────────────────────
 // Precompile Registration
 // ─────────────────────────────────────────────────────────────────────────
@@ -314,25 +618,41 @@ type PrecompileRegistry struct {
 	precompiles map[common.Address]vm.PrecompiledContract
 }
 
-// NewPrecompileRegistry creates a new registry
+// NewPrecompileRegistry creates a new registry with the default chain config
 func NewPrecompileRegistry() *PrecompileRegistry {
+	return NewPrecompileRegistryWithConfig(config.DefaultChainConfig())
+}
+
+// NewPrecompileRegistryWithConfig creates a registry honouring the chain
+// config's precompile switches
+func NewPrecompileRegistryWithConfig(cfg *config.ChainConfig) *PrecompileRegistry {
 	registry := &PrecompileRegistry{
 		precompiles: make(map[common.Address]vm.PrecompiledContract),
 	}
 
-	// Register PQ precompiles
-	pqVerify := &PqVerifyPrecompile{}
-	kyberEnc := &KyberEncPrecompile{}
-	kyberDec := &KyberDecPrecompile{}
+	// Register PQ precompiles, priced from the chain config gas schedule and
+	// verifying with the algorithms this config enables
+	gas := cfg.PrecompileGasSchedule()
+	algos := tx.NewSigAlgoRegistry(cfg)
+	pqVerify := &PqVerifyPrecompile{gas: gas, algos: algos}
+	kyberEncRevealed := &KyberEncRevealedPrecompile{gas: gas}
+	kyberDec := &KyberDecPrecompile{gas: gas}
+	pqBatchVerify := &PqBatchVerifyPrecompile{gas: gas, algos: algos}
+	shake128 := &Shake128Precompile{gas: gas}
+	shake256 := &Shake256Precompile{gas: gas}
+	slhDsaVerify := &SlhDsaVerifyPrecompile{gas: gas, algos: algos}
 
 	registry.precompiles[pqVerify.Address()] = pqVerify
-	registry.precompiles[kyberEnc.Address()] = kyberEnc
-	registry.precompiles[kyberDec.Address()] = kyberDec
-
-	log.Printf("[Precompiles] Registered 3 PQ precompiles:\n")
-	log.Printf("  0x0101: PqVerify (Dilithium2 verification)\n")
-	log.Printf("  0x0102: KyberEnc (Kyber768 encapsulation)\n")
-	log.Printf("  0x0103: KyberDec (Kyber768 decapsulation)\n")
+	registry.precompiles[kyberEncRevealed.Address()] = kyberEncRevealed
+	registry.precompiles[pqBatchVerify.Address()] = pqBatchVerify
+	registry.precompiles[shake128.Address()] = shake128
+	registry.precompiles[shake256.Address()] = shake256
+	registry.precompiles[slhDsaVerify.Address()] = slhDsaVerify
+
+	// KyberDec takes a private key as calldata; only devnets may opt in
+	if cfg.UnsafeKyberDecEnabled {
+		registry.precompiles[kyberDec.Address()] = kyberDec
+	}
 
 	return registry
 }
@@ -357,165 +677,13 @@ func (pr *PrecompileRegistry) GetAllPrecompiles() map[common.Address]vm.Precompi
 // Solidity Library Helper
 // ─────────────────────────────────────────────────────────────────────────
 
-// PQLibSolidity provides the Solidity library code for PQ operations
-const PQLibSolidity = `
-// SPDX-License-Identifier: MIT
-pragma solidity ^0.8.0;
-
-/**
- * PQ Library - Post-Quantum Cryptographic Operations
- * 
- * Provides Solidity interface to PQ precompiles:
- * - 0x0101: Dilithium2 signature verification
- * - 0x0102: Kyber768 encapsulation
- * - 0x0103: Kyber768 decapsulation
- */
-library PQ {
-    // PQ precompile addresses
-    address constant PQ_VERIFY_ADDR   = 0x0000000000000000000000000000000000000101;
-    address constant KYBER_ENC_ADDR   = 0x0000000000000000000000000000000000000102;
-    address constant KYBER_DEC_ADDR   = 0x0000000000000000000000000000000000000103;
-
-    /**
-     * Verify a Dilithium2 signature
-     * @param pubKey The public key (bytes)
-     * @param msgHash The message hash (bytes32)
-     * @param signature The signature (bytes)
-     * @return valid True if signature is valid
-     */
-    function verify(
-        bytes memory pubKey,
-        bytes32 msgHash,
-        bytes memory signature
-    ) internal view returns (bool valid) {
-        bytes memory msg = abi.encodePacked(msgHash);
-        
-        // Construct input for PqVerify precompile
-        bytes memory input = abi.encodePacked(
-            uint256(pubKey.length),
-            pubKey,
-            uint256(msg.length),
-            msg,
-            uint256(signature.length),
-            signature
-        );
-        
-        bytes memory output;
-        assembly {
-            // Call PqVerify precompile at 0x0101
-            let success := staticcall(
-                gas(),
-                PQ_VERIFY_ADDR,
-                add(input, 0x20),
-                mload(input),
-                0x00,
-                0x20
-            )
-            
-            if iszero(success) {
-                revert(0, 0)
-            }
-            
-            // Load result from return data
-            let result := mload(0x00)
-            valid := eq(result, 1)
-        }
-    }
-
-    /**
-     * Encapsulate with Kyber768
-     * @param pubKey The Kyber public key (bytes)
-     * @return ciphertext The encapsulated ciphertext
-     * @return sharedSecret The derived shared secret
-     */
-    function encapsulate(bytes memory pubKey)
-        internal
-        view
-        returns (bytes memory ciphertext, bytes memory sharedSecret)
-    {
-        bytes memory input = abi.encodePacked(
-            uint256(pubKey.length),
-            pubKey
-        );
-        
-        bytes memory output;
-        assembly {
-            // Call KyberEnc precompile at 0x0102
-            let success := staticcall(
-                gas(),
-                KYBER_ENC_ADDR,
-                add(input, 0x20),
-                mload(input),
-                0x00,
-                0x2000  // 8KB buffer for output
-            )
-            
-            if iszero(success) {
-                revert(0, 0)
-            }
-            
-            // Parse output [ct_len:32][ciphertext][ss_len:32][shared_secret]
-            let ct_len := mload(0x1c)
-            let ct_offset := 0x20
-            
-            ciphertext := mload(0x40)
-            mstore(ciphertext, ct_len)
-            mstore(0x40, add(ciphertext, add(0x20, ct_len)))
-            
-            let ss_offset := add(ct_offset, ct_len)
-            let ss_len := mload(add(ss_offset, 0x1c))
-            
-            sharedSecret := mload(0x40)
-            mstore(sharedSecret, ss_len)
-            mstore(0x40, add(sharedSecret, add(0x20, ss_len)))
-        }
-    }
-
-    /**
-     * Decapsulate with Kyber768
-     * @param privKey The Kyber private key (bytes)
-     * @param ciphertext The encapsulated ciphertext (bytes)
-     * @return sharedSecret The recovered shared secret
-     */
-    function decapsulate(bytes memory privKey, bytes memory ciphertext)
-        internal
-        view
-        returns (bytes memory sharedSecret)
-    {
-        bytes memory input = abi.encodePacked(
-            uint256(privKey.length),
-            privKey,
-            uint256(ciphertext.length),
-            ciphertext
-        );
-        
-        assembly {
-            // Call KyberDec precompile at 0x0103
-            let success := staticcall(
-                gas(),
-                KYBER_DEC_ADDR,
-                add(input, 0x20),
-                mload(input),
-                0x00,
-                0x100  // 256 bytes buffer for output
-            )
-            
-            if iszero(success) {
-                revert(0, 0)
-            }
-            
-            // Parse output [ss_len:32][shared_secret]
-            let ss_len := mload(0x1c)
-            
-            sharedSecret := mload(0x40)
-            mstore(sharedSecret, ss_len)
-            mstore(0x40, add(sharedSecret, add(0x20, ss_len)))
-        }
-    }
-}
-`
-
-// GetSolidityLibrary returns the Solidity library code
+// GetSolidityLibrary returns the Solidity library code (see pq_lib_gen.go)
 func GetSolidityLibrary() string {
 	return PQLibSolidity
 }
+
+// GetSmartAccountContract returns the reference PQ smart-account contract
+// (see pq_account_gen.go)
+func GetSmartAccountContract() string {
+	return PQSmartAccountSolidity
+}
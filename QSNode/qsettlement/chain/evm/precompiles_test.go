@@ -0,0 +1,461 @@
+package evm
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/cloudflare/circl/sign/slhdsa"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// katMessage is the message signed by every known-answer vector
+var katMessage = []byte("aureob1 PqVerify known answer")
+
+// katVector is a deterministic key pair and signature for one algorithm.
+// digest pins keccak256(pubKey || sig) so a change in key derivation or
+// signing (not just verification) is caught.
+type katVector struct {
+	name   string
+	algo   uint8
+	keygen func(seed []byte) (pubKey []byte, signer func(msg []byte) []byte)
+	digest string
+}
+
+// mldsaKeygen derives an ML-DSA key pair from seed and signs deterministically
+func mldsaKeygen(scheme sign.Scheme) func([]byte) ([]byte, func([]byte) []byte) {
+	return func(seed []byte) ([]byte, func([]byte) []byte) {
+		pub, priv := scheme.DeriveKey(seed[:scheme.SeedSize()])
+		pubBytes, _ := pub.MarshalBinary()
+		return pubBytes, func(msg []byte) []byte { return scheme.Sign(priv, msg, nil) }
+	}
+}
+
+// slhdsaKeygen derives an SLH-DSA key pair from seed and signs deterministically
+func slhdsaKeygen(id slhdsa.ID) func([]byte) ([]byte, func([]byte) []byte) {
+	return func(seed []byte) ([]byte, func([]byte) []byte) {
+		scheme := id.Scheme()
+		pub, priv := scheme.DeriveKey(seed[:scheme.SeedSize()])
+		pubBytes, _ := pub.MarshalBinary()
+		key := priv.(slhdsa.PrivateKey)
+		return pubBytes, func(msg []byte) []byte {
+			sig, err := slhdsa.SignDeterministic(&key, slhdsa.NewMessage(msg), nil)
+			if err != nil {
+				panic(err)
+			}
+			return sig
+		}
+	}
+}
+
+var katVectors = []katVector{
+	{"ML-DSA-44", tx.SigAlgoDilithium2, mldsaKeygen(mldsa44.Scheme()),
+		"0x698cb31944a14edfdb4c1689fb9722ca428223b8851a36b99237afc11b14fd4c"},
+	{"ML-DSA-65", tx.SigAlgoDilithium3, mldsaKeygen(mldsa65.Scheme()),
+		"0xb7a05b45cb3e3ddbff6324a8bc9c981d53aa29261563d62b1d8a66dd403c96bd"},
+	{"ML-DSA-87", tx.SigAlgoDilithium5, mldsaKeygen(mldsa87.Scheme()),
+		"0x4984ed6e47e12f8a2d3f5b39be394e11e6a6ef431951333298273c9901e8630e"},
+	{"SLH-DSA-SHA2-128s", tx.SigAlgoSLHDSASHA2128s, slhdsaKeygen(slhdsa.SHA2_128s),
+		"0xa54173df9ccc92813f04729b1887fb43f686eb527a33f5312f1286da57603654"},
+	{"SLH-DSA-SHAKE-128s", tx.SigAlgoSLHDSASHAKE128s, slhdsaKeygen(slhdsa.SHAKE_128s),
+		"0x1e9ba9756932b0af15753def38e43c2da0155de3bfbec07d8ed9be6ab1a09577"},
+}
+
+// katSeed is the fixed 96-byte seed vectors are derived from (truncated to
+// each scheme's seed size)
+func katSeed() []byte {
+	seed := make([]byte, 96)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	return seed
+}
+
+var bytesArgs = func() abi.Arguments {
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	return abi.Arguments{{Type: bytesType}, {Type: bytesType}, {Type: bytesType}}
+}()
+
+// encodePqVerifyABI builds an ABI-mode PqVerify input
+func encodePqVerifyABI(algo uint8, pubKey, msg, sig []byte) []byte {
+	body, err := bytesArgs.Pack(pubKey, msg, sig)
+	if err != nil {
+		panic(err)
+	}
+
+	header := make([]byte, 32)
+	header[30] = PqVerifyModeABI
+	header[31] = algo
+	return append(header, body...)
+}
+
+// inputModes builds a PqVerify input in each supported mode
+var inputModes = []struct {
+	name   string
+	encode func(algo uint8, pubKey, msg, sig []byte) []byte
+}{
+	{"packed", EncodePqVerifyInput},
+	{"abi", encodePqVerifyABI},
+}
+
+// runVerify calls a verify precompile and returns its 0/1 result
+func runVerify(p vm.PrecompiledContract, input []byte) (bool, error) {
+	out, err := p.Run(input)
+	if err != nil {
+		return false, err
+	}
+	if len(out) != 32 || !bytes.Equal(out[:31], make([]byte, 31)) || out[31] > 1 {
+		return false, errors.New("malformed precompile output")
+	}
+	return out[31] == 1, nil
+}
+
+func TestPqVerifyKnownAnswers(t *testing.T) {
+	registry := NewPrecompileRegistryWithConfig(config.DefaultChainConfig())
+	pqVerify := registry.GetPrecompile((&PqVerifyPrecompile{}).Address())
+	slhDsaVerify := registry.GetPrecompile((&SlhDsaVerifyPrecompile{}).Address())
+
+	for _, v := range katVectors {
+		t.Run(v.name, func(t *testing.T) {
+			pubKey, signer := v.keygen(katSeed())
+			sig := signer(katMessage)
+
+			if got := crypto.Keccak256Hash(pubKey, sig).Hex(); got != v.digest {
+				t.Fatalf("vector digest = %s, want %s", got, v.digest)
+			}
+
+			precompiles := []vm.PrecompiledContract{pqVerify}
+			if slhDsaAlgos[v.algo] {
+				precompiles = append(precompiles, slhDsaVerify)
+			}
+
+			for _, p := range precompiles {
+				for _, mode := range inputModes {
+					t.Run(p.Name()+"/"+mode.name, func(t *testing.T) {
+						checkVerifyVector(t, p, mode.encode, v.algo, pubKey, sig)
+					})
+				}
+			}
+		})
+	}
+}
+
+// checkVerifyVector runs the valid, tampered and malformed cases of a vector
+func checkVerifyVector(t *testing.T, p vm.PrecompiledContract, encode func(uint8, []byte, []byte, []byte) []byte, algo uint8, pubKey, sig []byte) {
+	t.Helper()
+
+	if ok, err := runVerify(p, encode(algo, pubKey, katMessage, sig)); err != nil || !ok {
+		t.Fatalf("valid signature: got (%v, %v), want (true, nil)", ok, err)
+	}
+
+	// Tampered message, signature and key verify to 0 without failing the call
+	badMsg := append([]byte{}, katMessage...)
+	badMsg[0] ^= 0x01
+	badSig := append([]byte{}, sig...)
+	badSig[len(badSig)/2] ^= 0x01
+	badKey := append([]byte{}, pubKey...)
+	badKey[len(badKey)-1] ^= 0x01
+
+	for name, input := range map[string][]byte{
+		"message":   encode(algo, pubKey, badMsg, sig),
+		"signature": encode(algo, pubKey, katMessage, badSig),
+		"key":       encode(algo, badKey, katMessage, sig),
+	} {
+		if ok, err := runVerify(p, input); err != nil || ok {
+			t.Errorf("tampered %s: got (%v, %v), want (false, nil)", name, ok, err)
+		}
+	}
+
+	// Wrong key/signature lengths fail the call
+	for name, input := range map[string][]byte{
+		"short key":       encode(algo, pubKey[:len(pubKey)-1], katMessage, sig),
+		"long key":        encode(algo, append(append([]byte{}, pubKey...), 0), katMessage, sig),
+		"short signature": encode(algo, pubKey, katMessage, sig[:len(sig)-1]),
+		"empty signature": encode(algo, pubKey, katMessage, nil),
+	} {
+		if _, err := runVerify(p, input); !errors.Is(err, ErrPqVerifyMalformed) {
+			t.Errorf("%s: got %v, want ErrPqVerifyMalformed", name, err)
+		}
+	}
+}
+
+func TestPqVerifyRejectsUnsupportedAlgorithms(t *testing.T) {
+	pqVerify := NewPrecompileRegistryWithConfig(config.DefaultChainConfig()).GetPrecompile((&PqVerifyPrecompile{}).Address())
+
+	for _, algo := range []uint8{0x00, tx.SigAlgoFalcon512, tx.SigAlgoFalcon1024, tx.SigAlgoMultisig, 0xff} {
+		input := EncodePqVerifyInput(algo, make([]byte, 897), katMessage, make([]byte, 666))
+		if _, err := pqVerify.Run(input); !errors.Is(err, ErrPqVerifyAlgo) {
+			t.Errorf("algo 0x%02x: got %v, want ErrPqVerifyAlgo", algo, err)
+		}
+	}
+}
+
+func TestPqVerifyUsesChainConfigAlgorithms(t *testing.T) {
+	pubKey, signer := katVectors[0].keygen(katSeed())
+	input := EncodePqVerifyInput(tx.SigAlgoDilithium2, pubKey, katMessage, signer(katMessage))
+
+	cfg := config.DefaultChainConfig()
+	cfg.PQSigAlgos[tx.SigAlgoDilithium2] = false
+	disabled := NewPrecompileRegistryWithConfig(cfg)
+	enabled := NewPrecompileRegistryWithConfig(config.DefaultChainConfig())
+
+	addr := (&PqVerifyPrecompile{}).Address()
+	if _, err := disabled.GetPrecompile(addr).Run(input); !errors.Is(err, ErrPqVerifyAlgo) {
+		t.Fatalf("algorithm disabled in chain config: got %v, want ErrPqVerifyAlgo", err)
+	}
+
+	if ok, err := runVerify(enabled.GetPrecompile(addr), input); err != nil || !ok {
+		t.Fatalf("algorithm enabled in chain config: got (%v, %v), want (true, nil)", ok, err)
+	}
+
+	batchInput := encodeBatchInput(tx.SigAlgoDilithium2, [][3][]byte{{pubKey, katMessage, signer(katMessage)}})
+	batchAddr := (&PqBatchVerifyPrecompile{}).Address()
+	if out, err := enabled.GetPrecompile(batchAddr).Run(batchInput); err != nil || out[31] != 1 {
+		t.Fatalf("batch with algorithm enabled: got (%x, %v)", out, err)
+	}
+	if _, err := disabled.GetPrecompile(batchAddr).Run(batchInput); err == nil {
+		t.Fatal("batch with algorithm disabled in chain config succeeded")
+	}
+}
+
+// encodeBatchInput builds a PqBatchVerify input from (pubKey, msg, sig) triples
+func encodeBatchInput(algo uint8, items [][3][]byte) []byte {
+	input := append(lengthWord(int(algo)), lengthWord(len(items))...)
+	for _, item := range items {
+		for _, field := range item {
+			input = append(input, lengthWord(len(field))...)
+			input = append(input, field...)
+		}
+	}
+	return input
+}
+
+// Ensure the test vectors cover every algorithm PqVerify accepts that has a
+// verifier linked in
+func TestKnownAnswerCoverage(t *testing.T) {
+	covered := make(map[uint8]bool)
+	for _, v := range katVectors {
+		covered[v.algo] = true
+	}
+
+	for algo := range pqVerifyAlgos {
+		info, err := defaultSigAlgos.Lookup(algo)
+		if err != nil || info.Verify == nil {
+			continue
+		}
+		if !covered[algo] {
+			t.Errorf("no known-answer vector for %s (0x%02x)", info.Name, algo)
+		}
+	}
+}
+
+func TestPqBatchUnits(t *testing.T) {
+	for n, want := range map[uint64]uint64{1: 1, 2: 2, 4: 3, 16: 8, 81: 27, 256: 64} {
+		if got := pqBatchUnits(n); got != want {
+			t.Errorf("pqBatchUnits(%d) = %d, want %d", n, got, want)
+		}
+	}
+
+	for n := uint64(1); n <= MaxPqBatchSize; n++ {
+		units := pqBatchUnits(n)
+
+		// Never cheaper than the wall-clock time on the worker pool, never
+		// dearer than n single calls
+		if wall := (n + pqBatchWorkers - 1) / pqBatchWorkers; units < wall {
+			t.Fatalf("n=%d: %d units is below %d verifications of wall time", n, units, wall)
+		}
+		if units > n {
+			t.Fatalf("n=%d: %d units exceeds %d single calls", n, units, n)
+		}
+		if n > 1 && units < pqBatchUnits(n-1) {
+			t.Fatalf("n=%d: units decrease", n)
+		}
+	}
+}
+
+func TestPqBatchVerify(t *testing.T) {
+	pubKey, signer := katVectors[0].keygen(katSeed())
+	sig := signer(katMessage)
+	badSig := append([]byte{}, sig...)
+	badSig[0] ^= 0x01
+
+	items := make([][3][]byte, 10)
+	for i := range items {
+		items[i] = [3][]byte{pubKey, katMessage, sig}
+	}
+	items[3][2], items[7][2] = badSig, badSig
+
+	batch := NewPrecompileRegistryWithConfig(config.DefaultChainConfig()).GetPrecompile((&PqBatchVerifyPrecompile{}).Address())
+	out, err := batch.Run(encodeBatchInput(tx.SigAlgoDilithium2, items))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out[31] != 0 {
+		t.Fatal("all_valid set with invalid signatures")
+	}
+	if bitmap := uint16(out[62])<<8 | uint16(out[63]); bitmap != 0x3ff&^(1<<3|1<<7) {
+		t.Fatalf("bitmap = %010b", bitmap)
+	}
+
+	// Malformed items fail the whole call, naming the first bad item
+	items[5][2] = sig[:100]
+	if _, err := batch.Run(encodeBatchInput(tx.SigAlgoDilithium2, items)); !errors.Is(err, ErrPqVerifyMalformed) {
+		t.Fatalf("short signature: got %v, want ErrPqVerifyMalformed", err)
+	}
+
+	g := config.DefaultPrecompileGas()
+	want := g.PqBatchBase + 6*g.PqVerifyAlgo[tx.SigAlgoDilithium2] + 10*g.PqVerifyPerMsgWord
+	if got := batch.RequiredGas(encodeBatchInput(tx.SigAlgoDilithium2, items)); got != want {
+		t.Fatalf("RequiredGas = %d, want %d", got, want)
+	}
+}
+
+func TestPqBatchVerifyBoundsWorkers(t *testing.T) {
+	var (
+		mu              sync.Mutex
+		active, maxSeen int
+	)
+
+	// A slow stand-in verifier that records how many calls overlap
+	algos := tx.NewSigAlgoRegistry(config.DefaultChainConfig())
+	err := algos.Register(&tx.SigAlgoInfo{
+		ID: tx.SigAlgoFalcon512, Name: "counting", PublicKeySize: 8, SignatureSize: 8,
+		Verify: func(pubKey, msg, sig []byte) bool {
+			mu.Lock()
+			active++
+			maxSeen = max(maxSeen, active)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return true
+		},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := make([][3][]byte, MaxPqBatchSize)
+	for i := range items {
+		items[i] = [3][]byte{make([]byte, 8), katMessage, make([]byte, 8)}
+	}
+
+	batch := &PqBatchVerifyPrecompile{algos: algos}
+	out, err := batch.Run(encodeBatchInput(tx.SigAlgoFalcon512, items))
+	if err != nil || out[31] != 1 {
+		t.Fatalf("Run: got (%x, %v)", out, err)
+	}
+	if maxSeen > pqBatchWorkers {
+		t.Fatalf("%d verifications ran concurrently, want at most %d", maxSeen, pqBatchWorkers)
+	}
+}
+
+// newKyberKey returns a Kyber768 key pair derived from katSeed
+func newKyberKey(t *testing.T) (pubKey, privKey []byte) {
+	t.Helper()
+
+	scheme := mlkem768.Scheme()
+	pk, sk := scheme.DeriveKeyPair(katSeed()[:scheme.SeedSize()])
+	pubKey, _ = pk.MarshalBinary()
+	privKey, _ = sk.MarshalBinary()
+	return pubKey, privKey
+}
+
+func TestKyberEncRevealedRoundTrip(t *testing.T) {
+	pubKey, privKey := newKyberKey(t)
+	seed := katSeed()[:KyberSeedSize]
+	input := append(append(lengthWord(len(pubKey)), pubKey...), seed...)
+
+	enc := &KyberEncRevealedPrecompile{}
+	out, err := enc.Run(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, sharedSecret, err := ParseKyberEncRevealedOutput(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ciphertext) != KyberCiphertextSize || len(sharedSecret) != KyberSharedKeySize {
+		t.Fatalf("got %d-byte ciphertext and %d-byte secret", len(ciphertext), len(sharedSecret))
+	}
+
+	// The recipient recovers the same secret off-chain
+	recovered, err := DecapsulateOffChain(privKey, ciphertext)
+	if err != nil || !bytes.Equal(recovered, sharedSecret) {
+		t.Fatalf("DecapsulateOffChain: got (%x, %v), want %x", recovered, err, sharedSecret)
+	}
+
+	// Anyone holding the revealed seed recomputes the same encapsulation
+	again, _ := enc.Run(input)
+	if !bytes.Equal(again, out) {
+		t.Fatal("encapsulation is not reproducible from the seed")
+	}
+	otherSeed := append([]byte{}, input...)
+	otherSeed[len(otherSeed)-1] ^= 0x01
+	if other, _ := enc.Run(otherSeed); bytes.Equal(other, out) {
+		t.Fatal("a different seed gave the same encapsulation")
+	}
+
+	for name, bad := range map[string][]byte{
+		"short key":      append(append(lengthWord(len(pubKey)-1), pubKey[1:]...), seed...),
+		"short seed":     input[:len(input)-1],
+		"trailing bytes": append(append([]byte{}, input...), 0),
+	} {
+		if _, err := enc.Run(bad); !errors.Is(err, ErrKyberMalformed) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrKyberMalformed)
+		}
+	}
+
+	// A tampered ciphertext decapsulates to a different secret
+	ciphertext[0] ^= 0x01
+	if recovered, err := DecapsulateOffChain(privKey, ciphertext); err != nil || bytes.Equal(recovered, sharedSecret) {
+		t.Fatalf("tampered ciphertext: got (%x, %v)", recovered, err)
+	}
+}
+
+func TestKyberDecRequiresOptIn(t *testing.T) {
+	kyberDec := (&KyberDecPrecompile{}).Address()
+
+	cfg := config.DefaultChainConfig()
+	if cfg.UnsafeKyberDecEnabled {
+		t.Fatal("KyberDec enabled in the default chain config")
+	}
+	if NewPrecompileRegistry().IsPrecompile(kyberDec) || NewPrecompileRegistryWithConfig(cfg).IsPrecompile(kyberDec) {
+		t.Fatal("KyberDec registered without UnsafeKyberDecEnabled")
+	}
+	if factory := NewEVMFactory(params.MergedTestChainConfig, cfg); factory.registry.IsPrecompile(kyberDec) {
+		t.Fatal("KyberDec reachable from the default EVM")
+	}
+
+	cfg.UnsafeKyberDecEnabled = true
+	dec := NewPrecompileRegistryWithConfig(cfg).GetPrecompile(kyberDec)
+	if dec == nil {
+		t.Fatal("KyberDec not registered with UnsafeKyberDecEnabled")
+	}
+
+	pubKey, privKey := newKyberKey(t)
+	out, _ := (&KyberEncRevealedPrecompile{}).Run(append(append(lengthWord(len(pubKey)), pubKey...), katSeed()[:KyberSeedSize]...))
+	ciphertext, sharedSecret, _ := ParseKyberEncRevealedOutput(out)
+
+	input := append(append(lengthWord(len(privKey)), privKey...), lengthWord(len(ciphertext))...)
+	out, err := dec.Run(append(input, ciphertext...))
+	if err != nil || !bytes.Equal(out, append(lengthWord(len(sharedSecret)), sharedSecret...)) {
+		t.Fatalf("KyberDec: got (%x, %v), want %x", out, err, sharedSecret)
+	}
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: MIT
+// Code generated by pqgen from evm.PQLibrarySpec. DO NOT EDIT.
+pragma solidity ^0.8.0;
+
+/**
+ * PQ Library - Post-Quantum Cryptographic Operations
+ *
+ * Provides Solidity interface to PQ precompiles:
+ * - 0x0101: PQ_VERIFY (verifyWithAlgo)
+ * - 0x0102: KYBER_ENC_REVEALED (encapsulateRevealed)
+ * - 0x0104: PQ_BATCH_VERIFY (verifyBatch)
+ * - 0x0105: SHAKE128 (shake128)
+ * - 0x0106: SHAKE256 (shake256)
+ * - 0x0107: SLH_DSA_VERIFY (verifySlhDsa)
+ */
+library PQ {
+    // PQ precompile addresses
+    address constant PQ_VERIFY_ADDR = 0x0000000000000000000000000000000000000101;
+    address constant KYBER_ENC_REVEALED_ADDR = 0x0000000000000000000000000000000000000102;
+    address constant PQ_BATCH_VERIFY_ADDR = 0x0000000000000000000000000000000000000104;
+    address constant SHAKE128_ADDR = 0x0000000000000000000000000000000000000105;
+    address constant SHAKE256_ADDR = 0x0000000000000000000000000000000000000106;
+    address constant SLH_DSA_VERIFY_ADDR = 0x0000000000000000000000000000000000000107;
+
+    uint256 constant PQ_VERIFY_MODE_ABI = 0x01; // PqVerify input mode for abi.encode(bytes, bytes, bytes) bodies
+    uint8 constant ALGO_DILITHIUM2 = 0x01; // ML-DSA-44
+    uint8 constant ALGO_DILITHIUM3 = 0x02; // ML-DSA-65
+    uint8 constant ALGO_DILITHIUM5 = 0x03; // ML-DSA-87
+    uint8 constant ALGO_SLHDSA_SHA2_128S = 0x10; // SLH-DSA-SHA2-128s
+    uint8 constant ALGO_SLHDSA_SHAKE_128S = 0x11; // SLH-DSA-SHAKE-128s
+
+    /**
+     * Verify a PQ signature with an explicit algorithm
+     * Reverts if the algorithm is not enabled or the key/signature sizes
+     * do not match it; returns false only for a wrong signature.
+     * Gas: algorithm base (Dilithium2 5500 ... SLH-DSA 80000) + 11 per message word
+     * @param algo The PQSigAlgo ID
+     * @param pubKey The public key
+     * @param msgHash The message hash
+     * @param signature The signature
+     * @return valid True if signature is valid
+     */
+    function verifyWithAlgo(
+        uint8 algo,
+        bytes memory pubKey,
+        bytes32 msgHash,
+        bytes memory signature
+    ) internal view returns (bool valid) {
+        bytes memory input = abi.encodePacked(
+            (uint256(0x01) << 8) | uint256(algo),
+            abi.encode(pubKey, abi.encodePacked(msgHash), signature)
+        );
+
+        bytes memory output = _call(PQ_VERIFY_ADDR, input);
+        valid = _readWord(output, 0) == 1;
+    }
+
+    /**
+     * Recompute a Kyber768 (ML-KEM-768) encapsulation from a revealed seed
+     * The seed and therefore the shared secret are public. Use this only
+     * to check an off-chain encapsulation whose seed has been disclosed,
+     * never to derive a secret.
+     * Gas: flat 4000
+     * @param pubKey The Kyber public key (1184 bytes)
+     * @param seed The revealed 32-byte encapsulation seed
+     * @return ciphertext The encapsulated ciphertext
+     * @return sharedSecret The derived shared secret
+     */
+    function encapsulateRevealed(
+        bytes memory pubKey,
+        bytes32 seed
+    ) internal view returns (bytes memory ciphertext, bytes memory sharedSecret) {
+        bytes memory input = abi.encodePacked(
+            uint256(pubKey.length),
+            pubKey,
+            seed
+        );
+
+        bytes memory output = _call(KYBER_ENC_REVEALED_ADDR, input);
+        uint256 offset = 0;
+        (ciphertext, offset) = _readBytes(output, offset);
+        (sharedSecret, ) = _readBytes(output, offset);
+    }
+
+    /**
+     * Verify up to 256 signatures of one algorithm in a single call
+     * Gas: 1500 + ceil(n^(3/4)) x the PqVerify price for n signatures
+     * @param algo The PQSigAlgo ID shared by all signatures
+     * @param pubKeys The public keys
+     * @param msgHashes The signed message hashes
+     * @param signatures The signatures
+     * @return allValid True if every signature is valid
+     * @return bitmap Bit i is set if signature i is valid
+     */
+    function verifyBatch(
+        uint8 algo,
+        bytes[] memory pubKeys,
+        bytes32[] memory msgHashes,
+        bytes[] memory signatures
+    ) internal view returns (bool allValid, uint256 bitmap) {
+        require(
+            pubKeys.length == msgHashes.length && pubKeys.length == signatures.length,
+            "PQ: batch length mismatch"
+        );
+
+        bytes memory input = abi.encodePacked(
+            uint256(algo),
+            uint256(pubKeys.length)
+        );
+        for (uint256 i = 0; i < pubKeys.length; i++) {
+            input = abi.encodePacked(
+                input,
+                uint256(pubKeys[i].length),
+                pubKeys[i],
+                uint256(32),
+                msgHashes[i],
+                uint256(signatures[i].length),
+                signatures[i]
+            );
+        }
+
+        bytes memory output = _call(PQ_BATCH_VERIFY_ADDR, input);
+        uint256 offset = 0;
+        allValid = _readWord(output, offset) == 1;
+        offset += 32;
+        bitmap = _readWord(output, offset);
+    }
+
+    /**
+     * SHAKE128 with arbitrary output length
+     * Gas: 60 + 10 per word of input and output
+     * @param data The input to absorb
+     * @param outLen The number of output bytes (1-16384)
+     * @return digest The SHAKE128 output
+     */
+    function shake128(
+        bytes memory data,
+        uint256 outLen
+    ) internal view returns (bytes memory digest) {
+        bytes memory input = abi.encodePacked(
+            uint256(outLen),
+            data
+        );
+
+        bytes memory output = _call(SHAKE128_ADDR, input);
+        digest = output;
+    }
+
+    /**
+     * SHAKE256 with arbitrary output length
+     * Gas: 60 + 12 per word of input and output
+     * @param data The input to absorb
+     * @param outLen The number of output bytes (1-16384)
+     * @return digest The SHAKE256 output
+     */
+    function shake256(
+        bytes memory data,
+        uint256 outLen
+    ) internal view returns (bytes memory digest) {
+        bytes memory input = abi.encodePacked(
+            uint256(outLen),
+            data
+        );
+
+        bytes memory output = _call(SHAKE256_ADDR, input);
+        digest = output;
+    }
+
+    /**
+     * Verify an SLH-DSA (SPHINCS+) signature
+     * Gas: SHA2-128s 24000, SHAKE-128s 80000 + 11 per message word
+     * @param paramSet ALGO_SLHDSA_SHA2_128S or ALGO_SLHDSA_SHAKE_128S
+     * @param pubKey The 32-byte SLH-DSA public key
+     * @param msgHash The message hash
+     * @param signature The signature
+     * @return valid True if signature is valid
+     */
+    function verifySlhDsa(
+        uint8 paramSet,
+        bytes memory pubKey,
+        bytes32 msgHash,
+        bytes memory signature
+    ) internal view returns (bool valid) {
+        bytes memory input = abi.encodePacked(
+            (uint256(0x01) << 8) | uint256(paramSet),
+            abi.encode(pubKey, abi.encodePacked(msgHash), signature)
+        );
+
+        bytes memory output = _call(SLH_DSA_VERIFY_ADDR, input);
+        valid = _readWord(output, 0) == 1;
+    }
+
+    /**
+     * Verify a Dilithium2 (ML-DSA-44) signature
+     * @param pubKey The public key
+     * @param msgHash The message hash
+     * @param signature The signature
+     * @return valid True if signature is valid
+     */
+    function verify(
+        bytes memory pubKey,
+        bytes32 msgHash,
+        bytes memory signature
+    ) internal view returns (bool valid) {
+        return verifyWithAlgo(ALGO_DILITHIUM2, pubKey, msgHash, signature);
+    }
+
+    function _call(address precompile, bytes memory input) private view returns (bytes memory output) {
+        bool success;
+        (success, output) = precompile.staticcall(input);
+        require(success, "PQ: precompile call failed");
+    }
+
+    function _readWord(bytes memory data, uint256 offset) private pure returns (uint256 word) {
+        require(data.length >= offset + 32, "PQ: output too short");
+        assembly {
+            word := mload(add(add(data, 0x20), offset))
+        }
+    }
+
+    function _readBytes(bytes memory data, uint256 offset)
+        private
+        pure
+        returns (bytes memory out, uint256 next)
+    {
+        uint256 len = _readWord(data, offset);
+        out = _slice(data, offset + 32, len);
+        next = offset + 32 + len;
+    }
+
+    function _slice(bytes memory data, uint256 start, uint256 len) private pure returns (bytes memory out) {
+        require(data.length >= start + len, "PQ: output too short");
+        out = new bytes(len);
+        for (uint256 i = 0; i < len; i++) {
+            out[i] = data[start + i];
+        }
+    }
+}
+
+contract PQHarness {
+    function verifyWithAlgo(uint8 algo, bytes memory pubKey, bytes32 msgHash, bytes memory signature) external view returns (bool) {
+        return PQ.verifyWithAlgo(algo, pubKey, msgHash, signature);
+    }
+
+    function shake256(bytes memory data, uint256 outLen) external view returns (bytes memory) {
+        return PQ.shake256(data, outLen);
+    }
+}
@@ -0,0 +1,435 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Address book limits
+const (
+	maxGoodAddrs       = 1024
+	maxBadAddrs        = 4096
+	needAddrsThreshold = 1000 // Below this many addresses we ask peers for more
+	maxAddrSelection   = 250  // Addresses handed out per PEX response
+	maxFailedAttempts  = 5    // Dial failures before an unverified address is dropped
+	maxGoodFailures    = 3    // Dial failures in a row before a good address is demoted
+	maxRetryBackoff    = time.Hour
+
+	DefaultBanDuration = 24 * time.Hour
+)
+
+// Address book errors
+var (
+	ErrAddrSelf   = errors.New("address is our own")
+	ErrAddrBanned = errors.New("address is banned")
+)
+
+// knownAddress is an address book entry
+type knownAddress struct {
+	Addr        *NetAddress `json:"addr"`
+	Src         NodeID      `json:"src,omitempty"` // Peer that told us about it
+	Good        bool        `json:"good"`
+	Attempts    int         `json:"attempts"` // Dial failures since the last success
+	AddedAt     time.Time   `json:"added_at"`
+	LastAttempt time.Time   `json:"last_attempt"`
+	LastSuccess time.Time   `json:"last_success"`
+}
+
+// retryDue reports whether enough time has passed since the last failed
+// dial; the wait doubles with every failure
+func (ka *knownAddress) retryDue(now time.Time) bool {
+	if ka.Attempts == 0 {
+		return true
+	}
+	backoff := min(time.Duration(1<<ka.Attempts)*time.Minute, maxRetryBackoff)
+	return now.Sub(ka.LastAttempt) >= backoff
+}
+
+// addrBookJSON is the on-disk form of the address book
+type addrBookJSON struct {
+	Addrs  []*knownAddress      `json:"addrs"`
+	Banned map[NodeID]time.Time `json:"banned"`
+}
+
+// AddrBook stores peer addresses learned from seeds and peer exchange.
+//
+// Addresses start in the bad bucket, where everything we have not verified
+// ourselves lives. An address moves to the good bucket once we dial it and
+// the PQ handshake proves the remote holds the node key behind its ID, so
+// gossip alone can never make an address good. Misbehaving peers are put on
+// a ban list that expires.
+type AddrBook struct {
+	filePath string
+	ourID    NodeID
+
+	mtx    sync.Mutex
+	good   map[NodeID]*knownAddress
+	bad    map[NodeID]*knownAddress
+	banned map[NodeID]time.Time // Ban expiry
+}
+
+// NewAddrBook creates an empty address book persisted at filePath. An empty
+// path keeps the book in memory only.
+func NewAddrBook(filePath string, ourID NodeID) *AddrBook {
+	return &AddrBook{
+		filePath: filePath,
+		ourID:    ourID,
+		good:     make(map[NodeID]*knownAddress),
+		bad:      make(map[NodeID]*knownAddress),
+		banned:   make(map[NodeID]time.Time),
+	}
+}
+
+// Load reads the address book file; a missing file leaves the book empty
+func (b *AddrBook) Load() error {
+	if b.filePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(b.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read address book: %w", err)
+	}
+
+	var stored addrBookJSON
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to decode address book %s: %w", b.filePath, err)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	for id, until := range stored.Banned {
+		if until.After(now) {
+			b.banned[id] = until
+		}
+	}
+
+	for _, ka := range stored.Addrs {
+		if ka == nil || ka.Addr == nil || ka.Addr.ID == b.ourID || b.isBanned(ka.Addr.ID, now) {
+			continue
+		}
+		if ka.Good && len(b.good) < maxGoodAddrs {
+			b.good[ka.Addr.ID] = ka
+		} else if len(b.bad) < maxBadAddrs {
+			ka.Good = false
+			b.bad[ka.Addr.ID] = ka
+		}
+	}
+
+	return nil
+}
+
+// Save writes the address book file
+func (b *AddrBook) Save() error {
+	if b.filePath == "" {
+		return nil
+	}
+
+	b.mtx.Lock()
+	stored := addrBookJSON{
+		Addrs:  make([]*knownAddress, 0, len(b.good)+len(b.bad)),
+		Banned: make(map[NodeID]time.Time, len(b.banned)),
+	}
+	for _, ka := range b.good {
+		entry := *ka
+		stored.Addrs = append(stored.Addrs, &entry)
+	}
+	for _, ka := range b.bad {
+		entry := *ka
+		stored.Addrs = append(stored.Addrs, &entry)
+	}
+	for id, until := range b.banned {
+		stored.Banned[id] = until
+	}
+	b.mtx.Unlock()
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode address book: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.filePath), 0o700); err != nil {
+		return fmt.Errorf("failed to create address book directory: %w", err)
+	}
+
+	// Write then rename, so a crash never leaves a truncated book
+	tmp := b.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write address book: %w", err)
+	}
+	if err := os.Rename(tmp, b.filePath); err != nil {
+		return fmt.Errorf("failed to write address book: %w", err)
+	}
+
+	return nil
+}
+
+// AddAddress adds an unverified address learned from src. Known addresses
+// are left alone, so gossip cannot replace an address we already have.
+func (b *AddrBook) AddAddress(addr *NetAddress, src NodeID) error {
+	if addr.ID == b.ourID {
+		return ErrAddrSelf
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	if b.isBanned(addr.ID, now) {
+		return fmt.Errorf("%w: %s", ErrAddrBanned, addr.ID)
+	}
+	if b.get(addr.ID) != nil {
+		return nil
+	}
+
+	if len(b.bad) >= maxBadAddrs {
+		b.evictBad()
+	}
+	b.bad[addr.ID] = &knownAddress{Addr: addr, Src: src, AddedAt: now}
+	return nil
+}
+
+// MarkGood records a completed handshake with addr, moving it to the good
+// bucket. addr must be an address we dialed: the handshake checked its ID.
+func (b *AddrBook) MarkGood(addr *NetAddress) {
+	if addr.ID == b.ourID {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	if b.isBanned(addr.ID, now) {
+		return
+	}
+
+	ka := b.get(addr.ID)
+	if ka == nil {
+		ka = &knownAddress{AddedAt: now}
+	}
+	ka.Addr = addr
+	ka.Attempts = 0
+	ka.LastAttempt = now
+	ka.LastSuccess = now
+
+	if !ka.Good {
+		delete(b.bad, addr.ID)
+		if len(b.good) >= maxGoodAddrs {
+			b.demoteOldestGood()
+		}
+		ka.Good = true
+		b.good[addr.ID] = ka
+	}
+}
+
+// MarkFailed records a failed dial. Unverified addresses are dropped after
+// maxFailedAttempts failures, good ones demoted after maxGoodFailures.
+func (b *AddrBook) MarkFailed(id NodeID) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	ka := b.get(id)
+	if ka == nil {
+		return
+	}
+	ka.Attempts++
+	ka.LastAttempt = time.Now()
+
+	switch {
+	case ka.Good && ka.Attempts >= maxGoodFailures:
+		delete(b.good, id)
+		ka.Good = false
+		if len(b.bad) >= maxBadAddrs {
+			b.evictBad()
+		}
+		b.bad[id] = ka
+	case !ka.Good && ka.Attempts >= maxFailedAttempts:
+		delete(b.bad, id)
+	}
+}
+
+// Ban removes id from the book and refuses it for duration
+func (b *AddrBook) Ban(id NodeID, duration time.Duration) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	delete(b.good, id)
+	delete(b.bad, id)
+	b.banned[id] = time.Now().Add(duration)
+}
+
+// IsBanned reports whether id is on the ban list
+func (b *AddrBook) IsBanned(id NodeID) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.isBanned(id, time.Now())
+}
+
+// RemoveAddress forgets id
+func (b *AddrBook) RemoveAddress(id NodeID) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	delete(b.good, id)
+	delete(b.bad, id)
+}
+
+// Size returns the number of good and bad addresses
+func (b *AddrBook) Size() (good, bad int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return len(b.good), len(b.bad)
+}
+
+// NeedMoreAddrs reports whether the book is small enough to ask for more
+func (b *AddrBook) NeedMoreAddrs() bool {
+	good, bad := b.Size()
+	return good+bad < needAddrsThreshold
+}
+
+// PickAddress returns a random address that is due for a dial and not
+// excluded. biasTowardsBad (0-100) is the chance of picking from the bad
+// bucket; it falls back to the other bucket when one has no candidates.
+func (b *AddrBook) PickAddress(biasTowardsBad int, exclude func(NodeID) bool) *NetAddress {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	candidates := func(bucket map[NodeID]*knownAddress) []*knownAddress {
+		var out []*knownAddress
+		for id, ka := range bucket {
+			if ka.retryDue(now) && (exclude == nil || !exclude(id)) {
+				out = append(out, ka)
+			}
+		}
+		return out
+	}
+
+	good, bad := candidates(b.good), candidates(b.bad)
+	pick := good
+	if len(bad) > 0 && (len(good) == 0 || rand.IntN(100) < biasTowardsBad) {
+		pick = bad
+	}
+	if len(pick) == 0 {
+		return nil
+	}
+	return pick[rand.IntN(len(pick))].Addr
+}
+
+// GetSelection returns up to maxAddrSelection random addresses to share
+// with a peer, good addresses first
+func (b *AddrBook) GetSelection() []*NetAddress {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	shuffled := func(bucket map[NodeID]*knownAddress) []*NetAddress {
+		out := make([]*NetAddress, 0, len(bucket))
+		for _, ka := range bucket {
+			out = append(out, ka.Addr)
+		}
+		rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+		return out
+	}
+
+	selection := append(shuffled(b.good), shuffled(b.bad)...)
+	if len(selection) > maxAddrSelection {
+		selection = selection[:maxAddrSelection]
+	}
+	return selection
+}
+
+// CrawlAddresses returns up to n addresses that are due for a dial and not
+// excluded, least recently tried first
+func (b *AddrBook) CrawlAddresses(n int, exclude func(NodeID) bool) []*NetAddress {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	var due []*knownAddress
+	for _, bucket := range []map[NodeID]*knownAddress{b.good, b.bad} {
+		for id, ka := range bucket {
+			if ka.retryDue(now) && (exclude == nil || !exclude(id)) {
+				due = append(due, ka)
+			}
+		}
+	}
+	slices.SortFunc(due, func(x, y *knownAddress) int {
+		return x.LastAttempt.Compare(y.LastAttempt)
+	})
+
+	addrs := make([]*NetAddress, 0, min(n, len(due)))
+	for _, ka := range due[:min(n, len(due))] {
+		addrs = append(addrs, ka.Addr)
+	}
+	return addrs
+}
+
+func (b *AddrBook) get(id NodeID) *knownAddress {
+	if ka, ok := b.good[id]; ok {
+		return ka
+	}
+	return b.bad[id]
+}
+
+// isBanned checks the ban list, dropping expired bans
+func (b *AddrBook) isBanned(id NodeID, now time.Time) bool {
+	until, ok := b.banned[id]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(b.banned, id)
+		return false
+	}
+	return true
+}
+
+// evictBad drops the bad address with the most failures, oldest first
+func (b *AddrBook) evictBad() {
+	var worst *knownAddress
+	for _, ka := range b.bad {
+		if worst == nil || ka.Attempts > worst.Attempts ||
+			(ka.Attempts == worst.Attempts && ka.AddedAt.Before(worst.AddedAt)) {
+			worst = ka
+		}
+	}
+	if worst != nil {
+		delete(b.bad, worst.Addr.ID)
+	}
+}
+
+// demoteOldestGood moves the least recently verified good address to the
+// bad bucket
+func (b *AddrBook) demoteOldestGood() {
+	var oldest *knownAddress
+	for _, ka := range b.good {
+		if oldest == nil || ka.LastSuccess.Before(oldest.LastSuccess) {
+			oldest = ka
+		}
+	}
+	if oldest == nil {
+		return
+	}
+
+	delete(b.good, oldest.Addr.ID)
+	oldest.Good = false
+	if len(b.bad) >= maxBadAddrs {
+		b.evictBad()
+	}
+	b.bad[oldest.Addr.ID] = oldest
+}
@@ -0,0 +1,212 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testAddr returns an address for a made-up node
+func testAddr(i int) *NetAddress {
+	return &NetAddress{
+		ID:   PubKeyToID([]byte(fmt.Sprintf("test node %d", i))),
+		Host: fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+		Port: 26656,
+	}
+}
+
+// bucketOf reports which bucket holds id: "good", "bad" or ""
+func bucketOf(b *AddrBook, id NodeID) string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch {
+	case b.good[id] != nil:
+		return "good"
+	case b.bad[id] != nil:
+		return "bad"
+	default:
+		return ""
+	}
+}
+
+func TestAddrBookSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "addrbook.json")
+	ourID := testAddr(0).ID
+	book := NewAddrBook(path, ourID)
+
+	good, bad, src := testAddr(1), testAddr(2), testAddr(3).ID
+	if err := book.AddAddress(bad, src); err != nil {
+		t.Fatal(err)
+	}
+	book.MarkFailed(bad.ID)
+	book.MarkGood(good)
+	banned := testAddr(4).ID
+	book.Ban(banned, time.Hour)
+
+	if err := book.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewAddrBook(path, ourID)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if bucketOf(loaded, good.ID) != "good" || bucketOf(loaded, bad.ID) != "bad" {
+		t.Fatalf("buckets after Load: %s is %q, %s is %q", good.ID, bucketOf(loaded, good.ID), bad.ID, bucketOf(loaded, bad.ID))
+	}
+	ka := loaded.bad[bad.ID]
+	if !ka.Addr.Equal(bad) || ka.Src != src || ka.Attempts != 1 || ka.LastAttempt.IsZero() {
+		t.Fatalf("bad entry not restored: %+v", ka)
+	}
+	if !loaded.good[good.ID].Addr.Equal(good) || loaded.good[good.ID].LastSuccess.IsZero() {
+		t.Fatalf("good entry not restored: %+v", loaded.good[good.ID])
+	}
+	if !loaded.IsBanned(banned) {
+		t.Fatal("ban not restored")
+	}
+
+	// A missing file is an empty book, a corrupt one an error
+	if err := NewAddrBook(filepath.Join(t.TempDir(), "none.json"), ourID).Load(); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAddrBook(path, ourID).Load(); err == nil {
+		t.Fatal("corrupt address book loaded")
+	}
+}
+
+func TestAddrBookBanExpiresAcrossLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrbook.json")
+	book := NewAddrBook(path, testAddr(0).ID)
+
+	short, long := testAddr(1), testAddr(2)
+	book.Ban(short.ID, 50*time.Millisecond)
+	book.Ban(long.ID, time.Hour)
+	if err := book.Save(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	loaded := NewAddrBook(path, testAddr(0).ID)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.banned[short.ID]; ok {
+		t.Fatal("expired ban loaded")
+	}
+	if err := loaded.AddAddress(short, ""); err != nil {
+		t.Fatalf("address with an expired ban: %v", err)
+	}
+	if err := loaded.AddAddress(long, ""); !errors.Is(err, ErrAddrBanned) {
+		t.Fatalf("address with a live ban: got %v, want %v", err, ErrAddrBanned)
+	}
+	loaded.MarkGood(long)
+	if bucketOf(loaded, long.ID) != "" {
+		t.Fatal("banned address marked good")
+	}
+}
+
+func TestAddrBookPromotesOnlyOnMarkGood(t *testing.T) {
+	book := NewAddrBook("", testAddr(0).ID)
+	addr := testAddr(1)
+
+	// Hearing about an address again, from anyone, leaves it unverified
+	for i := range 3 {
+		if err := book.AddAddress(addr, testAddr(10+i).ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bucketOf(book, addr.ID) != "bad" {
+		t.Fatalf("gossiped address in %q bucket, want bad", bucketOf(book, addr.ID))
+	}
+
+	book.MarkFailed(addr.ID)
+	book.MarkGood(addr)
+	if bucketOf(book, addr.ID) != "good" || book.good[addr.ID].Attempts != 0 {
+		t.Fatalf("MarkGood left the address in %q bucket", bucketOf(book, addr.ID))
+	}
+	if good, bad := book.Size(); good != 1 || bad != 0 {
+		t.Fatalf("size %d good, %d bad after promotion", good, bad)
+	}
+
+	// Gossip cannot replace a verified address
+	moved := *addr
+	moved.Host = "192.0.2.1"
+	book.AddAddress(&moved, testAddr(20).ID)
+	if !book.good[addr.ID].Addr.Equal(addr) {
+		t.Fatal("gossip replaced a good address")
+	}
+
+	if err := book.AddAddress(testAddr(0), ""); !errors.Is(err, ErrAddrSelf) {
+		t.Fatal("own address added")
+	}
+}
+
+func TestAddrBookDemotesFailingGoodAddrs(t *testing.T) {
+	book := NewAddrBook("", testAddr(0).ID)
+	addr := testAddr(1)
+	book.MarkGood(addr)
+
+	for range maxGoodFailures - 1 {
+		book.MarkFailed(addr.ID)
+	}
+	if bucketOf(book, addr.ID) != "good" {
+		t.Fatalf("demoted after %d failures", maxGoodFailures-1)
+	}
+
+	book.MarkFailed(addr.ID)
+	if bucketOf(book, addr.ID) != "bad" {
+		t.Fatalf("still %q after %d failures, want bad", bucketOf(book, addr.ID), maxGoodFailures)
+	}
+
+	// Once demoted it is dropped like any unverified address
+	for range maxFailedAttempts - maxGoodFailures {
+		book.MarkFailed(addr.ID)
+	}
+	if bucketOf(book, addr.ID) != "" {
+		t.Fatalf("still %q after %d failures", bucketOf(book, addr.ID), maxFailedAttempts)
+	}
+}
+
+func TestAddrBookCaps(t *testing.T) {
+	book := NewAddrBook("", testAddr(0).ID)
+
+	// Filling the bad bucket evicts the address with the most failures
+	for i := 1; i <= maxBadAddrs; i++ {
+		if err := book.AddAddress(testAddr(i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	worst := testAddr(7)
+	book.MarkFailed(worst.ID)
+	extra := testAddr(maxBadAddrs + 1)
+	if err := book.AddAddress(extra, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, bad := book.Size(); bad != maxBadAddrs {
+		t.Fatalf("bad bucket holds %d, cap is %d", bad, maxBadAddrs)
+	}
+	if bucketOf(book, worst.ID) != "" || bucketOf(book, extra.ID) != "bad" {
+		t.Fatal("eviction kept the failing address")
+	}
+
+	// Filling the good bucket demotes the least recently verified address
+	book = NewAddrBook("", testAddr(0).ID)
+	for i := 1; i <= maxGoodAddrs; i++ {
+		book.MarkGood(testAddr(i))
+	}
+	book.good[testAddr(5).ID].LastSuccess = time.Now().Add(-time.Hour)
+	book.MarkGood(testAddr(maxGoodAddrs + 1))
+	if good, bad := book.Size(); good != maxGoodAddrs || bad != 1 {
+		t.Fatalf("size %d good, %d bad, want %d and 1", good, bad, maxGoodAddrs)
+	}
+	if bucketOf(book, testAddr(5).ID) != "bad" {
+		t.Fatal("oldest good address not demoted")
+	}
+}
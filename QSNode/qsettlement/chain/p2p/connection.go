@@ -0,0 +1,534 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Packet types on an MConnection
+const (
+	packetTypePing byte = 0x01
+	packetTypePong byte = 0x02
+	packetTypeMsg  byte = 0x03 // [chID][eof][2-byte len][payload]
+
+	packetMsgHeaderSize = 1 + 1 + 1 + 2
+)
+
+// MConnection tuning
+const (
+	defaultSendQueueCapacity   = 16
+	defaultRecvMessageCapacity = 4 << 20 // 4 MiB
+	defaultSendTimeout         = 10 * time.Second
+	numBatchPackets            = 10 // Packets written between flushes
+	recentlySentDecayInterval  = 2 * time.Second
+	recentlySentDecay          = 0.8
+)
+
+// MConnection errors
+var (
+	ErrUnknownChannel   = errors.New("unknown channel")
+	ErrPacketTooLarge   = errors.New("packet payload too large")
+	ErrMessageTooLarge  = errors.New("message exceeds channel receive capacity")
+	ErrUnknownPacket    = errors.New("unknown packet type")
+	ErrPongTimeout      = errors.New("pong timeout")
+	ErrConnectionClosed = errors.New("connection closed")
+)
+
+// ChannelDescriptor describes one logical channel of an MConnection
+type ChannelDescriptor struct {
+	ID                  byte
+	Priority            int // Relative share of send bandwidth when busy
+	SendQueueCapacity   int // Queued messages, defaultSendQueueCapacity if zero
+	RecvMessageCapacity int // Largest reassembled message, defaultRecvMessageCapacity if zero
+}
+
+// MConnConfig configures an MConnection
+type MConnConfig struct {
+	SendRate             int64 // Bytes per second sent, 0 = unlimited
+	RecvRate             int64 // Bytes per second received, 0 = unlimited
+	MaxPacketPayloadSize int   // Message fragment size on the wire
+	PingInterval         time.Duration
+	PongTimeout          time.Duration
+}
+
+// DefaultMConnConfig returns the default MConnection settings
+func DefaultMConnConfig() MConnConfig {
+	return MConnConfig{
+		SendRate:             5120000, // 5 MB/s
+		RecvRate:             5120000,
+		MaxPacketPayloadSize: 1024,
+		PingInterval:         60 * time.Second,
+		PongTimeout:          45 * time.Second,
+	}
+}
+
+// ReceiveFunc is called with every complete message received on a channel
+type ReceiveFunc func(chID byte, msg []byte)
+
+// ErrorFunc is called once when the connection fails
+type ErrorFunc func(err error)
+
+// MConnection multiplexes prioritised channels over one connection.
+// Messages are split into packets of at most MaxPacketPayloadSize bytes, and
+// whenever several channels have data queued the next packet goes to the
+// channel that has sent the least relative to its priority, so a large
+// block transfer cannot hold up consensus votes. Sending and receiving are
+// rate limited, and a ping is sent every PingInterval; the connection fails
+// if the pong is not back within PongTimeout.
+type MConnection struct {
+	conn     net.Conn
+	bufR     *bufio.Reader
+	bufW     *bufio.Writer
+	cfg      MConnConfig
+	channels []*channel
+	byID     map[byte]*channel
+
+	onReceive ReceiveFunc
+	onError   ErrorFunc
+
+	sendSignal chan struct{} // A channel has queued data
+	pongSignal chan struct{} // A ping arrived and needs a pong
+	pongRecv   chan struct{} // Our ping was answered
+
+	sendLimiter *rateLimiter
+	recvLimiter *rateLimiter
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	errOnce   sync.Once
+	quit      chan struct{}
+}
+
+// NewMConnection creates a connection multiplexing chDescs over conn
+func NewMConnection(conn net.Conn, chDescs []*ChannelDescriptor, onReceive ReceiveFunc, onError ErrorFunc, cfg MConnConfig) (*MConnection, error) {
+	if cfg.MaxPacketPayloadSize <= 0 || cfg.MaxPacketPayloadSize > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid max packet payload size %d", cfg.MaxPacketPayloadSize)
+	}
+	if cfg.PingInterval <= 0 || cfg.PongTimeout <= 0 {
+		return nil, errors.New("ping interval and pong timeout must be positive")
+	}
+
+	mconn := &MConnection{
+		conn:        conn,
+		bufR:        bufio.NewReaderSize(conn, MaxFramePayload),
+		bufW:        bufio.NewWriterSize(conn, MaxFramePayload),
+		cfg:         cfg,
+		byID:        make(map[byte]*channel, len(chDescs)),
+		onReceive:   onReceive,
+		onError:     onError,
+		sendSignal:  make(chan struct{}, 1),
+		pongSignal:  make(chan struct{}, 1),
+		pongRecv:    make(chan struct{}, 1),
+		sendLimiter: newRateLimiter(cfg.SendRate),
+		recvLimiter: newRateLimiter(cfg.RecvRate),
+		quit:        make(chan struct{}),
+	}
+
+	for _, desc := range chDescs {
+		if _, dup := mconn.byID[desc.ID]; dup {
+			return nil, fmt.Errorf("duplicate channel 0x%02x", desc.ID)
+		}
+		ch := newChannel(*desc)
+		mconn.channels = append(mconn.channels, ch)
+		mconn.byID[desc.ID] = ch
+	}
+
+	return mconn, nil
+}
+
+// Start launches the send and receive routines
+func (c *MConnection) Start() {
+	c.startOnce.Do(func() {
+		go c.sendRoutine()
+		go c.recvRoutine()
+	})
+}
+
+// Stop closes the connection. It does not call onError.
+func (c *MConnection) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.quit)
+		c.conn.Close()
+	})
+}
+
+// Send queues msg on channel chID, blocking for up to defaultSendTimeout
+// while the queue is full. It reports whether msg was queued.
+func (c *MConnection) Send(chID byte, msg []byte) bool {
+	ch, ok := c.byID[chID]
+	if !ok {
+		log.Printf("[MConn] Send on unknown channel 0x%02x\n", chID)
+		return false
+	}
+
+	timer := time.NewTimer(defaultSendTimeout)
+	defer timer.Stop()
+
+	select {
+	case ch.sendQueue <- msg:
+		c.signalSend()
+		return true
+	case <-timer.C:
+		return false
+	case <-c.quit:
+		return false
+	}
+}
+
+// TrySend queues msg on channel chID without blocking
+func (c *MConnection) TrySend(chID byte, msg []byte) bool {
+	ch, ok := c.byID[chID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case ch.sendQueue <- msg:
+		c.signalSend()
+		return true
+	default:
+		return false
+	}
+}
+
+// CanSend reports whether channel chID has room in its send queue
+func (c *MConnection) CanSend(chID byte) bool {
+	ch, ok := c.byID[chID]
+	return ok && len(ch.sendQueue) < cap(ch.sendQueue)
+}
+
+func (c *MConnection) signalSend() {
+	select {
+	case c.sendSignal <- struct{}{}:
+	default:
+	}
+}
+
+// fail stops the connection and reports err, unless it was stopped already
+func (c *MConnection) fail(err error) {
+	select {
+	case <-c.quit:
+		return
+	default:
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) {
+		err = fmt.Errorf("%w: %v", ErrConnectionClosed, err)
+	}
+
+	c.Stop()
+	c.errOnce.Do(func() {
+		if c.onError != nil {
+			c.onError(err)
+		}
+	})
+}
+
+// sendRoutine writes packets, pings and pongs until the connection stops
+func (c *MConnection) sendRoutine() {
+	pingTicker := time.NewTicker(c.cfg.PingInterval)
+	defer pingTicker.Stop()
+
+	decayTicker := time.NewTicker(recentlySentDecayInterval)
+	defer decayTicker.Stop()
+
+	pongTimer := time.NewTimer(c.cfg.PongTimeout)
+	pongTimer.Stop()
+	defer pongTimer.Stop()
+	awaitingPong := false
+
+	for {
+		var err error
+		select {
+		case <-c.quit:
+			return
+
+		case <-decayTicker.C:
+			for _, ch := range c.channels {
+				ch.decayRecentlySent()
+			}
+
+		case <-pingTicker.C:
+			// An unanswered ping keeps its original deadline
+			if awaitingPong {
+				break
+			}
+			if err = c.writeControl(packetTypePing); err == nil {
+				awaitingPong = true
+				pongTimer.Reset(c.cfg.PongTimeout)
+			}
+
+		case <-c.pongRecv:
+			awaitingPong = false
+			pongTimer.Stop()
+
+		case <-pongTimer.C:
+			err = ErrPongTimeout
+
+		case <-c.pongSignal:
+			err = c.writeControl(packetTypePong)
+
+		case <-c.sendSignal:
+			var more bool
+			if more, err = c.sendBatch(); err == nil && more {
+				c.signalSend()
+			}
+		}
+
+		if err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+// writeControl sends a ping or pong immediately
+func (c *MConnection) writeControl(packetType byte) error {
+	if err := c.bufW.WriteByte(packetType); err != nil {
+		return err
+	}
+	c.sendLimiter.wait(1, c.quit)
+	return c.bufW.Flush()
+}
+
+// sendBatch writes up to numBatchPackets packets and flushes. It reports
+// whether data is still queued.
+func (c *MConnection) sendBatch() (bool, error) {
+	for range numBatchPackets {
+		ch := c.nextChannel()
+		if ch == nil {
+			return false, c.bufW.Flush()
+		}
+
+		n, err := ch.writePacket(c.bufW, c.cfg.MaxPacketPayloadSize)
+		if err != nil {
+			return false, err
+		}
+		c.sendLimiter.wait(n, c.quit)
+	}
+
+	return true, c.bufW.Flush()
+}
+
+// nextChannel picks the channel with pending data that has sent the least
+// relative to its priority
+func (c *MConnection) nextChannel() *channel {
+	var best *channel
+	bestRatio := math.MaxFloat64
+
+	for _, ch := range c.channels {
+		if !ch.hasPending() {
+			continue
+		}
+		ratio := float64(ch.recentlySent) / float64(ch.desc.Priority)
+		if ratio < bestRatio {
+			best, bestRatio = ch, ratio
+		}
+	}
+	return best
+}
+
+// recvRoutine reads packets until the connection fails
+func (c *MConnection) recvRoutine() {
+	for {
+		n, err := c.readPacket()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.recvLimiter.wait(n, c.quit)
+	}
+}
+
+// readPacket reads and handles one packet, returning its size
+func (c *MConnection) readPacket() (int, error) {
+	packetType, err := c.bufR.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch packetType {
+	case packetTypePing:
+		select {
+		case c.pongSignal <- struct{}{}:
+		default:
+		}
+		return 1, nil
+
+	case packetTypePong:
+		select {
+		case c.pongRecv <- struct{}{}:
+		default:
+		}
+		return 1, nil
+
+	case packetTypeMsg:
+		var header [packetMsgHeaderSize - 1]byte
+		if _, err := io.ReadFull(c.bufR, header[:]); err != nil {
+			return 0, err
+		}
+
+		chID, eof := header[0], header[1] == 1
+		payloadLen := int(binary.BigEndian.Uint16(header[2:]))
+		if payloadLen > c.cfg.MaxPacketPayloadSize {
+			return 0, fmt.Errorf("%w: %d bytes", ErrPacketTooLarge, payloadLen)
+		}
+
+		ch, ok := c.byID[chID]
+		if !ok {
+			return 0, fmt.Errorf("%w: 0x%02x", ErrUnknownChannel, chID)
+		}
+
+		payload := make([]byte, payloadLen)
+		if _, err := io.ReadFull(c.bufR, payload); err != nil {
+			return 0, err
+		}
+
+		msg, err := ch.recvPacket(payload, eof)
+		if err != nil {
+			return 0, err
+		}
+		if msg != nil && c.onReceive != nil {
+			c.onReceive(chID, msg)
+		}
+		return packetMsgHeaderSize + payloadLen, nil
+
+	default:
+		return 0, fmt.Errorf("%w: 0x%02x", ErrUnknownPacket, packetType)
+	}
+}
+
+// channel is the send queue and reassembly buffer of one channel
+type channel struct {
+	desc         ChannelDescriptor
+	sendQueue    chan []byte
+	sending      []byte // Rest of the message being sent; sendRoutine only
+	recving      []byte // Message being reassembled; recvRoutine only
+	recentlySent int64  // Bytes sent recently, decayed; sendRoutine only
+}
+
+func newChannel(desc ChannelDescriptor) *channel {
+	if desc.Priority <= 0 {
+		desc.Priority = 1
+	}
+	if desc.SendQueueCapacity <= 0 {
+		desc.SendQueueCapacity = defaultSendQueueCapacity
+	}
+	if desc.RecvMessageCapacity <= 0 {
+		desc.RecvMessageCapacity = defaultRecvMessageCapacity
+	}
+
+	return &channel{
+		desc:      desc,
+		sendQueue: make(chan []byte, desc.SendQueueCapacity),
+	}
+}
+
+// hasPending loads the next queued message if nothing is in flight
+func (ch *channel) hasPending() bool {
+	if ch.sending == nil {
+		select {
+		case msg := <-ch.sendQueue:
+			ch.sending = msg
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// writePacket writes the next fragment of the message in flight
+func (ch *channel) writePacket(w *bufio.Writer, maxPayload int) (int, error) {
+	payload := ch.sending[:min(len(ch.sending), maxPayload)]
+	eof := len(payload) == len(ch.sending)
+
+	var header [packetMsgHeaderSize]byte
+	header[0] = packetTypeMsg
+	header[1] = ch.desc.ID
+	if eof {
+		header[2] = 1
+	}
+	binary.BigEndian.PutUint16(header[3:], uint16(len(payload)))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return 0, err
+	}
+
+	if eof {
+		ch.sending = nil
+	} else {
+		ch.sending = ch.sending[len(payload):]
+	}
+
+	n := len(header) + len(payload)
+	ch.recentlySent += int64(n)
+	return n, nil
+}
+
+// recvPacket appends a fragment and returns the message once complete
+func (ch *channel) recvPacket(payload []byte, eof bool) ([]byte, error) {
+	if len(ch.recving)+len(payload) > ch.desc.RecvMessageCapacity {
+		return nil, fmt.Errorf("%w: channel 0x%02x", ErrMessageTooLarge, ch.desc.ID)
+	}
+
+	ch.recving = append(ch.recving, payload...)
+	if !eof {
+		return nil, nil
+	}
+
+	msg := ch.recving
+	ch.recving = nil
+	return msg, nil
+}
+
+func (ch *channel) decayRecentlySent() {
+	ch.recentlySent = int64(float64(ch.recentlySent) * recentlySentDecay)
+}
+
+// rateLimiter is a token bucket holding up to one second of traffic.
+// It is used from a single goroutine.
+type rateLimiter struct {
+	rate   float64 // Bytes per second, 0 = unlimited
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// wait takes n bytes from the bucket, sleeping until they are available or
+// quit is closed
+func (r *rateLimiter) wait(n int, quit <-chan struct{}) {
+	if r.rate <= 0 {
+		return
+	}
+
+	now := time.Now()
+	r.tokens = min(r.rate, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	r.last = now
+	r.tokens -= float64(n)
+
+	if r.tokens >= 0 {
+		return
+	}
+
+	timer := time.NewTimer(time.Duration(-r.tokens / r.rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-quit:
+	}
+}
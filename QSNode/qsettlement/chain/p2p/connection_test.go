@@ -0,0 +1,236 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// received is a message delivered by an MConnection
+type received struct {
+	chID byte
+	msg  []byte
+}
+
+// testMConnConfig is the default config with small packets and no rate limit
+func testMConnConfig() MConnConfig {
+	cfg := DefaultMConnConfig()
+	cfg.SendRate = 0
+	cfg.RecvRate = 0
+	cfg.MaxPacketPayloadSize = 256
+	return cfg
+}
+
+// newTestMConn returns a started MConnection over conn that reports its
+// messages and errors on the returned channels
+func newTestMConn(t *testing.T, conn net.Conn, chDescs []*ChannelDescriptor, cfg MConnConfig) (*MConnection, <-chan received, <-chan error) {
+	t.Helper()
+
+	msgs := make(chan received, 64)
+	errs := make(chan error, 4)
+	mconn, err := NewMConnection(conn, chDescs,
+		func(chID byte, msg []byte) { msgs <- received{chID, msg} },
+		func(err error) { errs <- err },
+		cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mconn.Start()
+	t.Cleanup(mconn.Stop)
+	return mconn, msgs, errs
+}
+
+// writeRawPacket writes a message packet straight to conn
+func writeRawPacket(conn net.Conn, chID byte, eof bool, payload []byte) error {
+	header := []byte{packetTypeMsg, chID, 0, 0, 0}
+	if eof {
+		header[2] = 1
+	}
+	binary.BigEndian.PutUint16(header[3:], uint16(len(payload)))
+	_, err := conn.Write(append(header, payload...))
+	return err
+}
+
+// waitError returns the connection error, failing the test if none comes
+func waitError(t *testing.T, errs <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection did not fail")
+		return nil
+	}
+}
+
+func TestMConnectionReassemblesMessages(t *testing.T) {
+	a, b := net.Pipe()
+	descs := []*ChannelDescriptor{{ID: 0x01}}
+	sender, _, _ := newTestMConn(t, a, descs, testMConnConfig())
+	_, msgs, _ := newTestMConn(t, b, descs, testMConnConfig())
+
+	// 40 packets of 256 bytes, the last one short
+	msg := make([]byte, 40*256-17)
+	rand.Read(msg)
+	small := []byte("after")
+	if !sender.Send(0x01, msg) || !sender.Send(0x01, small) {
+		t.Fatal("Send failed")
+	}
+
+	for _, want := range [][]byte{msg, small} {
+		select {
+		case got := <-msgs:
+			if got.chID != 0x01 || !bytes.Equal(got.msg, want) {
+				t.Fatalf("received %d bytes on 0x%02x, want %d bytes on 0x01", len(got.msg), got.chID, len(want))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
+
+func TestMConnectionPrioritisesChannels(t *testing.T) {
+	const bulk, vote byte = 0x01, 0x02
+	descs := []*ChannelDescriptor{
+		{ID: bulk, Priority: 1},
+		{ID: vote, Priority: 10},
+	}
+
+	a, b := net.Pipe()
+	sender, _, _ := newTestMConn(t, a, descs, testMConnConfig())
+	_, msgs, _ := newTestMConn(t, b, descs, testMConnConfig())
+
+	// Each bulk message is 64 packets
+	block := make([]byte, 64*256)
+	for range 4 {
+		if !sender.Send(bulk, block) {
+			t.Fatal("Send failed")
+		}
+	}
+
+	// Once the bulk transfer is under way a vote overtakes the rest of it
+	var order []byte
+	for len(order) < 5 {
+		select {
+		case got := <-msgs:
+			order = append(order, got.chID)
+			if len(order) == 1 && !sender.Send(vote, []byte("vote")) {
+				t.Fatal("Send failed")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %x, want 5 messages", order)
+		}
+	}
+	if !bytes.Equal(order, []byte{bulk, vote, bulk, bulk, bulk}) {
+		t.Fatalf("messages arrived on channels %x, want the vote second", order)
+	}
+}
+
+func TestMConnectionRejectsOversizedInput(t *testing.T) {
+	descs := []*ChannelDescriptor{{ID: 0x01, RecvMessageCapacity: 600}}
+
+	for _, tt := range []struct {
+		name    string
+		packets [][]byte
+		want    error
+	}{
+		{"oversized packet", [][]byte{make([]byte, 257)}, ErrPacketTooLarge},
+		{"oversized message", [][]byte{make([]byte, 256), make([]byte, 256), make([]byte, 256)}, ErrMessageTooLarge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, conn := net.Pipe()
+			defer raw.Close()
+			_, msgs, errs := newTestMConn(t, conn, descs, testMConnConfig())
+
+			go func() {
+				for i, payload := range tt.packets {
+					if writeRawPacket(raw, 0x01, i == len(tt.packets)-1, payload) != nil {
+						return
+					}
+				}
+			}()
+
+			if err := waitError(t, errs); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if len(msgs) != 0 {
+				t.Fatal("oversized input was delivered")
+			}
+		})
+	}
+}
+
+func TestMConnectionPongTimeout(t *testing.T) {
+	raw, conn := net.Pipe()
+	defer raw.Close()
+
+	// The remote end reads our pings and never answers
+	go io.Copy(io.Discard, raw)
+
+	cfg := testMConnConfig()
+	cfg.PingInterval = 20 * time.Millisecond
+	cfg.PongTimeout = 50 * time.Millisecond
+	_, _, errs := newTestMConn(t, conn, []*ChannelDescriptor{{ID: 0x01}}, cfg)
+
+	if err := waitError(t, errs); !errors.Is(err, ErrPongTimeout) {
+		t.Fatalf("got %v, want ErrPongTimeout", err)
+	}
+}
+
+func TestMConnectionReportsErrorOnce(t *testing.T) {
+	raw, conn := net.Pipe()
+
+	var calls atomic.Int32
+	failed := make(chan error, 1)
+	mconn, err := NewMConnection(conn, []*ChannelDescriptor{{ID: 0x01}}, nil,
+		func(err error) {
+			calls.Add(1)
+			select {
+			case failed <- err:
+			default:
+			}
+		}, testMConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mconn.Start()
+	defer mconn.Stop()
+
+	// Nobody reads raw, so the send routine is blocked writing when a bad
+	// packet fails the receive routine; both routines then fail
+	mconn.TrySend(0x01, make([]byte, 4096))
+	raw.Write([]byte{0xff})
+	raw.Close()
+
+	if err := waitError(t, failed); !errors.Is(err, ErrUnknownPacket) && !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("onError called %d times, want 1", n)
+	}
+
+	// Stopping a healthy connection reports nothing
+	raw, conn = net.Pipe()
+	defer raw.Close()
+	calls.Store(0)
+	mconn, err = NewMConnection(conn, []*ChannelDescriptor{{ID: 0x01}}, nil,
+		func(error) { calls.Add(1) }, testMConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mconn.Start()
+	mconn.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("onError called %d times after Stop, want 0", n)
+	}
+}
@@ -0,0 +1,576 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/consensus"
+)
+
+// handshakeProtocol is absorbed first into every transcript and prefixes
+// every transcript signature
+const handshakeProtocol = "AUREO-PQ-HANDSHAKE-v1"
+
+// DefaultHandshakeKEM is the ephemeral KEM used when none is configured
+const DefaultHandshakeKEM = "kyber768"
+
+// Handshake versions. Each side offers its highest version in its hello and
+// both use the lower of the two; the hellos are part of the signed
+// transcript, so a relay cannot downgrade a session unnoticed.
+const (
+	HandshakeVersionPQ     byte = 0x01 // Kyber key exchange
+	HandshakeVersionHybrid byte = 0x02 // X25519 ECDH combined with Kyber
+)
+
+// DefaultHandshakeTimeout bounds a whole handshake when none is configured
+const DefaultHandshakeTimeout = 20 * time.Second
+
+const x25519KeySize = 32
+
+const (
+	roleInitiator = "initiator"
+	roleResponder = "responder"
+)
+
+// Handshake errors, wrapped in a *HandshakeError
+var (
+	ErrHandshakeConfig    = errors.New("invalid handshake config")
+	ErrHandshakeMalformed = errors.New("malformed handshake message")
+	ErrHandshakeVersion   = errors.New("no acceptable handshake version")
+	ErrBadPeerSignature   = errors.New("peer transcript signature invalid")
+	ErrPeerIDMismatch     = errors.New("peer node ID does not match expected")
+	ErrPeerNotAllowed     = errors.New("peer not allowed")
+)
+
+// HandshakeError reports the handshake step that failed
+type HandshakeError struct {
+	Step string
+	Err  error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("pq handshake failed at %s: %v", e.Step, e.Err)
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// PeerVerifier decides whether an authenticated peer may connect
+type PeerVerifier interface {
+	VerifyPeer(id NodeID, pubKey []byte) error
+}
+
+// PeerVerifierFunc adapts a function to PeerVerifier
+type PeerVerifierFunc func(id NodeID, pubKey []byte) error
+
+// VerifyPeer calls f(id, pubKey)
+func (f PeerVerifierFunc) VerifyPeer(id NodeID, pubKey []byte) error {
+	return f(id, pubKey)
+}
+
+// AllowNodeIDs admits only the listed node IDs
+func AllowNodeIDs(ids ...NodeID) PeerVerifier {
+	allowed := make(map[NodeID]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+
+	return PeerVerifierFunc(func(id NodeID, _ []byte) error {
+		if !allowed[id] {
+			return fmt.Errorf("%w: %s", ErrPeerNotAllowed, id)
+		}
+		return nil
+	})
+}
+
+// ValidatorSetVerifier admits only peers whose node key is the Dilithium2
+// key of an active validator in the registry
+func ValidatorSetVerifier(registry *consensus.ValidatorRegistry) PeerVerifier {
+	return PeerVerifierFunc(func(id NodeID, pubKey []byte) error {
+		for _, v := range registry.GetAllActiveValidators() {
+			if v.Algorithm == NodeKeyScheme && bytes.Equal(v.PQPublicKey, pubKey) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s is not an active validator", ErrPeerNotAllowed, id)
+	})
+}
+
+// HandshakeConfig configures both sides of the PQ handshake
+type HandshakeConfig struct {
+	NodeKey    *NodeKey      // Signs our transcript; its ID is what peers see
+	ChainID    uint64        // Peers must match, config.DefaultChainID if zero
+	KEMs       []string      // Ephemeral KEMs offered, [DefaultHandshakeKEM] if empty
+	Version    byte          // Highest version offered, HandshakeVersionPQ if zero
+	MinVersion byte          // Lowest version accepted, HandshakeVersionPQ if zero
+	Timeout    time.Duration // Deadline for the whole handshake, DefaultHandshakeTimeout if zero
+	Verifier   PeerVerifier  // Admits authenticated peers; nil admits any
+	Rekey      *RekeyPolicy  // Session rekeying, DefaultRekeyPolicy() if nil
+}
+
+// PQHandshake performs the PQ handshake as the dialing peer. If expected is
+// non-empty the remote must authenticate as that node ID.
+//
+//	-> hello_i             version, chain ID, KEMs and signature schemes
+//	<- hello_r
+//	-> status_i            accept, or reject with a reason
+//	<- status_r
+//	-> e_i [|| x_i]        initiator ephemeral KEM (and X25519) public key
+//	<- e_r [|| x_r]        responder ephemeral KEM (and X25519) public key
+//	-> ct_i = Encaps(e_r)
+//	<- ct_r = Encaps(e_i)
+//	-> s_i, Sign(s_i, h)   initiator node key and transcript signature
+//	<- s_r, Sign(s_r, h)   responder node key and transcript signature
+//
+// h is the SHA3-256 hash of every message before the signatures, so a relay
+// that substitutes its own ephemeral keys cannot produce valid signatures.
+// The session key is derived from the transcript after both auth frames,
+// so a relay that swaps in its own node key and signature over h ends up
+// with peers holding different keys rather than an unknown key share.
+// With HandshakeVersionHybrid the X25519 secret is mixed into the session
+// key alongside both KEM secrets, so breaking either primitive alone does
+// not expose the session.
+//
+// Every frame is checked against the size its position allows before it is
+// read, and the whole exchange must finish within the configured timeout.
+func PQHandshake(conn net.Conn, cfg *HandshakeConfig, expected NodeID) (*PQSecureConn, error) {
+	return runHandshake(conn, cfg, true, expected)
+}
+
+// PQHandshakeListener performs the PQ handshake as the accepting peer
+func PQHandshakeListener(conn net.Conn, cfg *HandshakeConfig) (*PQSecureConn, error) {
+	return runHandshake(conn, cfg, false, "")
+}
+
+// handshake holds the state of one handshake run
+type handshake struct {
+	conn       net.Conn
+	cfg        *HandshakeConfig
+	initiator  bool
+	hello      *Hello
+	minVersion byte
+	transcript hash.Hash
+}
+
+// newHandshake validates cfg and builds our hello
+func newHandshake(conn net.Conn, cfg *HandshakeConfig, initiator bool) (*handshake, error) {
+	if cfg == nil || cfg.NodeKey == nil {
+		return nil, fmt.Errorf("%w: node key required", ErrHandshakeConfig)
+	}
+
+	hello := &Hello{
+		Version:    cfg.Version,
+		ChainID:    cfg.ChainID,
+		KEMs:       cfg.KEMs,
+		SigSchemes: []string{NodeKeyScheme},
+	}
+	if hello.Version == 0 {
+		hello.Version = HandshakeVersionPQ
+	}
+	if hello.ChainID == 0 {
+		hello.ChainID = config.DefaultChainID
+	}
+	if len(hello.KEMs) == 0 {
+		hello.KEMs = []string{DefaultHandshakeKEM}
+	}
+
+	minVersion := cfg.MinVersion
+	if minVersion == 0 {
+		minVersion = HandshakeVersionPQ
+	}
+	if hello.Version > HandshakeVersionHybrid || minVersion > hello.Version {
+		return nil, fmt.Errorf("%w: offering version %d with minimum %d", ErrHandshakeConfig, hello.Version, minVersion)
+	}
+
+	for _, kem := range hello.KEMs {
+		if _, err := kemScheme(kem); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrHandshakeConfig, err)
+		}
+	}
+
+	return &handshake{
+		conn:       conn,
+		cfg:        cfg,
+		initiator:  initiator,
+		hello:      hello,
+		minVersion: minVersion,
+		transcript: sha3.New256(),
+	}, nil
+}
+
+func runHandshake(conn net.Conn, cfg *HandshakeConfig, initiator bool, expected NodeID) (*PQSecureConn, error) {
+	hs, err := newHandshake(conn, cfg, initiator)
+	if err != nil {
+		return nil, &HandshakeError{Step: "config", Err: err}
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, &HandshakeError{Step: "config", Err: err}
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	hs.absorb([]byte(handshakeProtocol))
+
+	// Step 1: Exchange hellos and agree on version and algorithms
+	params, err := hs.negotiate()
+	if err != nil {
+		return nil, &HandshakeError{Step: "negotiation", Err: err}
+	}
+
+	ephemeral, err := GenerateKyberKEM(params.kem)
+	if err != nil {
+		return nil, &HandshakeError{Step: "key exchange", Err: err}
+	}
+	scheme := ephemeral.scheme
+
+	// Step 2: Exchange ephemeral public keys
+	keyMsg := ephemeral.PublicKey()
+	keyMsgSize := scheme.PublicKeySize()
+	var x25519Key *ecdh.PrivateKey
+	if params.version == HandshakeVersionHybrid {
+		if x25519Key, err = ecdh.X25519().GenerateKey(rand.Reader); err != nil {
+			return nil, &HandshakeError{Step: "key exchange", Err: err}
+		}
+		keyMsg = append(keyMsg[:len(keyMsg):len(keyMsg)], x25519Key.PublicKey().Bytes()...)
+		keyMsgSize += x25519KeySize
+	}
+
+	peerKeyMsg, err := hs.exchange(keyMsg, keyMsgSize)
+	if err != nil {
+		return nil, &HandshakeError{Step: "key exchange", Err: err}
+	}
+	if len(peerKeyMsg) != keyMsgSize {
+		return nil, &HandshakeError{Step: "key exchange", Err: fmt.Errorf("%w: key message of %d bytes", ErrHandshakeMalformed, len(peerKeyMsg))}
+	}
+
+	peerKEMKey := peerKeyMsg[:scheme.PublicKeySize()]
+	var classicalSecret []byte
+	if params.version == HandshakeVersionHybrid {
+		peerX25519, err := ecdh.X25519().NewPublicKey(peerKeyMsg[len(peerKEMKey):])
+		if err != nil {
+			return nil, &HandshakeError{Step: "key exchange", Err: fmt.Errorf("%w: %v", ErrHandshakeMalformed, err)}
+		}
+		if classicalSecret, err = x25519Key.ECDH(peerX25519); err != nil {
+			return nil, &HandshakeError{Step: "key exchange", Err: fmt.Errorf("%w: %v", ErrHandshakeMalformed, err)}
+		}
+	}
+
+	// Step 3: Encapsulate to the peer's key and exchange ciphertexts
+	ciphertext, sharedSecret, err := ephemeral.Encapsulate(peerKEMKey)
+	if err != nil {
+		return nil, &HandshakeError{Step: "key exchange", Err: fmt.Errorf("%w: %v", ErrHandshakeMalformed, err)}
+	}
+
+	peerCiphertext, err := hs.exchange(ciphertext, scheme.CiphertextSize())
+	if err != nil {
+		return nil, &HandshakeError{Step: "key exchange", Err: err}
+	}
+
+	peerSharedSecret, err := ephemeral.Decapsulate(peerCiphertext)
+	if err != nil {
+		return nil, &HandshakeError{Step: "key exchange", Err: fmt.Errorf("%w: %v", ErrHandshakeMalformed, err)}
+	}
+
+	transcriptHash := hs.transcript.Sum(nil)
+
+	// Step 4: Authenticate. The initiator proves itself first so the
+	// responder never reveals its identity to a peer it rejects.
+	var peerPubKey []byte
+	if initiator {
+		if err := hs.sendAuth(transcriptHash); err != nil {
+			return nil, err
+		}
+		if peerPubKey, err = hs.recvAuth(transcriptHash, expected); err != nil {
+			return nil, err
+		}
+	} else {
+		if peerPubKey, err = hs.recvAuth(transcriptHash, expected); err != nil {
+			return nil, err
+		}
+		if err := hs.sendAuth(transcriptHash); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 5: Derive the session key over the transcript including both
+	// auth frames, from both KEM secrets in initiator order followed by the
+	// X25519 secret when hybrid
+	initiatorSecret, responderSecret := sharedSecret, peerSharedSecret
+	if !initiator {
+		initiatorSecret, responderSecret = peerSharedSecret, sharedSecret
+	}
+
+	sharedSecrets := make([]byte, 0, len(initiatorSecret)+len(responderSecret)+len(classicalSecret))
+	sharedSecrets = append(sharedSecrets, initiatorSecret...)
+	sharedSecrets = append(sharedSecrets, responderSecret...)
+	sharedSecrets = append(sharedSecrets, classicalSecret...)
+
+	sessionSecret, err := hkdf.Extract(sha3.New256, sharedSecrets, hs.transcript.Sum(nil))
+	if err != nil {
+		return nil, &HandshakeError{Step: "session", Err: err}
+	}
+
+	secureConn, err := NewPQSecureConn(conn, sessionSecret, initiator)
+	if err != nil {
+		return nil, &HandshakeError{Step: "session", Err: err}
+	}
+	secureConn.remotePubKey = peerPubKey
+	if cfg.Rekey != nil {
+		secureConn.SetRekeyPolicy(*cfg.Rekey)
+	}
+	secureConn.remoteID = PubKeyToID(peerPubKey)
+
+	log.Printf("[PQHandshake] Handshake complete with %s (version %d, %s, initiator: %t)\n",
+		secureConn.remoteID, params.version, params.kem, initiator)
+
+	return secureConn, nil
+}
+
+// negotiate exchanges hellos, then an accept or reject status, so that a
+// peer we turn away learns why instead of seeing the connection drop
+func (hs *handshake) negotiate() (*negotiated, error) {
+	ourHello, err := hs.hello.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHandshakeConfig, err)
+	}
+
+	peerHelloMsg, err := hs.exchange(ourHello, maxHelloSize)
+	if err != nil {
+		return nil, err
+	}
+
+	peerHello, err := UnmarshalHello(peerHelloMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	initiatorHello, responderHello := hs.hello, peerHello
+	if !hs.initiator {
+		initiatorHello, responderHello = peerHello, hs.hello
+	}
+	params, negErr := negotiate(initiatorHello, responderHello, hs.minVersion)
+
+	status := []byte{statusAccept}
+	if negErr != nil {
+		reason := negErr.Error()
+		if len(reason) > maxRejectReason {
+			reason = reason[:maxRejectReason]
+		}
+		status = append([]byte{statusReject}, reason...)
+	}
+
+	// The initiator sends first; whoever rejects stops after sending
+	if hs.initiator {
+		if err := hs.send(status); err != nil {
+			return nil, err
+		}
+		if negErr != nil {
+			return nil, negErr
+		}
+		if err := hs.recvStatus(); err != nil {
+			return nil, err
+		}
+		return params, nil
+	}
+
+	if err := hs.recvStatus(); err != nil {
+		if negErr != nil {
+			return nil, negErr
+		}
+		return nil, err
+	}
+	if err := hs.send(status); err != nil {
+		return nil, err
+	}
+	if negErr != nil {
+		return nil, negErr
+	}
+	return params, nil
+}
+
+// recvStatus reads the peer's negotiation status
+func (hs *handshake) recvStatus() error {
+	status, err := hs.recv(maxStatusSize)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(status) == 1 && status[0] == statusAccept:
+		return nil
+	case len(status) >= 1 && status[0] == statusReject:
+		return fmt.Errorf("%w: %s", ErrRejectedByPeer, status[1:])
+	default:
+		return fmt.Errorf("%w: invalid status", ErrHandshakeMalformed)
+	}
+}
+
+// sendAuth sends our node public key and our signature over the transcript,
+// absorbing both
+func (hs *handshake) sendAuth(transcriptHash []byte) error {
+	role := roleResponder
+	if hs.initiator {
+		role = roleInitiator
+	}
+
+	sig, err := hs.cfg.NodeKey.Sign(authMessage(role, transcriptHash))
+	if err != nil {
+		return &HandshakeError{Step: "authentication", Err: fmt.Errorf("%w: %v", ErrHandshakeConfig, err)}
+	}
+
+	if err := hs.send(hs.cfg.NodeKey.PubKey); err != nil {
+		return &HandshakeError{Step: "authentication", Err: err}
+	}
+	if err := hs.send(sig); err != nil {
+		return &HandshakeError{Step: "authentication", Err: err}
+	}
+
+	return nil
+}
+
+// recvAuth reads and absorbs the peer's node public key and transcript
+// signature, and checks the resulting node ID against expected and the configured verifier
+func (hs *handshake) recvAuth(transcriptHash []byte, expected NodeID) ([]byte, error) {
+	peerRole := roleInitiator
+	if hs.initiator {
+		peerRole = roleResponder
+	}
+
+	peerPubKey, err := hs.recv(nodePubKeySize())
+	if err != nil {
+		return nil, &HandshakeError{Step: "authentication", Err: err}
+	}
+	sig, err := hs.recv(nodeSignatureSize())
+	if err != nil {
+		return nil, &HandshakeError{Step: "authentication", Err: err}
+	}
+
+	if !VerifyNodeSignature(peerPubKey, authMessage(peerRole, transcriptHash), sig) {
+		return nil, &HandshakeError{Step: "authentication", Err: ErrBadPeerSignature}
+	}
+
+	peerID := PubKeyToID(peerPubKey)
+	if expected != "" && peerID != expected {
+		return nil, &HandshakeError{
+			Step: "peer verification",
+			Err:  fmt.Errorf("%w: got %s, want %s", ErrPeerIDMismatch, peerID, expected),
+		}
+	}
+
+	if hs.cfg.Verifier != nil {
+		if err := hs.cfg.Verifier.VerifyPeer(peerID, peerPubKey); err != nil {
+			if !errors.Is(err, ErrPeerNotAllowed) {
+				err = fmt.Errorf("%w: %v", ErrPeerNotAllowed, err)
+			}
+			return nil, &HandshakeError{Step: "peer verification", Err: err}
+		}
+	}
+
+	return peerPubKey, nil
+}
+
+// exchange sends msg and receives the peer's counterpart of at most maxLen
+// bytes, initiator first, absorbing both into the transcript in wire order
+func (hs *handshake) exchange(msg []byte, maxLen int) ([]byte, error) {
+	if hs.initiator {
+		if err := hs.send(msg); err != nil {
+			return nil, err
+		}
+		return hs.recv(maxLen)
+	}
+
+	peerMsg, err := hs.recv(maxLen)
+	if err != nil {
+		return nil, err
+	}
+	return peerMsg, hs.send(msg)
+}
+
+func (hs *handshake) send(msg []byte) error {
+	if err := writeHandshakeFrame(hs.conn, msg); err != nil {
+		return err
+	}
+	hs.absorb(msg)
+	return nil
+}
+
+func (hs *handshake) recv(maxLen int) ([]byte, error) {
+	msg, err := readHandshakeFrame(hs.conn, maxLen)
+	if err != nil {
+		return nil, err
+	}
+	hs.absorb(msg)
+	return msg, nil
+}
+
+// absorb adds a length-prefixed message to the transcript
+func (hs *handshake) absorb(msg []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(msg)))
+	hs.transcript.Write(length[:])
+	hs.transcript.Write(msg)
+}
+
+// authMessage is what a peer in role signs over the transcript hash
+func authMessage(role string, transcriptHash []byte) []byte {
+	msg := make([]byte, 0, len(handshakeProtocol)+1+len(role)+len(transcriptHash))
+	msg = append(msg, handshakeProtocol...)
+	msg = append(msg, 0)
+	msg = append(msg, role...)
+	msg = append(msg, transcriptHash...)
+	return msg
+}
+
+// writeHandshakeFrame writes a [2-byte length][data] frame
+func writeHandshakeFrame(conn net.Conn, data []byte) error {
+	if len(data) > 65535 {
+		return fmt.Errorf("%w: frame of %d bytes", ErrHandshakeMalformed, len(data))
+	}
+
+	frame := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(frame[:2], uint16(len(data)))
+	copy(frame[2:], data)
+
+	if _, err := conn.Write(frame); err != nil {
+		return fmt.Errorf("failed to write handshake frame: %w", err)
+	}
+	return nil
+}
+
+// readHandshakeFrame reads a [2-byte length][data] frame, rejecting it
+// before reading the data if it is longer than maxLen
+func readHandshakeFrame(conn net.Conn, maxLen int) ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read handshake frame header: %w", err)
+	}
+
+	frameLen := int(binary.BigEndian.Uint16(header[:]))
+	if frameLen > maxLen {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrHandshakeFrameSize, frameLen, maxLen)
+	}
+
+	data := make([]byte, frameLen)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, fmt.Errorf("failed to read handshake frame: %w", err)
+	}
+	return data, nil
+}
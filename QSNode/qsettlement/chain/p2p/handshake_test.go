@@ -0,0 +1,506 @@
+package p2p
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// handshakeFrames is how many frames both sides send before the auth frames
+const handshakeFrames = 8
+
+func TestMain(m *testing.M) {
+	// Handshakes and the switch log every connection
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newTestNodeKey(tb testing.TB) *NodeKey {
+	tb.Helper()
+
+	key, err := GenerateNodeKey()
+	if err != nil {
+		tb.Fatalf("GenerateNodeKey: %v", err)
+	}
+	return key
+}
+
+func newTestHandshakeConfig(tb testing.TB) *HandshakeConfig {
+	tb.Helper()
+	return &HandshakeConfig{NodeKey: newTestNodeKey(tb), Timeout: 10 * time.Second}
+}
+
+type handshakeResult struct {
+	conn *PQSecureConn
+	err  error
+}
+
+// runPair runs both sides of a handshake over the given conns
+func runPair(initConn, respConn net.Conn, initCfg, respCfg *HandshakeConfig, expected NodeID) (handshakeResult, handshakeResult) {
+	respDone := make(chan handshakeResult, 1)
+	go func() {
+		conn, err := PQHandshakeListener(respConn, respCfg)
+		if err != nil {
+			respConn.Close()
+		}
+		respDone <- handshakeResult{conn, err}
+	}()
+
+	conn, err := PQHandshake(initConn, initCfg, expected)
+	if err != nil {
+		initConn.Close()
+	}
+	return handshakeResult{conn, err}, <-respDone
+}
+
+// secureConnPair returns both ends of a completed handshake over net.Pipe
+func secureConnPair(tb testing.TB) (*PQSecureConn, *PQSecureConn) {
+	tb.Helper()
+
+	a, b := net.Pipe()
+	initiator, responder := runPair(a, b, newTestHandshakeConfig(tb), newTestHandshakeConfig(tb), "")
+	if initiator.err != nil || responder.err != nil {
+		tb.Fatalf("handshake failed: initiator %v, responder %v", initiator.err, responder.err)
+	}
+	tb.Cleanup(func() {
+		initiator.conn.Close()
+		responder.conn.Close()
+	})
+	return initiator.conn, responder.conn
+}
+
+// relayFrame rewrites frame i of the handshake; h is the transcript hash
+// before the auth frames once i >= handshakeFrames
+type relayFrame func(i int, frame, h []byte) []byte
+
+// runRelay sits between an initiator and a responder, forwarding handshake
+// frames through rewrite and then relaying raw bytes both ways
+func runRelay(toInit, toResp net.Conn, rewrite relayFrame) {
+	defer toInit.Close()
+	defer toResp.Close()
+
+	transcript := sha3.New256()
+	absorb := func(msg []byte) {
+		h := &handshake{transcript: transcript}
+		h.absorb(msg)
+	}
+	absorb([]byte(handshakeProtocol))
+
+	var h []byte
+	for i := 0; i < handshakeFrames+4; i++ {
+		// Frames alternate until the initiator sends both its auth frames
+		// and the responder replies with both of its own
+		src, dst := toInit, toResp
+		if (i < handshakeFrames && i%2 == 1) || i >= handshakeFrames+2 {
+			src, dst = toResp, toInit
+		}
+
+		frame, err := readHandshakeFrame(src, 65535)
+		if err != nil {
+			return
+		}
+		if i < handshakeFrames {
+			absorb(frame)
+		} else if h == nil {
+			h = transcript.Sum(nil)
+		}
+		if err := writeHandshakeFrame(dst, rewrite(i, frame, h)); err != nil {
+			return
+		}
+	}
+
+	go io.Copy(toResp, toInit)
+	io.Copy(toInit, toResp)
+}
+
+// relayedPair runs a handshake through runRelay
+func relayedPair(t *testing.T, initCfg, respCfg *HandshakeConfig, expected NodeID, rewrite relayFrame) (handshakeResult, handshakeResult) {
+	t.Helper()
+
+	initConn, relayInit := net.Pipe()
+	relayResp, respConn := net.Pipe()
+	go runRelay(relayInit, relayResp, rewrite)
+
+	initiator, responder := runPair(initConn, respConn, initCfg, respCfg, expected)
+	t.Cleanup(func() {
+		for _, r := range []handshakeResult{initiator, responder} {
+			if r.conn != nil {
+				r.conn.Close()
+			}
+		}
+	})
+	return initiator, responder
+}
+
+func TestHandshakeAuthenticatesBothSides(t *testing.T) {
+	for _, version := range []byte{HandshakeVersionPQ, HandshakeVersionHybrid} {
+		initCfg, respCfg := newTestHandshakeConfig(t), newTestHandshakeConfig(t)
+		initCfg.Version, respCfg.Version = version, version
+
+		initiator, responder := relayedPair(t, initCfg, respCfg, respCfg.NodeKey.ID(),
+			func(_ int, frame, _ []byte) []byte { return frame })
+		if initiator.err != nil || responder.err != nil {
+			t.Fatalf("version %d: initiator %v, responder %v", version, initiator.err, responder.err)
+		}
+
+		if initiator.conn.RemoteID() != respCfg.NodeKey.ID() || responder.conn.RemoteID() != initCfg.NodeKey.ID() {
+			t.Fatalf("version %d: remote IDs %s, %s", version, initiator.conn.RemoteID(), responder.conn.RemoteID())
+		}
+		checkRoundTrip(t, initiator.conn, responder.conn, []byte("ping"))
+	}
+}
+
+func TestHandshakeRejectsTamperedFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame int
+		want  error
+	}{
+		// Every frame before the signatures is covered by them
+		{"initiator key message", 4, ErrBadPeerSignature},
+		{"responder key message", 5, ErrBadPeerSignature},
+		{"initiator ciphertext", 6, ErrBadPeerSignature},
+		{"responder ciphertext", 7, ErrBadPeerSignature},
+		{"initiator signature", handshakeFrames + 1, ErrBadPeerSignature},
+		{"responder signature", handshakeFrames + 3, ErrBadPeerSignature},
+	}
+
+	for _, tt := range tests {
+		initiator, responder := relayedPair(t, newTestHandshakeConfig(t), newTestHandshakeConfig(t), "",
+			func(i int, frame, _ []byte) []byte {
+				if i == tt.frame {
+					frame = append([]byte(nil), frame...)
+					frame[len(frame)-1] ^= 0x01
+				}
+				return frame
+			})
+
+		if !errors.Is(initiator.err, tt.want) && !errors.Is(responder.err, tt.want) {
+			t.Errorf("%s: got initiator %v, responder %v, want %v", tt.name, initiator.err, responder.err, tt.want)
+		}
+	}
+}
+
+// A relay that replaces the initiator's identity with its own, signing the
+// transcript it observed, must not leave the responder sharing a key with
+// the initiator while believing it is talking to the relay
+func TestHandshakeRejectsIdentitySwap(t *testing.T) {
+	relayKey := newTestNodeKey(t)
+	initCfg, respCfg := newTestHandshakeConfig(t), newTestHandshakeConfig(t)
+
+	initiator, responder := relayedPair(t, initCfg, respCfg, respCfg.NodeKey.ID(),
+		func(i int, frame, h []byte) []byte {
+			switch i {
+			case handshakeFrames:
+				return relayKey.PubKey
+			case handshakeFrames + 1:
+				sig, err := relayKey.Sign(authMessage(roleInitiator, h))
+				if err != nil {
+					t.Error(err)
+				}
+				return sig
+			}
+			return frame
+		})
+
+	// Each signature is valid over the transcript it covers, so both sides
+	// finish, but the responder believes it is talking to the relay
+	if initiator.err != nil || responder.err != nil {
+		t.Fatalf("handshake failed: initiator %v, responder %v", initiator.err, responder.err)
+	}
+	if responder.conn.RemoteID() != relayKey.ID() {
+		t.Fatalf("responder sees %s, want relay %s", responder.conn.RemoteID(), relayKey.ID())
+	}
+
+	// Traffic from the initiator must not authenticate as the relay's
+	go initiator.conn.Write([]byte("from the initiator"))
+	if _, err := responder.conn.Read(make([]byte, 64)); !errors.Is(err, ErrFrameAuth) {
+		t.Fatalf("responder read %v, want %v", err, ErrFrameAuth)
+	}
+}
+
+// A relay that rewrites the hellos to offer PQ-only, while both sides
+// prefer hybrid, must not get them to agree on a PQ-only session
+func TestHandshakeRejectsHybridDowngrade(t *testing.T) {
+	downgrade := func(frames ...int) relayFrame {
+		return func(i int, frame, _ []byte) []byte {
+			for _, f := range frames {
+				if i == f {
+					frame = append([]byte(nil), frame...)
+					frame[0] = HandshakeVersionPQ
+				}
+			}
+			return frame
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		frames []int
+	}{
+		{"both hellos", []int{0, 1}},
+		{"initiator hello", []int{0}},
+		{"responder hello", []int{1}},
+	} {
+		initCfg, respCfg := newTestHandshakeConfig(t), newTestHandshakeConfig(t)
+		initCfg.Version, respCfg.Version = HandshakeVersionHybrid, HandshakeVersionHybrid
+
+		initiator, responder := relayedPair(t, initCfg, respCfg, "", downgrade(tt.frames...))
+		if initiator.err == nil || responder.err == nil {
+			t.Fatalf("%s: handshake completed: initiator %v, responder %v", tt.name, initiator.err, responder.err)
+		}
+
+		// With both hellos rewritten the sides agree on PQ-only, and only
+		// the signed transcript catches it
+		if len(tt.frames) == 2 && !errors.Is(responder.err, ErrBadPeerSignature) {
+			t.Fatalf("%s: responder %v, want %v", tt.name, responder.err, ErrBadPeerSignature)
+		}
+	}
+}
+
+func TestHandshakeNegotiatesLowerVersion(t *testing.T) {
+	pqKeyMsg := defaultKEMKeySize(t)
+
+	for _, tt := range []struct {
+		initiator, responder byte
+		want                 byte
+	}{
+		{HandshakeVersionPQ, HandshakeVersionPQ, HandshakeVersionPQ},
+		{HandshakeVersionHybrid, HandshakeVersionPQ, HandshakeVersionPQ},
+		{HandshakeVersionPQ, HandshakeVersionHybrid, HandshakeVersionPQ},
+		{HandshakeVersionHybrid, HandshakeVersionHybrid, HandshakeVersionHybrid},
+	} {
+		initCfg, respCfg := newTestHandshakeConfig(t), newTestHandshakeConfig(t)
+		initCfg.Version, respCfg.Version = tt.initiator, tt.responder
+
+		// The key message carries an X25519 key only in hybrid sessions
+		var keyMsgSizes []int
+		initiator, responder := relayedPair(t, initCfg, respCfg, "", func(i int, frame, _ []byte) []byte {
+			if i == 4 || i == 5 {
+				keyMsgSizes = append(keyMsgSizes, len(frame))
+			}
+			return frame
+		})
+		if initiator.err != nil || responder.err != nil {
+			t.Fatalf("%d vs %d: initiator %v, responder %v", tt.initiator, tt.responder, initiator.err, responder.err)
+		}
+
+		want := pqKeyMsg
+		if tt.want == HandshakeVersionHybrid {
+			want += x25519KeySize
+		}
+		if len(keyMsgSizes) != 2 || keyMsgSizes[0] != want || keyMsgSizes[1] != want {
+			t.Fatalf("%d vs %d: key messages of %v bytes, want %d (version %d)", tt.initiator, tt.responder, keyMsgSizes, want, tt.want)
+		}
+		checkRoundTrip(t, initiator.conn, responder.conn, []byte("ping"))
+	}
+}
+
+// defaultKEMKeySize returns the public key size of DefaultHandshakeKEM
+func defaultKEMKeySize(t *testing.T) int {
+	t.Helper()
+
+	scheme, err := kemScheme(DefaultHandshakeKEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scheme.PublicKeySize()
+}
+
+// setVersion rewrites the version byte of a hello frame
+func setVersion(frame int, version byte) relayFrame {
+	return func(i int, data, _ []byte) []byte {
+		if i == frame {
+			data = append([]byte(nil), data...)
+			data[0] = version
+		}
+		return data
+	}
+}
+
+// oversize replaces frame with one of n bytes
+func oversize(frame, n int) relayFrame {
+	return func(i int, data, _ []byte) []byte {
+		if i == frame {
+			return make([]byte, n)
+		}
+		return data
+	}
+}
+
+func TestHandshakeRejectsNegotiation(t *testing.T) {
+	passthrough := func(_ int, frame, _ []byte) []byte { return frame }
+
+	tests := []struct {
+		name      string
+		configure func(initCfg, respCfg *HandshakeConfig)
+		rewrite   relayFrame
+		// Each side fails with its own error, or with ErrRejectedByPeer
+		// carrying the other side's error as the reason
+		initErr, respErr error
+	}{
+		{
+			name:      "chain ID mismatch",
+			configure: func(initCfg, _ *HandshakeConfig) { initCfg.ChainID = config.DefaultChainID + 1 },
+			rewrite:   passthrough,
+			initErr:   ErrChainIDMismatch,
+			respErr:   ErrChainIDMismatch,
+		},
+		{
+			name: "version below minimum",
+			configure: func(_, respCfg *HandshakeConfig) {
+				respCfg.Version, respCfg.MinVersion = HandshakeVersionHybrid, HandshakeVersionHybrid
+			},
+			rewrite: passthrough,
+			initErr: ErrHandshakeVersion,
+			respErr: ErrHandshakeVersion,
+		},
+		{
+			name: "responder version below initiator minimum",
+			configure: func(initCfg, _ *HandshakeConfig) {
+				initCfg.Version, initCfg.MinVersion = HandshakeVersionHybrid, HandshakeVersionHybrid
+			},
+			rewrite: passthrough,
+			initErr: ErrHandshakeVersion,
+			respErr: ErrHandshakeVersion,
+		},
+		{
+			name:      "unknown version",
+			configure: func(_, _ *HandshakeConfig) {},
+			rewrite:   setVersion(0, 0x00),
+			initErr:   ErrHandshakeVersion,
+			respErr:   ErrHandshakeVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		initCfg, respCfg := newTestHandshakeConfig(t), newTestHandshakeConfig(t)
+		tt.configure(initCfg, respCfg)
+
+		initiator, responder := relayedPair(t, initCfg, respCfg, "", tt.rewrite)
+		checkNegotiationError(t, tt.name+": initiator", initiator.err, tt.initErr)
+		checkNegotiationError(t, tt.name+": responder", responder.err, tt.respErr)
+	}
+}
+
+// checkNegotiationError accepts want itself, or a rejection whose reason is
+// want's message
+func checkNegotiationError(t *testing.T, name string, err, want error) {
+	t.Helper()
+
+	if errors.Is(err, want) {
+		return
+	}
+	if errors.Is(err, ErrRejectedByPeer) && strings.Contains(err.Error(), want.Error()) {
+		return
+	}
+	t.Errorf("%s: got %v, want %v", name, err, want)
+}
+
+func TestHandshakeRejectsUnknownConfiguredVersion(t *testing.T) {
+	initCfg, respCfg := newTestHandshakeConfig(t), newTestHandshakeConfig(t)
+	initCfg.Version = HandshakeVersionHybrid + 1
+
+	a, b := net.Pipe()
+	initiator, responder := runPair(a, b, initCfg, respCfg, "")
+	if !errors.Is(initiator.err, ErrHandshakeConfig) {
+		t.Fatalf("initiator: got %v, want %v", initiator.err, ErrHandshakeConfig)
+	}
+	if responder.err == nil {
+		t.Fatal("responder completed a handshake with a misconfigured initiator")
+	}
+}
+
+func TestHandshakeRejectsOversizedFrames(t *testing.T) {
+	keyMsgSize := defaultKEMKeySize(t)
+
+	for _, tt := range []struct {
+		name    string
+		rewrite relayFrame
+	}{
+		{"initiator hello", oversize(0, maxHelloSize+1)},
+		{"initiator status", oversize(2, maxStatusSize+1)},
+		{"initiator key message", oversize(4, keyMsgSize+1)},
+		{"initiator node key", oversize(handshakeFrames, nodePubKeySize()+1)},
+	} {
+		initiator, responder := relayedPair(t, newTestHandshakeConfig(t), newTestHandshakeConfig(t), "", tt.rewrite)
+		if !errors.Is(responder.err, ErrHandshakeFrameSize) {
+			t.Errorf("%s: responder got %v, want %v", tt.name, responder.err, ErrHandshakeFrameSize)
+		}
+		if initiator.err == nil {
+			t.Errorf("%s: initiator completed the handshake", tt.name)
+		}
+	}
+
+	// The responder's frames are bounded the same way
+	initiator, responder := relayedPair(t, newTestHandshakeConfig(t), newTestHandshakeConfig(t), "", oversize(1, maxHelloSize+1))
+	if !errors.Is(initiator.err, ErrHandshakeFrameSize) || responder.err == nil {
+		t.Fatalf("responder hello: initiator %v, responder %v", initiator.err, responder.err)
+	}
+}
+
+func TestHandshakeDeadline(t *testing.T) {
+	for _, initiator := range []bool{true, false} {
+		cfg := newTestHandshakeConfig(t)
+		cfg.Timeout = 50 * time.Millisecond
+
+		// The peer accepts the connection but never speaks or reads
+		conn, silent := net.Pipe()
+		t.Cleanup(func() { silent.Close() })
+
+		start := time.Now()
+		var err error
+		if initiator {
+			_, err = PQHandshake(conn, cfg, "")
+		} else {
+			_, err = PQHandshakeListener(conn, cfg)
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("initiator %t: got %v, want %v", initiator, err, os.ErrDeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("initiator %t: handshake gave up after %v", initiator, elapsed)
+		}
+	}
+}
+
+func TestHandshakeRejectsUnexpectedPeer(t *testing.T) {
+	a, b := net.Pipe()
+	other := newTestNodeKey(t)
+
+	initiator, _ := runPair(a, b, newTestHandshakeConfig(t), newTestHandshakeConfig(t), other.ID())
+	if !errors.Is(initiator.err, ErrPeerIDMismatch) {
+		t.Fatalf("got %v, want %v", initiator.err, ErrPeerIDMismatch)
+	}
+}
+
+// checkRoundTrip writes msg on from and reads it back on to
+func checkRoundTrip(t *testing.T, from, to *PQSecureConn, msg []byte) {
+	t.Helper()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := from.Write(msg)
+		errc <- err
+	}()
+
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(to, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if string(got) != string(msg) {
+		t.Fatalf("read %q, want %q", got, msg)
+	}
+}
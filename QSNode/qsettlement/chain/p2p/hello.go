@@ -0,0 +1,178 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Hello limits, enforced when encoding and decoding
+const (
+	maxHelloSize     = 1024
+	maxHelloAlgos    = 16
+	maxHelloAlgoName = 32
+	helloFixedSize   = 1 + 8 // version + chain ID
+)
+
+// Negotiation status, sent by each side after the hellos
+const (
+	statusAccept    byte = 0x00
+	statusReject    byte = 0x01 // Followed by a reason
+	maxRejectReason      = 256
+	maxStatusSize        = 1 + maxRejectReason
+)
+
+// Negotiation errors
+var (
+	ErrChainIDMismatch    = errors.New("peer is on a different chain")
+	ErrNoCommonKEM        = errors.New("no common key exchange algorithm")
+	ErrNoCommonSigScheme  = errors.New("no common signature scheme")
+	ErrRejectedByPeer     = errors.New("handshake rejected by peer")
+	ErrHandshakeFrameSize = errors.New("handshake frame exceeds size limit")
+)
+
+// Hello is the first handshake message from each side
+//
+//	[1 version][8 chain ID][1 n]{[1 len][KEM name]}*n[1 m]{[1 len][scheme name]}*m
+type Hello struct {
+	Version    byte     // Highest handshake version offered
+	ChainID    uint64   // Peers on other chains are rejected
+	KEMs       []string // Key exchange algorithms, most preferred first
+	SigSchemes []string // Node key signature schemes, most preferred first
+}
+
+// Marshal encodes the hello message
+func (h *Hello) Marshal() ([]byte, error) {
+	out := make([]byte, helloFixedSize, maxHelloSize)
+	out[0] = h.Version
+	binary.BigEndian.PutUint64(out[1:9], h.ChainID)
+
+	var err error
+	if out, err = appendAlgoList(out, h.KEMs); err != nil {
+		return nil, err
+	}
+	if out, err = appendAlgoList(out, h.SigSchemes); err != nil {
+		return nil, err
+	}
+
+	if len(out) > maxHelloSize {
+		return nil, fmt.Errorf("%w: hello is %d bytes", ErrHandshakeFrameSize, len(out))
+	}
+	return out, nil
+}
+
+// UnmarshalHello decodes a hello message
+func UnmarshalHello(data []byte) (*Hello, error) {
+	if len(data) < helloFixedSize+2 || len(data) > maxHelloSize {
+		return nil, fmt.Errorf("%w: hello of %d bytes", ErrHandshakeMalformed, len(data))
+	}
+
+	h := &Hello{
+		Version: data[0],
+		ChainID: binary.BigEndian.Uint64(data[1:9]),
+	}
+
+	rest := data[helloFixedSize:]
+	var err error
+	if h.KEMs, rest, err = readAlgoList(rest); err != nil {
+		return nil, err
+	}
+	if h.SigSchemes, rest, err = readAlgoList(rest); err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing hello bytes", ErrHandshakeMalformed, len(rest))
+	}
+
+	return h, nil
+}
+
+func appendAlgoList(out []byte, algos []string) ([]byte, error) {
+	if len(algos) == 0 || len(algos) > maxHelloAlgos {
+		return nil, fmt.Errorf("%w: must list 1-%d algorithms", ErrHandshakeConfig, maxHelloAlgos)
+	}
+
+	out = append(out, byte(len(algos)))
+	for _, algo := range algos {
+		if len(algo) == 0 || len(algo) > maxHelloAlgoName {
+			return nil, fmt.Errorf("%w: invalid algorithm name %q", ErrHandshakeConfig, algo)
+		}
+		out = append(out, byte(len(algo)))
+		out = append(out, algo...)
+	}
+	return out, nil
+}
+
+func readAlgoList(data []byte) ([]string, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, ErrHandshakeMalformed
+	}
+
+	count := int(data[0])
+	if count == 0 || count > maxHelloAlgos {
+		return nil, nil, fmt.Errorf("%w: %d algorithms listed", ErrHandshakeMalformed, count)
+	}
+	data = data[1:]
+
+	algos := make([]string, 0, count)
+	for range count {
+		if len(data) < 1 {
+			return nil, nil, ErrHandshakeMalformed
+		}
+		nameLen := int(data[0])
+		if nameLen == 0 || nameLen > maxHelloAlgoName || len(data) < 1+nameLen {
+			return nil, nil, ErrHandshakeMalformed
+		}
+		algos = append(algos, string(data[1:1+nameLen]))
+		data = data[1+nameLen:]
+	}
+	return algos, data, nil
+}
+
+// negotiated is the outcome of comparing both hellos
+type negotiated struct {
+	version   byte
+	kem       string
+	sigScheme string
+}
+
+// negotiate picks the session parameters from the initiator's and the
+// responder's hellos. Both sides compute the same result; algorithms are
+// taken in the initiator's order of preference.
+func negotiate(initiator, responder *Hello, minVersion byte) (*negotiated, error) {
+	if initiator.ChainID != responder.ChainID {
+		return nil, fmt.Errorf("%w: %d vs %d", ErrChainIDMismatch, initiator.ChainID, responder.ChainID)
+	}
+
+	version := min(initiator.Version, responder.Version, HandshakeVersionHybrid)
+	if version < minVersion {
+		return nil, fmt.Errorf("%w: best common version %d, minimum is %d", ErrHandshakeVersion, version, minVersion)
+	}
+
+	result := &negotiated{version: version}
+
+	for _, kem := range initiator.KEMs {
+		if slices.Contains(responder.KEMs, kem) {
+			if _, err := kemScheme(kem); err == nil {
+				result.kem = kem
+				break
+			}
+		}
+	}
+	if result.kem == "" {
+		return nil, fmt.Errorf("%w: %v vs %v", ErrNoCommonKEM, initiator.KEMs, responder.KEMs)
+	}
+
+	for _, scheme := range initiator.SigSchemes {
+		if scheme == NodeKeyScheme && slices.Contains(responder.SigSchemes, scheme) {
+			result.sigScheme = scheme
+			break
+		}
+	}
+	if result.sigScheme == "" {
+		return nil, fmt.Errorf("%w: %v vs %v", ErrNoCommonSigScheme, initiator.SigSchemes, responder.SigSchemes)
+	}
+
+	return result, nil
+}
@@ -3,413 +3,577 @@ package p2p
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
+	"crypto/hkdf"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync"
 	"time"
 
-	// Import Kyber from QSmart (path adjusted based on actual module layout)
-	// "github.com/mchawda/qsmart/pqcrypto/kyber"
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem512"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"golang.org/x/crypto/sha3"
 )
 
 // KyberKEM handles post-quantum key encapsulation mechanism
 type KyberKEM struct {
-	algorithm string // "kyber512", "kyber768", "kyber1024"
+	algorithm  string // "kyber512", "kyber768", "kyber1024"
+	scheme     kem.Scheme
 	privateKey []byte // KEM private key
 	publicKey  []byte // KEM public key
 }
 
+// kemScheme maps a Kyber algorithm name to its ML-KEM (FIPS 203) scheme
+func kemScheme(algo string) (kem.Scheme, error) {
+	switch algo {
+	case "kyber512":
+		return mlkem512.Scheme(), nil
+	case "kyber768":
+		return mlkem768.Scheme(), nil
+	case "kyber1024":
+		return mlkem1024.Scheme(), nil
+	default:
+		return nil, fmt.Errorf("unsupported Kyber algorithm: %s", algo)
+	}
+}
+
 // NewKyberKEM creates a new Kyber KEM instance
 func NewKyberKEM(algo string, privKey []byte, pubKey []byte) (*KyberKEM, error) {
-	if algo != "kyber512" && algo != "kyber768" && algo != "kyber1024" {
-		return nil, fmt.Errorf("unsupported Kyber algorithm: %s", algo)
+	scheme, err := kemScheme(algo)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(privKey) == 0 || len(pubKey) == 0 {
-		return nil, errors.New("private key and public key cannot be empty")
+	if len(privKey) != scheme.PrivateKeySize() || len(pubKey) != scheme.PublicKeySize() {
+		return nil, fmt.Errorf("invalid %s key pair: private %d bytes, public %d bytes", algo, len(privKey), len(pubKey))
 	}
 
 	return &KyberKEM{
 		algorithm:  algo,
+		scheme:     scheme,
 		privateKey: privKey,
 		publicKey:  pubKey,
 	}, nil
 }
 
+// GenerateKyberKEM creates a KyberKEM with a fresh keypair
+func GenerateKyberKEM(algo string) (*KyberKEM, error) {
+	pubKey, privKey, err := GenerateKeyPair(algo)
+	if err != nil {
+		return nil, err
+	}
+	return NewKyberKEM(algo, privKey, pubKey)
+}
+
 // GenerateKeyPair generates a new Kyber KEM keypair
-// In production, this uses actual Kyber implementation from QSmart
 func GenerateKeyPair(algo string) (pubKey []byte, privKey []byte, err error) {
-	if algo != "kyber512" && algo != "kyber768" && algo != "kyber1024" {
-		return nil, nil, fmt.Errorf("unsupported algorithm: %s", algo)
+	scheme, err := kemScheme(algo)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// TODO: Replace with actual Kyber key generation from QSmart
-	// pubKey, privKey, err := kyber.GenerateKeyPair768()
-
-	// Placeholder: generate mock keys
-	pubKey = make([]byte, 1184)  // Kyber768 public key is ~1184 bytes
-	privKey = make([]byte, 2400) // Kyber768 private key is ~2400 bytes
+	pk, sk, err := scheme.GenerateKeyPair()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
 
-	_, err = rand.Read(pubKey)
+	pubKey, err = pk.MarshalBinary()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate public key: %w", err)
+		return nil, nil, fmt.Errorf("failed to encode public key: %w", err)
 	}
 
-	_, err = rand.Read(privKey)
+	privKey, err = sk.MarshalBinary()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
 	}
 
 	return pubKey, privKey, nil
 }
 
+// PublicKey returns the KEM public key
+func (k *KyberKEM) PublicKey() []byte {
+	return k.publicKey
+}
+
 // Encapsulate creates a shared secret using peer's public key
 // Returns: (ciphertext, shared_secret, error)
-// In production, this uses actual Kyber encapsulation
 func (k *KyberKEM) Encapsulate(peerPublicKey []byte) (ciphertext []byte, sharedSecret []byte, err error) {
-	if len(peerPublicKey) == 0 {
-		return nil, nil, errors.New("peer public key cannot be empty")
-	}
-
-	// TODO: Replace with actual Kyber encapsulation from QSmart
-	// ct, ss, err := kyber.Encapsulate768(peerPublicKey)
-
-	// Placeholder: generate mock ciphertext and shared secret
-	ciphertext = make([]byte, 1088)  // Kyber768 ciphertext is ~1088 bytes
-	sharedSecret = make([]byte, 32)  // Shared secret is typically 32 bytes
-
-	_, err = rand.Read(ciphertext)
+	pk, err := k.scheme.UnmarshalBinaryPublicKey(peerPublicKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate ciphertext: %w", err)
+		return nil, nil, fmt.Errorf("invalid peer public key: %w", err)
 	}
 
-	_, err = rand.Read(sharedSecret)
+	ciphertext, sharedSecret, err = k.scheme.Encapsulate(pk)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate shared secret: %w", err)
+		return nil, nil, fmt.Errorf("encapsulation failed: %w", err)
 	}
 
-	log.Printf("[Kyber] Encapsulated with %s, ciphertext: %d bytes, secret: %d bytes\n",
-		k.algorithm, len(ciphertext), len(sharedSecret))
-
 	return ciphertext, sharedSecret, nil
 }
 
 // Decapsulate recovers the shared secret from ciphertext using private key
-// In production, this uses actual Kyber decapsulation
 func (k *KyberKEM) Decapsulate(ciphertext []byte) (sharedSecret []byte, err error) {
-	if len(ciphertext) == 0 {
-		return nil, errors.New("ciphertext cannot be empty")
+	if len(ciphertext) != k.scheme.CiphertextSize() {
+		return nil, fmt.Errorf("ciphertext must be %d bytes, got %d", k.scheme.CiphertextSize(), len(ciphertext))
 	}
 
-	// TODO: Replace with actual Kyber decapsulation from QSmart
-	// ss, err := kyber.Decapsulate768(k.privateKey, ciphertext)
-
-	// Placeholder: generate mock shared secret matching ciphertext
-	sharedSecret = make([]byte, 32)
-	_, err = rand.Read(sharedSecret)
+	sk, err := k.scheme.UnmarshalBinaryPrivateKey(k.privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate shared secret: %w", err)
+		return nil, fmt.Errorf("invalid private key: %w", err)
 	}
 
-	log.Printf("[Kyber] Decapsulated ciphertext (%d bytes), shared secret: %d bytes\n",
-		len(ciphertext), len(sharedSecret))
+	sharedSecret, err = k.scheme.Decapsulate(sk, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decapsulation failed: %w", err)
+	}
 
 	return sharedSecret, nil
 }
 
-// PQSecureConn wraps a network connection with PQ encryption using Kyber + AES
-type PQSecureConn struct {
-	conn         net.Conn
-	sharedSecret []byte
-	cipher       cipher.Block
-	encryptCtr   cipher.Stream
-	decryptCtr   cipher.Stream
-	readBuffer   []byte
-	nonce        uint64
+// Secure connection framing limits and key schedule labels
+const (
+	MaxFramePayload = 65536 // Largest plaintext carried by one frame
+
+	frameHeaderSize = 4
+	frameTypeSize   = 1
+	aeadKeySize     = 32      // AES-256
+	maxFramesPerKey = 1 << 32 // Forces a key update long before the nonce wraps
+
+	keyLabelInitiator = "aureo-pq initiator->responder"
+	keyLabelResponder = "aureo-pq responder->initiator"
+	keyLabelUpdate    = "aureo-pq key update"
+)
+
+// Frame types, the first byte of every decrypted frame
+const (
+	frameTypeData      byte = 0x00
+	frameTypeKeyUpdate byte = 0x01
+)
+
+// Secure connection errors
+var (
+	ErrFrameTooLarge  = errors.New("frame too large")
+	ErrFrameAuth      = errors.New("frame authentication failed")
+	ErrFrameType      = errors.New("unknown frame type")
+	ErrNonceExhausted = errors.New("frame nonce counter exhausted")
+)
+
+// RekeyPolicy controls when a PQSecureConn ratchets its send key. Limits
+// are checked before each write, so an idle link rekeys on its next write.
+type RekeyPolicy struct {
+	Interval time.Duration // Maximum age of a send key; 0 disables
+	Bytes    uint64        // Maximum bytes sent under one key; 0 disables
 }
 
-// NewPQSecureConn creates a new PQ-secure connection wrapper
-func NewPQSecureConn(conn net.Conn, sharedSecret []byte) (*PQSecureConn, error) {
-	if len(sharedSecret) < 32 {
-		return nil, errors.New("shared secret must be at least 32 bytes")
+// DefaultRekeyPolicy rekeys every hour or every 1 GiB sent
+func DefaultRekeyPolicy() RekeyPolicy {
+	return RekeyPolicy{
+		Interval: time.Hour,
+		Bytes:    1 << 30,
 	}
+}
 
-	// Use first 32 bytes of shared secret as AES key
-	aesKey := sharedSecret[:32]
+// frameKey is the key state of one direction of a PQSecureConn
+type frameKey struct {
+	key     []byte
+	aead    cipher.AEAD
+	nonce   uint64    // Frame counter, reset on every key update
+	created time.Time // When this key came into use
+	bytes   uint64    // Plaintext bytes processed under this key
+	epoch   uint64    // Number of key updates so far
+}
 
-	// Create AES cipher
-	block, err := aes.NewCipher(aesKey)
+// newFrameKey creates the AES-256-GCM state for one direction
+func newFrameKey(key []byte) (*frameKey, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
 	}
 
-	// Create IV from next 16 bytes of shared secret (or generate if not enough bytes)
-	var iv [16]byte
-	if len(sharedSecret) >= 48 {
-		copy(iv[:], sharedSecret[32:48])
-	} else {
-		copy(iv[:], make([]byte, 16))
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	stream := cipher.NewCTR(block, iv[:])
+	return &frameKey{key: key, aead: aead, created: time.Now()}, nil
+}
 
-	return &PQSecureConn{
-		conn:         conn,
-		sharedSecret: sharedSecret,
-		cipher:       block,
-		encryptCtr:   stream,
-		decryptCtr:   stream,
-		readBuffer:   make([]byte, 65536), // 64KB buffer
-		nonce:        0,
-	}, nil
+// ratchet replaces the key with HKDF-Expand(key, "aureo-pq key update") and
+// wipes the old one. HKDF is one-way, so a compromised key cannot be used
+// to recover earlier keys or decrypt earlier frames.
+func (k *frameKey) ratchet() error {
+	next, err := hkdf.Expand(sha3.New256, k.key, keyLabelUpdate, aeadKeySize)
+	if err != nil {
+		return fmt.Errorf("failed to ratchet key: %w", err)
+	}
+
+	updated, err := newFrameKey(next)
+	if err != nil {
+		return err
+	}
+
+	clear(k.key)
+	updated.epoch = k.epoch + 1
+	*k = *updated
+	return nil
 }
 
-// Write encrypts and sends data over the connection
-func (psc *PQSecureConn) Write(data []byte) (int, error) {
-	if len(data) == 0 {
-		return 0, nil
+// nextNonce returns the GCM nonce for the next frame and advances the counter
+func (k *frameKey) nextNonce() ([]byte, error) {
+	if k.nonce >= maxFramesPerKey {
+		return nil, ErrNonceExhausted
 	}
 
-	// Create frame: [4-byte length][encrypted data]
-	frame := make([]byte, 4+len(data))
-	binary.BigEndian.PutUint32(frame[:4], uint32(len(data)))
+	nonce := make([]byte, k.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], k.nonce)
+	k.nonce++
+	return nonce, nil
+}
+
+// PQSecureConn wraps a network connection with AES-256-GCM framing keyed by
+// the PQ handshake. Each direction has its own HKDF-derived key and a frame
+// counter used as the nonce, so frames cannot be reordered, replayed or
+// reflected back to their sender.
+//
+// Frame: [4-byte length][ciphertext || 16-byte tag], the length is
+// authenticated as additional data. The plaintext is [1-byte type][payload].
+//
+// Each side ratchets its own send key according to its RekeyPolicy by
+// sending a key update frame under the old key; the receiver ratchets its
+// receive key when it opens that frame.
+type PQSecureConn struct {
+	conn net.Conn
 
-	// Encrypt payload
-	ciphertext := make([]byte, len(data))
-	psc.encryptCtr.XORKeyStream(ciphertext, data)
-	copy(frame[4:], ciphertext)
+	sendMtx      sync.Mutex
+	sendKey      *frameKey
+	rekey        RekeyPolicy
+	pendingFrame []byte // Unsent tail of a frame interrupted by a write deadline
 
-	// Send to network
-	n, err := psc.conn.Write(frame)
-	log.Printf("[PQSecureConn] Write: %d bytes (frame: %d)\n", len(data), n)
+	recvMtx    sync.Mutex
+	recvKey    *frameKey
+	readBuffer []byte // Decrypted bytes not yet returned by Read
 
-	// Account for frame header
-	if n > 4 {
-		return n - 4, err
-	}
-	return 0, err
+	// Partially received frame, kept when a read deadline interrupts it
+	frameHeader [frameHeaderSize]byte
+	headerRead  int
+	frameBody   []byte // Allocated once the header is complete
+	bodyRead    int
+
+	remoteID     NodeID // Set by the handshake once the peer is authenticated
+	remotePubKey []byte
 }
 
-// Read receives and decrypts data from the connection
-func (psc *PQSecureConn) Read(p []byte) (int, error) {
-	// Read frame header (4 bytes length)
-	header := make([]byte, 4)
-	_, err := io.ReadFull(psc.conn, header)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read frame header: %w", err)
+// NewPQSecureConn creates a secure connection from the handshake session
+// secret. Both sides pass the same secret; initiator selects which derived
+// key is used for sending. The connection starts with DefaultRekeyPolicy.
+func NewPQSecureConn(conn net.Conn, sessionSecret []byte, initiator bool) (*PQSecureConn, error) {
+	if len(sessionSecret) < 32 {
+		return nil, errors.New("session secret must be at least 32 bytes")
 	}
 
-	frameLen := binary.BigEndian.Uint32(header)
-	if frameLen > 65536 {
-		return 0, errors.New("frame too large")
+	initiatorKey, err := hkdf.Expand(sha3.New256, sessionSecret, keyLabelInitiator, aeadKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive initiator key: %w", err)
 	}
 
-	// Read encrypted payload
-	encryptedData := make([]byte, frameLen)
-	_, err = io.ReadFull(psc.conn, encryptedData)
+	responderKey, err := hkdf.Expand(sha3.New256, sessionSecret, keyLabelResponder, aeadKeySize)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read frame data: %w", err)
+		return nil, fmt.Errorf("failed to derive responder key: %w", err)
 	}
 
-	// Decrypt
-	plaintext := make([]byte, frameLen)
-	psc.decryptCtr.XORKeyStream(plaintext, encryptedData)
+	sendKey, recvKey := initiatorKey, responderKey
+	if !initiator {
+		sendKey, recvKey = responderKey, initiatorKey
+	}
 
-	// Copy to output buffer
-	n := copy(p, plaintext)
-	log.Printf("[PQSecureConn] Read: %d bytes (decrypted)\n", n)
+	sendState, err := newFrameKey(sendKey)
+	if err != nil {
+		return nil, err
+	}
 
-	return n, nil
-}
+	recvState, err := newFrameKey(recvKey)
+	if err != nil {
+		return nil, err
+	}
 
-// Close closes the underlying connection
-func (psc *PQSecureConn) Close() error {
-	return psc.conn.Close()
+	return &PQSecureConn{
+		conn:    conn,
+		sendKey: sendState,
+		recvKey: recvState,
+		rekey:   DefaultRekeyPolicy(),
+	}, nil
 }
 
-// LocalAddr returns the local address
-func (psc *PQSecureConn) LocalAddr() net.Addr {
-	return psc.conn.LocalAddr()
-}
+// SetRekeyPolicy replaces the send key rekeying policy
+func (psc *PQSecureConn) SetRekeyPolicy(policy RekeyPolicy) {
+	psc.sendMtx.Lock()
+	defer psc.sendMtx.Unlock()
 
-// RemoteAddr returns the remote address
-func (psc *PQSecureConn) RemoteAddr() net.Addr {
-	return psc.conn.RemoteAddr()
+	psc.rekey = policy
 }
 
-// SetDeadline sets read/write deadline
-func (psc *PQSecureConn) SetDeadline(t time.Time) error {
-	return psc.conn.SetDeadline(t)
-}
+// KeyEpochs returns how many key updates have been sent and received
+func (psc *PQSecureConn) KeyEpochs() (send, recv uint64) {
+	psc.sendMtx.Lock()
+	send = psc.sendKey.epoch
+	psc.sendMtx.Unlock()
 
-// SetReadDeadline sets read deadline
-func (psc *PQSecureConn) SetReadDeadline(t time.Time) error {
-	return psc.conn.SetReadDeadline(t)
-}
+	psc.recvMtx.Lock()
+	recv = psc.recvKey.epoch
+	psc.recvMtx.Unlock()
 
-// SetWriteDeadline sets write deadline
-func (psc *PQSecureConn) SetWriteDeadline(t time.Time) error {
-	return psc.conn.SetWriteDeadline(t)
+	return send, recv
 }
 
-// PQHandshake performs a PQ-secure handshake using Kyber KEM
-// Called by peer initiating connection
-func PQHandshake(conn net.Conn, myKEM *KyberKEM) (*PQSecureConn, error) {
-	// Step 1: Send our public key
-	myPubKey := myKEM.publicKey
-	if len(myPubKey) > 65535 {
-		return nil, errors.New("public key too large")
-	}
+// Rekey sends a key update and ratchets the send key immediately
+func (psc *PQSecureConn) Rekey() error {
+	psc.sendMtx.Lock()
+	defer psc.sendMtx.Unlock()
 
-	pubKeyFrame := make([]byte, 2+len(myPubKey))
-	binary.BigEndian.PutUint16(pubKeyFrame[:2], uint16(len(myPubKey)))
-	copy(pubKeyFrame[2:], myPubKey)
+	return psc.updateSendKey()
+}
 
-	_, err := conn.Write(pubKeyFrame)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send public key: %w", err)
+// updateSendKey announces a key update under the current key, then ratchets;
+// sendMtx must be held. Once any of the update frame is sent the key is
+// ratcheted, even if the rest is still pending.
+func (psc *PQSecureConn) updateSendKey() error {
+	sent, err := psc.writeFrame(frameTypeKeyUpdate, nil)
+	if !sent {
+		return err
 	}
 
-	log.Printf("[PQHandshake] Sent public key (%d bytes)\n", len(myPubKey))
-
-	// Step 2: Receive peer's public key
-	peerPubKeyHeader := make([]byte, 2)
-	_, err = io.ReadFull(conn, peerPubKeyHeader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer public key header: %w", err)
+	if ratchetErr := psc.sendKey.ratchet(); ratchetErr != nil {
+		return ratchetErr
 	}
 
-	peerPubKeyLen := binary.BigEndian.Uint16(peerPubKeyHeader)
-	peerPubKey := make([]byte, peerPubKeyLen)
-	_, err = io.ReadFull(conn, peerPubKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer public key: %w", err)
-	}
+	log.Printf("[PQSecureConn] Send key updated (epoch %d)\n", psc.sendKey.epoch)
+	return err
+}
 
-	log.Printf("[PQHandshake] Received peer public key (%d bytes)\n", peerPubKeyLen)
+// rekeyDue reports whether the send key must be updated before sending n
+// more bytes; sendMtx must be held
+func (psc *PQSecureConn) rekeyDue(n int) bool {
+	key := psc.sendKey
 
-	// Step 3: Encapsulate (create shared secret for peer)
-	ciphertext, sharedSecret, err := myKEM.Encapsulate(peerPubKey)
-	if err != nil {
-		return nil, fmt.Errorf("encapsulation failed: %w", err)
+	if key.nonce >= maxFramesPerKey-1 {
+		return true
+	}
+	if psc.rekey.Bytes > 0 && key.bytes > 0 && key.bytes+uint64(n) > psc.rekey.Bytes {
+		return true
+	}
+	if psc.rekey.Interval > 0 && time.Since(key.created) >= psc.rekey.Interval {
+		return true
 	}
+	return false
+}
 
-	// Step 4: Send ciphertext to peer
-	ctFrame := make([]byte, 2+len(ciphertext))
-	binary.BigEndian.PutUint16(ctFrame[:2], uint16(len(ciphertext)))
-	copy(ctFrame[2:], ciphertext)
+// Write encrypts and sends data, split into frames of at most
+// MaxFramePayload bytes, updating the send key first when the rekey
+// policy requires it
+func (psc *PQSecureConn) Write(data []byte) (int, error) {
+	psc.sendMtx.Lock()
+	defer psc.sendMtx.Unlock()
 
-	_, err = conn.Write(ctFrame)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send ciphertext: %w", err)
-	}
+	written := 0
+	for written < len(data) {
+		chunk := data[written:min(written+MaxFramePayload, len(data))]
 
-	log.Printf("[PQHandshake] Sent ciphertext (%d bytes)\n", len(ciphertext))
+		if psc.rekeyDue(len(chunk)) {
+			if err := psc.updateSendKey(); err != nil {
+				return written, err
+			}
+		}
 
-	// Step 5: Receive peer's ciphertext
-	peerCtHeader := make([]byte, 2)
-	_, err = io.ReadFull(conn, peerCtHeader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer ciphertext header: %w", err)
+		sent, err := psc.writeFrame(frameTypeData, chunk)
+		if sent {
+			written += len(chunk)
+		}
+		if err != nil {
+			return written, err
+		}
 	}
 
-	peerCtLen := binary.BigEndian.Uint16(peerCtHeader)
-	peerCiphertext := make([]byte, peerCtLen)
-	_, err = io.ReadFull(conn, peerCiphertext)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer ciphertext: %w", err)
+	return written, nil
+}
+
+// writeFrame seals and sends one frame after any pending one; sendMtx must
+// be held. sent reports whether the frame is committed to the stream: a
+// frame interrupted before its first byte is dropped and its nonce reused,
+// one interrupted later has its tail sent ahead of the next frame.
+func (psc *PQSecureConn) writeFrame(frameType byte, data []byte) (sent bool, err error) {
+	if len(psc.pendingFrame) > 0 {
+		n, err := psc.conn.Write(psc.pendingFrame)
+		psc.pendingFrame = psc.pendingFrame[n:]
+		if err != nil {
+			return false, err
+		}
 	}
 
-	log.Printf("[PQHandshake] Received peer ciphertext (%d bytes)\n", peerCtLen)
+	key := psc.sendKey
 
-	// Step 6: Decapsulate peer's ciphertext to get shared secret
-	peerSharedSecret, err := myKEM.Decapsulate(peerCiphertext)
+	nonce, err := key.nextNonce()
 	if err != nil {
-		return nil, fmt.Errorf("decapsulation failed: %w", err)
+		return false, err
 	}
 
-	// Step 7: Combine both shared secrets for final session key
-	// sharedSecret from our encapsulation + peerSharedSecret from our decapsulation
-	finalSecret := append(sharedSecret, peerSharedSecret...)
+	plaintext := make([]byte, frameTypeSize+len(data))
+	plaintext[0] = frameType
+	copy(plaintext[frameTypeSize:], data)
 
-	log.Printf("[PQHandshake] Handshake complete. Final session key: %d bytes\n", len(finalSecret))
+	sealedLen := len(plaintext) + key.aead.Overhead()
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+sealedLen)
+	binary.BigEndian.PutUint32(frame, uint32(sealedLen))
+	frame = key.aead.Seal(frame, nonce, plaintext, frame[:frameHeaderSize])
 
-	// Create PQ-secure connection
-	return NewPQSecureConn(conn, finalSecret)
-}
+	n, err := psc.conn.Write(frame)
+	if n == 0 && err != nil {
+		key.nonce--
+		return false, err
+	}
 
-// PQHandshakeListener handles incoming PQ handshake connections
-func PQHandshakeListener(conn net.Conn, myKEM *KyberKEM) (*PQSecureConn, error) {
-	// Step 1: Receive peer's public key
-	peerPubKeyHeader := make([]byte, 2)
-	_, err := io.ReadFull(conn, peerPubKeyHeader)
+	key.bytes += uint64(len(data))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read peer public key header: %w", err)
+		psc.pendingFrame = frame[n:]
 	}
+	return true, err
+}
 
-	peerPubKeyLen := binary.BigEndian.Uint16(peerPubKeyHeader)
-	peerPubKey := make([]byte, peerPubKeyLen)
-	_, err = io.ReadFull(conn, peerPubKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer public key: %w", err)
+// Read returns decrypted data, keeping whatever part of a frame does not
+// fit in p for the next call
+func (psc *PQSecureConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
 	}
 
-	// Step 2: Send our public key
-	myPubKey := myKEM.publicKey
-	pubKeyFrame := make([]byte, 2+len(myPubKey))
-	binary.BigEndian.PutUint16(pubKeyFrame[:2], uint16(len(myPubKey)))
-	copy(pubKeyFrame[2:], myPubKey)
+	psc.recvMtx.Lock()
+	defer psc.recvMtx.Unlock()
 
-	_, err = conn.Write(pubKeyFrame)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send public key: %w", err)
-	}
+	for len(psc.readBuffer) == 0 {
+		frameType, payload, err := psc.readFrame()
+		if err != nil {
+			return 0, err
+		}
 
-	// Step 3: Receive peer's ciphertext
-	peerCtHeader := make([]byte, 2)
-	_, err = io.ReadFull(conn, peerCtHeader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer ciphertext header: %w", err)
+		switch frameType {
+		case frameTypeData:
+			psc.readBuffer = payload
+		case frameTypeKeyUpdate:
+			if err := psc.recvKey.ratchet(); err != nil {
+				return 0, err
+			}
+			log.Printf("[PQSecureConn] Receive key updated (epoch %d)\n", psc.recvKey.epoch)
+		default:
+			return 0, fmt.Errorf("%w: 0x%02x", ErrFrameType, frameType)
+		}
 	}
 
-	peerCtLen := binary.BigEndian.Uint16(peerCtHeader)
-	peerCiphertext := make([]byte, peerCtLen)
-	_, err = io.ReadFull(conn, peerCiphertext)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read peer ciphertext: %w", err)
-	}
+	n := copy(p, psc.readBuffer)
+	psc.readBuffer = psc.readBuffer[n:]
+
+	return n, nil
+}
 
-	// Step 4: Decapsulate to get shared secret
-	sharedSecret, err := myKEM.Decapsulate(peerCiphertext)
+// readFrame receives and opens one frame; recvMtx must be held. A clean
+// close between frames is reported as io.EOF. Errors from the underlying
+// connection are returned as is, so deadline errors remain net.Errors, and
+// whatever part of the frame had arrived is kept for the next call.
+func (psc *PQSecureConn) readFrame() (byte, []byte, error) {
+	key := psc.recvKey
+	minLen := uint32(frameTypeSize + key.aead.Overhead())
+
+	for psc.headerRead < frameHeaderSize {
+		n, err := psc.conn.Read(psc.frameHeader[psc.headerRead:])
+		psc.headerRead += n
+		if err != nil && psc.headerRead < frameHeaderSize {
+			if err == io.EOF && psc.headerRead > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+	}
+
+	if psc.frameBody == nil {
+		frameLen := binary.BigEndian.Uint32(psc.frameHeader[:])
+		if frameLen > MaxFramePayload+minLen {
+			return 0, nil, ErrFrameTooLarge
+		}
+		if frameLen < minLen {
+			return 0, nil, ErrFrameAuth
+		}
+		psc.frameBody = make([]byte, frameLen)
+	}
+
+	for psc.bodyRead < len(psc.frameBody) {
+		n, err := psc.conn.Read(psc.frameBody[psc.bodyRead:])
+		psc.bodyRead += n
+		if err != nil && psc.bodyRead < len(psc.frameBody) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+	}
+
+	sealed := psc.frameBody
+	psc.headerRead, psc.frameBody, psc.bodyRead = 0, nil, 0
+
+	nonce, err := key.nextNonce()
 	if err != nil {
-		return nil, fmt.Errorf("decapsulation failed: %w", err)
+		return 0, nil, err
 	}
 
-	// Step 5: Encapsulate for peer
-	ciphertext, peerSharedSecret, err := myKEM.Encapsulate(peerPubKey)
+	plaintext, err := key.aead.Open(sealed[:0], nonce, sealed, psc.frameHeader[:])
 	if err != nil {
-		return nil, fmt.Errorf("encapsulation failed: %w", err)
+		return 0, nil, ErrFrameAuth
 	}
 
-	// Step 6: Send ciphertext to peer
-	ctFrame := make([]byte, 2+len(ciphertext))
-	binary.BigEndian.PutUint16(ctFrame[:2], uint16(len(ciphertext)))
-	copy(ctFrame[2:], ciphertext)
+	payload := plaintext[frameTypeSize:]
+	key.bytes += uint64(len(payload))
 
-	_, err = conn.Write(ctFrame)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send ciphertext: %w", err)
-	}
+	return plaintext[0], payload, nil
+}
+
+// RemoteID returns the authenticated node ID of the peer
+func (psc *PQSecureConn) RemoteID() NodeID {
+	return psc.remoteID
+}
 
-	// Step 7: Combine shared secrets
-	finalSecret := append(sharedSecret, peerSharedSecret...)
+// RemotePubKey returns the peer's Dilithium node public key
+func (psc *PQSecureConn) RemotePubKey() []byte {
+	return psc.remotePubKey
+}
 
-	log.Printf("[PQHandshakeListener] Handshake complete. Final session key: %d bytes\n", len(finalSecret))
+// Close closes the underlying connection
+func (psc *PQSecureConn) Close() error {
+	return psc.conn.Close()
+}
 
-	// Create PQ-secure connection
-	return NewPQSecureConn(conn, finalSecret)
+// LocalAddr returns the local address
+func (psc *PQSecureConn) LocalAddr() net.Addr {
+	return psc.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address
+func (psc *PQSecureConn) RemoteAddr() net.Addr {
+	return psc.conn.RemoteAddr()
+}
+
+// SetDeadline sets read/write deadline
+func (psc *PQSecureConn) SetDeadline(t time.Time) error {
+	return psc.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets read deadline
+func (psc *PQSecureConn) SetReadDeadline(t time.Time) error {
+	return psc.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets write deadline
+func (psc *PQSecureConn) SetWriteDeadline(t time.Time) error {
+	return psc.conn.SetWriteDeadline(t)
 }
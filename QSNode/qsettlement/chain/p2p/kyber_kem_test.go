@@ -0,0 +1,275 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/nettest"
+)
+
+// testSessionSecret keys connections built without a handshake
+var testSessionSecret = bytes.Repeat([]byte{0x5a}, 32)
+
+// rawPair returns a sender and receiver whose frames pass through the test:
+// frames written by sender are read from senderRaw and delivered by
+// writing them to receiverRaw
+func rawPair(t *testing.T) (sender *PQSecureConn, senderRaw net.Conn, receiver *PQSecureConn, receiverRaw net.Conn) {
+	t.Helper()
+
+	s, sRaw := net.Pipe()
+	rRaw, r := net.Pipe()
+
+	sender, err := NewPQSecureConn(s, testSessionSecret, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err = NewPQSecureConn(r, testSessionSecret, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		for _, c := range []net.Conn{s, sRaw, rRaw, r} {
+			c.Close()
+		}
+	})
+	return sender, sRaw, receiver, rRaw
+}
+
+// captureFrames writes msg on sender and returns the raw frames it produced
+func captureFrames(t *testing.T, sender *PQSecureConn, raw net.Conn, msg []byte, frames int) [][]byte {
+	t.Helper()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := sender.Write(msg)
+		errc <- err
+	}()
+
+	captured := make([][]byte, frames)
+	for i := range captured {
+		captured[i] = readRawFrame(t, raw)
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return captured
+}
+
+// readRawFrame reads one sealed frame, header included, off raw
+func readRawFrame(t *testing.T, raw net.Conn) []byte {
+	t.Helper()
+
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		t.Fatalf("frame header: %v", err)
+	}
+	frame := make([]byte, frameHeaderSize+int(binary.BigEndian.Uint32(header)))
+	copy(frame, header)
+	if _, err := io.ReadFull(raw, frame[frameHeaderSize:]); err != nil {
+		t.Fatalf("frame body: %v", err)
+	}
+	return frame
+}
+
+// deliver writes raw frames to the receiver side and reads one message back
+func deliver(receiver *PQSecureConn, raw net.Conn, frames ...[]byte) ([]byte, error) {
+	go func() {
+		for _, frame := range frames {
+			if _, err := raw.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, MaxFramePayload)
+	n, err := receiver.Read(buf)
+	return buf[:n], err
+}
+
+func TestSecureConnBidirectional(t *testing.T) {
+	initiator, responder := secureConnPair(t)
+
+	// Larger than one frame each way, written concurrently
+	up := bytes.Repeat([]byte("initiator "), MaxFramePayload/5)
+	down := bytes.Repeat([]byte("responder "), MaxFramePayload/4)
+
+	errc := make(chan error, 2)
+	for _, w := range []struct {
+		conn *PQSecureConn
+		msg  []byte
+	}{{initiator, up}, {responder, down}} {
+		go func() {
+			_, err := w.conn.Write(w.msg)
+			errc <- err
+		}()
+	}
+
+	for _, r := range []struct {
+		conn *PQSecureConn
+		want []byte
+	}{{responder, up}, {initiator, down}} {
+		got := make([]byte, len(r.want))
+		if _, err := io.ReadFull(r.conn, got); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if !bytes.Equal(got, r.want) {
+			t.Fatal("received data differs from what was sent")
+		}
+	}
+
+	for range 2 {
+		if err := <-errc; err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestSecureConnRejectsTamperedFrames(t *testing.T) {
+	msg := []byte("transfer 100 to validator 7")
+
+	tests := []struct {
+		name   string
+		tamper func(frame []byte) []byte
+	}{
+		{"ciphertext bit", func(f []byte) []byte { f[frameHeaderSize] ^= 0x01; return f }},
+		{"tag bit", func(f []byte) []byte { f[len(f)-1] ^= 0x80; return f }},
+		{"header bit", func(f []byte) []byte {
+			// Clear the lowest set bit so the frame claims fewer bytes than
+			// were sent and the read still completes
+			n := binary.BigEndian.Uint32(f)
+			binary.BigEndian.PutUint32(f, n&(n-1))
+			return f[:frameHeaderSize+int(n&(n-1))]
+		}},
+	}
+
+	for _, tt := range tests {
+		sender, senderRaw, receiver, receiverRaw := rawPair(t)
+		frames := captureFrames(t, sender, senderRaw, msg, 1)
+
+		if _, err := deliver(receiver, receiverRaw, tt.tamper(frames[0])); !errors.Is(err, ErrFrameAuth) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrFrameAuth)
+		}
+	}
+}
+
+func TestSecureConnRejectsReplayAndReorder(t *testing.T) {
+	sender, senderRaw, receiver, receiverRaw := rawPair(t)
+	captureFrames(t, sender, senderRaw, []byte("first"), 1)
+	second := captureFrames(t, sender, senderRaw, []byte("second"), 1)[0]
+
+	if _, err := deliver(receiver, receiverRaw, second); !errors.Is(err, ErrFrameAuth) {
+		t.Fatalf("reordered frame: got %v, want %v", err, ErrFrameAuth)
+	}
+
+	sender, senderRaw, receiver, receiverRaw = rawPair(t)
+	first := captureFrames(t, sender, senderRaw, []byte("first"), 1)[0]
+	if got, err := deliver(receiver, receiverRaw, first); err != nil || string(got) != "first" {
+		t.Fatalf("first frame: got (%q, %v)", got, err)
+	}
+	if _, err := deliver(receiver, receiverRaw, first); !errors.Is(err, ErrFrameAuth) {
+		t.Fatalf("replayed frame: got %v, want %v", err, ErrFrameAuth)
+	}
+}
+
+func TestSecureConnNetConn(t *testing.T) {
+	nettest.TestConn(t, func() (net.Conn, net.Conn, func(), error) {
+		a, b := net.Pipe()
+		c1, err := NewPQSecureConn(a, testSessionSecret, true)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		c2, err := NewPQSecureConn(b, testSessionSecret, false)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return c1, c2, func() {
+			c1.Close()
+			c2.Close()
+		}, nil
+	})
+}
+
+// A read deadline that interrupts a frame must leave the stream intact
+func TestSecureConnReadDeadlineMidFrame(t *testing.T) {
+	sender, senderRaw, receiver, receiverRaw := rawPair(t)
+	msg := "split across deadlines"
+	frame := captureFrames(t, sender, senderRaw, []byte(msg), 1)[0]
+
+	// Deliver part of the header, then part of the body, timing out after each
+	buf := make([]byte, 64)
+	prev := 0
+	for _, cut := range []int{frameHeaderSize / 2, frameHeaderSize + 5} {
+		go receiverRaw.Write(frame[prev:cut])
+		prev = cut
+
+		receiver.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		_, err := receiver.Read(buf)
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Fatalf("cut at %d: got %v, want an unwrapped timeout net.Error", cut, err)
+		}
+	}
+
+	go receiverRaw.Write(frame[prev:])
+	receiver.SetReadDeadline(time.Time{})
+	n, err := receiver.Read(buf)
+	if err != nil || string(buf[:n]) != msg {
+		t.Fatalf("read after deadlines: got (%q, %v), want %q", buf[:n], err, msg)
+	}
+}
+
+// A key recovered after a key update must not open frames sent before it
+func TestSecureConnRekeyForwardSecrecy(t *testing.T) {
+	sender, senderRaw, receiver, receiverRaw := rawPair(t)
+	earlier := captureFrames(t, sender, senderRaw, []byte("epoch 0"), 1)[0]
+
+	if got, err := deliver(receiver, receiverRaw, earlier); err != nil || string(got) != "epoch 0" {
+		t.Fatalf("epoch 0 frame: got (%q, %v)", got, err)
+	}
+	epoch0Key := receiver.recvKey.key
+
+	errc := make(chan error, 1)
+	go func() { errc <- sender.Rekey() }()
+	update := readRawFrame(t, senderRaw)
+	if err := <-errc; err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	later := captureFrames(t, sender, senderRaw, []byte("epoch 1"), 1)[0]
+
+	if got, err := deliver(receiver, receiverRaw, update, later); err != nil || string(got) != "epoch 1" {
+		t.Fatalf("epoch 1 frame: got (%q, %v)", got, err)
+	}
+	if send, recv := receiver.KeyEpochs(); send != 0 || recv != 1 {
+		t.Fatalf("receiver epochs (%d, %d), want (0, 1)", send, recv)
+	}
+
+	// The ratchet wipes the old key in place
+	if !bytes.Equal(epoch0Key, make([]byte, aeadKeySize)) {
+		t.Fatal("epoch 0 receive key not wiped after the update")
+	}
+
+	// Neither the current key nor any key ratcheted from it opens the
+	// earlier frame under any early nonce
+	key, err := newFrameKey(bytes.Clone(receiver.recvKey.key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for epoch := 1; epoch <= 3; epoch++ {
+		for nonce := range uint64(4) {
+			key.nonce = nonce
+			n, _ := key.nextNonce()
+			if _, err := key.aead.Open(nil, n, earlier[frameHeaderSize:], earlier[:frameHeaderSize]); err == nil {
+				t.Fatalf("epoch %d key opened an epoch 0 frame", epoch)
+			}
+		}
+		if err := key.ratchet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidNetAddress is returned for malformed peer addresses
+var ErrInvalidNetAddress = errors.New("invalid peer address")
+
+// NetAddress is a peer address of the form "nodeID@host:port". The node ID
+// is checked during the PQ handshake, so a dialed address is authenticated.
+type NetAddress struct {
+	ID   NodeID
+	Host string
+	Port uint16
+}
+
+// ParseNetAddress parses "nodeID@host:port", optionally prefixed with "tcp://"
+func ParseNetAddress(s string) (*NetAddress, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "tcp://")
+
+	id, hostPort, found := strings.Cut(s, "@")
+	if !found {
+		return nil, fmt.Errorf("%w: %q has no node ID", ErrInvalidNetAddress, s)
+	}
+
+	nodeID := NodeID(strings.ToLower(id))
+	if err := nodeID.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidNetAddress, s, err)
+	}
+
+	addr, err := parseHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidNetAddress, s, err)
+	}
+	addr.ID = nodeID
+
+	return addr, nil
+}
+
+// ParseNetAddresses parses a comma-separated address list as used for
+// persistent_peers and seeds; empty entries are skipped
+func ParseNetAddresses(list string) ([]*NetAddress, error) {
+	var addrs []*NetAddress
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		addr, err := ParseNetAddress(entry)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// NewNetAddressFromConn returns the address of a connected peer
+func NewNetAddressFromConn(id NodeID, addr net.Addr) (*NetAddress, error) {
+	na, err := parseHostPort(addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNetAddress, err)
+	}
+	na.ID = id
+	return na, nil
+}
+
+func parseHostPort(hostPort string) (*NetAddress, error) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return nil, errors.New("empty host")
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	return &NetAddress{Host: host, Port: uint16(port)}, nil
+}
+
+// DialString returns the "host:port" to dial
+func (na *NetAddress) DialString() string {
+	return net.JoinHostPort(na.Host, strconv.Itoa(int(na.Port)))
+}
+
+// String returns "nodeID@host:port"
+func (na *NetAddress) String() string {
+	return fmt.Sprintf("%s@%s", na.ID, na.DialString())
+}
+
+// Equal reports whether both addresses name the same node and endpoint
+func (na *NetAddress) Equal(other *NetAddress) bool {
+	return other != nil && na.ID == other.ID && na.Host == other.Host && na.Port == other.Port
+}
+
+// MarshalText encodes the address as "nodeID@host:port"
+func (na *NetAddress) MarshalText() ([]byte, error) {
+	return []byte(na.String()), nil
+}
+
+// UnmarshalText decodes an address written by MarshalText
+func (na *NetAddress) UnmarshalText(text []byte) error {
+	parsed, err := ParseNetAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*na = *parsed
+	return nil
+}
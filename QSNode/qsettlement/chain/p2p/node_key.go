@@ -0,0 +1,182 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"golang.org/x/crypto/sha3"
+)
+
+// NodeIDByteLength is the number of key hash bytes in a node ID
+const NodeIDByteLength = 20
+
+// NodeKeyScheme is the signature scheme of node keys, as named in the
+// handshake hello
+const NodeKeyScheme = "dilithium2"
+
+// NodeID identifies a node: the hex-encoded first 20 bytes of the SHA3-256
+// hash of its Dilithium2 (ML-DSA-44) node public key
+type NodeID string
+
+// PubKeyToID derives the node ID of a Dilithium node public key
+func PubKeyToID(pubKey []byte) NodeID {
+	hash := sha3.Sum256(pubKey)
+	return NodeID(hex.EncodeToString(hash[:NodeIDByteLength]))
+}
+
+// Validate checks that id is well-formed
+func (id NodeID) Validate() error {
+	if len(id) != 2*NodeIDByteLength {
+		return fmt.Errorf("node ID must be %d hex characters, got %d", 2*NodeIDByteLength, len(id))
+	}
+	if _, err := hex.DecodeString(string(id)); err != nil {
+		return fmt.Errorf("node ID is not hex: %w", err)
+	}
+	return nil
+}
+
+// NodeKey is the Dilithium2 (ML-DSA-44) key that authenticates a node in the
+// p2p handshake. It is separate from validator consensus keys.
+type NodeKey struct {
+	PrivKey []byte
+	PubKey  []byte
+}
+
+// GenerateNodeKey creates a fresh node key
+func GenerateNodeKey() (*NodeKey, error) {
+	scheme := mldsa44.Scheme()
+
+	pk, sk, err := scheme.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate node key: %w", err)
+	}
+
+	pubKey, err := pk.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode node public key: %w", err)
+	}
+
+	privKey, err := sk.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode node private key: %w", err)
+	}
+
+	return &NodeKey{PrivKey: privKey, PubKey: pubKey}, nil
+}
+
+// NewNodeKey wraps existing key bytes, checking their sizes
+func NewNodeKey(privKey, pubKey []byte) (*NodeKey, error) {
+	scheme := mldsa44.Scheme()
+
+	if len(privKey) != scheme.PrivateKeySize() || len(pubKey) != scheme.PublicKeySize() {
+		return nil, errors.New("node key must be an ML-DSA-44 key pair")
+	}
+
+	return &NodeKey{PrivKey: privKey, PubKey: pubKey}, nil
+}
+
+// nodeKeyJSON is the on-disk form of a node key
+type nodeKeyJSON struct {
+	PrivKey []byte `json:"priv_key"`
+	PubKey  []byte `json:"pub_key"`
+}
+
+// LoadNodeKey reads a node key file
+func LoadNodeKey(path string) (*NodeKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node key: %w", err)
+	}
+
+	var stored nodeKeyJSON
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode node key %s: %w", path, err)
+	}
+
+	return NewNodeKey(stored.PrivKey, stored.PubKey)
+}
+
+// LoadOrGenNodeKey reads the node key at path, creating it on first start
+// so the node ID stays the same across restarts
+func LoadOrGenNodeKey(path string) (*NodeKey, error) {
+	if _, err := os.Stat(path); err == nil {
+		return LoadNodeKey(path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat node key: %w", err)
+	}
+
+	key, err := GenerateNodeKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := key.SaveAs(path); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// SaveAs writes the node key to path, readable only by the owner
+func (k *NodeKey) SaveAs(path string) error {
+	data, err := json.Marshal(nodeKeyJSON{PrivKey: k.PrivKey, PubKey: k.PubKey})
+	if err != nil {
+		return fmt.Errorf("failed to encode node key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create node key directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write node key: %w", err)
+	}
+
+	return nil
+}
+
+// ID returns the node ID of the key
+func (k *NodeKey) ID() NodeID {
+	return PubKeyToID(k.PubKey)
+}
+
+// Sign signs msg with the node private key
+func (k *NodeKey) Sign(msg []byte) ([]byte, error) {
+	scheme := mldsa44.Scheme()
+
+	sk, err := scheme.UnmarshalBinaryPrivateKey(k.PrivKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node private key: %w", err)
+	}
+
+	return scheme.Sign(sk, msg, nil), nil
+}
+
+// VerifyNodeSignature checks a node key signature
+func VerifyNodeSignature(pubKey, msg, sig []byte) bool {
+	scheme := mldsa44.Scheme()
+
+	if len(sig) != scheme.SignatureSize() {
+		return false
+	}
+
+	pk, err := scheme.UnmarshalBinaryPublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	return scheme.Verify(pk, msg, sig, nil)
+}
+
+// nodePubKeySize is the encoded size of a node public key
+func nodePubKeySize() int {
+	return mldsa44.Scheme().PublicKeySize()
+}
+
+// nodeSignatureSize is the size of a node key signature
+func nodeSignatureSize() int {
+	return mldsa44.Scheme().SignatureSize()
+}
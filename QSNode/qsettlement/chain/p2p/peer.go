@@ -0,0 +1,123 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+)
+
+// Peer is an authenticated connection to another node
+type Peer struct {
+	conn       *PQSecureConn
+	mconn      *MConnection // Set by the switch before the peer is added
+	addr       *NetAddress  // Dialed address, or the remote endpoint for inbound peers
+	outbound   bool
+	persistent bool
+	createdAt  time.Time
+
+	stopOnce sync.Once
+	done     chan struct{} // Closed once the peer is removed from the switch
+}
+
+func newPeer(conn *PQSecureConn, addr *NetAddress, outbound, persistent bool) *Peer {
+	return &Peer{
+		conn:       conn,
+		addr:       addr,
+		outbound:   outbound,
+		persistent: persistent,
+		createdAt:  time.Now(),
+		done:       make(chan struct{}),
+	}
+}
+
+// ID returns the peer's authenticated node ID
+func (p *Peer) ID() NodeID {
+	return p.conn.RemoteID()
+}
+
+// NetAddress returns the peer's address
+func (p *Peer) NetAddress() *NetAddress {
+	return p.addr
+}
+
+// IsOutbound reports whether we dialed the peer
+func (p *Peer) IsOutbound() bool {
+	return p.outbound
+}
+
+// IsPersistent reports whether the peer is redialed when it disconnects
+func (p *Peer) IsPersistent() bool {
+	return p.persistent
+}
+
+// Conn returns the encrypted connection to the peer. Its traffic belongs
+// to the peer's MConnection; use Send and TrySend to talk to the peer.
+func (p *Peer) Conn() *PQSecureConn {
+	return p.conn
+}
+
+// Send queues msg on channel chID, blocking while the channel's queue is
+// full. It reports whether msg was queued.
+func (p *Peer) Send(chID byte, msg []byte) bool {
+	return p.mconn.Send(chID, msg)
+}
+
+// TrySend queues msg on channel chID if there is room
+func (p *Peer) TrySend(chID byte, msg []byte) bool {
+	return p.mconn.TrySend(chID, msg)
+}
+
+// Uptime returns how long the peer has been connected
+func (p *Peer) Uptime() time.Duration {
+	return time.Since(p.createdAt)
+}
+
+// Done is closed when the peer has been stopped
+func (p *Peer) Done() <-chan struct{} {
+	return p.done
+}
+
+// stop closes the connection; it is safe to call more than once
+func (p *Peer) stop() {
+	p.stopOnce.Do(func() {
+		if p.mconn != nil {
+			p.mconn.Stop()
+		} else {
+			p.conn.Close()
+		}
+		close(p.done)
+	})
+}
+
+// PeerEventType identifies a peer lifecycle event
+type PeerEventType int
+
+const (
+	PeerConnected    PeerEventType = iota // Handshake done, peer added
+	PeerDisconnected                      // Peer removed, Err holds the reason
+	PeerDialFailed                        // Outbound dial or handshake failed, Peer is nil
+)
+
+func (t PeerEventType) String() string {
+	switch t {
+	case PeerConnected:
+		return "connected"
+	case PeerDisconnected:
+		return "disconnected"
+	case PeerDialFailed:
+		return "dial failed"
+	default:
+		return "unknown"
+	}
+}
+
+// PeerEvent reports a change in the switch's peer set
+type PeerEvent struct {
+	Type PeerEventType
+	Peer *Peer
+	Addr *NetAddress
+	Err  error
+}
+
+// PeerEventHandler receives peer events. Handlers run synchronously on the
+// goroutine that caused the event and must not block.
+type PeerEventHandler func(PeerEvent)
@@ -0,0 +1,587 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// PexChannel carries peer exchange messages
+const PexChannel byte = 0x00
+
+// PEX messages
+//
+//	request: [0x01]
+//	addrs:   [0x02][2 count]{[1 len]["nodeID@host:port"]}*count
+//	self:    [0x03][1 len]["host:port"]
+//
+// A self message announces where the sender listens; its node ID is the
+// authenticated peer's, and an empty host stands for the IP the peer
+// connects from.
+const (
+	pexRequestMsg byte = 0x01
+	pexAddrsMsg   byte = 0x02
+	pexSelfMsg    byte = 0x03
+	maxPexAddrLen      = 255
+	maxPexMsgSize      = 1 + 2 + maxAddrSelection*(1+maxPexAddrLen)
+)
+
+// PEX timing
+const (
+	defaultEnsurePeersPeriod = 30 * time.Second
+	seedDisconnectWait       = 3 * time.Second  // Lets a served peer read our reply before we hang up
+	seedPeerTimeout          = 30 * time.Second // Longest a seed keeps any peer
+)
+
+// PEX errors
+var (
+	ErrPexMalformed   = errors.New("malformed PEX message")
+	ErrPexUnsolicited = errors.New("unsolicited PEX addresses")
+	ErrPexTooFrequent = errors.New("PEX requests too frequent")
+	ErrPeerBanned     = errors.New("peer is banned")
+	errSeedDone       = errors.New("seed finished with peer")
+)
+
+// PEXReactor exchanges peer addresses and keeps the switch's outbound peer
+// slots filled from the address book.
+//
+// Addresses only become good once we dial them: the switch dials with the
+// expected node ID, and the PQ handshake fails unless the remote proves it
+// holds the Dilithium key behind that ID. Every peer announces the address
+// it listens on when it connects, so seeds and other nodes learn about
+// nodes that dial in; that address is added unverified like any other.
+//
+// In seed mode the reactor crawls the network instead: it dials addresses
+// from the book, asks each for more, and disconnects every peer once it has
+// been served.
+type PEXReactor struct {
+	sw       *Switch
+	book     *AddrBook
+	seeds    []*NetAddress
+	seedIDs  map[NodeID]bool
+	seedMode bool
+
+	externalAddr string // Announced instead of the listen address if set
+
+	ensurePeersPeriod time.Duration
+
+	mtx          sync.Mutex
+	requestsSent map[NodeID]bool      // Peers with an unanswered request from us
+	lastSent     map[NodeID]time.Time // When we last asked each peer
+	lastRequest  map[NodeID]time.Time // When each node last asked us; outlives its connections
+	selfReceived map[NodeID]bool      // Peers that have announced their address
+
+	running bool
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPEXReactor creates a PEX reactor for sw. Seeds are taken from cfg,
+// SeedMode selects crawling and ExternalAddress is announced to peers in
+// place of the listen address.
+func NewPEXReactor(sw *Switch, book *AddrBook, cfg *config.P2PConfig) (*PEXReactor, error) {
+	if cfg == nil {
+		cfg = config.DefaultP2PConfig()
+	}
+
+	seeds, err := ParseNetAddresses(cfg.Seeds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seeds: %w", err)
+	}
+
+	r := &PEXReactor{
+		sw:                sw,
+		book:              book,
+		seeds:             seeds,
+		seedIDs:           make(map[NodeID]bool, len(seeds)),
+		seedMode:          cfg.SeedMode,
+		externalAddr:      strings.TrimPrefix(cfg.ExternalAddress, "tcp://"),
+		ensurePeersPeriod: defaultEnsurePeersPeriod,
+		requestsSent:      make(map[NodeID]bool),
+		lastSent:          make(map[NodeID]time.Time),
+		lastRequest:       make(map[NodeID]time.Time),
+		selfReceived:      make(map[NodeID]bool),
+	}
+	for _, seed := range seeds {
+		r.seedIDs[seed.ID] = true
+	}
+
+	return r, nil
+}
+
+// Channels returns the PEX channel
+func (r *PEXReactor) Channels() []*ChannelDescriptor {
+	return []*ChannelDescriptor{{
+		ID:                  PexChannel,
+		Priority:            1,
+		SendQueueCapacity:   10,
+		RecvMessageCapacity: maxPexMsgSize,
+	}}
+}
+
+// Start loads the address book and starts dialing or crawling
+func (r *PEXReactor) Start() error {
+	if err := r.book.Load(); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	r.running = true
+	r.quit = make(chan struct{})
+	r.mtx.Unlock()
+
+	good, bad := r.book.Size()
+	log.Printf("[PEX] Started (seed mode: %t, %d good and %d bad addresses, %d seeds)\n",
+		r.seedMode, good, bad, len(r.seeds))
+
+	r.wg.Add(1)
+	go r.ensurePeersRoutine()
+	return nil
+}
+
+// Stop waits for in-flight dials and saves the address book
+func (r *PEXReactor) Stop() {
+	r.mtx.Lock()
+	if !r.running {
+		r.mtx.Unlock()
+		return
+	}
+	r.running = false
+	close(r.quit)
+	r.mtx.Unlock()
+
+	r.wg.Wait()
+	if err := r.book.Save(); err != nil {
+		log.Printf("[PEX] Failed to save address book: %v\n", err)
+	}
+}
+
+// AddPeer announces our address, marks dialed addresses good and asks new
+// peers for addresses when the book needs them
+func (r *PEXReactor) AddPeer(peer *Peer) {
+	if r.book.IsBanned(peer.ID()) {
+		go r.sw.StopPeerForError(peer, ErrPeerBanned)
+		return
+	}
+
+	if self := r.selfAddr(); self != "" {
+		peer.TrySend(PexChannel, encodePexSelf(self))
+	}
+
+	if !peer.IsOutbound() {
+		return
+	}
+
+	if !r.seedIDs[peer.ID()] {
+		r.book.MarkGood(peer.NetAddress())
+	}
+	if r.seedMode || r.book.NeedMoreAddrs() {
+		r.requestAddrs(peer)
+	}
+}
+
+// RemovePeer forgets the peer's request state. When the peer last asked us
+// is kept, so reconnecting does not reset its request rate limit.
+func (r *PEXReactor) RemovePeer(peer *Peer, reason error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.requestsSent, peer.ID())
+	delete(r.lastSent, peer.ID())
+	delete(r.selfReceived, peer.ID())
+}
+
+// selfAddr returns the "host:port" we announce: the external address if
+// configured, else the listen address, with the host left empty when we
+// listen on all interfaces
+func (r *PEXReactor) selfAddr() string {
+	if r.externalAddr != "" {
+		return r.externalAddr
+	}
+
+	listenAddr := r.sw.ListenAddr()
+	if listenAddr == nil {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(listenAddr.String())
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Receive handles address requests and responses. Malformed, unsolicited
+// or too frequent messages get the peer banned.
+func (r *PEXReactor) Receive(chID byte, peer *Peer, msg []byte) {
+	msgType, addrs, err := decodePexMsg(msg)
+	if err != nil {
+		r.punish(peer, err)
+		return
+	}
+
+	switch msgType {
+	case pexRequestMsg:
+		if err := r.checkRequestRate(peer.ID()); err != nil {
+			r.punish(peer, err)
+			return
+		}
+
+		peer.TrySend(PexChannel, encodePexAddrs(r.book.GetSelection()))
+		if r.seedMode {
+			r.disconnectLater(peer)
+		}
+
+	case pexAddrsMsg:
+		r.mtx.Lock()
+		requested := r.requestsSent[peer.ID()]
+		delete(r.requestsSent, peer.ID())
+		r.mtx.Unlock()
+
+		if !requested {
+			r.punish(peer, ErrPexUnsolicited)
+			return
+		}
+
+		added := 0
+		for _, addr := range addrs {
+			if err := r.book.AddAddress(addr, peer.ID()); err == nil {
+				added++
+			}
+		}
+		log.Printf("[PEX] Received %d addresses from %s\n", added, peer.ID())
+
+		if r.seedMode {
+			r.disconnectLater(peer)
+		}
+
+	case pexSelfMsg:
+		r.mtx.Lock()
+		announced := r.selfReceived[peer.ID()]
+		r.selfReceived[peer.ID()] = true
+		r.mtx.Unlock()
+
+		if announced {
+			r.punish(peer, ErrPexUnsolicited)
+			return
+		}
+
+		addr := addrs[0]
+		addr.ID = peer.ID()
+		if addr.Host == "" {
+			addr.Host = peer.NetAddress().Host
+		}
+		if err := r.book.AddAddress(addr, peer.ID()); err == nil {
+			log.Printf("[PEX] %s announced %s\n", peer.ID(), addr.DialString())
+		}
+	}
+}
+
+// requestAddrs asks peer for addresses unless a request is outstanding.
+// Requests to one peer are spaced by half the ensure peers period, well
+// clear of the limit checkRequestRate enforces on the other side.
+func (r *PEXReactor) requestAddrs(peer *Peer) {
+	id := peer.ID()
+	now := time.Now()
+
+	r.mtx.Lock()
+	if last, ok := r.lastSent[id]; r.requestsSent[id] || (ok && now.Sub(last) < r.ensurePeersPeriod/2) {
+		r.mtx.Unlock()
+		return
+	}
+	r.requestsSent[id] = true
+	r.lastSent[id] = now
+	r.mtx.Unlock()
+
+	if !peer.TrySend(PexChannel, []byte{pexRequestMsg}) {
+		r.mtx.Lock()
+		delete(r.requestsSent, id)
+		delete(r.lastSent, id)
+		r.mtx.Unlock()
+	}
+}
+
+// checkRequestRate allows one request per peer every third of the ensure
+// peers period, which is how often well-behaved nodes ask
+func (r *PEXReactor) checkRequestRate(id NodeID) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := time.Now()
+	if last, ok := r.lastRequest[id]; ok && now.Sub(last) < r.ensurePeersPeriod/3 {
+		return fmt.Errorf("%w: last request %s ago", ErrPexTooFrequent, now.Sub(last).Round(time.Millisecond))
+	}
+	r.lastRequest[id] = now
+	return nil
+}
+
+// pruneRequestRates drops request times too old to limit anything
+func (r *PEXReactor) pruneRequestRates() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := time.Now()
+	for id, last := range r.lastRequest {
+		if now.Sub(last) >= r.ensurePeersPeriod/3 {
+			delete(r.lastRequest, id)
+		}
+	}
+}
+
+// punish bans a misbehaving peer and disconnects it. Bans are by node ID
+// and node keys cost nothing to generate, so a ban only stops a peer that
+// reconnects with the same key; floods from fresh keys are bounded by the
+// switch's inbound peer and handshake limits instead.
+func (r *PEXReactor) punish(peer *Peer, reason error) {
+	log.Printf("[PEX] Banning %s for %s: %v\n", peer.ID(), DefaultBanDuration, reason)
+	r.book.Ban(peer.ID(), DefaultBanDuration)
+	r.sw.StopPeerForError(peer, reason)
+}
+
+// disconnectLater hangs up on a peer a seed has finished with, leaving time
+// for queued messages to go out
+func (r *PEXReactor) disconnectLater(peer *Peer) {
+	if peer.IsPersistent() {
+		return
+	}
+	time.AfterFunc(seedDisconnectWait, func() {
+		r.sw.StopPeerForError(peer, errSeedDone)
+	})
+}
+
+// ensurePeersRoutine dials (or crawls) right away and then every
+// ensurePeersPeriod, saving the address book each round
+func (r *PEXReactor) ensurePeersRoutine() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.ensurePeersPeriod)
+	defer ticker.Stop()
+
+	for {
+		if r.seedMode {
+			r.crawlPeers()
+		} else {
+			r.ensurePeers()
+		}
+
+		select {
+		case <-ticker.C:
+			r.pruneRequestRates()
+			if err := r.book.Save(); err != nil {
+				log.Printf("[PEX] Failed to save address book: %v\n", err)
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// ensurePeers dials addresses from the book until the outbound slots are
+// full, asking peers for more addresses and falling back to the seeds when
+// the book has nothing to offer
+func (r *PEXReactor) ensurePeers() {
+	outbound, _ := r.sw.NumPeers()
+	need := r.sw.MaxNumOutboundPeers() - outbound
+
+	if need > 0 {
+		// With few peers, prefer addresses that have worked before
+		biasTowardsBad := min(outbound, 8)*10 + 10
+
+		picked := make(map[NodeID]bool)
+		exclude := func(id NodeID) bool {
+			return picked[id] || r.seedIDs[id] || r.sw.IsDialingOrConnected(id)
+		}
+		for range need {
+			addr := r.book.PickAddress(biasTowardsBad, exclude)
+			if addr == nil {
+				break
+			}
+			picked[addr.ID] = true
+			r.dial(addr)
+		}
+
+		if len(picked) == 0 && outbound == 0 {
+			r.dialSeeds()
+		}
+	}
+
+	if r.book.NeedMoreAddrs() {
+		if peers := r.sw.Peers(); len(peers) > 0 {
+			r.requestAddrs(peers[rand.IntN(len(peers))])
+		}
+	}
+}
+
+// crawlPeers is ensurePeers for seed mode: it drops peers that have been
+// connected too long and dials the least recently tried addresses
+func (r *PEXReactor) crawlPeers() {
+	for _, peer := range r.sw.Peers() {
+		if !peer.IsPersistent() && peer.Uptime() > seedPeerTimeout {
+			r.sw.StopPeerForError(peer, errSeedDone)
+		}
+	}
+
+	outbound, _ := r.sw.NumPeers()
+	addrs := r.book.CrawlAddresses(r.sw.MaxNumOutboundPeers()-outbound, func(id NodeID) bool {
+		return r.seedIDs[id] || r.sw.IsDialingOrConnected(id)
+	})
+	for _, addr := range addrs {
+		r.dial(addr)
+	}
+
+	if good, bad := r.book.Size(); good+bad == 0 {
+		r.dialSeeds()
+	}
+}
+
+// dial connects to addr in the background, recording failures in the book.
+// Success is recorded by AddPeer.
+func (r *PEXReactor) dial(addr *NetAddress) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		_, err := r.sw.DialPeer(addr)
+		switch {
+		case err == nil,
+			errors.Is(err, ErrDuplicatePeer),
+			errors.Is(err, ErrMaxOutboundPeers),
+			errors.Is(err, ErrSwitchNotRunning):
+		default:
+			log.Printf("[PEX] Dial to %s failed: %v\n", addr, err)
+			r.book.MarkFailed(addr.ID)
+		}
+	}()
+}
+
+// dialSeeds connects to one seed, trying them in random order
+func (r *PEXReactor) dialSeeds() {
+	if len(r.seeds) == 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		for _, i := range rand.Perm(len(r.seeds)) {
+			seed := r.seeds[i]
+			if seed.ID == r.sw.NodeID() {
+				continue
+			}
+			_, err := r.sw.DialPeer(seed)
+			if err == nil || errors.Is(err, ErrDuplicatePeer) {
+				return
+			}
+			if errors.Is(err, ErrSwitchNotRunning) {
+				return
+			}
+			log.Printf("[PEX] Dial to seed %s failed: %v\n", seed, err)
+		}
+	}()
+}
+
+// encodePexAddrs builds an addrs message; addresses too long to encode
+// are skipped
+func encodePexAddrs(addrs []*NetAddress) []byte {
+	msg := make([]byte, 3, 3+len(addrs)*64)
+	msg[0] = pexAddrsMsg
+
+	count := 0
+	for _, addr := range addrs {
+		if count == maxAddrSelection {
+			break
+		}
+		s := addr.String()
+		if len(s) > maxPexAddrLen {
+			continue
+		}
+		msg = append(msg, byte(len(s)))
+		msg = append(msg, s...)
+		count++
+	}
+	binary.BigEndian.PutUint16(msg[1:3], uint16(count))
+
+	return msg
+}
+
+// encodePexSelf builds a self message announcing hostPort
+func encodePexSelf(hostPort string) []byte {
+	if len(hostPort) > maxPexAddrLen {
+		return nil
+	}
+	msg := make([]byte, 0, 2+len(hostPort))
+	msg = append(msg, pexSelfMsg, byte(len(hostPort)))
+	return append(msg, hostPort...)
+}
+
+// decodePexMsg parses a PEX message; addrs is set for addrs messages, and
+// holds the announced address, without node ID, for self messages
+func decodePexMsg(msg []byte) (msgType byte, addrs []*NetAddress, err error) {
+	if len(msg) == 0 {
+		return 0, nil, fmt.Errorf("%w: empty message", ErrPexMalformed)
+	}
+
+	switch msg[0] {
+	case pexRequestMsg:
+		if len(msg) != 1 {
+			return 0, nil, fmt.Errorf("%w: %d byte request", ErrPexMalformed, len(msg))
+		}
+		return pexRequestMsg, nil, nil
+
+	case pexAddrsMsg:
+		if len(msg) < 3 {
+			return 0, nil, fmt.Errorf("%w: truncated addrs", ErrPexMalformed)
+		}
+		count := int(binary.BigEndian.Uint16(msg[1:3]))
+		if count > maxAddrSelection {
+			return 0, nil, fmt.Errorf("%w: %d addresses", ErrPexMalformed, count)
+		}
+
+		rest := msg[3:]
+		addrs = make([]*NetAddress, 0, count)
+		for range count {
+			if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+				return 0, nil, fmt.Errorf("%w: truncated addrs", ErrPexMalformed)
+			}
+			addr, err := ParseNetAddress(string(rest[1 : 1+int(rest[0])]))
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: %v", ErrPexMalformed, err)
+			}
+			addrs = append(addrs, addr)
+			rest = rest[1+int(rest[0]):]
+		}
+		if len(rest) != 0 {
+			return 0, nil, fmt.Errorf("%w: %d trailing bytes", ErrPexMalformed, len(rest))
+		}
+		return pexAddrsMsg, addrs, nil
+
+	case pexSelfMsg:
+		if len(msg) < 2 || len(msg) != 2+int(msg[1]) {
+			return 0, nil, fmt.Errorf("%w: truncated self address", ErrPexMalformed)
+		}
+		host, portStr, err := net.SplitHostPort(string(msg[2:]))
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrPexMalformed, err)
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil || port == 0 {
+			return 0, nil, fmt.Errorf("%w: invalid port %q", ErrPexMalformed, portStr)
+		}
+		return pexSelfMsg, []*NetAddress{{Host: host, Port: uint16(port)}}, nil
+
+	default:
+		return 0, nil, fmt.Errorf("%w: unknown type 0x%02x", ErrPexMalformed, msg[0])
+	}
+}
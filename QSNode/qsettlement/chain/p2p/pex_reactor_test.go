@@ -0,0 +1,178 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// newPexSwitch returns a started switch running a PEX reactor with an
+// in-memory address book
+func newPexSwitch(t *testing.T, listenAddr, externalAddr string) (*Switch, *AddrBook) {
+	t.Helper()
+
+	cfg := config.DefaultP2PConfig()
+	cfg.ListenAddress = listenAddr
+	cfg.ExternalAddress = externalAddr
+
+	sw, err := NewSwitch(cfg, &HandshakeConfig{NodeKey: newTestNodeKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := NewAddrBook("", sw.NodeID())
+	pex, err := NewPEXReactor(sw, book, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddReactor("pex", pex); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sw.Stop() })
+	return sw, book
+}
+
+// waitForAddr waits until book knows an address for id
+func waitForAddr(t *testing.T, book *AddrBook, id NodeID) *NetAddress {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		book.mtx.Lock()
+		ka := book.get(id)
+		book.mtx.Unlock()
+		if ka != nil {
+			return ka.Addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no address for %s", id)
+	return nil
+}
+
+func TestPexSelfMsgRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		hostPort string
+		host     string
+		port     uint16
+	}{
+		{"10.0.0.1:26656", "10.0.0.1", 26656},
+		{":26656", "", 26656},
+		{"[2001:db8::1]:26656", "2001:db8::1", 26656},
+		{"node.example.com:1", "node.example.com", 1},
+	} {
+		msgType, addrs, err := decodePexMsg(encodePexSelf(tt.hostPort))
+		if err != nil || msgType != pexSelfMsg || len(addrs) != 1 {
+			t.Fatalf("%s: got (%d, %v, %v)", tt.hostPort, msgType, addrs, err)
+		}
+		if addrs[0].Host != tt.host || addrs[0].Port != tt.port {
+			t.Errorf("%s: decoded host %q port %d", tt.hostPort, addrs[0].Host, addrs[0].Port)
+		}
+	}
+
+	for _, msg := range [][]byte{
+		{pexSelfMsg},
+		{pexSelfMsg, 5, '1', ':', '2'},
+		append([]byte{pexSelfMsg, 6}, "host:0"...),
+		append([]byte{pexSelfMsg, 4}, "host"...),
+	} {
+		if _, _, err := decodePexMsg(msg); !errors.Is(err, ErrPexMalformed) {
+			t.Errorf("%q: got %v, want %v", msg, err, ErrPexMalformed)
+		}
+	}
+}
+
+// A node that only dials out must still become discoverable: the peer it
+// dials learns the address it listens on
+func TestPexAnnouncesListenAddress(t *testing.T) {
+	listener, listenerBook := newPexSwitch(t, "tcp://127.0.0.1:0", "")
+
+	// Listening on all interfaces announces only the port; the listener
+	// fills in the IP it sees the connection come from
+	dialer, _ := newPexSwitch(t, "tcp://0.0.0.0:0", "")
+	if _, err := dialer.DialPeer(dialAddr(t, listener)); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	addr := waitForAddr(t, listenerBook, dialer.NodeID())
+	listenAddr, err := parseHostPort(dialer.ListenAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listenAddr.Port
+	if addr.Host != "127.0.0.1" || addr.Port != port {
+		t.Fatalf("listener learned %s, want 127.0.0.1:%d", addr.DialString(), port)
+	}
+
+	// The announced address is unverified until dialed
+	if good, _ := listenerBook.Size(); good != 0 {
+		t.Fatalf("announced address marked good before any dial")
+	}
+}
+
+func TestPexAnnouncesExternalAddress(t *testing.T) {
+	listener, listenerBook := newPexSwitch(t, "tcp://127.0.0.1:0", "")
+	dialer, _ := newPexSwitch(t, "tcp://127.0.0.1:0", "tcp://203.0.113.7:26656")
+	if _, err := dialer.DialPeer(dialAddr(t, listener)); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	if addr := waitForAddr(t, listenerBook, dialer.NodeID()); addr.DialString() != "203.0.113.7:26656" {
+		t.Fatalf("listener learned %s, want the external address", addr.DialString())
+	}
+}
+
+// Only the dialing side verifies the address it dialed
+func TestPexMarksGoodAfterOutboundHandshake(t *testing.T) {
+	listener, listenerBook := newPexSwitch(t, "tcp://127.0.0.1:0", "")
+	dialer, dialerBook := newPexSwitch(t, "tcp://127.0.0.1:0", "")
+	if _, err := dialer.DialPeer(dialAddr(t, listener)); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	waitForAddr(t, listenerBook, dialer.NodeID())
+	if bucketOf(dialerBook, listener.NodeID()) != "good" {
+		t.Fatal("dialed address not marked good")
+	}
+	if bucketOf(listenerBook, dialer.NodeID()) != "bad" {
+		t.Fatal("inbound peer's address marked good")
+	}
+}
+
+func TestPexRequestRateOutlivesConnection(t *testing.T) {
+	sw, err := NewSwitch(config.DefaultP2PConfig(), &HandshakeConfig{NodeKey: newTestNodeKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pex, err := NewPEXReactor(sw, NewAddrBook("", sw.NodeID()), config.DefaultP2PConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _ := secureConnPair(t)
+	peer := newPeer(conn, nil, false, false)
+	if err := pex.checkRequestRate(peer.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reconnecting does not reset the limit
+	pex.RemovePeer(peer, errors.New("test disconnect"))
+	if err := pex.checkRequestRate(peer.ID()); !errors.Is(err, ErrPexTooFrequent) {
+		t.Fatalf("request after reconnect: got %v, want %v", err, ErrPexTooFrequent)
+	}
+
+	// Pruning keeps recent request times and drops stale ones
+	pex.pruneRequestRates()
+	if _, ok := pex.lastRequest[peer.ID()]; !ok {
+		t.Fatal("recent request time pruned")
+	}
+	pex.lastRequest[peer.ID()] = time.Now().Add(-pex.ensurePeersPeriod)
+	pex.pruneRequestRates()
+	if len(pex.lastRequest) != 0 {
+		t.Fatal("stale request time kept")
+	}
+}
@@ -0,0 +1,20 @@
+package p2p
+
+// Reactor handles the messages of one or more channels. The Switch calls
+// AddPeer when a peer is connected, Receive for every message on the
+// reactor's channels, and RemovePeer when the peer disconnects. Receive runs
+// on the peer's receive routine, so slow work must be handed off.
+type Reactor interface {
+	Channels() []*ChannelDescriptor
+	AddPeer(peer *Peer)
+	RemovePeer(peer *Peer, reason error)
+	Receive(chID byte, peer *Peer, msg []byte)
+}
+
+// ReactorService is a reactor with background work. The Switch starts it
+// after it starts listening and stops it before disconnecting peers.
+type ReactorService interface {
+	Reactor
+	Start() error
+	Stop()
+}
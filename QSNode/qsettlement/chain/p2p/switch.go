@@ -0,0 +1,649 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// Persistent peer redial backoff
+const (
+	dialBackoffInitial = time.Second
+	dialBackoffMax     = 5 * time.Minute
+)
+
+// Accept error backoff, so a persistent failure such as running out of
+// file descriptors does not spin the accept loop
+const (
+	acceptBackoffInitial = 5 * time.Millisecond
+	acceptBackoffMax     = time.Second
+)
+
+// broadcastQueueSize bounds the broadcasts waiting for broadcastRoutine
+const broadcastQueueSize = 64
+
+// maxInboundHandshakes bounds the inbound handshakes in flight; connections
+// beyond it are closed before any KEM or signature work
+const maxInboundHandshakes = 16
+
+// Switch errors
+var (
+	ErrSwitchNotRunning = errors.New("switch is not running")
+	ErrSwitchRunning    = errors.New("switch is already running")
+	ErrSelfConnection   = errors.New("connection to self")
+	ErrDuplicatePeer    = errors.New("peer already connected")
+	ErrMaxInboundPeers  = errors.New("inbound peer limit reached")
+	ErrMaxOutboundPeers = errors.New("outbound peer limit reached")
+	ErrMaxHandshakes    = errors.New("inbound handshake limit reached")
+	ErrPeerNotFound     = errors.New("peer not found")
+	ErrDuplicateReactor = errors.New("reactor or channel already registered")
+)
+
+// Switch manages the node's peer set. It accepts inbound connections,
+// keeps persistent peers connected, runs the PQ handshake on every
+// connection and enforces the inbound/outbound peer limits.
+//
+// Every peer runs an MConnection carrying the channels of the registered
+// reactors; a failed connection removes the peer.
+type Switch struct {
+	cfg      *config.P2PConfig
+	hsCfg    *HandshakeConfig
+	mconnCfg MConnConfig
+	nodeID   NodeID
+	handlers []PeerEventHandler
+
+	reactors     map[string]Reactor
+	reactorsByCh map[byte]Reactor
+	chDescs      []*ChannelDescriptor
+
+	mtx        sync.RWMutex
+	peers      map[NodeID]*Peer
+	dialing    map[NodeID]bool
+	persistent map[NodeID]*NetAddress
+	inbound    int
+	outbound   int
+
+	listener       net.Listener
+	handshakeSlots chan struct{} // Semaphore on inbound handshakes
+	broadcasts     chan broadcast
+	running        bool
+	quit           chan struct{}
+	wg             sync.WaitGroup
+}
+
+// NewSwitch creates a switch. hsCfg must carry the node key; its Timeout
+// defaults to cfg.HandshakeTimeout.
+func NewSwitch(cfg *config.P2PConfig, hsCfg *HandshakeConfig) (*Switch, error) {
+	if cfg == nil {
+		cfg = config.DefaultP2PConfig()
+	}
+	if hsCfg == nil || hsCfg.NodeKey == nil {
+		return nil, fmt.Errorf("%w: node key required", ErrHandshakeConfig)
+	}
+
+	persistentAddrs, err := ParseNetAddresses(cfg.PersistentPeers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid persistent_peers: %w", err)
+	}
+
+	handshake := *hsCfg
+	if handshake.Timeout == 0 {
+		handshake.Timeout = cfg.HandshakeTimeout
+	}
+
+	sw := &Switch{
+		cfg:          cfg,
+		hsCfg:        &handshake,
+		mconnCfg:     mconnConfig(cfg),
+		nodeID:       hsCfg.NodeKey.ID(),
+		reactors:     make(map[string]Reactor),
+		reactorsByCh: make(map[byte]Reactor),
+		peers:        make(map[NodeID]*Peer),
+		dialing:      make(map[NodeID]bool),
+		persistent:   make(map[NodeID]*NetAddress),
+
+		handshakeSlots: make(chan struct{}, maxInboundHandshakes),
+		broadcasts:     make(chan broadcast, broadcastQueueSize),
+	}
+	for _, addr := range persistentAddrs {
+		sw.persistent[addr.ID] = addr
+	}
+
+	return sw, nil
+}
+
+// mconnConfig applies the P2P config's connection settings to the defaults
+func mconnConfig(cfg *config.P2PConfig) MConnConfig {
+	mconnCfg := DefaultMConnConfig()
+	if cfg.SendRate > 0 {
+		mconnCfg.SendRate = cfg.SendRate
+	}
+	if cfg.RecvRate > 0 {
+		mconnCfg.RecvRate = cfg.RecvRate
+	}
+	if cfg.MaxPacketMsgPayloadSize > 0 {
+		mconnCfg.MaxPacketPayloadSize = cfg.MaxPacketMsgPayloadSize
+	}
+	if cfg.PingInterval > 0 {
+		mconnCfg.PingInterval = cfg.PingInterval
+	}
+	if cfg.PongTimeout > 0 {
+		mconnCfg.PongTimeout = cfg.PongTimeout
+	}
+	return mconnCfg
+}
+
+// AddReactor registers a reactor and its channels. It must be called
+// before Start.
+func (sw *Switch) AddReactor(name string, reactor Reactor) error {
+	if _, ok := sw.reactors[name]; ok {
+		return fmt.Errorf("%w: reactor %s", ErrDuplicateReactor, name)
+	}
+
+	for _, desc := range reactor.Channels() {
+		if _, ok := sw.reactorsByCh[desc.ID]; ok {
+			return fmt.Errorf("%w: channel 0x%02x", ErrDuplicateReactor, desc.ID)
+		}
+	}
+
+	for _, desc := range reactor.Channels() {
+		sw.reactorsByCh[desc.ID] = reactor
+		sw.chDescs = append(sw.chDescs, desc)
+	}
+	sw.reactors[name] = reactor
+
+	log.Printf("[Switch] Added reactor %s (%d channels)\n", name, len(reactor.Channels()))
+	return nil
+}
+
+// Reactor returns a registered reactor by name
+func (sw *Switch) Reactor(name string) Reactor {
+	return sw.reactors[name]
+}
+
+// Broadcast queues msg for broadcastRoutine to send on channel chID to
+// every connected peer. It blocks while the broadcast queue is full and
+// reports whether msg was queued.
+func (sw *Switch) Broadcast(chID byte, msg []byte) bool {
+	sw.mtx.RLock()
+	running, quit := sw.running, sw.quit
+	sw.mtx.RUnlock()
+	if !running {
+		return false
+	}
+
+	select {
+	case sw.broadcasts <- broadcast{chID: chID, msg: msg}:
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+// NodeID returns our own node ID
+func (sw *Switch) NodeID() NodeID {
+	return sw.nodeID
+}
+
+// OnPeerEvent registers a handler for peer lifecycle events. It must be
+// called before Start.
+func (sw *Switch) OnPeerEvent(handler PeerEventHandler) {
+	sw.handlers = append(sw.handlers, handler)
+}
+
+// Start listens on cfg.ListenAddress and starts dialing persistent peers
+func (sw *Switch) Start() error {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	if sw.running {
+		return ErrSwitchRunning
+	}
+
+	listener, err := net.Listen("tcp", strings.TrimPrefix(sw.cfg.ListenAddress, "tcp://"))
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", sw.cfg.ListenAddress, err)
+	}
+
+	sw.listener = listener
+	sw.quit = make(chan struct{})
+	sw.running = true
+
+	sw.wg.Add(2)
+	go sw.acceptRoutine()
+	go sw.broadcastRoutine()
+
+	for name, reactor := range sw.reactors {
+		if service, ok := reactor.(ReactorService); ok {
+			if err := service.Start(); err != nil {
+				sw.running = false
+				close(sw.quit)
+				listener.Close()
+				return fmt.Errorf("failed to start reactor %s: %w", name, err)
+			}
+		}
+	}
+
+	for _, addr := range sw.persistent {
+		sw.wg.Add(1)
+		go sw.persistentPeerRoutine(addr)
+	}
+
+	log.Printf("[Switch] Node %s listening on %s (%d persistent peers)\n",
+		sw.nodeID, listener.Addr(), len(sw.persistent))
+	return nil
+}
+
+// Stop closes the listener and disconnects every peer
+func (sw *Switch) Stop() error {
+	sw.mtx.Lock()
+	if !sw.running {
+		sw.mtx.Unlock()
+		return ErrSwitchNotRunning
+	}
+	sw.running = false
+	close(sw.quit)
+	sw.listener.Close()
+
+	peers := make([]*Peer, 0, len(sw.peers))
+	for _, peer := range sw.peers {
+		peers = append(peers, peer)
+	}
+	sw.mtx.Unlock()
+
+	for _, reactor := range sw.reactors {
+		if service, ok := reactor.(ReactorService); ok {
+			service.Stop()
+		}
+	}
+
+	for _, peer := range peers {
+		sw.StopPeerForError(peer, ErrSwitchNotRunning)
+	}
+
+	sw.wg.Wait()
+	log.Printf("[Switch] Stopped\n")
+	return nil
+}
+
+// ListenAddr returns the address the switch is listening on
+func (sw *Switch) ListenAddr() net.Addr {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	if sw.listener == nil {
+		return nil
+	}
+	return sw.listener.Addr()
+}
+
+// Peers returns the connected peers
+func (sw *Switch) Peers() []*Peer {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	peers := make([]*Peer, 0, len(sw.peers))
+	for _, peer := range sw.peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// Peer returns a connected peer by ID
+func (sw *Switch) Peer(id NodeID) (*Peer, bool) {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	peer, ok := sw.peers[id]
+	return peer, ok
+}
+
+// MaxNumOutboundPeers returns the configured outbound peer limit
+func (sw *Switch) MaxNumOutboundPeers() int {
+	return sw.cfg.MaxNumOutboundPeers
+}
+
+// NumPeers returns the number of outbound and inbound peers
+func (sw *Switch) NumPeers() (outbound, inbound int) {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	return sw.outbound, sw.inbound
+}
+
+// IsDialingOrConnected reports whether id is connected or being dialed
+func (sw *Switch) IsDialingOrConnected(id NodeID) bool {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	_, connected := sw.peers[id]
+	return connected || sw.dialing[id]
+}
+
+// DialPeer connects to addr and adds it as a peer. Non-persistent dials
+// count against MaxNumOutboundPeers.
+func (sw *Switch) DialPeer(addr *NetAddress) (*Peer, error) {
+	_, persistent := sw.persistent[addr.ID]
+	return sw.dialPeer(addr, persistent)
+}
+
+// StopPeerForError disconnects a peer; reason is reported in the
+// PeerDisconnected event
+func (sw *Switch) StopPeerForError(peer *Peer, reason error) {
+	sw.mtx.Lock()
+	current, ok := sw.peers[peer.ID()]
+	if !ok || current != peer {
+		sw.mtx.Unlock()
+		peer.stop()
+		return
+	}
+
+	delete(sw.peers, peer.ID())
+	if peer.IsOutbound() {
+		sw.outbound--
+	} else {
+		sw.inbound--
+	}
+	sw.mtx.Unlock()
+
+	peer.stop()
+
+	for _, reactor := range sw.reactors {
+		reactor.RemovePeer(peer, reason)
+	}
+
+	log.Printf("[Switch] Peer %s disconnected: %v\n", peer.ID(), reason)
+	sw.emit(PeerEvent{Type: PeerDisconnected, Peer: peer, Addr: peer.NetAddress(), Err: reason})
+}
+
+// StopPeer disconnects the peer with the given ID
+func (sw *Switch) StopPeer(id NodeID, reason error) error {
+	peer, ok := sw.Peer(id)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPeerNotFound, id)
+	}
+
+	sw.StopPeerForError(peer, reason)
+	return nil
+}
+
+// acceptRoutine accepts inbound connections until the listener closes,
+// backing off while Accept keeps failing
+func (sw *Switch) acceptRoutine() {
+	defer sw.wg.Done()
+
+	var backoff time.Duration
+	for {
+		conn, err := sw.listener.Accept()
+		if err != nil {
+			select {
+			case <-sw.quit:
+				return
+			default:
+			}
+
+			backoff = min(max(2*backoff, acceptBackoffInitial), acceptBackoffMax)
+			log.Printf("[Switch] Accept failed, retrying in %s: %v\n", backoff, err)
+			select {
+			case <-sw.quit:
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+
+		// Refuse before the handshake when full, so a flood of inbound
+		// connections costs no KEM or signature work
+		sw.mtx.RLock()
+		full := sw.inbound >= sw.cfg.MaxNumInboundPeers
+		sw.mtx.RUnlock()
+		if full {
+			log.Printf("[Switch] Rejected inbound %s: %v\n", conn.RemoteAddr(), ErrMaxInboundPeers)
+			conn.Close()
+			continue
+		}
+
+		select {
+		case sw.handshakeSlots <- struct{}{}:
+		default:
+			log.Printf("[Switch] Rejected inbound %s: %v\n", conn.RemoteAddr(), ErrMaxHandshakes)
+			conn.Close()
+			continue
+		}
+
+		sw.wg.Add(1)
+		go func() {
+			defer sw.wg.Done()
+			_, err := sw.acceptPeer(conn)
+			<-sw.handshakeSlots
+			if err != nil {
+				log.Printf("[Switch] Rejected inbound %s: %v\n", conn.RemoteAddr(), err)
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// acceptPeer runs the listener side of the handshake and adds the peer
+func (sw *Switch) acceptPeer(conn net.Conn) (*Peer, error) {
+	secureConn, err := PQHandshakeListener(conn, sw.hsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := NewNetAddressFromConn(secureConn.RemoteID(), conn.RemoteAddr())
+	if err != nil {
+		return nil, err
+	}
+
+	_, persistent := sw.persistent[addr.ID]
+	peer := newPeer(secureConn, addr, false, persistent)
+	if err := sw.addPeer(peer); err != nil {
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+// dialPeer dials addr, runs the initiator side of the handshake expecting
+// addr.ID and adds the peer
+func (sw *Switch) dialPeer(addr *NetAddress, persistent bool) (*Peer, error) {
+	if addr.ID == sw.nodeID {
+		return nil, ErrSelfConnection
+	}
+
+	sw.mtx.Lock()
+	if !sw.running {
+		sw.mtx.Unlock()
+		return nil, ErrSwitchNotRunning
+	}
+	if _, ok := sw.peers[addr.ID]; ok || sw.dialing[addr.ID] {
+		sw.mtx.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrDuplicatePeer, addr.ID)
+	}
+	if !persistent && sw.outbound >= sw.cfg.MaxNumOutboundPeers {
+		sw.mtx.Unlock()
+		return nil, ErrMaxOutboundPeers
+	}
+	sw.dialing[addr.ID] = true
+	sw.mtx.Unlock()
+
+	defer func() {
+		sw.mtx.Lock()
+		delete(sw.dialing, addr.ID)
+		sw.mtx.Unlock()
+	}()
+
+	peer, err := sw.connect(addr, persistent)
+	if err != nil {
+		sw.emit(PeerEvent{Type: PeerDialFailed, Addr: addr, Err: err})
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+func (sw *Switch) connect(addr *NetAddress, persistent bool) (*Peer, error) {
+	conn, err := net.DialTimeout("tcp", addr.DialString(), sw.cfg.DialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+
+	secureConn, err := PQHandshake(conn, sw.hsCfg, addr.ID)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	peer := newPeer(secureConn, addr, true, persistent)
+	if err := sw.addPeer(peer); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+// addPeer checks the authenticated peer against the peer set and limits,
+// then adds it, hands it to the reactors and starts its MConnection
+func (sw *Switch) addPeer(peer *Peer) error {
+	id := peer.ID()
+	if id == sw.nodeID {
+		return ErrSelfConnection
+	}
+
+	mconn, err := NewMConnection(peer.conn, sw.chDescs,
+		func(chID byte, msg []byte) {
+			sw.reactorsByCh[chID].Receive(chID, peer, msg)
+		},
+		func(err error) {
+			sw.StopPeerForError(peer, err)
+		},
+		sw.mconnCfg)
+	if err != nil {
+		return err
+	}
+	peer.mconn = mconn
+
+	sw.mtx.Lock()
+	if !sw.running {
+		sw.mtx.Unlock()
+		return ErrSwitchNotRunning
+	}
+	if _, ok := sw.peers[id]; ok {
+		sw.mtx.Unlock()
+		return fmt.Errorf("%w: %s", ErrDuplicatePeer, id)
+	}
+
+	if peer.IsOutbound() {
+		if !peer.IsPersistent() && sw.outbound >= sw.cfg.MaxNumOutboundPeers {
+			sw.mtx.Unlock()
+			return ErrMaxOutboundPeers
+		}
+		sw.outbound++
+	} else {
+		if sw.inbound >= sw.cfg.MaxNumInboundPeers {
+			sw.mtx.Unlock()
+			return ErrMaxInboundPeers
+		}
+		sw.inbound++
+	}
+	sw.peers[id] = peer
+	sw.mtx.Unlock()
+
+	for _, reactor := range sw.reactors {
+		reactor.AddPeer(peer)
+	}
+	mconn.Start()
+
+	log.Printf("[Switch] Added peer %s (outbound: %t, persistent: %t)\n",
+		peer.NetAddress(), peer.IsOutbound(), peer.IsPersistent())
+	sw.emit(PeerEvent{Type: PeerConnected, Peer: peer, Addr: peer.NetAddress()})
+
+	return nil
+}
+
+// persistentPeerRoutine keeps addr connected, redialing with exponential
+// backoff whenever the dial fails or the peer disconnects
+func (sw *Switch) persistentPeerRoutine(addr *NetAddress) {
+	defer sw.wg.Done()
+
+	backoff := dialBackoffInitial
+	for {
+		peer, err := sw.dialPeer(addr, true)
+		if errors.Is(err, ErrDuplicatePeer) {
+			// Connected inbound, or a dial is in flight; watch that instead
+			peer, _ = sw.Peer(addr.ID)
+			err = nil
+		}
+
+		if err == nil && peer != nil {
+			select {
+			case <-peer.Done():
+			case <-sw.quit:
+				return
+			}
+			// Only a connection that stayed up resets the backoff, so a peer
+			// that drops us right after the handshake is not redialed in a loop
+			if peer.Uptime() >= dialBackoffMax {
+				backoff = dialBackoffInitial
+			}
+		}
+
+		if errors.Is(err, ErrSwitchNotRunning) {
+			return
+		}
+		if err != nil {
+			log.Printf("[Switch] Dial to persistent peer %s failed, retrying in %s: %v\n", addr, backoff, err)
+		}
+
+		// Up to 25% jitter so restarted nodes don't redial in lockstep
+		wait := backoff + rand.N(backoff/4+1)
+		select {
+		case <-time.After(wait):
+		case <-sw.quit:
+			return
+		}
+		backoff = min(2*backoff, dialBackoffMax)
+	}
+}
+
+// broadcast is a message queued by Broadcast
+type broadcast struct {
+	chID byte
+	msg  []byte
+}
+
+// broadcastRoutine hands queued broadcasts to each peer's send queue. It
+// never waits on a peer: a peer whose queue is full misses the message.
+func (sw *Switch) broadcastRoutine() {
+	defer sw.wg.Done()
+
+	for {
+		select {
+		case b := <-sw.broadcasts:
+			for _, peer := range sw.Peers() {
+				if !peer.TrySend(b.chID, b.msg) {
+					log.Printf("[Switch] Dropped broadcast on channel %#x to peer %s: send queue full\n", b.chID, peer.ID())
+				}
+			}
+		case <-sw.quit:
+			return
+		}
+	}
+}
+
+// emit delivers an event to every handler
+func (sw *Switch) emit(event PeerEvent) {
+	for _, handler := range sw.handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,244 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// newTestSwitch returns a started switch listening on a loopback port
+func newTestSwitch(t *testing.T, slots int) *Switch {
+	t.Helper()
+
+	cfg := config.DefaultP2PConfig()
+	cfg.ListenAddress = "tcp://127.0.0.1:0"
+	cfg.HandshakeTimeout = 10 * time.Second
+
+	sw, err := NewSwitch(cfg, &HandshakeConfig{NodeKey: newTestNodeKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots > 0 {
+		sw.handshakeSlots = make(chan struct{}, slots)
+	}
+	if err := sw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sw.Stop() })
+	return sw
+}
+
+// dialAddr returns sw's listen address as a NetAddress for its node ID
+func dialAddr(t *testing.T, sw *Switch) *NetAddress {
+	t.Helper()
+
+	addr, err := ParseNetAddress(fmt.Sprintf("%s@%s", sw.NodeID(), sw.ListenAddr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestSwitchBoundsInboundHandshakes(t *testing.T) {
+	sw := newTestSwitch(t, 1)
+
+	// A peer that connects and never speaks holds the only handshake slot
+	stalled, err := net.Dial("tcp", sw.ListenAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sw.handshakeSlots) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stalled connection never started a handshake")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Further connections are closed without a handshake
+	extra, err := net.Dial("tcp", sw.ListenAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer extra.Close()
+	extra.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = extra.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); err == nil || ok && netErr.Timeout() {
+		t.Fatalf("connection beyond the handshake limit got %v, want it closed", err)
+	}
+
+	// Once the stalled handshake fails its slot is free again
+	stalled.Close()
+	dialer := newTestSwitch(t, 0)
+	for {
+		_, err := dialer.DialPeer(dialAddr(t, sw))
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dial after the slot was released: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// failingListener fails every Accept until closed
+type failingListener struct {
+	accepts atomic.Int64
+	closed  chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.accepts.Add(1)
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+		return nil, errors.New("accept: too many open files")
+	}
+}
+
+func (l *failingListener) Close() error   { close(l.closed); return nil }
+func (l *failingListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestSwitchBacksOffOnAcceptErrors(t *testing.T) {
+	sw, err := NewSwitch(config.DefaultP2PConfig(), &HandshakeConfig{NodeKey: newTestNodeKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := &failingListener{closed: make(chan struct{})}
+	sw.listener = listener
+	sw.quit = make(chan struct{})
+	sw.wg.Add(1)
+	go sw.acceptRoutine()
+
+	time.Sleep(300 * time.Millisecond)
+	close(sw.quit)
+	listener.Close()
+	sw.wg.Wait()
+
+	// 5ms doubling: about 7 attempts in 300ms, not a busy loop
+	if n := listener.accepts.Load(); n > 12 {
+		t.Fatalf("%d Accept calls in 300ms, want backoff", n)
+	}
+}
+
+func TestSwitchBroadcastSkipsFullQueues(t *testing.T) {
+	sw, err := NewSwitch(config.DefaultP2PConfig(), &HandshakeConfig{NodeKey: newTestNodeKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The MConnection is never started, so nothing drains its send queue
+	local, _ := secureConnPair(t)
+	mconn, err := NewMConnection(local, []*ChannelDescriptor{{ID: 0x01, SendQueueCapacity: 2}},
+		func(byte, []byte) {}, func(error) {}, DefaultMConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := newPeer(local, nil, true, false)
+	peer.mconn = mconn
+	sw.peers[peer.ID()] = peer
+
+	sw.running = true
+	sw.quit = make(chan struct{})
+	sw.wg.Add(1)
+	go sw.broadcastRoutine()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 2 * broadcastQueueSize {
+			if !sw.Broadcast(0x01, []byte{byte(i)}) {
+				t.Errorf("broadcast %d not queued", i)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Broadcast blocked on a peer with a full send queue")
+	}
+
+	close(sw.quit)
+	sw.wg.Wait()
+
+	// The peer's queue kept the first messages and dropped the rest
+	queue := mconn.byID[0x01].sendQueue
+	if len(queue) != 2 {
+		t.Fatalf("peer queue holds %d messages, want 2", len(queue))
+	}
+	for i := range 2 {
+		if msg := <-queue; msg[0] != byte(i) {
+			t.Errorf("queued message %d is %d", i, msg[0])
+		}
+	}
+
+	sw.running = false
+	if sw.Broadcast(0x01, []byte{0}) {
+		t.Error("Broadcast on a stopped switch reported the message queued")
+	}
+}
+
+func TestSwitchPersistentPeerWaitsBeforeRedial(t *testing.T) {
+	remote := newTestSwitch(t, 0)
+
+	cfg := config.DefaultP2PConfig()
+	cfg.ListenAddress = "tcp://127.0.0.1:0"
+	cfg.PersistentPeers = dialAddr(t, remote).String()
+	sw, err := NewSwitch(cfg, &HandshakeConfig{NodeKey: newTestNodeKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connected := make(chan time.Time, 4)
+	disconnected := make(chan time.Time, 4)
+	sw.OnPeerEvent(func(e PeerEvent) {
+		switch e.Type {
+		case PeerConnected:
+			connected <- time.Now()
+		case PeerDisconnected:
+			disconnected <- time.Now()
+		}
+	})
+	if err := sw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sw.Stop() })
+
+	select {
+	case <-connected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("persistent peer never connected")
+	}
+
+	// The remote end drops us; the redial waits out the backoff
+	for {
+		if err := remote.StopPeer(sw.NodeID(), errors.New("test disconnect")); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	var dropped time.Time
+	select {
+	case dropped = <-disconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("disconnect not noticed")
+	}
+
+	select {
+	case redialed := <-connected:
+		if wait := redialed.Sub(dropped); wait < dialBackoffInitial {
+			t.Fatalf("redialed %s after the disconnect, want at least %s", wait, dialBackoffInitial)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("persistent peer never reconnected")
+	}
+}
@@ -0,0 +1,238 @@
+package tx
+
+import (
+	"container/list"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultVerifyCacheSize bounds the number of verified txs remembered by hash.
+// Sized for several 10k-tx blocks so mempool-verified txs hit at block import.
+const DefaultVerifyCacheSize = 65536
+
+// verifiedTx is a cached verification outcome. It records the generation of
+// the verifier's algorithm registry, since disabling an algorithm must make
+// cached txs using it fail again.
+type verifiedTx struct {
+	hash     common.Hash
+	from     common.Address
+	algosGen uint64
+}
+
+// verifyCache is a bounded LRU of successfully verified transactions keyed by
+// keccak256 of the raw 0x79 encoding, i.e. the tx hash. The derived From, Hash
+// and Type fields are excluded from that encoding, so the key is the same
+// before and after verification. Failures are not cached so a compact tx
+// rejected for an unregistered key can pass once the key lands in state.
+type verifyCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[common.Hash]*list.Element
+	order   *list.List
+}
+
+func newVerifyCache(size int) *verifyCache {
+	if size <= 0 {
+		size = DefaultVerifyCacheSize
+	}
+	return &verifyCache{
+		size:    size,
+		entries: make(map[common.Hash]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *verifyCache) get(hash common.Hash) (*verifiedTx, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[hash]
+	if !exists {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*verifiedTx), true
+}
+
+func (c *verifyCache) add(entry *verifiedTx) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.entries[entry.hash]; exists {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[entry.hash] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*verifiedTx).hash)
+	}
+}
+
+func (c *verifyCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *verifyCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[common.Hash]*list.Element)
+	c.order.Init()
+}
+
+// BatchVerifier verifies slices of PQ transactions across a worker pool and
+// caches sender/hash results by raw-tx hash
+type BatchVerifier struct {
+	workers int
+	keys    PQKeyStore
+	algos   *SigAlgoRegistry
+	cache   *verifyCache
+}
+
+// NewBatchVerifier creates a batch verifier that accepts the algorithms of
+// SigAlgos(). workers <= 0 uses one worker per CPU; keys may be nil if
+// compact transactions are not accepted.
+func NewBatchVerifier(workers int, cacheSize int, keys PQKeyStore) *BatchVerifier {
+	return NewBatchVerifierWithAlgos(workers, cacheSize, keys, nil)
+}
+
+// NewBatchVerifierWithAlgos creates a batch verifier that accepts the
+// algorithms enabled in algos, e.g. NewSigAlgoRegistry(chainConfig); nil
+// uses SigAlgos()
+func NewBatchVerifierWithAlgos(workers int, cacheSize int, keys PQKeyStore, algos *SigAlgoRegistry) *BatchVerifier {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if algos == nil {
+		algos = SigAlgos()
+	}
+
+	return &BatchVerifier{
+		workers: workers,
+		keys:    keys,
+		algos:   algos,
+		cache:   newVerifyCache(cacheSize),
+	}
+}
+
+// Verify verifies a single transaction, consulting the cache first
+func (bv *BatchVerifier) Verify(tx *PQTransaction) error {
+	if tx != nil {
+		if hash, err := tx.ComputeHash(); err == nil {
+			if entry, hit := bv.cache.get(hash); hit && bv.stillValid(tx, entry) {
+				from := entry.from
+				tx.From = &from
+				tx.Hash = hash
+				return nil
+			}
+		}
+	}
+
+	// Snapshot the generation first: if the registry changes mid-verification
+	// the entry is recorded against the old generation and misses next time
+	algosGen := bv.algos.Generation()
+
+	if err := verifyPQTx(tx, bv.keys, bv.algos); err != nil {
+		return err
+	}
+
+	bv.cache.add(&verifiedTx{hash: tx.Hash, from: *tx.From, algosGen: algosGen})
+	return nil
+}
+
+// stillValid reports whether a cached result still holds: the algorithm
+// registry must be unchanged, and a compact tx's key must still resolve in
+// the key store (it may have been rolled back with the state)
+func (bv *BatchVerifier) stillValid(tx *PQTransaction, entry *verifiedTx) bool {
+	if entry.algosGen != bv.algos.Generation() {
+		return false
+	}
+
+	if tx.IsCompact() {
+		if _, err := tx.ResolvePublicKey(bv.keys); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// VerifyBatch verifies txs in parallel and returns one error slot per tx
+// (nil for valid transactions). Each valid tx has From and Hash set.
+func (bv *BatchVerifier) VerifyBatch(txs []*PQTransaction) []error {
+	errs := make([]error, len(txs))
+	if len(txs) == 0 {
+		return errs
+	}
+
+	start := time.Now()
+
+	workers := bv.workers
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = bv.Verify(txs[i])
+			}
+		}()
+	}
+
+	for i := range txs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+
+	log.Printf("[PQTx] Verified batch of %d txs (%d invalid) with %d workers in %s\n",
+		len(txs), failed, workers, time.Since(start))
+
+	return errs
+}
+
+// CacheLen returns the number of cached verification results
+func (bv *BatchVerifier) CacheLen() int {
+	return bv.cache.len()
+}
+
+// ClearCache drops all cached results, e.g. after a reorg replaces the
+// state the key store reads from
+func (bv *BatchVerifier) ClearCache() {
+	bv.cache.clear()
+}
+
+var (
+	defaultBatchVerifierOnce sync.Once
+	defaultBatchVerifier     *BatchVerifier
+)
+
+// VerifyBatch verifies txs with a shared, process-wide BatchVerifier that
+// uses one worker per CPU and does not resolve compact transactions
+func VerifyBatch(txs []*PQTransaction) []error {
+	defaultBatchVerifierOnce.Do(func() {
+		defaultBatchVerifier = NewBatchVerifier(0, DefaultVerifyCacheSize, nil)
+	})
+	return defaultBatchVerifier.VerifyBatch(txs)
+}
@@ -0,0 +1,189 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+func TestBatchVerifierCacheKeyStable(t *testing.T) {
+	signer := newTestSigner(t)
+	tx := signer.sign(t, newTestTx(0), false)
+	raw, err := tx.EncodePQTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bv := NewBatchVerifier(1, 16, nil)
+	if err := bv.Verify(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh decode has no derived fields yet; it must map to the same entry
+	fresh, err := DecodePQTx(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := fresh.ComputeHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != tx.Hash {
+		t.Fatalf("hash changed by verification: %s before, %s after", hash.Hex(), tx.Hash.Hex())
+	}
+	if _, hit := bv.cache.get(hash); !hit {
+		t.Fatal("freshly decoded tx misses the cache")
+	}
+
+	if err := bv.Verify(fresh); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.From == nil || *fresh.From != signer.address() || bv.CacheLen() != 1 {
+		t.Fatalf("unexpected cache hit result: from %v, %d entries", fresh.From, bv.CacheLen())
+	}
+}
+
+func TestBatchVerifierCacheFollowsAlgoRegistry(t *testing.T) {
+	signer := newTestSigner(t)
+	tx := signer.sign(t, newTestTx(0), false)
+
+	algos := NewSigAlgoRegistry(config.DefaultChainConfig())
+	bv := NewBatchVerifierWithAlgos(1, 16, nil, algos)
+	if err := bv.Verify(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := algos.SetEnabled(SigAlgoDilithium2, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := bv.Verify(tx); !errors.Is(err, ErrSigAlgoDisabled) {
+		t.Fatalf("cached result survived disabling the algorithm: %v", err)
+	}
+
+	algos.SetEnabled(SigAlgoDilithium2, true)
+	if err := bv.Verify(tx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// revocableKeyStore can drop keys, like state rolled back by a reorg
+type revocableKeyStore struct {
+	*MemoryKeyStore
+	revoked map[common.Address]bool
+}
+
+func (ks *revocableKeyStore) GetPQKey(addr common.Address) []byte {
+	if ks.revoked[addr] {
+		return nil
+	}
+	return ks.MemoryKeyStore.GetPQKey(addr)
+}
+
+func TestBatchVerifierCacheFollowsKeyStore(t *testing.T) {
+	signer := newTestSigner(t)
+	keys := &revocableKeyStore{MemoryKeyStore: NewMemoryKeyStore(), revoked: make(map[common.Address]bool)}
+	if _, err := RegisterPQKey(signer.sign(t, newTestTx(0), false), keys); err != nil {
+		t.Fatal(err)
+	}
+
+	compact := signer.sign(t, newTestTx(1), true)
+	bv := NewBatchVerifier(1, 16, keys)
+	if err := bv.Verify(compact); err != nil {
+		t.Fatal(err)
+	}
+
+	keys.revoked[signer.address()] = true
+	if err := bv.Verify(compact); !errors.Is(err, ErrPQKeyNotRegistered) {
+		t.Fatalf("cached compact tx verified without its key: %v", err)
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	signer := newTestSigner(t)
+	txs := make([]*PQTransaction, 8)
+	for i := range txs {
+		txs[i] = signer.sign(t, newTestTx(uint64(i)), false)
+	}
+	txs[3].PQSignature[0] ^= 0x01
+	txs[5].PQSigAlgo = 0x7f
+
+	errs := NewBatchVerifier(4, 16, nil).VerifyBatch(txs)
+	for i, err := range errs {
+		switch i {
+		case 3:
+			if !errors.Is(err, ErrInvalidPQSignature) {
+				t.Fatalf("tx %d: expected ErrInvalidPQSignature, got %v", i, err)
+			}
+		case 5:
+			if !errors.Is(err, ErrUnknownSigAlgo) {
+				t.Fatalf("tx %d: expected ErrUnknownSigAlgo, got %v", i, err)
+			}
+		default:
+			if err != nil || txs[i].From == nil || *txs[i].From != signer.address() {
+				t.Fatalf("tx %d: %v", i, err)
+			}
+		}
+	}
+}
+
+var (
+	benchTxsOnce sync.Once
+	benchTxs     []*PQTransaction
+)
+
+// blockOfTxs returns n signed Dilithium2 transfers from 64 senders, shared
+// across benchmarks since signing 10k txs dominates setup
+func blockOfTxs(b *testing.B, n int) []*PQTransaction {
+	benchTxsOnce.Do(func() {
+		signers := make([]*testSigner, 64)
+		for i := range signers {
+			signers[i] = newTestSigner(b)
+		}
+		benchTxs = make([]*PQTransaction, 10_000)
+		for i := range benchTxs {
+			benchTxs[i] = signers[i%len(signers)].sign(b, newTestTx(uint64(i/len(signers))), false)
+		}
+	})
+	return benchTxs[:n]
+}
+
+// BenchmarkVerifyBatch measures block import throughput for 1k-10k 0x79 txs,
+// with a cold cache (txs first seen in the block) and a warm one (txs already
+// verified by the txpool)
+func BenchmarkVerifyBatch(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(out) })
+
+	for _, n := range []int{1_000, 5_000, 10_000} {
+		txs := blockOfTxs(b, n)
+
+		b.Run(fmt.Sprintf("cold/%d", n), func(b *testing.B) {
+			blocks := 0
+			for b.Loop() {
+				NewBatchVerifier(0, DefaultVerifyCacheSize, nil).VerifyBatch(txs)
+				blocks++
+			}
+			b.ReportMetric(float64(blocks*n)/b.Elapsed().Seconds(), "tx/s")
+		})
+
+		b.Run(fmt.Sprintf("cached/%d", n), func(b *testing.B) {
+			bv := NewBatchVerifier(0, DefaultVerifyCacheSize, nil)
+			bv.VerifyBatch(txs)
+
+			blocks := 0
+			for b.Loop() {
+				bv.VerifyBatch(txs)
+				blocks++
+			}
+			b.ReportMetric(float64(blocks*n)/b.Elapsed().Seconds(), "tx/s")
+		})
+	}
+}
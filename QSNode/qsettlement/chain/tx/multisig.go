@@ -0,0 +1,236 @@
+package tx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// SigAlgoMultisig marks an M-of-N multisig 0x79 transaction. PQPublicKey then
+// holds rlp(MultisigKeySet) and PQSignature holds rlp(MultisigSignature), so
+// the account address is keccak256(rlp(key set))[12:] like any other PQ key.
+const SigAlgoMultisig uint8 = 0x20
+
+// MaxMultisigKeys bounds N so verification cost stays predictable
+const MaxMultisigKeys = 16
+
+var (
+	// ErrInvalidMultisigKeySet is returned for malformed or inconsistent key sets
+	ErrInvalidMultisigKeySet = errors.New("invalid multisig key set")
+	// ErrInvalidMultisigSignature is returned for malformed signature bundles
+	ErrInvalidMultisigSignature = errors.New("invalid multisig signature")
+	// ErrMultisigThreshold is returned when fewer than Threshold signatures verify
+	ErrMultisigThreshold = errors.New("multisig threshold not met")
+)
+
+// MultisigKeySet is the N-key, M-threshold account definition
+type MultisigKeySet struct {
+	Algo      uint8    // Member signature algorithm (e.g. SigAlgoDilithium2)
+	Threshold uint8    // Minimum number of valid member signatures (M)
+	Keys      [][]byte // Member public keys (N), order fixes the bitmap layout
+}
+
+// MultisigSignature carries the member signatures. Bit i of Bitmap (LSB first
+// within each byte) is set if Keys[i] signed; Sigs are in ascending key order.
+type MultisigSignature struct {
+	Bitmap []byte
+	Sigs   [][]byte
+}
+
+// NewMultisigKeySet builds and validates an M-of-N key set
+func NewMultisigKeySet(algo uint8, threshold uint8, keys [][]byte) (*MultisigKeySet, error) {
+	ks := &MultisigKeySet{
+		Algo:      algo,
+		Threshold: threshold,
+		Keys:      keys,
+	}
+
+	if err := ks.validate(SigAlgos()); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// validate checks threshold bounds, member algorithm and key sizes
+func (ks *MultisigKeySet) validate(r *SigAlgoRegistry) error {
+	n := len(ks.Keys)
+	if n == 0 || n > MaxMultisigKeys {
+		return fmt.Errorf("%w: %d keys (max %d)", ErrInvalidMultisigKeySet, n, MaxMultisigKeys)
+	}
+
+	if ks.Threshold == 0 || int(ks.Threshold) > n {
+		return fmt.Errorf("%w: threshold %d of %d", ErrInvalidMultisigKeySet, ks.Threshold, n)
+	}
+
+	if ks.Algo == SigAlgoMultisig {
+		return fmt.Errorf("%w: nested multisig is not allowed", ErrInvalidMultisigKeySet)
+	}
+
+	info, err := r.Lookup(ks.Algo)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMultisigKeySet, err)
+	}
+
+	for i, key := range ks.Keys {
+		if len(key) != info.PublicKeySize {
+			return fmt.Errorf("%w: key %d is %d bytes, %s expects %d",
+				ErrInvalidMultisigKeySet, i, len(key), info.Name, info.PublicKeySize)
+		}
+		// Duplicate keys would let one signer count twice towards the threshold
+		for j := 0; j < i; j++ {
+			if bytes.Equal(ks.Keys[j], key) {
+				return fmt.Errorf("%w: key %d duplicates key %d", ErrInvalidMultisigKeySet, i, j)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Encode returns rlp(key set), the value placed in PQPublicKey
+func (ks *MultisigKeySet) Encode() ([]byte, error) {
+	return rlp.EncodeToBytes(ks)
+}
+
+// Address derives the multisig account address from the key set
+func (ks *MultisigKeySet) Address() (common.Address, error) {
+	encoded, err := ks.Encode()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to encode key set: %w", err)
+	}
+	return PQKeyAddress(encoded), nil
+}
+
+// DecodeMultisigKeySet decodes a key set from PQPublicKey
+func DecodeMultisigKeySet(data []byte) (*MultisigKeySet, error) {
+	var ks MultisigKeySet
+	if err := rlp.DecodeBytes(data, &ks); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMultisigKeySet, err)
+	}
+	return &ks, nil
+}
+
+// NewMultisigSignature assembles a signature bundle from per-key signatures,
+// where sigs[i] is Keys[i]'s signature or nil if that member did not sign
+func NewMultisigSignature(sigs [][]byte) *MultisigSignature {
+	ms := &MultisigSignature{
+		Bitmap: make([]byte, (len(sigs)+7)/8),
+	}
+
+	for i, sig := range sigs {
+		if sig == nil {
+			continue
+		}
+		ms.Bitmap[i/8] |= 1 << (i % 8)
+		ms.Sigs = append(ms.Sigs, sig)
+	}
+
+	return ms
+}
+
+// Encode returns rlp(signature bundle), the value placed in PQSignature
+func (ms *MultisigSignature) Encode() ([]byte, error) {
+	return rlp.EncodeToBytes(ms)
+}
+
+// DecodeMultisigSignature decodes a signature bundle from PQSignature
+func DecodeMultisigSignature(data []byte) (*MultisigSignature, error) {
+	var ms MultisigSignature
+	if err := rlp.DecodeBytes(data, &ms); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMultisigSignature, err)
+	}
+	return &ms, nil
+}
+
+// signers returns the key indexes marked in the bitmap for an n-key set
+func (ms *MultisigSignature) signers(n int) ([]int, error) {
+	if len(ms.Bitmap) != (n+7)/8 {
+		return nil, fmt.Errorf("%w: bitmap is %d bytes, expected %d", ErrInvalidMultisigSignature, len(ms.Bitmap), (n+7)/8)
+	}
+
+	var indexes []int
+	set := 0
+	for i, b := range ms.Bitmap {
+		set += bits.OnesCount8(b)
+		for bit := 0; bit < 8; bit++ {
+			if b&(1<<bit) == 0 {
+				continue
+			}
+			idx := i*8 + bit
+			if idx >= n {
+				return nil, fmt.Errorf("%w: bit %d set beyond %d keys", ErrInvalidMultisigSignature, idx, n)
+			}
+			indexes = append(indexes, idx)
+		}
+	}
+
+	if set != len(ms.Sigs) {
+		return nil, fmt.Errorf("%w: %d bits set but %d signatures", ErrInvalidMultisigSignature, set, len(ms.Sigs))
+	}
+
+	return indexes, nil
+}
+
+// verifyMultisig counts valid member signatures over msg and requires at
+// least Threshold of them
+func (r *SigAlgoRegistry) verifyMultisig(pubKey, msg, sig []byte) error {
+	ks, err := DecodeMultisigKeySet(pubKey)
+	if err != nil {
+		return err
+	}
+
+	if err := ks.validate(r); err != nil {
+		return err
+	}
+
+	ms, err := DecodeMultisigSignature(sig)
+	if err != nil {
+		return err
+	}
+
+	indexes, err := ms.signers(len(ks.Keys))
+	if err != nil {
+		return err
+	}
+
+	// Signatures past the threshold are not checked
+	valid := 0
+	for i, idx := range indexes {
+		if r.Verify(ks.Algo, ks.Keys[idx], msg, ms.Sigs[i]) == nil {
+			valid++
+			if valid == int(ks.Threshold) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %d of %d valid, need %d", ErrMultisigThreshold, valid, len(ks.Keys), ks.Threshold)
+}
+
+// multisigVerifyGas prices the member signatures carried beyond the first,
+// which TxGas covers as it does for single-key txs. Each is charged the
+// PqVerify base price of the member algorithm, or the fallback price when
+// the key set is a compact reference.
+func multisigVerifyGas(pubKey, sig []byte) uint64 {
+	ms, err := DecodeMultisigSignature(sig)
+	if err != nil || len(ms.Sigs) < 2 {
+		return 0
+	}
+
+	schedule := config.DefaultPrecompileGas()
+	perSig := schedule.PqVerifyFallback
+	if ks, err := DecodeMultisigKeySet(pubKey); err == nil {
+		if price, ok := schedule.PqVerifyAlgo[ks.Algo]; ok {
+			perSig = price
+		}
+	}
+
+	return uint64(len(ms.Sigs)-1) * perSig
+}
@@ -0,0 +1,235 @@
+package tx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+var multisigMsg = []byte("multisig signing hash")
+
+// newMultisigMembers returns n signers and their encoded key set
+func newMultisigMembers(t *testing.T, threshold, n int) ([]*testSigner, []byte) {
+	t.Helper()
+
+	members := make([]*testSigner, n)
+	keys := make([][]byte, n)
+	for i := range members {
+		members[i] = newTestSigner(t)
+		keys[i] = members[i].pub
+	}
+	ks, err := NewMultisigKeySet(SigAlgoDilithium2, uint8(threshold), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := ks.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return members, encoded
+}
+
+// multisigSig returns a bundle signed over multisigMsg by the given members
+func multisigSig(t *testing.T, members []*testSigner, signers ...int) *MultisigSignature {
+	t.Helper()
+
+	sigs := make([][]byte, len(members))
+	for _, i := range signers {
+		sigs[i] = members[i].signHash(multisigMsg)
+	}
+	return NewMultisigSignature(sigs)
+}
+
+func verifyMultisig(t *testing.T, pubKey []byte, ms *MultisigSignature) error {
+	t.Helper()
+
+	sig, err := ms.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSigAlgoRegistry(config.DefaultChainConfig()).Verify(SigAlgoMultisig, pubKey, multisigMsg, sig)
+}
+
+// distinctKeys returns n different ML-DSA-44-sized keys
+func distinctKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = make([]byte, mldsa44.PublicKeySize)
+		keys[i][0] = byte(i)
+	}
+	return keys
+}
+
+func TestMultisigThreshold(t *testing.T) {
+	members, pubKey := newMultisigMembers(t, 2, 3)
+
+	for _, signers := range [][]int{{0, 1}, {0, 2}, {1, 2}, {0, 1, 2}} {
+		if err := verifyMultisig(t, pubKey, multisigSig(t, members, signers...)); err != nil {
+			t.Errorf("signed by %v: %v", signers, err)
+		}
+	}
+
+	for _, signers := range [][]int{{}, {0}, {2}} {
+		if err := verifyMultisig(t, pubKey, multisigSig(t, members, signers...)); !errors.Is(err, ErrMultisigThreshold) {
+			t.Errorf("signed by %v: got %v, want %v", signers, err, ErrMultisigThreshold)
+		}
+	}
+
+	// A forged member signature does not count towards the threshold
+	ms := multisigSig(t, members, 0, 1)
+	ms.Sigs[1][0] ^= 0x01
+	if err := verifyMultisig(t, pubKey, ms); !errors.Is(err, ErrMultisigThreshold) {
+		t.Errorf("one forged signature: got %v, want %v", err, ErrMultisigThreshold)
+	}
+
+	// Nor does a valid signature placed under another member's bit
+	ms = multisigSig(t, members, 0, 1)
+	ms.Bitmap[0] = 0b101
+	if err := verifyMultisig(t, pubKey, ms); !errors.Is(err, ErrMultisigThreshold) {
+		t.Errorf("signature under the wrong bit: got %v, want %v", err, ErrMultisigThreshold)
+	}
+
+	// Signatures past the threshold are not checked
+	ms = multisigSig(t, members, 0, 1, 2)
+	ms.Sigs[2] = make([]byte, len(ms.Sigs[2]))
+	if err := verifyMultisig(t, pubKey, ms); err != nil {
+		t.Errorf("junk third signature after the threshold: %v", err)
+	}
+}
+
+func TestMultisigRejectsMalformedBundles(t *testing.T) {
+	members, pubKey := newMultisigMembers(t, 1, 3)
+
+	tests := []struct {
+		name   string
+		mutate func(ms *MultisigSignature)
+	}{
+		{"more bits than signatures", func(ms *MultisigSignature) { ms.Bitmap[0] |= 0b100 }},
+		{"more signatures than bits", func(ms *MultisigSignature) { ms.Sigs = append(ms.Sigs, ms.Sigs[0]) }},
+		{"bit beyond the key count", func(ms *MultisigSignature) { ms.Bitmap[0] = 0b1000 }},
+		{"high bit of the last byte", func(ms *MultisigSignature) { ms.Bitmap[0] = 0x80 }},
+		{"bitmap too long", func(ms *MultisigSignature) { ms.Bitmap = append(ms.Bitmap, 0) }},
+		{"bitmap empty", func(ms *MultisigSignature) { ms.Bitmap = nil }},
+	}
+
+	for _, tt := range tests {
+		ms := multisigSig(t, members, 0)
+		tt.mutate(ms)
+		if err := verifyMultisig(t, pubKey, ms); !errors.Is(err, ErrInvalidMultisigSignature) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidMultisigSignature)
+		}
+	}
+}
+
+func TestMultisigRejectsInvalidKeySets(t *testing.T) {
+	dup := distinctKeys(3)
+	dup[2] = dup[0]
+
+	tests := []struct {
+		name      string
+		algo      uint8
+		threshold uint8
+		keys      [][]byte
+	}{
+		{"duplicate keys", SigAlgoDilithium2, 2, dup},
+		{"more than 16 keys", SigAlgoDilithium2, 1, distinctKeys(MaxMultisigKeys + 1)},
+		{"no keys", SigAlgoDilithium2, 1, nil},
+		{"zero threshold", SigAlgoDilithium2, 0, distinctKeys(2)},
+		{"threshold above key count", SigAlgoDilithium2, 3, distinctKeys(2)},
+		{"nested multisig", SigAlgoMultisig, 1, distinctKeys(2)},
+		{"disabled member algorithm", SigAlgoFalcon512, 1, distinctKeys(2)},
+		{"wrong key size", SigAlgoDilithium3, 1, distinctKeys(2)},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewMultisigKeySet(tt.algo, tt.threshold, tt.keys); !errors.Is(err, ErrInvalidMultisigKeySet) {
+			t.Errorf("%s: NewMultisigKeySet got %v, want %v", tt.name, err, ErrInvalidMultisigKeySet)
+		}
+
+		// Key sets that bypass the constructor are rejected on verification
+		pubKey, err := (&MultisigKeySet{Algo: tt.algo, Threshold: tt.threshold, Keys: tt.keys}).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := NewMultisigSignature(make([][]byte, len(tt.keys)))
+		if err := verifyMultisig(t, pubKey, sig); !errors.Is(err, ErrInvalidMultisigKeySet) {
+			t.Errorf("%s: Verify got %v, want %v", tt.name, err, ErrInvalidMultisigKeySet)
+		}
+	}
+
+	if _, err := NewMultisigKeySet(SigAlgoDilithium2, MaxMultisigKeys, distinctKeys(MaxMultisigKeys)); err != nil {
+		t.Errorf("%d keys: %v", MaxMultisigKeys, err)
+	}
+}
+
+// newMultisigTx returns a threshold-of-n multisig tx signed by the first
+// threshold members
+func newMultisigTx(t *testing.T, threshold, n int) *PQTransaction {
+	t.Helper()
+
+	members := make([]*testSigner, n)
+	keys := make([][]byte, n)
+	for i := range members {
+		members[i] = newTestSigner(t)
+		keys[i] = members[i].pub
+	}
+	ks, err := NewMultisigKeySet(SigAlgoDilithium2, uint8(threshold), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := newTestTx(0)
+	tx.PQSigAlgo = SigAlgoMultisig
+	if tx.PQPublicKey, err = ks.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := tx.ComputeSigningHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigs := make([][]byte, n)
+	for i := range threshold {
+		sigs[i] = members[i].signHash(hash)
+	}
+	if tx.PQSignature, err = NewMultisigSignature(sigs).Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestMultisigIntrinsicGas(t *testing.T) {
+	// calldataGas prices the tx fields without any verification charge
+	calldataGas := func(tx *PQTransaction) uint64 {
+		single := *tx
+		single.PQSigAlgo = SigAlgoDilithium2
+		return single.IntrinsicGas(false)
+	}
+	perSig := config.DefaultPrecompileGas().PqVerifyAlgo[SigAlgoDilithium2]
+
+	for _, tt := range []struct {
+		threshold, n int
+		extraSigs    uint64
+	}{
+		{1, 3, 0},
+		{2, 3, 1},
+		{3, 3, 2},
+	} {
+		tx := newMultisigTx(t, tt.threshold, tt.n)
+		if got, want := tx.IntrinsicGas(false), calldataGas(tx)+tt.extraSigs*perSig; got != want {
+			t.Errorf("%d-of-%d: intrinsic gas %d, want %d", tt.threshold, tt.n, got, want)
+		}
+	}
+
+	// Compact key references are charged the fallback price
+	tx := newMultisigTx(t, 2, 2)
+	tx.PQPublicKey = PQKeyAddress(tx.PQPublicKey).Bytes()
+	fallback := config.DefaultPrecompileGas().PqVerifyFallback
+	if got, want := tx.IntrinsicGas(false), calldataGas(tx)+fallback; got != want {
+		t.Errorf("compact 2-of-2: intrinsic gas %d, want %d", got, want)
+	}
+}
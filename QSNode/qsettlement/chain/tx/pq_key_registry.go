@@ -0,0 +1,209 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/sha3"
+)
+
+// PQKeyRefLength is the PQPublicKey length that marks a compact 0x79 transaction.
+// Instead of the full ~1.3 KB Dilithium key, a compact tx carries the 20-byte
+// address of a key that was registered in state by an earlier full-key tx.
+const PQKeyRefLength = common.AddressLength
+
+// Intrinsic gas parameters (matching Ethereum calldata pricing)
+const (
+	TxGas                uint64 = 21000 // Base cost of any transaction
+	TxDataZeroGas        uint64 = 4     // Per zero byte of data/PQ fields
+	TxDataNonZeroGas     uint64 = 16    // Per non-zero byte of data/PQ fields
+	PQKeyRegistrationGas uint64 = 20000 // One-off cost of storing a PQ key in state
+)
+
+var (
+	// ErrPQKeyNotRegistered is returned when a compact tx references an unknown key
+	ErrPQKeyNotRegistered = errors.New("referenced PQ public key is not registered")
+	// ErrPQKeyStoreMissing is returned when a compact tx is verified without a key store
+	ErrPQKeyStoreMissing = errors.New("compact PQ transaction requires a key store")
+	// ErrPQKeyMismatch is returned when a stored key does not hash to its address
+	ErrPQKeyMismatch = errors.New("registered PQ public key does not match its address")
+)
+
+// PQKeyStore is the state view used to resolve and register PQ public keys
+type PQKeyStore interface {
+	// GetPQKey returns the registered public key for addr, or nil if none
+	GetPQKey(addr common.Address) []byte
+	// SetPQKey registers the public key for addr
+	SetPQKey(addr common.Address, pubKey []byte) error
+}
+
+// MemoryKeyStore is an in-memory PQKeyStore used by tooling and tests
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[common.Address][]byte
+}
+
+// NewMemoryKeyStore creates an empty in-memory key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys: make(map[common.Address][]byte),
+	}
+}
+
+// GetPQKey returns the registered public key for addr
+func (ks *MemoryKeyStore) GetPQKey(addr common.Address) []byte {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[addr]
+}
+
+// SetPQKey registers the public key for addr
+func (ks *MemoryKeyStore) SetPQKey(addr common.Address, pubKey []byte) error {
+	if len(pubKey) == 0 {
+		return errors.New("public key cannot be empty")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[addr] = common.CopyBytes(pubKey)
+	return nil
+}
+
+// PQKeyAddress derives the account address for a PQ public key
+// address = last 20 bytes of keccak256(pubkey)
+func PQKeyAddress(pubKey []byte) common.Address {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(pubKey)
+	return common.BytesToAddress(hash.Sum(nil)[12:32])
+}
+
+// IsCompact reports whether the transaction references a registered key
+// instead of embedding the full public key
+func (tx *PQTransaction) IsCompact() bool {
+	return len(tx.PQPublicKey) == PQKeyRefLength
+}
+
+// KeyRef returns the registered-key address carried by a compact transaction
+func (tx *PQTransaction) KeyRef() (common.Address, error) {
+	if !tx.IsCompact() {
+		return common.Address{}, errors.New("transaction carries a full public key")
+	}
+	return common.BytesToAddress(tx.PQPublicKey), nil
+}
+
+// ResolvePublicKey returns the full public key used to verify the transaction,
+// looking compact key references up in keys
+func (tx *PQTransaction) ResolvePublicKey(keys PQKeyStore) ([]byte, error) {
+	if !tx.IsCompact() {
+		return tx.PQPublicKey, nil
+	}
+
+	if keys == nil {
+		return nil, ErrPQKeyStoreMissing
+	}
+
+	ref, _ := tx.KeyRef()
+	pubKey := keys.GetPQKey(ref)
+	if len(pubKey) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPQKeyNotRegistered, ref.Hex())
+	}
+
+	// Guard against a corrupted store handing back someone else's key
+	if PQKeyAddress(pubKey) != ref {
+		return nil, fmt.Errorf("%w: %s", ErrPQKeyMismatch, ref.Hex())
+	}
+
+	return pubKey, nil
+}
+
+// RegisterPQKey stores the sender's public key on first use so later
+// transactions from the same account can use the compact encoding.
+// It returns true if a new key was written.
+//
+// Keys only enter state through this function. The block executor must call
+// it on the state's key store for every successfully applied full-key 0x79
+// tx, in block order, so a compact tx later in the same block resolves; it
+// must not be called from the txpool or BatchVerifier, which run before a
+// block is final. No 0x79 executor exists in this tree yet, so until one
+// lands compact txs only verify against keys seeded directly with
+// PQKeyStore.SetPQKey (devnet genesis and tooling).
+func RegisterPQKey(tx *PQTransaction, keys PQKeyStore) (bool, error) {
+	if keys == nil || tx.IsCompact() || len(tx.PQPublicKey) == 0 {
+		return false, nil
+	}
+
+	addr := PQKeyAddress(tx.PQPublicKey)
+	if len(keys.GetPQKey(addr)) != 0 {
+		return false, nil
+	}
+
+	if err := keys.SetPQKey(addr, tx.PQPublicKey); err != nil {
+		return false, fmt.Errorf("failed to register PQ key for %s: %w", addr.Hex(), err)
+	}
+
+	return true, nil
+}
+
+// IntrinsicGas computes the gas charged before execution, pricing the data
+// payload and the PQ key/signature bytes like calldata. Multisig transactions
+// pay for each member signature past the first, and full-key transactions
+// that register a new key pay PQKeyRegistrationGas on top.
+func (tx *PQTransaction) IntrinsicGas(registersKey bool) uint64 {
+	gas := TxGas
+	for _, field := range [][]byte{tx.Data, tx.PQPublicKey, tx.PQSignature} {
+		for _, b := range field {
+			if b == 0 {
+				gas += TxDataZeroGas
+			} else {
+				gas += TxDataNonZeroGas
+			}
+		}
+	}
+
+	if tx.PQSigAlgo == SigAlgoMultisig {
+		gas += multisigVerifyGas(tx.PQPublicKey, tx.PQSignature)
+	}
+
+	if registersKey && !tx.IsCompact() {
+		gas += PQKeyRegistrationGas
+	}
+
+	return gas
+}
+
+// EncodingStats compares the full and compact encodings of a transaction
+type EncodingStats struct {
+	FullSize    int    // Encoded size embedding the public key
+	CompactSize int    // Encoded size referencing a registered key
+	FullGas     uint64 // Intrinsic gas for the full encoding (no registration)
+	CompactGas  uint64 // Intrinsic gas for the compact encoding
+}
+
+// CompareEncodings reports size and gas for tx in both encodings. The
+// signature is carried over unchanged since only its length matters here.
+func CompareEncodings(tx *PQTransaction, pubKey []byte) (*EncodingStats, error) {
+	full := *tx
+	full.PQPublicKey = pubKey
+
+	compact := *tx
+	compact.PQPublicKey = PQKeyAddress(pubKey).Bytes()
+
+	fullBytes, err := full.EncodePQTx()
+	if err != nil {
+		return nil, err
+	}
+
+	compactBytes, err := compact.EncodePQTx()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncodingStats{
+		FullSize:    len(fullBytes),
+		CompactSize: len(compactBytes),
+		FullGas:     full.IntrinsicGas(false),
+		CompactGas:  compact.IntrinsicGas(false),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package tx
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompactTxVerifiesAgainstRegisteredKey(t *testing.T) {
+	signer := newTestSigner(t)
+	keys := NewMemoryKeyStore()
+
+	compact := signer.sign(t, newTestTx(1), true)
+	if err := VerifyPQTxWithKeys(compact, keys); !errors.Is(err, ErrPQKeyNotRegistered) {
+		t.Fatalf("expected ErrPQKeyNotRegistered before registration, got %v", err)
+	}
+	if err := VerifyPQTx(compact); !errors.Is(err, ErrPQKeyStoreMissing) {
+		t.Fatalf("expected ErrPQKeyStoreMissing without a key store, got %v", err)
+	}
+
+	full := signer.sign(t, newTestTx(0), false)
+	if err := VerifyPQTxWithKeys(full, keys); err != nil {
+		t.Fatalf("full-key tx failed to verify: %v", err)
+	}
+	registered, err := RegisterPQKey(full, keys)
+	if err != nil || !registered {
+		t.Fatalf("expected key to be registered, got %t, %v", registered, err)
+	}
+	if registered, _ := RegisterPQKey(full, keys); registered {
+		t.Fatal("key registered twice")
+	}
+
+	if err := VerifyPQTxWithKeys(compact, keys); err != nil {
+		t.Fatalf("compact tx failed to verify after registration: %v", err)
+	}
+	if *compact.From != *full.From {
+		t.Fatalf("compact sender %s, full sender %s", compact.From.Hex(), full.From.Hex())
+	}
+}
+
+func TestCompareEncodings(t *testing.T) {
+	signer := newTestSigner(t)
+	tx := signer.sign(t, newTestTx(0), false)
+
+	stats, err := CompareEncodings(tx, signer.pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key shrinks to its reference; RLP length prefixes shrink too
+	minSaved := len(signer.pub) - PQKeyRefLength
+	if saved := stats.FullSize - stats.CompactSize; saved < minSaved {
+		t.Fatalf("compact encoding saves %d bytes, expected at least %d", saved, minSaved)
+	}
+	if stats.CompactGas >= stats.FullGas {
+		t.Fatalf("compact gas %d not below full gas %d", stats.CompactGas, stats.FullGas)
+	}
+}
+
+// benchmarkEncoding reports the encoded size and intrinsic gas of a signed
+// Dilithium2 transfer alongside the encoding cost
+func benchmarkEncoding(b *testing.B, compact bool) {
+	signer := newTestSigner(b)
+	tx := signer.sign(b, newTestTx(0), compact)
+
+	var encoded []byte
+	b.ReportAllocs()
+	for b.Loop() {
+		var err error
+		if encoded, err = tx.EncodePQTx(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(len(encoded)), "bytes/tx")
+	b.ReportMetric(float64(tx.IntrinsicGas(false)), "gas/tx")
+}
+
+func BenchmarkEncodingFull(b *testing.B)    { benchmarkEncoding(b, false) }
+func BenchmarkEncodingCompact(b *testing.B) { benchmarkEncoding(b, true) }
+
+// benchmarkVerify reports the verification cost of each encoding; compact
+// transactions pay an extra key store lookup and address check
+func benchmarkVerify(b *testing.B, compact bool) {
+	signer := newTestSigner(b)
+	keys := NewMemoryKeyStore()
+	if _, err := RegisterPQKey(signer.sign(b, newTestTx(0), false), keys); err != nil {
+		b.Fatal(err)
+	}
+	tx := signer.sign(b, newTestTx(1), compact)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := VerifyPQTxWithKeys(tx, keys); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyFull(b *testing.B)    { benchmarkVerify(b, false) }
+func BenchmarkVerifyCompact(b *testing.B) { benchmarkVerify(b, true) }
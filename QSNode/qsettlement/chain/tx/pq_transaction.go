@@ -1,17 +1,14 @@
 package tx
 
 import (
-	"crypto/sha3"
 	"errors"
 	"fmt"
-	"log"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
-
-	// Import Dilithium from QSmart (path adjusted)
-	// "github.com/mchawda/qsmart/pqcrypto/dilithium"
+	"golang.org/x/crypto/sha3"
 )
 
 // PQTransaction represents an EIP-2718 Type 0x79 post-quantum transaction
@@ -27,23 +24,23 @@ type PQTransaction struct {
 	Data            []byte
 
 	// EIP-2930 optional access list
-	AccessList []AccessTuple `rlp:"nil"`
+	AccessList []AccessTuple
 
 	// Post-Quantum Signature Fields (EIP-2718 type 0x79)
-	PQSigAlgo   uint8  // 0x01 = Dilithium2, 0x02 = Dilithium3, etc.
-	PQPublicKey []byte // Dilithium2 public key (~1.3 KB)
+	PQSigAlgo   uint8  // See SigAlgo* constants: 0x01 = Dilithium2, 0x02 = Dilithium3, etc.
+	PQPublicKey []byte // Dilithium2 public key (~1.3 KB), or 20-byte registered key address (compact form)
 	PQSignature []byte // Dilithium2 signature (~2.7-3.0 KB)
 
-	// Derived fields (set during verification)
-	From    *common.Address
-	Hash    common.Hash
-	Type    uint8 // Always 0x79 for this tx type
+	// Derived fields (set during verification, excluded from the encoding/hash)
+	From    *common.Address `rlp:"-"`
+	Hash    common.Hash     `rlp:"-"`
+	Type    uint8           `rlp:"-"` // Always 0x79 for this tx type
 }
 
 // AccessTuple represents an EIP-2930 access list entry
 type AccessTuple struct {
-	Address     common.Address
-	StorageKeys []common.Hash
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
 }
 
 // DecodePQTx decodes an EIP-2718 type 0x79 transaction from RLP bytes
@@ -103,7 +100,7 @@ func (tx *PQTransaction) SighashParts() []interface{} {
 	}
 }
 
-// ComputeSigningHash computes the hash that was signed with Dilithium2
+// ComputeSigningHash computes the hash that was signed with the PQSigAlgo key
 func (tx *PQTransaction) ComputeSigningHash() ([]byte, error) {
 	sighashData := tx.SighashParts()
 
@@ -122,21 +119,36 @@ func (tx *PQTransaction) ComputeSigningHash() ([]byte, error) {
 
 // DeriveAddress derives the sender address from PQ public key
 // address = last 20 bytes of keccak256(pubkey)
+// Compact transactions already carry the address of their registered key.
 func (tx *PQTransaction) DeriveAddress() (common.Address, error) {
 	if len(tx.PQPublicKey) == 0 {
 		return common.Address{}, errors.New("public key is empty")
 	}
 
-	hash := sha3.NewLegacyKeccak256()
-	hash.Write(tx.PQPublicKey)
-	hashBytes := hash.Sum(nil)
+	if tx.IsCompact() {
+		return tx.KeyRef()
+	}
 
-	// Take last 20 bytes as address
-	return common.BytesToAddress(hashBytes[12:32]), nil
+	// Take last 20 bytes of the key hash as address
+	return PQKeyAddress(tx.PQPublicKey), nil
 }
 
 // VerifyPQTx verifies the PQ transaction signature and sets the From address
+// Compact transactions are rejected since there is no key store to resolve them.
 func VerifyPQTx(tx *PQTransaction) error {
+	return VerifyPQTxWithKeys(tx, nil)
+}
+
+// VerifyPQTxWithKeys verifies a full or compact PQ transaction, resolving
+// compact key references through keys, and sets the From address.
+// It does not log on success since it runs for every tx during block import;
+// use BatchVerifier for whole blocks.
+func VerifyPQTxWithKeys(tx *PQTransaction, keys PQKeyStore) error {
+	return verifyPQTx(tx, keys, SigAlgos())
+}
+
+// verifyPQTx verifies tx against the algorithms enabled in algos
+func verifyPQTx(tx *PQTransaction, keys PQKeyStore, algos *SigAlgoRegistry) error {
 	if tx == nil {
 		return errors.New("transaction is nil")
 	}
@@ -149,24 +161,21 @@ func VerifyPQTx(tx *PQTransaction) error {
 		return errors.New("signature is empty")
 	}
 
+	// Resolve the full public key (looked up in state for compact txs)
+	pubKey, err := tx.ResolvePublicKey(keys)
+	if err != nil {
+		return err
+	}
+
 	// Compute signing hash
 	signingHash, err := tx.ComputeSigningHash()
 	if err != nil {
 		return fmt.Errorf("failed to compute signing hash: %w", err)
 	}
 
-	// TODO: Replace with actual Dilithium2 verification from QSmart
-	// verified := dilithium.Verify(tx.PQPublicKey, signingHash, tx.PQSignature)
-	// if !verified {
-	//     return errors.New("invalid PQ signature")
-	// }
-
-	log.Printf("[PQTx] Verifying PQ signature (algo: %d, pubkey len: %d, sig len: %d)\n",
-		tx.PQSigAlgo, len(tx.PQPublicKey), len(tx.PQSignature))
-
-	// Placeholder: accept all valid signatures for now
-	if len(tx.PQSignature) < 100 {
-		return errors.New("signature too short")
+	// Verify with the algorithm selected by PQSigAlgo (rejects unknown/disabled IDs)
+	if err := algos.Verify(tx.PQSigAlgo, pubKey, signingHash, tx.PQSignature); err != nil {
+		return fmt.Errorf("PQ signature verification failed: %w", err)
 	}
 
 	// Derive and set the From address
@@ -185,8 +194,6 @@ func VerifyPQTx(tx *PQTransaction) error {
 
 	tx.Hash = txHash
 
-	log.Printf("[PQTx] Verified | From: %s | Hash: %s\n", from.Hex(), txHash.Hex())
-
 	return nil
 }
 
@@ -211,10 +218,15 @@ func DecodeTx(raw []byte) (interface{}, error) {
 
 	txType := raw[0]
 
+	// Untyped legacy transactions start with an RLP list prefix
+	if txType >= 0xc0 {
+		return DecodeLegacyTx(raw)
+	}
+
 	switch txType {
 	case 0x00:
-		// Legacy transaction
-		return DecodeLegacyTx(raw[1:])
+		// EIP-2718 reserves no type byte for legacy txs; they are untyped RLP
+		return nil, errors.New("legacy transaction must not carry a type byte")
 	case 0x01:
 		// EIP-2930 access list transaction
 		return Decode2930Tx(raw[1:])
@@ -229,36 +241,48 @@ func DecodeTx(raw []byte) (interface{}, error) {
 	}
 }
 
-// TODO: Add placeholder decoders for other transaction types
-// For now, just stub them out
+// Classic (secp256k1) transactions are decoded into go-ethereum's types so
+// sender recovery and hashing match the rest of the Ethereum tooling.
 
+// DecodeLegacyTx decodes an untyped legacy transaction from its RLP payload
 func DecodeLegacyTx(data []byte) (interface{}, error) {
-	log.Printf("[Tx] Decoding legacy transaction (%d bytes)\n", len(data))
-	return nil, errors.New("legacy transaction decoding not yet implemented")
+	var inner types.LegacyTx
+	if err := rlp.DecodeBytes(data, &inner); err != nil {
+		return nil, fmt.Errorf("failed to decode legacy transaction: %w", err)
+	}
+	return types.NewTx(&inner), nil
 }
 
+// Decode2930Tx decodes an EIP-2930 access list transaction from its RLP payload
 func Decode2930Tx(data []byte) (interface{}, error) {
-	log.Printf("[Tx] Decoding EIP-2930 transaction (%d bytes)\n", len(data))
-	return nil, errors.New("EIP-2930 transaction decoding not yet implemented")
+	var inner types.AccessListTx
+	if err := rlp.DecodeBytes(data, &inner); err != nil {
+		return nil, fmt.Errorf("failed to decode EIP-2930 transaction: %w", err)
+	}
+	return types.NewTx(&inner), nil
 }
 
+// Decode1559Tx decodes an EIP-1559 dynamic fee transaction from its RLP payload
 func Decode1559Tx(data []byte) (interface{}, error) {
-	log.Printf("[Tx] Decoding EIP-1559 transaction (%d bytes)\n", len(data))
-	return nil, errors.New("EIP-1559 transaction decoding not yet implemented")
+	var inner types.DynamicFeeTx
+	if err := rlp.DecodeBytes(data, &inner); err != nil {
+		return nil, fmt.Errorf("failed to decode EIP-1559 transaction: %w", err)
+	}
+	return types.NewTx(&inner), nil
 }
 
-// Gas calculates the total gas cost of the transaction
-func (tx *PQTransaction) Gas() uint64 {
+// GetGas returns the transaction gas limit
+func (tx *PQTransaction) GetGas() uint64 {
 	return tx.Gas
 }
 
-// Value returns the value being transferred
-func (tx *PQTransaction) Value() *big.Int {
+// GetValue returns the value being transferred
+func (tx *PQTransaction) GetValue() *big.Int {
 	return tx.Value
 }
 
-// Nonce returns the transaction nonce
-func (tx *PQTransaction) Nonce() uint64 {
+// GetNonce returns the transaction nonce
+func (tx *PQTransaction) GetNonce() uint64 {
 	return tx.Nonce
 }
 
@@ -0,0 +1,234 @@
+package tx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// pqTxJSON is the Ethereum-style JSON form of a 0x79 transaction: quantities
+// and byte strings are 0x-prefixed hex, plus the pq* signature fields
+type pqTxJSON struct {
+	Type                 hexutil.Uint64  `json:"type"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	To                   *common.Address `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Input                *hexutil.Bytes  `json:"input"`
+	AccessList           *[]AccessTuple  `json:"accessList"`
+	PQSigAlgo            *hexutil.Uint64 `json:"pqSigAlgo"`
+	PQPublicKey          *hexutil.Bytes  `json:"pqPublicKey"`
+	PQSignature          *hexutil.Bytes  `json:"pqSignature"`
+
+	// Derived fields, omitted until the tx has been verified
+	From *common.Address `json:"from,omitempty"`
+	Hash *common.Hash    `json:"hash,omitempty"`
+}
+
+// toJSON converts the transaction to its JSON form
+func (tx *PQTransaction) toJSON() *pqTxJSON {
+	nonce := hexutil.Uint64(tx.Nonce)
+	gas := hexutil.Uint64(tx.Gas)
+	algo := hexutil.Uint64(tx.PQSigAlgo)
+	input := hexutil.Bytes(tx.Data)
+	pubKey := hexutil.Bytes(tx.PQPublicKey)
+	sig := hexutil.Bytes(tx.PQSignature)
+
+	accessList := tx.AccessList
+	if accessList == nil {
+		accessList = []AccessTuple{}
+	}
+
+	enc := &pqTxJSON{
+		Type:                 hexutil.Uint64(0x79),
+		ChainID:              (*hexutil.Big)(tx.ChainID),
+		Nonce:                &nonce,
+		MaxPriorityFeePerGas: (*hexutil.Big)(tx.MaxPriorityFeePerGas),
+		MaxFeePerGas:         (*hexutil.Big)(tx.MaxFeePerGas),
+		Gas:                  &gas,
+		To:                   tx.To,
+		Value:                (*hexutil.Big)(tx.Value),
+		Input:                &input,
+		AccessList:           &accessList,
+		PQSigAlgo:            &algo,
+		PQPublicKey:          &pubKey,
+		PQSignature:          &sig,
+		From:                 tx.From,
+	}
+
+	if tx.Hash != (common.Hash{}) {
+		hash := tx.Hash
+		enc.Hash = &hash
+	}
+
+	return enc
+}
+
+// MarshalJSON encodes the transaction in eth_getTransactionByHash style
+func (tx *PQTransaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tx.toJSON())
+}
+
+// UnmarshalJSON decodes a transaction produced by MarshalJSON. The hash is
+// recomputed and the sender re-derived from the key field; a "hash" or "from"
+// that disagrees with them is rejected. The signature is not verified.
+func (tx *PQTransaction) UnmarshalJSON(input []byte) error {
+	var dec pqTxJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	if dec.Type != 0x79 {
+		return fmt.Errorf("invalid transaction type: expected 0x79, got 0x%x", uint64(dec.Type))
+	}
+
+	var decoded PQTransaction
+	decoded.Type = 0x79
+
+	if dec.ChainID == nil {
+		return errors.New("missing required field 'chainId' in transaction")
+	}
+	decoded.ChainID = (*big.Int)(dec.ChainID)
+
+	if dec.Nonce == nil {
+		return errors.New("missing required field 'nonce' in transaction")
+	}
+	decoded.Nonce = uint64(*dec.Nonce)
+
+	if dec.MaxPriorityFeePerGas == nil {
+		return errors.New("missing required field 'maxPriorityFeePerGas' in transaction")
+	}
+	decoded.MaxPriorityFeePerGas = (*big.Int)(dec.MaxPriorityFeePerGas)
+
+	if dec.MaxFeePerGas == nil {
+		return errors.New("missing required field 'maxFeePerGas' in transaction")
+	}
+	decoded.MaxFeePerGas = (*big.Int)(dec.MaxFeePerGas)
+
+	if dec.Gas == nil {
+		return errors.New("missing required field 'gas' in transaction")
+	}
+	decoded.Gas = uint64(*dec.Gas)
+
+	decoded.To = dec.To
+
+	if dec.Value == nil {
+		return errors.New("missing required field 'value' in transaction")
+	}
+	decoded.Value = (*big.Int)(dec.Value)
+
+	if dec.Input == nil {
+		return errors.New("missing required field 'input' in transaction")
+	}
+	decoded.Data = *dec.Input
+
+	if dec.AccessList != nil {
+		decoded.AccessList = *dec.AccessList
+	}
+
+	if dec.PQSigAlgo == nil {
+		return errors.New("missing required field 'pqSigAlgo' in transaction")
+	}
+	if *dec.PQSigAlgo > 0xff {
+		return fmt.Errorf("pqSigAlgo out of range: 0x%x", uint64(*dec.PQSigAlgo))
+	}
+	decoded.PQSigAlgo = uint8(*dec.PQSigAlgo)
+
+	if dec.PQPublicKey == nil {
+		return errors.New("missing required field 'pqPublicKey' in transaction")
+	}
+	decoded.PQPublicKey = *dec.PQPublicKey
+
+	if dec.PQSignature == nil {
+		return errors.New("missing required field 'pqSignature' in transaction")
+	}
+	decoded.PQSignature = *dec.PQSignature
+
+	hash, err := decoded.ComputeHash()
+	if err != nil {
+		return fmt.Errorf("failed to compute hash: %w", err)
+	}
+	if dec.Hash != nil && *dec.Hash != hash {
+		return fmt.Errorf("transaction hash mismatch: have %s, computed %s", dec.Hash.Hex(), hash.Hex())
+	}
+	decoded.Hash = hash
+
+	if dec.From != nil {
+		from, err := decoded.DeriveAddress()
+		if err != nil {
+			return fmt.Errorf("failed to derive address: %w", err)
+		}
+		if *dec.From != from {
+			return fmt.Errorf("sender mismatch: have %s, derived %s", dec.From.Hex(), from.Hex())
+		}
+		decoded.From = &from
+	}
+
+	*tx = decoded
+	return nil
+}
+
+// RPCPQTransaction is the eth_getTransactionByHash view of a 0x79 tx, adding
+// block inclusion details. Block fields are null for pending transactions.
+type RPCPQTransaction struct {
+	Tx               *PQTransaction
+	BlockHash        *common.Hash
+	BlockNumber      *big.Int
+	TransactionIndex *uint64
+	GasPrice         *big.Int // Effective gas price once mined, fee cap while pending
+}
+
+// NewRPCPQTransaction builds the RPC view of tx; pass a zero blockHash for pending txs
+func NewRPCPQTransaction(tx *PQTransaction, blockHash common.Hash, blockNumber uint64, index uint64, baseFee *big.Int) *RPCPQTransaction {
+	result := &RPCPQTransaction{
+		Tx:       tx,
+		GasPrice: tx.MaxFeePerGas,
+	}
+
+	if blockHash != (common.Hash{}) {
+		result.BlockHash = &blockHash
+		result.BlockNumber = new(big.Int).SetUint64(blockNumber)
+		result.TransactionIndex = &index
+
+		// effectiveGasPrice = min(maxFee, baseFee + maxPriorityFee)
+		if baseFee != nil {
+			price := new(big.Int).Add(baseFee, tx.MaxPriorityFeePerGas)
+			if price.Cmp(tx.MaxFeePerGas) > 0 {
+				price.Set(tx.MaxFeePerGas)
+			}
+			result.GasPrice = price
+		}
+	}
+
+	return result
+}
+
+// MarshalJSON flattens the transaction fields and block details into one object
+func (r *RPCPQTransaction) MarshalJSON() ([]byte, error) {
+	var index *hexutil.Uint64
+	if r.TransactionIndex != nil {
+		i := hexutil.Uint64(*r.TransactionIndex)
+		index = &i
+	}
+
+	return json.Marshal(&struct {
+		*pqTxJSON
+		BlockHash        *common.Hash    `json:"blockHash"`
+		BlockNumber      *hexutil.Big    `json:"blockNumber"`
+		TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+		GasPrice         *hexutil.Big    `json:"gasPrice"`
+	}{
+		pqTxJSON:         r.Tx.toJSON(),
+		BlockHash:        r.BlockHash,
+		BlockNumber:      (*hexutil.Big)(r.BlockNumber),
+		TransactionIndex: index,
+		GasPrice:         (*hexutil.Big)(r.GasPrice),
+	})
+}
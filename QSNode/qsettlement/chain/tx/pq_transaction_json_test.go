@@ -0,0 +1,206 @@
+package tx
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// rawTxs returns the canonical encoding of one transaction of every type
+func rawTxs(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainID := big.NewInt(9357)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+
+	classic := func(inner types.TxData) []byte {
+		signed, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), inner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := signed.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	pq := func(tx *PQTransaction) []byte {
+		raw, err := tx.EncodePQTx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	signer := newTestSigner(t)
+	withAccessList := newTestTx(2)
+	withAccessList.AccessList = []AccessTuple{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+
+	return map[string][]byte{
+		"legacy": classic(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1e9), Gas: 21000, To: &to, Value: big.NewInt(1)}),
+		"2930":   classic(&types.AccessListTx{ChainID: chainID, Nonce: 2, GasPrice: big.NewInt(1e9), Gas: 30000, To: &to, AccessList: accessList}),
+		"1559": classic(&types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(2e9),
+			Gas: 30000, To: &to, Data: []byte{0x01}, AccessList: accessList}),
+		"0x79":          pq(signer.sign(t, withAccessList, false)),
+		"0x79 compact":  pq(signer.sign(t, newTestTx(3), true)),
+		"0x79 multisig": pq(newMultisigTx(t, 2, 3)),
+		"0x79 create":   pq(signer.sign(t, &PQTransaction{ChainID: chainID, MaxPriorityFeePerGas: big.NewInt(1), MaxFeePerGas: big.NewInt(1), Value: new(big.Int), Data: []byte{0x60, 0x00}}, false)),
+	}
+}
+
+func TestTransactionJSONRoundTrip(t *testing.T) {
+	for name, raw := range rawTxs(t) {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := DecodeTx(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Verified 0x79 txs carry from and hash, which must survive too
+			if pqTx, ok := decoded.(*PQTransaction); ok && !pqTx.IsCompact() {
+				if err := VerifyPQTx(pqTx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			encoded, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]any
+			if err := json.Unmarshal(encoded, &fields); err != nil {
+				t.Fatal(err)
+			}
+			txType := uint64(raw[0])
+			if raw[0] >= 0xc0 {
+				txType = types.LegacyTxType
+			}
+			if want := hexutil.EncodeUint64(txType); fields["type"] != want {
+				t.Fatalf("type field %v, expected %s", fields["type"], want)
+			}
+
+			switch tx := decoded.(type) {
+			case *PQTransaction:
+				for _, field := range []string{"pqSigAlgo", "pqPublicKey", "pqSignature", "maxFeePerGas", "accessList"} {
+					if _, ok := fields[field]; !ok {
+						t.Fatalf("missing %q in %s", field, encoded)
+					}
+				}
+
+				var back PQTransaction
+				if err := json.Unmarshal(encoded, &back); err != nil {
+					t.Fatal(err)
+				}
+				again, err := back.EncodePQTx()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(again, raw) {
+					t.Fatal("canonical encoding changed by the JSON round trip")
+				}
+				if back.Hash != tx.Hash && tx.Hash != (common.Hash{}) {
+					t.Fatalf("hash %s, expected %s", back.Hash.Hex(), tx.Hash.Hex())
+				}
+				if (back.From == nil) != (tx.From == nil) || (back.From != nil && *back.From != *tx.From) {
+					t.Fatalf("from %v, expected %v", back.From, tx.From)
+				}
+
+			case *types.Transaction:
+				var back types.Transaction
+				if err := json.Unmarshal(encoded, &back); err != nil {
+					t.Fatal(err)
+				}
+				again, err := back.MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(again, raw) || back.Hash() != tx.Hash() {
+					t.Fatal("canonical encoding changed by the JSON round trip")
+				}
+
+			default:
+				t.Fatalf("unexpected decoded type %T", decoded)
+			}
+		})
+	}
+}
+
+func TestPQTransactionJSONRejectsMismatches(t *testing.T) {
+	signer := newTestSigner(t)
+	tx := signer.sign(t, newTestTx(0), false)
+	if err := VerifyPQTx(tx); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tamper := range map[string]func(map[string]any){
+		"hash":  func(m map[string]any) { m["hash"] = common.Hash{0x01}.Hex() },
+		"from":  func(m map[string]any) { m["from"] = common.Address{0x01}.Hex() },
+		"type":  func(m map[string]any) { m["type"] = "0x2" },
+		"nonce": func(m map[string]any) { delete(m, "nonce") },
+		"algo":  func(m map[string]any) { m["pqSigAlgo"] = "0x100" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var fields map[string]any
+			if err := json.Unmarshal(encoded, &fields); err != nil {
+				t.Fatal(err)
+			}
+			tamper(fields)
+			bad, _ := json.Marshal(fields)
+
+			var back PQTransaction
+			if err := json.Unmarshal(bad, &back); err == nil {
+				t.Fatalf("accepted tampered %s", name)
+			}
+		})
+	}
+}
+
+func TestRPCPQTransactionJSON(t *testing.T) {
+	signer := newTestSigner(t)
+	tx := signer.sign(t, newTestTx(0), false)
+	if err := VerifyPQTx(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(rpcTx *RPCPQTransaction, want map[string]any) {
+		t.Helper()
+		encoded, err := json.Marshal(rpcTx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(encoded, &fields); err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range want {
+			if fields[key] != value {
+				t.Fatalf("%s: got %v, expected %v", key, fields[key], value)
+			}
+		}
+	}
+
+	// maxFee 20 gwei, tip 1 gwei, base fee 5 gwei: effective price 6 gwei
+	check(NewRPCPQTransaction(tx, common.Hash{0x01}, 7, 2, big.NewInt(5e9)), map[string]any{
+		"blockNumber": "0x7", "transactionIndex": "0x2", "gasPrice": "0x165a0bc00", "hash": tx.Hash.Hex(),
+	})
+	check(NewRPCPQTransaction(tx, common.Hash{}, 0, 0, nil), map[string]any{
+		"blockHash": nil, "blockNumber": nil, "transactionIndex": nil, "gasPrice": "0x4a817c800",
+	})
+}
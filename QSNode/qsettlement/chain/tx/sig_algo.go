@@ -0,0 +1,207 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/cloudflare/circl/sign/slhdsa"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// PQSigAlgo IDs carried in the PQSigAlgo field of 0x79 transactions
+const (
+	SigAlgoDilithium2      uint8 = 0x01 // ML-DSA-44 (FIPS 204)
+	SigAlgoDilithium3      uint8 = 0x02 // ML-DSA-65 (FIPS 204)
+	SigAlgoDilithium5      uint8 = 0x03 // ML-DSA-87 (FIPS 204)
+	SigAlgoFalcon512       uint8 = 0x04 // Falcon-512 (FN-DSA draft)
+	SigAlgoFalcon1024      uint8 = 0x05 // Falcon-1024 (FN-DSA draft)
+	SigAlgoSLHDSASHA2128s  uint8 = 0x10 // SLH-DSA-SHA2-128s (FIPS 205)
+	SigAlgoSLHDSASHAKE128s uint8 = 0x11 // SLH-DSA-SHAKE-128s (FIPS 205)
+)
+
+var (
+	// ErrUnknownSigAlgo is returned for PQSigAlgo IDs with no registry entry
+	ErrUnknownSigAlgo = errors.New("unknown PQ signature algorithm")
+	// ErrSigAlgoDisabled is returned for algorithms not activated in chain config
+	ErrSigAlgoDisabled = errors.New("PQ signature algorithm is not enabled")
+	// ErrSigAlgoUnavailable is returned for algorithms registered without a verifier
+	ErrSigAlgoUnavailable = errors.New("PQ signature algorithm has no verifier")
+	// ErrInvalidPQSignature is returned when signature verification fails
+	ErrInvalidPQSignature = errors.New("invalid PQ signature")
+)
+
+// VerifyFunc checks sig over msg against a raw public key
+type VerifyFunc func(pubKey, msg, sig []byte) bool
+
+// SigAlgoInfo describes one PQ signature algorithm
+type SigAlgoInfo struct {
+	ID            uint8
+	Name          string
+	PublicKeySize int        // Exact public key size in bytes
+	SignatureSize int        // Exact (or maximum, if VariableSig) signature size in bytes
+	VariableSig   bool       // Signature length may be shorter than SignatureSize (Falcon)
+	Verify        VerifyFunc // nil if no implementation is linked in
+}
+
+// CheckSizes validates key and signature lengths for the algorithm
+func (info *SigAlgoInfo) CheckSizes(pubKey, sig []byte) error {
+	if len(pubKey) != info.PublicKeySize {
+		return fmt.Errorf("%s public key must be %d bytes, got %d", info.Name, info.PublicKeySize, len(pubKey))
+	}
+
+	if info.VariableSig {
+		if len(sig) == 0 || len(sig) > info.SignatureSize {
+			return fmt.Errorf("%s signature must be 1-%d bytes, got %d", info.Name, info.SignatureSize, len(sig))
+		}
+	} else if len(sig) != info.SignatureSize {
+		return fmt.Errorf("%s signature must be %d bytes, got %d", info.Name, info.SignatureSize, len(sig))
+	}
+
+	return nil
+}
+
+// schemeVerifier adapts a circl signature scheme to a VerifyFunc
+func schemeVerifier(scheme sign.Scheme) VerifyFunc {
+	return func(pubKey, msg, sig []byte) bool {
+		pk, err := scheme.UnmarshalBinaryPublicKey(pubKey)
+		if err != nil {
+			return false
+		}
+		return scheme.Verify(pk, msg, sig, nil)
+	}
+}
+
+// builtinSigAlgos returns the algorithms known to this node
+func builtinSigAlgos() []*SigAlgoInfo {
+	return []*SigAlgoInfo{
+		{ID: SigAlgoDilithium2, Name: "ML-DSA-44", PublicKeySize: 1312, SignatureSize: 2420, Verify: schemeVerifier(mldsa44.Scheme())},
+		{ID: SigAlgoDilithium3, Name: "ML-DSA-65", PublicKeySize: 1952, SignatureSize: 3309, Verify: schemeVerifier(mldsa65.Scheme())},
+		{ID: SigAlgoDilithium5, Name: "ML-DSA-87", PublicKeySize: 2592, SignatureSize: 4627, Verify: schemeVerifier(mldsa87.Scheme())},
+		// Falcon is registered for size checks only until an audited Go implementation is available
+		{ID: SigAlgoFalcon512, Name: "Falcon-512", PublicKeySize: 897, SignatureSize: 666, VariableSig: true},
+		{ID: SigAlgoFalcon1024, Name: "Falcon-1024", PublicKeySize: 1793, SignatureSize: 1280, VariableSig: true},
+		{ID: SigAlgoSLHDSASHA2128s, Name: "SLH-DSA-SHA2-128s", PublicKeySize: 32, SignatureSize: 7856, Verify: schemeVerifier(slhdsa.SHA2_128s.Scheme())},
+		{ID: SigAlgoSLHDSASHAKE128s, Name: "SLH-DSA-SHAKE-128s", PublicKeySize: 32, SignatureSize: 7856, Verify: schemeVerifier(slhdsa.SHAKE_128s.Scheme())},
+		// Multisig sizes depend on the key set; see verifyMultisig
+		{ID: SigAlgoMultisig, Name: "PQ-Multisig"},
+	}
+}
+
+// SigAlgoRegistry maps PQSigAlgo IDs to verifiers and activation flags
+type SigAlgoRegistry struct {
+	mu         sync.RWMutex
+	algos      map[uint8]*SigAlgoInfo
+	enabled    map[uint8]bool
+	generation uint64 // Bumped on every change so cached results can be invalidated
+}
+
+// NewSigAlgoRegistry creates a registry with the built-in algorithms,
+// enabling those activated in cfg
+func NewSigAlgoRegistry(cfg *config.ChainConfig) *SigAlgoRegistry {
+	registry := &SigAlgoRegistry{
+		algos:   make(map[uint8]*SigAlgoInfo),
+		enabled: make(map[uint8]bool),
+	}
+
+	for _, info := range builtinSigAlgos() {
+		registry.algos[info.ID] = info
+		registry.enabled[info.ID] = cfg.IsPQSigAlgoEnabled(info.ID)
+	}
+
+	return registry
+}
+
+// Register adds or replaces an algorithm (e.g. to link in a Falcon verifier)
+func (r *SigAlgoRegistry) Register(info *SigAlgoInfo, enabled bool) error {
+	if info == nil || info.Name == "" {
+		return errors.New("algorithm info must have a name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.algos[info.ID] = info
+	r.enabled[info.ID] = enabled
+	r.generation++
+	return nil
+}
+
+// SetEnabled toggles an algorithm's activation flag
+func (r *SigAlgoRegistry) SetEnabled(id uint8, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.algos[id]; !exists {
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownSigAlgo, id)
+	}
+	r.enabled[id] = enabled
+	r.generation++
+	return nil
+}
+
+// Generation returns a counter that changes whenever an algorithm is
+// registered or toggled
+func (r *SigAlgoRegistry) Generation() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.generation
+}
+
+// Lookup returns an enabled algorithm by ID
+func (r *SigAlgoRegistry) Lookup(id uint8) (*SigAlgoInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, exists := r.algos[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrUnknownSigAlgo, id)
+	}
+
+	if !r.enabled[id] {
+		return nil, fmt.Errorf("%w: %s (0x%02x)", ErrSigAlgoDisabled, info.Name, id)
+	}
+
+	return info, nil
+}
+
+// Verify checks sizes and the signature for the given algorithm ID
+func (r *SigAlgoRegistry) Verify(id uint8, pubKey, msg, sig []byte) error {
+	info, err := r.Lookup(id)
+	if err != nil {
+		return err
+	}
+
+	if id == SigAlgoMultisig {
+		return r.verifyMultisig(pubKey, msg, sig)
+	}
+
+	if err := info.CheckSizes(pubKey, sig); err != nil {
+		return err
+	}
+
+	if info.Verify == nil {
+		return fmt.Errorf("%w: %s", ErrSigAlgoUnavailable, info.Name)
+	}
+
+	if !info.Verify(pubKey, msg, sig) {
+		return ErrInvalidPQSignature
+	}
+
+	return nil
+}
+
+// sigAlgos holds the mainnet rules from config.DefaultChainConfig
+var sigAlgos = NewSigAlgoRegistry(config.DefaultChainConfig())
+
+// SigAlgos returns the registry used by VerifyPQTx and NewBatchVerifier,
+// which enables the algorithms of config.DefaultChainConfig. A node running
+// another chain config builds its own with NewSigAlgoRegistry and passes it
+// to NewBatchVerifierWithAlgos.
+func SigAlgos() *SigAlgoRegistry {
+	return sigAlgos
+}
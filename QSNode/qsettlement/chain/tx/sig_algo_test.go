@@ -0,0 +1,143 @@
+package tx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/cloudflare/circl/sign/slhdsa"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/config"
+)
+
+// schemeAlgos are the algorithms with a linked-in verifier
+var schemeAlgos = []struct {
+	id     uint8
+	scheme sign.Scheme
+}{
+	{SigAlgoDilithium2, mldsa44.Scheme()},
+	{SigAlgoDilithium3, mldsa65.Scheme()},
+	{SigAlgoDilithium5, mldsa87.Scheme()},
+	{SigAlgoSLHDSASHA2128s, slhdsa.SHA2_128s.Scheme()},
+	{SigAlgoSLHDSASHAKE128s, slhdsa.SHAKE_128s.Scheme()},
+}
+
+func TestSigAlgoSizes(t *testing.T) {
+	algos := NewSigAlgoRegistry(config.DefaultChainConfig())
+
+	sizes := map[uint8][2]int{
+		SigAlgoDilithium2:      {1312, 2420},
+		SigAlgoDilithium3:      {1952, 3309},
+		SigAlgoDilithium5:      {2592, 4627},
+		SigAlgoSLHDSASHA2128s:  {32, 7856},
+		SigAlgoSLHDSASHAKE128s: {32, 7856},
+	}
+	for _, a := range schemeAlgos {
+		want := sizes[a.id]
+		if a.scheme.PublicKeySize() != want[0] || a.scheme.SignatureSize() != want[1] {
+			t.Fatalf("0x%02x: scheme sizes (%d, %d), want %v", a.id, a.scheme.PublicKeySize(), a.scheme.SignatureSize(), want)
+		}
+
+		info, err := algos.Lookup(a.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.PublicKeySize != want[0] || info.SignatureSize != want[1] {
+			t.Fatalf("%s: registered sizes (%d, %d), want %v", info.Name, info.PublicKeySize, info.SignatureSize, want)
+		}
+
+		pubKey, sig := make([]byte, want[0]), make([]byte, want[1])
+		for _, bad := range [][2][]byte{
+			{pubKey[:want[0]-1], sig},
+			{append(pubKey, 0), sig},
+			{pubKey, sig[:want[1]-1]},
+			{pubKey, append(sig, 0)},
+			{pubKey, nil},
+		} {
+			err := algos.Verify(a.id, bad[0], []byte("msg"), bad[1])
+			if err == nil || errors.Is(err, ErrInvalidPQSignature) {
+				t.Errorf("%s: %d-byte key, %d-byte sig: got %v, want a size error", info.Name, len(bad[0]), len(bad[1]), err)
+			}
+		}
+	}
+
+	// Falcon signatures are variable length up to the maximum
+	falcon := &SigAlgoInfo{Name: "Falcon-512", PublicKeySize: 897, SignatureSize: 666, VariableSig: true}
+	key := make([]byte, 897)
+	for sigLen, ok := range map[int]bool{0: false, 1: true, 600: true, 666: true, 667: false} {
+		if err := falcon.CheckSizes(key, make([]byte, sigLen)); (err == nil) != ok {
+			t.Errorf("Falcon-512 %d-byte signature: got %v", sigLen, err)
+		}
+	}
+}
+
+func TestSigAlgoRejectsUnknownAndDisabled(t *testing.T) {
+	cfg := config.DefaultChainConfig()
+	cfg.PQSigAlgos[SigAlgoDilithium3] = false
+	algos := NewSigAlgoRegistry(cfg)
+
+	if err := algos.Verify(0x7f, nil, nil, nil); !errors.Is(err, ErrUnknownSigAlgo) {
+		t.Errorf("unknown ID: got %v, want %v", err, ErrUnknownSigAlgo)
+	}
+	if err := algos.SetEnabled(0x7f, true); !errors.Is(err, ErrUnknownSigAlgo) {
+		t.Errorf("enabling unknown ID: got %v, want %v", err, ErrUnknownSigAlgo)
+	}
+
+	// Disabled by chain config, and Falcon is off by default
+	for _, id := range []uint8{SigAlgoDilithium3, SigAlgoFalcon512, SigAlgoFalcon1024} {
+		if _, err := algos.Lookup(id); !errors.Is(err, ErrSigAlgoDisabled) {
+			t.Errorf("0x%02x: got %v, want %v", id, err, ErrSigAlgoDisabled)
+		}
+	}
+
+	// Enabled without a linked-in verifier
+	if err := algos.SetEnabled(SigAlgoFalcon512, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := algos.Verify(SigAlgoFalcon512, make([]byte, 897), []byte("msg"), make([]byte, 600)); !errors.Is(err, ErrSigAlgoUnavailable) {
+		t.Errorf("Falcon-512 without verifier: got %v, want %v", err, ErrSigAlgoUnavailable)
+	}
+
+	// Toggling bumps the generation that cached results are checked against
+	gen := algos.Generation()
+	algos.SetEnabled(SigAlgoDilithium3, true)
+	if algos.Generation() == gen {
+		t.Error("SetEnabled did not change the generation")
+	}
+	if _, err := algos.Lookup(SigAlgoDilithium3); err != nil {
+		t.Errorf("re-enabled ML-DSA-65: %v", err)
+	}
+}
+
+func TestSigAlgoRoundTrip(t *testing.T) {
+	algos := NewSigAlgoRegistry(config.DefaultChainConfig())
+	msg := []byte("PQ transaction signing hash")
+
+	for _, a := range schemeAlgos {
+		pk, sk, err := a.scheme.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKey, err := pk.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := a.scheme.Sign(sk, msg, nil)
+
+		if err := algos.Verify(a.id, pubKey, msg, sig); err != nil {
+			t.Fatalf("0x%02x: valid signature rejected: %v", a.id, err)
+		}
+
+		if err := algos.Verify(a.id, pubKey, []byte("another message"), sig); !errors.Is(err, ErrInvalidPQSignature) {
+			t.Errorf("0x%02x: signature over another message: got %v", a.id, err)
+		}
+
+		sig[len(sig)/2] ^= 0x01
+		if err := algos.Verify(a.id, pubKey, msg, sig); !errors.Is(err, ErrInvalidPQSignature) {
+			t.Errorf("0x%02x: tampered signature: got %v", a.id, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package tx
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testSigner signs 0x79 transactions with an ML-DSA-44 (Dilithium2) key
+type testSigner struct {
+	pub  []byte
+	priv *mldsa44.PrivateKey
+}
+
+func newTestSigner(tb testing.TB) *testSigner {
+	tb.Helper()
+
+	pk, sk, err := mldsa44.GenerateKey(nil)
+	if err != nil {
+		tb.Fatalf("failed to generate key: %v", err)
+	}
+	pub, err := pk.MarshalBinary()
+	if err != nil {
+		tb.Fatalf("failed to encode key: %v", err)
+	}
+
+	return &testSigner{pub: pub, priv: sk}
+}
+
+// address returns the account address of the signer's key
+func (s *testSigner) address() common.Address {
+	return PQKeyAddress(s.pub)
+}
+
+// sign fills in PQPublicKey (full key or compact reference) and PQSignature
+func (s *testSigner) sign(tb testing.TB, tx *PQTransaction, compact bool) *PQTransaction {
+	tb.Helper()
+
+	tx.PQSigAlgo = SigAlgoDilithium2
+	if compact {
+		tx.PQPublicKey = s.address().Bytes()
+	} else {
+		tx.PQPublicKey = s.pub
+	}
+
+	hash, err := tx.ComputeSigningHash()
+	if err != nil {
+		tb.Fatalf("failed to compute signing hash: %v", err)
+	}
+	tx.PQSignature = s.signHash(hash)
+
+	return tx
+}
+
+// signHash signs a precomputed signing hash
+func (s *testSigner) signHash(hash []byte) []byte {
+	return mldsa44.Scheme().Sign(s.priv, hash, nil)
+}
+
+// newTestTx returns an unsigned transfer with a small calldata payload
+func newTestTx(nonce uint64) *PQTransaction {
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	return &PQTransaction{
+		ChainID:              big.NewInt(9357),
+		Nonce:                nonce,
+		MaxPriorityFeePerGas: big.NewInt(1_000_000_000),
+		MaxFeePerGas:         big.NewInt(20_000_000_000),
+		Gas:                  50_000,
+		To:                   &to,
+		Value:                big.NewInt(1),
+		Data:                 []byte{0xde, 0xad, 0xbe, 0xef},
+	}
+}
@@ -0,0 +1,487 @@
+package txpool
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+// Transaction type bytes admitted by the pool
+const (
+	TxTypeLegacy     uint8 = 0x00
+	TxTypeAccessList uint8 = 0x01
+	TxTypeDynamicFee uint8 = 0x02
+	TxTypePQ         uint8 = 0x79
+)
+
+var (
+	// ErrTxTypeNotPermitted is returned for tx types outside Config.PermittedTxTypes
+	ErrTxTypeNotPermitted = errors.New("transaction type not permitted")
+	// ErrOversizedTx is returned when a raw tx exceeds Config.MaxTxBytes
+	ErrOversizedTx = errors.New("transaction exceeds max_tx_bytes")
+	// ErrAlreadyKnown is returned when the tx is already in the pool
+	ErrAlreadyKnown = errors.New("transaction already known")
+	// ErrNonceTooLow is returned when the tx nonce is below the sender's state nonce
+	ErrNonceTooLow = errors.New("nonce too low")
+	// ErrReplaceUnderpriced is returned when a same-nonce replacement does not bump fees enough
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+	// ErrSenderLimit is returned when a sender already has MaxTxsPerSender pending txs
+	ErrSenderLimit = errors.New("sender transaction limit reached")
+	// ErrPoolFull is returned when the pool is full and the tx does not outbid the cheapest one
+	ErrPoolFull = errors.New("transaction pool is full")
+	// ErrWrongChainID is returned when a tx is signed for another chain
+	ErrWrongChainID = errors.New("transaction chain ID mismatch")
+)
+
+// Config holds mempool limits (mempool section of the chain config)
+type Config struct {
+	ChainID          *big.Int
+	PermittedTxTypes []uint8 // Allowed EIP-2718 type bytes
+	MaxTxBytes       int     // Maximum encoded size of a single tx
+	MaxTxs           int     // Maximum number of txs across all senders
+	MaxTxsPerSender  int     // Maximum number of txs per sender
+	PriceBumpPercent uint64  // Minimum fee bump for same-nonce replacement
+}
+
+// DefaultConfig returns the blueprint mempool settings. 0x02 is admitted in
+// addition to the blueprint's types since bundler handleOps bundles are
+// EIP-1559 transactions.
+func DefaultConfig(chainID *big.Int) Config {
+	return Config{
+		ChainID:          chainID,
+		PermittedTxTypes: []uint8{TxTypeLegacy, TxTypeAccessList, TxTypeDynamicFee, TxTypePQ},
+		MaxTxBytes:       2_000_000,
+		MaxTxs:           8192,
+		MaxTxsPerSender:  64,
+		PriceBumpPercent: 10,
+	}
+}
+
+// NonceReader exposes the account nonces of the latest state
+type NonceReader interface {
+	GetNonce(addr common.Address) uint64
+}
+
+// PoolTx is a transaction normalised across legacy, typed and 0x79 formats
+type PoolTx struct {
+	Hash      common.Hash
+	Type      uint8
+	From      common.Address
+	Nonce     uint64
+	Gas       uint64
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+	Raw       []byte
+	Tx        interface{} // *types.Transaction or *tx.PQTransaction
+
+	added time.Time
+}
+
+// Size returns the encoded size of the transaction
+func (ptx *PoolTx) Size() int {
+	return len(ptx.Raw)
+}
+
+// EffectiveTip returns the miner tip per gas at the given base fee.
+// It may be negative if the fee cap is below the base fee.
+func (ptx *PoolTx) EffectiveTip(baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return new(big.Int).Set(ptx.GasTipCap)
+	}
+
+	tip := new(big.Int).Sub(ptx.GasFeeCap, baseFee)
+	if ptx.GasTipCap.Cmp(tip) < 0 {
+		tip.Set(ptx.GasTipCap)
+	}
+	return tip
+}
+
+// TxPool holds verified transactions ordered per sender by nonce
+type TxPool struct {
+	mu       sync.RWMutex
+	config   Config
+	state    NonceReader
+	verifier *tx.BatchVerifier
+	signer   types.Signer
+	baseFee  *big.Int
+
+	all     map[common.Hash]*PoolTx
+	senders map[common.Address]map[uint64]*PoolTx
+}
+
+// NewTxPool creates a mempool. The verifier is shared with block import so
+// txs verified on admission hit its cache when the block is executed.
+func NewTxPool(config Config, state NonceReader, verifier *tx.BatchVerifier) *TxPool {
+	if verifier == nil {
+		verifier = tx.NewBatchVerifier(0, tx.DefaultVerifyCacheSize, nil)
+	}
+
+	return &TxPool{
+		config:   config,
+		state:    state,
+		verifier: verifier,
+		signer:   types.LatestSignerForChainID(config.ChainID),
+		all:      make(map[common.Hash]*PoolTx),
+		senders:  make(map[common.Address]map[uint64]*PoolTx),
+	}
+}
+
+// SetBaseFee updates the base fee used for priority ordering
+func (p *TxPool) SetBaseFee(baseFee *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.baseFee = baseFee
+}
+
+// Len returns the number of transactions in the pool
+func (p *TxPool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.all)
+}
+
+// Get returns a pooled transaction by hash
+func (p *TxPool) Get(hash common.Hash) *PoolTx {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.all[hash]
+}
+
+// rawTxType returns the EIP-2718 type of a raw transaction
+func rawTxType(raw []byte) uint8 {
+	if raw[0] >= 0xc0 {
+		return TxTypeLegacy
+	}
+	return raw[0]
+}
+
+func (p *TxPool) isPermitted(txType uint8) bool {
+	for _, t := range p.config.PermittedTxTypes {
+		if t == txType {
+			return true
+		}
+	}
+	return false
+}
+
+// decode runs tx.DecodeTx and signature verification, producing a PoolTx
+func (p *TxPool) decode(raw []byte) (*PoolTx, error) {
+	decoded, err := tx.DecodeTx(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ptx := &PoolTx{
+		Type:  rawTxType(raw),
+		Raw:   common.CopyBytes(raw),
+		Tx:    decoded,
+		added: time.Now(),
+	}
+
+	switch t := decoded.(type) {
+	case *tx.PQTransaction:
+		if p.config.ChainID != nil && (t.ChainID == nil || t.ChainID.Cmp(p.config.ChainID) != 0) {
+			return nil, ErrWrongChainID
+		}
+		if err := p.verifier.Verify(t); err != nil {
+			return nil, err
+		}
+		ptx.Hash = t.Hash
+		ptx.From = *t.From
+		ptx.Nonce = t.Nonce
+		ptx.Gas = t.Gas
+		ptx.GasFeeCap = new(big.Int).Set(t.MaxFeePerGas)
+		ptx.GasTipCap = new(big.Int).Set(t.MaxPriorityFeePerGas)
+
+	case *types.Transaction:
+		from, err := types.Sender(p.signer, t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sender: %w", err)
+		}
+		ptx.Hash = t.Hash()
+		ptx.From = from
+		ptx.Nonce = t.Nonce()
+		ptx.Gas = t.Gas()
+		ptx.GasFeeCap = t.GasFeeCap()
+		ptx.GasTipCap = t.GasTipCap()
+
+	default:
+		return nil, fmt.Errorf("unsupported decoded transaction %T", decoded)
+	}
+
+	return ptx, nil
+}
+
+// Add validates a raw transaction and inserts it into the pool
+func (p *TxPool) Add(raw []byte) (common.Hash, error) {
+	if len(raw) == 0 {
+		return common.Hash{}, errors.New("empty transaction data")
+	}
+
+	if len(raw) > p.config.MaxTxBytes {
+		return common.Hash{}, fmt.Errorf("%w: %d > %d", ErrOversizedTx, len(raw), p.config.MaxTxBytes)
+	}
+
+	if txType := rawTxType(raw); !p.isPermitted(txType) {
+		return common.Hash{}, fmt.Errorf("%w: 0x%02x", ErrTxTypeNotPermitted, txType)
+	}
+
+	// Signature verification happens outside the lock
+	ptx, err := p.decode(raw)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.insert(ptx); err != nil {
+		return common.Hash{}, err
+	}
+
+	return ptx.Hash, nil
+}
+
+// insert applies nonce, replacement and capacity rules; caller holds the lock
+func (p *TxPool) insert(ptx *PoolTx) error {
+	if _, exists := p.all[ptx.Hash]; exists {
+		return ErrAlreadyKnown
+	}
+
+	if p.state != nil && ptx.Nonce < p.state.GetNonce(ptx.From) {
+		return ErrNonceTooLow
+	}
+
+	queue := p.senders[ptx.From]
+
+	// Replacement-by-fee: both caps must rise by PriceBumpPercent
+	if old, exists := queue[ptx.Nonce]; exists {
+		if !p.outbids(ptx, old) {
+			return ErrReplaceUnderpriced
+		}
+		delete(p.all, old.Hash)
+		queue[ptx.Nonce] = ptx
+		p.all[ptx.Hash] = ptx
+		log.Printf("[TxPool] Replaced %s with %s (sender %s, nonce %d)\n",
+			old.Hash.Hex(), ptx.Hash.Hex(), ptx.From.Hex(), ptx.Nonce)
+		return nil
+	}
+
+	if len(queue) >= p.config.MaxTxsPerSender {
+		return ErrSenderLimit
+	}
+
+	if len(p.all) >= p.config.MaxTxs {
+		if err := p.evictFor(ptx); err != nil {
+			return err
+		}
+	}
+
+	if queue == nil {
+		queue = make(map[uint64]*PoolTx)
+		p.senders[ptx.From] = queue
+	}
+	queue[ptx.Nonce] = ptx
+	p.all[ptx.Hash] = ptx
+
+	return nil
+}
+
+// outbids reports whether replacement bumps both fee caps of old by the configured percentage
+func (p *TxPool) outbids(replacement, old *PoolTx) bool {
+	bump := big.NewInt(int64(100 + p.config.PriceBumpPercent))
+	hundred := big.NewInt(100)
+
+	minFeeCap := new(big.Int).Mul(old.GasFeeCap, bump)
+	minFeeCap.Div(minFeeCap, hundred)
+	minTipCap := new(big.Int).Mul(old.GasTipCap, bump)
+	minTipCap.Div(minTipCap, hundred)
+
+	return replacement.GasFeeCap.Cmp(minFeeCap) >= 0 && replacement.GasTipCap.Cmp(minTipCap) >= 0
+}
+
+// evictFor drops the cheapest evictable tx to make room for ptx. Only the
+// highest-nonce tx of each sender is evictable so no nonce gaps are created.
+func (p *TxPool) evictFor(ptx *PoolTx) error {
+	var victim *PoolTx
+	for _, queue := range p.senders {
+		var tail *PoolTx
+		for _, candidate := range queue {
+			if tail == nil || candidate.Nonce > tail.Nonce {
+				tail = candidate
+			}
+		}
+		if tail != nil && (victim == nil || tail.EffectiveTip(p.baseFee).Cmp(victim.EffectiveTip(p.baseFee)) < 0) {
+			victim = tail
+		}
+	}
+
+	if victim == nil || ptx.EffectiveTip(p.baseFee).Cmp(victim.EffectiveTip(p.baseFee)) <= 0 {
+		return ErrPoolFull
+	}
+
+	p.removeLocked(victim)
+	log.Printf("[TxPool] Evicted %s (sender %s) for higher-priced %s\n",
+		victim.Hash.Hex(), victim.From.Hex(), ptx.Hash.Hex())
+	return nil
+}
+
+// removeLocked deletes a tx from all indexes; caller holds the lock
+func (p *TxPool) removeLocked(ptx *PoolTx) {
+	delete(p.all, ptx.Hash)
+	if queue, exists := p.senders[ptx.From]; exists {
+		delete(queue, ptx.Nonce)
+		if len(queue) == 0 {
+			delete(p.senders, ptx.From)
+		}
+	}
+}
+
+// Remove drops the given transactions (e.g. after they were included in a block)
+func (p *TxPool) Remove(hashes []common.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, hash := range hashes {
+		if ptx, exists := p.all[hash]; exists {
+			p.removeLocked(ptx)
+		}
+	}
+}
+
+// Prune drops transactions whose nonce has already been used in state
+func (p *TxPool) Prune() int {
+	if p.state == nil {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pruned := 0
+	for addr, queue := range p.senders {
+		stateNonce := p.state.GetNonce(addr)
+		for nonce, ptx := range queue {
+			if nonce < stateNonce {
+				p.removeLocked(ptx)
+				pruned++
+			}
+		}
+	}
+	return pruned
+}
+
+// executable returns a sender's nonce-contiguous txs starting at the state nonce
+func (p *TxPool) executable(addr common.Address, queue map[uint64]*PoolTx) []*PoolTx {
+	nonces := make([]uint64, 0, len(queue))
+	for nonce := range queue {
+		nonces = append(nonces, nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+
+	next := nonces[0]
+	if p.state != nil {
+		next = p.state.GetNonce(addr)
+	}
+
+	var ready []*PoolTx
+	for _, nonce := range nonces {
+		if nonce < next {
+			continue
+		}
+		if nonce != next {
+			break
+		}
+		ready = append(ready, queue[nonce])
+		next++
+	}
+	return ready
+}
+
+// senderCursor walks one sender's executable txs in nonce order
+type senderCursor struct {
+	txs []*PoolTx
+	tip *big.Int
+}
+
+// cursorHeap orders sender heads by effective tip, then arrival time
+type cursorHeap []*senderCursor
+
+func (h cursorHeap) Len() int { return len(h) }
+func (h cursorHeap) Less(i, j int) bool {
+	if cmp := h[i].tip.Cmp(h[j].tip); cmp != 0 {
+		return cmp > 0
+	}
+	return h[i].txs[0].added.Before(h[j].txs[0].added)
+}
+func (h cursorHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*senderCursor)) }
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// Reap returns executable transactions for the block proposer, ordered by
+// effective tip across all tx types while preserving per-sender nonce order.
+// A sender is skipped once its next tx does not fit the byte or gas budget.
+// Zero limits mean unlimited.
+func (p *TxPool) Reap(maxBytes int, maxGas uint64) []*PoolTx {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	cursors := make(cursorHeap, 0, len(p.senders))
+	for addr, queue := range p.senders {
+		if ready := p.executable(addr, queue); len(ready) > 0 {
+			cursors = append(cursors, &senderCursor{txs: ready, tip: ready[0].EffectiveTip(p.baseFee)})
+		}
+	}
+	heap.Init(&cursors)
+
+	var (
+		reaped    []*PoolTx
+		usedBytes int
+		usedGas   uint64
+	)
+	for cursors.Len() > 0 {
+		cursor := cursors[0]
+		head := cursor.txs[0]
+
+		// Txs that cannot pay the base fee, or would overflow the budget, end this sender's run
+		if head.EffectiveTip(p.baseFee).Sign() < 0 ||
+			(maxBytes > 0 && usedBytes+head.Size() > maxBytes) ||
+			(maxGas > 0 && usedGas+head.Gas > maxGas) {
+			heap.Pop(&cursors)
+			continue
+		}
+
+		reaped = append(reaped, head)
+		usedBytes += head.Size()
+		usedGas += head.Gas
+
+		cursor.txs = cursor.txs[1:]
+		if len(cursor.txs) == 0 {
+			heap.Pop(&cursors)
+			continue
+		}
+		cursor.tip = cursor.txs[0].EffectiveTip(p.baseFee)
+		heap.Fix(&cursors, 0)
+	}
+
+	log.Printf("[TxPool] Reaped %d txs (%d bytes, %d gas) from %d pending\n",
+		len(reaped), usedBytes, usedGas, len(p.all))
+
+	return reaped
+}
@@ -0,0 +1,404 @@
+package txpool
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/aa"
+	"github.com/mchawda/aureob1/QSNode/qsettlement/chain/tx"
+)
+
+var (
+	testChainID = big.NewInt(9357)
+	testTo      = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+)
+
+func TestMain(m *testing.M) {
+	// The pool logs every replacement, eviction and reap
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9))
+}
+
+// nonceState is a NonceReader over a fixed set of account nonces
+type nonceState map[common.Address]uint64
+
+func (s nonceState) GetNonce(addr common.Address) uint64 {
+	return s[addr]
+}
+
+func newTestPool(t *testing.T, config Config, state NonceReader) *TxPool {
+	t.Helper()
+
+	if config.ChainID == nil {
+		config = DefaultConfig(testChainID)
+	}
+	return NewTxPool(config, state, nil)
+}
+
+// ecdsaAccount signs classic transactions of every type
+type ecdsaAccount struct {
+	key  *ecdsa.PrivateKey
+	addr common.Address
+}
+
+func newECDSAAccount(t *testing.T) *ecdsaAccount {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ecdsaAccount{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+func (a *ecdsaAccount) sign(t *testing.T, inner types.TxData) []byte {
+	t.Helper()
+
+	signed, err := types.SignNewTx(a.key, types.LatestSignerForChainID(testChainID), inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func (a *ecdsaAccount) legacy(t *testing.T, nonce uint64, gasPrice *big.Int) []byte {
+	return a.sign(t, &types.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: 21000, To: &testTo, Value: big.NewInt(1)})
+}
+
+func (a *ecdsaAccount) accessList(t *testing.T, nonce uint64, gasPrice *big.Int) []byte {
+	return a.sign(t, &types.AccessListTx{ChainID: testChainID, Nonce: nonce, GasPrice: gasPrice, Gas: 21000, To: &testTo, Value: big.NewInt(1)})
+}
+
+func (a *ecdsaAccount) dynamicFee(t *testing.T, nonce uint64, tip, feeCap *big.Int, gas uint64) []byte {
+	return a.sign(t, &types.DynamicFeeTx{ChainID: testChainID, Nonce: nonce, GasTipCap: tip, GasFeeCap: feeCap, Gas: gas, To: &testTo, Value: big.NewInt(1)})
+}
+
+// pqAccount signs 0x79 transactions with an ML-DSA-44 key
+type pqAccount struct {
+	pub  []byte
+	priv *mldsa44.PrivateKey
+	addr common.Address
+}
+
+func newPQAccount(t *testing.T) *pqAccount {
+	t.Helper()
+
+	pk, sk, err := mldsa44.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := pk.MarshalBinary()
+	return &pqAccount{pub: pub, priv: sk, addr: tx.PQKeyAddress(pub)}
+}
+
+func (a *pqAccount) tx(t *testing.T, chainID *big.Int, nonce uint64, tip, feeCap *big.Int) []byte {
+	t.Helper()
+
+	pqTx := &tx.PQTransaction{
+		ChainID:              chainID,
+		Nonce:                nonce,
+		MaxPriorityFeePerGas: tip,
+		MaxFeePerGas:         feeCap,
+		Gas:                  21000,
+		To:                   &testTo,
+		Value:                big.NewInt(1),
+		PQSigAlgo:            tx.SigAlgoDilithium2,
+		PQPublicKey:          a.pub,
+	}
+	hash, err := pqTx.ComputeSigningHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pqTx.PQSignature = mldsa44.Scheme().Sign(a.priv, hash, nil)
+
+	raw, err := pqTx.EncodePQTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func mustAdd(t *testing.T, pool *TxPool, raw []byte) common.Hash {
+	t.Helper()
+
+	hash, err := pool.Add(raw)
+	if err != nil {
+		t.Fatalf("Add 0x%02x tx: %v", rawTxType(raw), err)
+	}
+	return hash
+}
+
+// reapedHashes returns the hashes of a Reap in order
+func reapedHashes(reaped []*PoolTx) []common.Hash {
+	hashes := make([]common.Hash, len(reaped))
+	for i, ptx := range reaped {
+		hashes[i] = ptx.Hash
+	}
+	return hashes
+}
+
+func checkReap(t *testing.T, got []*PoolTx, want ...common.Hash) {
+	t.Helper()
+
+	hashes := reapedHashes(got)
+	if len(hashes) != len(want) {
+		t.Fatalf("reaped %d txs, want %d", len(hashes), len(want))
+	}
+	for i := range want {
+		if hashes[i] != want[i] {
+			t.Fatalf("reaped tx %d is %s, want %s", i, hashes[i].Hex(), want[i].Hex())
+		}
+	}
+}
+
+func TestPoolOrdersAcrossTxTypes(t *testing.T) {
+	pool := newTestPool(t, Config{}, nil)
+	legacy, accessList, dynamic := newECDSAAccount(t), newECDSAAccount(t), newECDSAAccount(t)
+	pq := newPQAccount(t)
+
+	legacy0 := mustAdd(t, pool, legacy.legacy(t, 0, gwei(3)))
+	legacy1 := mustAdd(t, pool, legacy.legacy(t, 1, gwei(10)))
+	al := mustAdd(t, pool, accessList.accessList(t, 0, gwei(2)))
+	dyn := mustAdd(t, pool, dynamic.dynamicFee(t, 0, gwei(5), gwei(50), 21000))
+	pqHash := mustAdd(t, pool, pq.tx(t, testChainID, 0, gwei(4), gwei(60)))
+
+	for hash, want := range map[common.Hash]uint8{legacy0: TxTypeLegacy, al: TxTypeAccessList, dyn: TxTypeDynamicFee, pqHash: TxTypePQ} {
+		if got := pool.Get(hash); got == nil || got.Type != want {
+			t.Fatalf("pooled %s: got %+v, want type 0x%02x", hash.Hex(), got, want)
+		}
+	}
+	if got := pool.Get(pqHash).From; got != pq.addr {
+		t.Fatalf("0x79 sender %s, want %s", got.Hex(), pq.addr.Hex())
+	}
+
+	// The legacy sender's nonce 1 pays most but must follow its nonce 0
+	checkReap(t, pool.Reap(0, 0), dyn, pqHash, legacy0, legacy1, al)
+
+	// At a 47 gwei base fee the fee caps limit the 0x02 tx to a 3 gwei tip
+	// and the 0x79 tx to 4, and txs priced below the base fee are left out
+	pool.SetBaseFee(gwei(47))
+	checkReap(t, pool.Reap(0, 0), pqHash, dyn)
+}
+
+func TestPoolReplaceByFee(t *testing.T) {
+	pool := newTestPool(t, Config{}, nil)
+	sender := newECDSAAccount(t)
+
+	original := mustAdd(t, pool, sender.dynamicFee(t, 0, gwei(10), gwei(100), 21000))
+
+	// Each cap must rise by PriceBumpPercent (10%)
+	for _, tt := range []struct {
+		name        string
+		tip, feeCap int64
+	}{
+		{"same fees", 10, 100},
+		{"tip bumped only", 11, 100},
+		{"fee cap bumped only", 10, 110},
+	} {
+		if _, err := pool.Add(sender.dynamicFee(t, 0, gwei(tt.tip), gwei(tt.feeCap), 22000)); !errors.Is(err, ErrReplaceUnderpriced) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrReplaceUnderpriced)
+		}
+	}
+	if _, err := pool.Add(sender.dynamicFee(t, 0, big.NewInt(10_900_000_000), gwei(120), 21000)); !errors.Is(err, ErrReplaceUnderpriced) {
+		t.Errorf("tip 9%% higher: got %v, want %v", err, ErrReplaceUnderpriced)
+	}
+
+	// A replacement of another type only has to outbid the fees
+	replacement := mustAdd(t, pool, sender.legacy(t, 0, gwei(110)))
+	if pool.Len() != 1 || pool.Get(original) != nil || pool.Get(replacement) == nil {
+		t.Fatalf("after replacement: %d txs, original present %v", pool.Len(), pool.Get(original) != nil)
+	}
+	if _, err := pool.Add(sender.legacy(t, 0, gwei(110))); !errors.Is(err, ErrAlreadyKnown) {
+		t.Fatalf("resubmitted replacement: got %v, want %v", err, ErrAlreadyKnown)
+	}
+}
+
+func TestPoolNonceGaps(t *testing.T) {
+	sender := newECDSAAccount(t)
+	state := nonceState{sender.addr: 5}
+	pool := newTestPool(t, Config{}, state)
+
+	if _, err := pool.Add(sender.legacy(t, 4, gwei(1))); !errors.Is(err, ErrNonceTooLow) {
+		t.Fatalf("stale nonce: got %v, want %v", err, ErrNonceTooLow)
+	}
+
+	tx5 := mustAdd(t, pool, sender.legacy(t, 5, gwei(1)))
+	tx7 := mustAdd(t, pool, sender.legacy(t, 7, gwei(1)))
+	checkReap(t, pool.Reap(0, 0), tx5)
+
+	tx6 := mustAdd(t, pool, sender.legacy(t, 6, gwei(1)))
+	checkReap(t, pool.Reap(0, 0), tx5, tx6, tx7)
+
+	// Nothing is executable until the state reaches the first pooled nonce
+	state[sender.addr] = 4
+	checkReap(t, pool.Reap(0, 0))
+
+	state[sender.addr] = 7
+	if pruned := pool.Prune(); pruned != 2 {
+		t.Fatalf("pruned %d txs, want 2", pruned)
+	}
+	checkReap(t, pool.Reap(0, 0), tx7)
+}
+
+func TestPoolEvictsUnderCapacity(t *testing.T) {
+	config := DefaultConfig(testChainID)
+	config.MaxTxs = 3
+	config.MaxTxsPerSender = 2
+	pool := newTestPool(t, config, nil)
+
+	a, b, c := newECDSAAccount(t), newECDSAAccount(t), newECDSAAccount(t)
+	a0 := mustAdd(t, pool, a.legacy(t, 0, gwei(1)))
+	a1 := mustAdd(t, pool, a.legacy(t, 1, gwei(5)))
+	b0 := mustAdd(t, pool, b.legacy(t, 0, gwei(3)))
+
+	if _, err := pool.Add(a.legacy(t, 2, gwei(100))); !errors.Is(err, ErrSenderLimit) {
+		t.Fatalf("third tx from one sender: got %v, want %v", err, ErrSenderLimit)
+	}
+
+	// Only sender tails are evictable, so the cheapest candidate is b0 at
+	// 3 gwei rather than a0, whose removal would strand a1
+	if _, err := pool.Add(c.legacy(t, 0, gwei(3))); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("tx not outbidding the cheapest tail: got %v, want %v", err, ErrPoolFull)
+	}
+	c0 := mustAdd(t, pool, c.legacy(t, 0, gwei(4)))
+
+	if pool.Len() != 3 || pool.Get(b0) != nil {
+		t.Fatalf("after eviction: %d txs, b0 present %v", pool.Len(), pool.Get(b0) != nil)
+	}
+	for _, hash := range []common.Hash{a0, a1, c0} {
+		if pool.Get(hash) == nil {
+			t.Fatalf("%s evicted", hash.Hex())
+		}
+	}
+}
+
+func TestPoolReapLimits(t *testing.T) {
+	pool := newTestPool(t, Config{}, nil)
+	large, small1, small2 := newECDSAAccount(t), newECDSAAccount(t), newECDSAAccount(t)
+
+	bigTx := mustAdd(t, pool, large.dynamicFee(t, 0, gwei(9), gwei(50), 100_000))
+	s1 := mustAdd(t, pool, small1.dynamicFee(t, 0, gwei(3), gwei(50), 21000))
+	s1next := mustAdd(t, pool, small1.dynamicFee(t, 1, gwei(3), gwei(50), 21000))
+	s2 := mustAdd(t, pool, small2.dynamicFee(t, 0, gwei(2), gwei(50), 21000))
+
+	checkReap(t, pool.Reap(0, 0), bigTx, s1, s1next, s2)
+
+	// The best-paying tx does not fit, but cheaper ones behind it still do
+	checkReap(t, pool.Reap(0, 50_000), s1, s1next)
+	checkReap(t, pool.Reap(0, 121_000), bigTx, s1)
+
+	size := pool.Get(bigTx).Size()
+	checkReap(t, pool.Reap(size, 0), bigTx)
+	checkReap(t, pool.Reap(size+pool.Get(s1).Size()+pool.Get(s1next).Size(), 0), bigTx, s1, s1next)
+	checkReap(t, pool.Reap(size, 21000), s1)
+
+	// Reaping leaves the pool unchanged
+	if pool.Len() != 4 {
+		t.Fatalf("pool has %d txs after reaping, want 4", pool.Len())
+	}
+}
+
+func TestPoolRejectsInvalidEncodings(t *testing.T) {
+	config := DefaultConfig(testChainID)
+	config.MaxTxBytes = 1024
+	pool := newTestPool(t, config, nil)
+	sender, pq := newECDSAAccount(t), newPQAccount(t)
+
+	// EIP-2718 has no 0x00-prefixed form of a legacy tx
+	legacy := sender.legacy(t, 0, gwei(1))
+	if _, err := pool.Add(append([]byte{TxTypeLegacy}, legacy...)); err == nil {
+		t.Fatal("0x00-prefixed legacy tx accepted")
+	}
+
+	blob := append([]byte{0x03}, legacy...)
+	if _, err := pool.Add(blob); !errors.Is(err, ErrTxTypeNotPermitted) {
+		t.Fatalf("0x03 tx: got %v, want %v", err, ErrTxTypeNotPermitted)
+	}
+
+	if _, err := pool.Add(make([]byte, 1025)); !errors.Is(err, ErrOversizedTx) {
+		t.Fatalf("oversized tx: got %v, want %v", err, ErrOversizedTx)
+	}
+
+	config.MaxTxBytes = DefaultConfig(testChainID).MaxTxBytes
+	pool = newTestPool(t, config, nil)
+	if _, err := pool.Add(pq.tx(t, big.NewInt(1), 0, gwei(1), gwei(10))); !errors.Is(err, ErrWrongChainID) {
+		t.Fatalf("0x79 tx for another chain: got %v, want %v", err, ErrWrongChainID)
+	}
+
+	config.PermittedTxTypes = []uint8{TxTypeLegacy}
+	pool = newTestPool(t, config, nil)
+	if _, err := pool.Add(sender.dynamicFee(t, 0, gwei(1), gwei(10), 21000)); !errors.Is(err, ErrTxTypeNotPermitted) {
+		t.Fatalf("0x02 tx outside PermittedTxTypes: got %v, want %v", err, ErrTxTypeNotPermitted)
+	}
+}
+
+func TestPoolAcceptsBundlerBundle(t *testing.T) {
+	pub, priv, err := mldsa44.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubBytes, _ := pub.MarshalBinary()
+
+	bundler, err := aa.NewBundler(aa.BundlerConfig{
+		EntryPoint: common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"),
+		ChainID:    testChainID,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := &aa.UserOperation{
+		Sender:               common.HexToAddress("0x00000000000000000000000000000000000000a1"),
+		Nonce:                big.NewInt(0),
+		CallData:             []byte{0xde, 0xad, 0xbe, 0xef},
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(150000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         gwei(20),
+		MaxPriorityFeePerGas: gwei(1),
+	}
+	opHash, err := op.Hash(common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"), testChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Signature, err = aa.EncodePQSignature(tx.SigAlgoDilithium2, pubBytes, mldsa44.Scheme().Sign(priv, opHash.Bytes(), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bundler.AddUserOperation(op); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := newECDSAAccount(t)
+	bundle, err := bundler.BuildBundle(executor.key, 0, gwei(1), gwei(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := bundle.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := newTestPool(t, Config{}, nil)
+	hash := mustAdd(t, pool, raw)
+	if ptx := pool.Get(hash); ptx.Type != TxTypeDynamicFee || ptx.From != executor.addr || hash != bundle.Hash() {
+		t.Fatalf("pooled bundle: type 0x%02x from %s hash %s", ptx.Type, ptx.From.Hex(), hash.Hex())
+	}
+}
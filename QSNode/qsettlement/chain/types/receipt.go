@@ -0,0 +1,280 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Receipt status values
+const (
+	ReceiptStatusFailed     = uint64(0)
+	ReceiptStatusSuccessful = uint64(1)
+)
+
+// ErrCumulativeGasDecreased is returned by DeriveFields when a receipt's
+// cumulative gas is below that of the receipt before it
+var ErrCumulativeGasDecreased = errors.New("cumulative gas used decreased")
+
+var (
+	receiptStatusFailedRLP     = []byte{}
+	receiptStatusSuccessfulRLP = []byte{0x01}
+)
+
+// Receipt records the execution result of a transaction. Unlike go-ethereum's
+// receipt type it encodes and hashes 0x79 receipts, which geth skips as an
+// unsupported type when deriving the receipts root.
+type Receipt struct {
+	// Consensus fields (committed to by BlockHeader.receipts_root)
+	Type              uint8
+	Status            uint64
+	CumulativeGasUsed uint64
+	Bloom             gethtypes.Bloom
+	Logs              []*gethtypes.Log
+
+	// Derived fields, filled in by DeriveFields and execution
+	TxHash            common.Hash
+	ContractAddress   common.Address
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+	BlockHash         common.Hash
+	BlockNumber       *big.Int
+	TransactionIndex  uint
+}
+
+// receiptRLP is the consensus encoding: [status, cumulativeGasUsed, bloom, logs]
+type receiptRLP struct {
+	PostStateOrStatus []byte
+	CumulativeGasUsed uint64
+	Bloom             gethtypes.Bloom
+	Logs              []*gethtypes.Log
+}
+
+// NewReceipt creates a receipt for a transaction of the given type
+func NewReceipt(txType uint8, failed bool, cumulativeGasUsed uint64) *Receipt {
+	r := &Receipt{
+		Type:              txType,
+		CumulativeGasUsed: cumulativeGasUsed,
+	}
+	if failed {
+		r.Status = ReceiptStatusFailed
+	} else {
+		r.Status = ReceiptStatusSuccessful
+	}
+	return r
+}
+
+// CreateBloom computes the bloom filter over the log addresses and topics
+func CreateBloom(logs []*gethtypes.Log) gethtypes.Bloom {
+	var bloom gethtypes.Bloom
+	for _, log := range logs {
+		bloom.Add(log.Address.Bytes())
+		for _, topic := range log.Topics {
+			bloom.Add(topic.Bytes())
+		}
+	}
+	return bloom
+}
+
+// SetLogs attaches execution logs and recomputes the bloom filter
+func (r *Receipt) SetLogs(logs []*gethtypes.Log) {
+	r.Logs = logs
+	r.Bloom = CreateBloom(logs)
+}
+
+func (r *Receipt) statusEncoding() []byte {
+	if r.Status == ReceiptStatusFailed {
+		return receiptStatusFailedRLP
+	}
+	return receiptStatusSuccessfulRLP
+}
+
+// MarshalBinary returns the consensus encoding: rlp(...) for legacy receipts,
+// type || rlp(...) for typed receipts (including 0x79)
+func (r *Receipt) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.encodeTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *Receipt) encodeTo(buf *bytes.Buffer) error {
+	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	if r.Type != gethtypes.LegacyTxType {
+		buf.WriteByte(r.Type)
+	}
+	return rlp.Encode(buf, data)
+}
+
+// UnmarshalBinary decodes the consensus encoding produced by MarshalBinary
+func (r *Receipt) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("empty receipt data")
+	}
+
+	var data receiptRLP
+	if b[0] >= 0xc0 {
+		// Untyped legacy receipt
+		r.Type = gethtypes.LegacyTxType
+		if err := rlp.DecodeBytes(b, &data); err != nil {
+			return fmt.Errorf("failed to decode legacy receipt: %w", err)
+		}
+	} else {
+		r.Type = b[0]
+		if err := rlp.DecodeBytes(b[1:], &data); err != nil {
+			return fmt.Errorf("failed to decode receipt type 0x%x: %w", b[0], err)
+		}
+	}
+
+	switch {
+	case bytes.Equal(data.PostStateOrStatus, receiptStatusSuccessfulRLP):
+		r.Status = ReceiptStatusSuccessful
+	case bytes.Equal(data.PostStateOrStatus, receiptStatusFailedRLP):
+		r.Status = ReceiptStatusFailed
+	default:
+		return fmt.Errorf("invalid receipt status %x", data.PostStateOrStatus)
+	}
+
+	r.CumulativeGasUsed = data.CumulativeGasUsed
+	r.Bloom = data.Bloom
+	r.Logs = data.Logs
+	return nil
+}
+
+// Receipts is a block's receipt list in transaction order
+type Receipts []*Receipt
+
+// Len returns the number of receipts (DerivableList)
+func (rs Receipts) Len() int { return len(rs) }
+
+// EncodeIndex writes the consensus encoding of the i'th receipt (DerivableList).
+// The interface has no error return; DeriveReceiptsRoot checks that every
+// receipt encodes before hashing, so a failure here is only logged.
+func (rs Receipts) EncodeIndex(i int, w *bytes.Buffer) {
+	if err := rs[i].encodeTo(w); err != nil {
+		log.Printf("[Receipts] Failed to encode receipt %d: %v\n", i, err)
+	}
+}
+
+// DeriveReceiptsRoot computes the receipts trie root stored in
+// BlockHeader.receipts_root, keyed by rlp(index) as in Ethereum
+func DeriveReceiptsRoot(receipts Receipts) (common.Hash, error) {
+	var buf bytes.Buffer
+	for i, r := range receipts {
+		buf.Reset()
+		if err := r.encodeTo(&buf); err != nil {
+			return common.Hash{}, fmt.Errorf("failed to encode receipt %d: %w", i, err)
+		}
+	}
+	return gethtypes.DeriveSha(receipts, trie.NewStackTrie(nil)), nil
+}
+
+// DeriveFields fills in the non-consensus fields of a block's receipts.
+// txHashes and gasPrices are indexed like receipts.
+func (rs Receipts) DeriveFields(blockHash common.Hash, number uint64, txHashes []common.Hash, gasPrices []*big.Int) error {
+	if len(txHashes) != len(rs) || len(gasPrices) != len(rs) {
+		return fmt.Errorf("receipt/transaction count mismatch: %d receipts, %d txs, %d prices",
+			len(rs), len(txHashes), len(gasPrices))
+	}
+
+	logIndex := uint(0)
+	for i, r := range rs {
+		r.TxHash = txHashes[i]
+		r.EffectiveGasPrice = gasPrices[i]
+		r.BlockHash = blockHash
+		r.BlockNumber = new(big.Int).SetUint64(number)
+		r.TransactionIndex = uint(i)
+
+		if i == 0 {
+			r.GasUsed = r.CumulativeGasUsed
+		} else {
+			prev := rs[i-1].CumulativeGasUsed
+			if r.CumulativeGasUsed < prev {
+				return fmt.Errorf("%w: receipt %d has %d, receipt %d has %d",
+					ErrCumulativeGasDecreased, i, r.CumulativeGasUsed, i-1, prev)
+			}
+			r.GasUsed = r.CumulativeGasUsed - prev
+		}
+
+		for _, log := range r.Logs {
+			log.BlockNumber = number
+			log.BlockHash = blockHash
+			log.TxHash = r.TxHash
+			log.TxIndex = uint(i)
+			log.Index = logIndex
+			logIndex++
+		}
+	}
+
+	return nil
+}
+
+// ReceiptIndex serves receipt lookups by transaction hash
+type ReceiptIndex struct {
+	mu       sync.RWMutex
+	byTxHash map[common.Hash]*Receipt
+	byBlock  map[common.Hash]Receipts
+}
+
+// NewReceiptIndex creates an empty receipt index
+func NewReceiptIndex() *ReceiptIndex {
+	return &ReceiptIndex{
+		byTxHash: make(map[common.Hash]*Receipt),
+		byBlock:  make(map[common.Hash]Receipts),
+	}
+}
+
+// AddBlock indexes a block's receipts; DeriveFields must have been called
+func (ri *ReceiptIndex) AddBlock(blockHash common.Hash, receipts Receipts) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	ri.byBlock[blockHash] = receipts
+	for _, r := range receipts {
+		ri.byTxHash[r.TxHash] = r
+	}
+}
+
+// RemoveBlock drops a block's receipts (e.g. on reorg)
+func (ri *ReceiptIndex) RemoveBlock(blockHash common.Hash) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	for _, r := range ri.byBlock[blockHash] {
+		delete(ri.byTxHash, r.TxHash)
+	}
+	delete(ri.byBlock, blockHash)
+}
+
+// GetReceipt returns the receipt for a transaction hash
+func (ri *ReceiptIndex) GetReceipt(txHash common.Hash) (*Receipt, error) {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+
+	r, exists := ri.byTxHash[txHash]
+	if !exists {
+		return nil, fmt.Errorf("receipt for %s not found", txHash.Hex())
+	}
+	return r, nil
+}
+
+// GetBlockReceipts returns all receipts of a block
+func (ri *ReceiptIndex) GetBlockReceipts(blockHash common.Hash) (Receipts, error) {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+
+	rs, exists := ri.byBlock[blockHash]
+	if !exists {
+		return nil, fmt.Errorf("receipts for block %s not found", blockHash.Hex())
+	}
+	return rs, nil
+}
@@ -0,0 +1,200 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const pqReceiptType = 0x79
+
+func testLogs(seed byte) []*gethtypes.Log {
+	return []*gethtypes.Log{
+		{
+			Address: common.BytesToAddress([]byte{0xaa, seed}),
+			Topics:  []common.Hash{common.BytesToHash([]byte{0x01, seed}), common.BytesToHash([]byte{0x02, seed})},
+			Data:    []byte{0xde, 0xad, seed},
+		},
+		{
+			Address: common.BytesToAddress([]byte{0xbb, seed}),
+			Data:    []byte{},
+		},
+	}
+}
+
+// newTestReceipts returns a receipt per tx type, every second one with logs
+// and every third one failed
+func newTestReceipts(txTypes ...uint8) Receipts {
+	rs := make(Receipts, len(txTypes))
+	for i, txType := range txTypes {
+		rs[i] = NewReceipt(txType, i%3 == 2, uint64(21000*(i+1)))
+		if i%2 == 0 {
+			rs[i].SetLogs(testLogs(byte(i)))
+		}
+	}
+	return rs
+}
+
+// toGeth converts receipts to go-ethereum's type for comparison
+func toGeth(rs Receipts) gethtypes.Receipts {
+	out := make(gethtypes.Receipts, len(rs))
+	for i, r := range rs {
+		out[i] = &gethtypes.Receipt{
+			Type:              r.Type,
+			Status:            r.Status,
+			CumulativeGasUsed: r.CumulativeGasUsed,
+			Bloom:             r.Bloom,
+			Logs:              r.Logs,
+		}
+	}
+	return out
+}
+
+func TestReceiptsRootMatchesGeth(t *testing.T) {
+	for name, rs := range map[string]Receipts{
+		"empty":         {},
+		"legacy":        newTestReceipts(gethtypes.LegacyTxType, gethtypes.LegacyTxType, gethtypes.LegacyTxType),
+		"1559":          newTestReceipts(gethtypes.DynamicFeeTxType, gethtypes.DynamicFeeTxType),
+		"mixed classic": newTestReceipts(gethtypes.LegacyTxType, gethtypes.AccessListTxType, gethtypes.DynamicFeeTxType, gethtypes.LegacyTxType),
+	} {
+		geth := toGeth(rs)
+
+		for i := range rs {
+			var ours, theirs bytes.Buffer
+			rs.EncodeIndex(i, &ours)
+			geth.EncodeIndex(i, &theirs)
+			if !bytes.Equal(ours.Bytes(), theirs.Bytes()) {
+				t.Fatalf("%s: receipt %d encodes to %x, geth %x", name, i, ours.Bytes(), theirs.Bytes())
+			}
+		}
+
+		root, err := DeriveReceiptsRoot(rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := gethtypes.DeriveSha(geth, trie.NewStackTrie(nil)); root != want {
+			t.Fatalf("%s: receipts root %s, geth %s", name, root.Hex(), want.Hex())
+		}
+	}
+}
+
+func TestPQReceiptRoundTrip(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		r := NewReceipt(pqReceiptType, failed, 84000)
+		r.SetLogs(testLogs(7))
+
+		enc, err := r.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc[0] != pqReceiptType {
+			t.Fatalf("encoding starts with 0x%02x, want 0x79", enc[0])
+		}
+
+		// The payload is the same as a 1559 receipt's behind a different type byte
+		as1559 := *r
+		as1559.Type = gethtypes.DynamicFeeTxType
+		enc1559, _ := as1559.MarshalBinary()
+		if !bytes.Equal(enc[1:], enc1559[1:]) {
+			t.Fatal("0x79 payload differs from the 1559 payload")
+		}
+
+		var got Receipt
+		if err := got.UnmarshalBinary(enc); err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != r.Type || got.Status != r.Status || got.CumulativeGasUsed != r.CumulativeGasUsed || got.Bloom != r.Bloom || len(got.Logs) != len(r.Logs) {
+			t.Fatalf("decoded %+v, want %+v", got, r)
+		}
+		for i, l := range got.Logs {
+			if l.Address != r.Logs[i].Address || !bytes.Equal(l.Data, r.Logs[i].Data) || len(l.Topics) != len(r.Logs[i].Topics) {
+				t.Fatalf("log %d decoded as %+v, want %+v", i, l, r.Logs[i])
+			}
+		}
+	}
+
+	// 0x79 receipts are committed to by the root rather than skipped as geth does
+	rs := newTestReceipts(gethtypes.DynamicFeeTxType, pqReceiptType)
+	withPQ, err := DeriveReceiptsRoot(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs[1].Type = gethtypes.DynamicFeeTxType
+	without, _ := DeriveReceiptsRoot(rs)
+	if withPQ == without {
+		t.Fatal("receipt type is not committed to by the receipts root")
+	}
+
+	var r Receipt
+	if err := r.UnmarshalBinary([]byte{pqReceiptType, 0xc1, 0x02}); err == nil {
+		t.Fatal("truncated 0x79 receipt decoded")
+	}
+}
+
+func TestReceiptBloom(t *testing.T) {
+	r := NewReceipt(pqReceiptType, false, 21000)
+	logs := testLogs(1)
+	r.SetLogs(logs)
+
+	if want := gethtypes.CreateBloom(&gethtypes.Receipt{Logs: logs}); r.Bloom != want {
+		t.Fatal("bloom differs from geth's")
+	}
+	for _, l := range logs {
+		if !gethtypes.BloomLookup(r.Bloom, l.Address) {
+			t.Fatalf("address %s missing from bloom", l.Address.Hex())
+		}
+		for _, topic := range l.Topics {
+			if !gethtypes.BloomLookup(r.Bloom, topic) {
+				t.Fatalf("topic %s missing from bloom", topic.Hex())
+			}
+		}
+	}
+	if gethtypes.BloomLookup(r.Bloom, common.HexToAddress("0x00000000000000000000000000000000000000cc")) {
+		t.Fatal("bloom matches an address that logged nothing")
+	}
+
+	r.SetLogs(nil)
+	if r.Bloom != (gethtypes.Bloom{}) {
+		t.Fatal("bloom not cleared with the logs")
+	}
+}
+
+func TestDeriveFields(t *testing.T) {
+	rs := newTestReceipts(gethtypes.LegacyTxType, pqReceiptType, gethtypes.DynamicFeeTxType)
+	rs[1].CumulativeGasUsed = rs[0].CumulativeGasUsed // A zero-gas tx is allowed
+	hashes := []common.Hash{{0x01}, {0x02}, {0x03}}
+	prices := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+	blockHash := common.Hash{0xbb}
+
+	if err := rs.DeriveFields(blockHash, 9, hashes, prices); err != nil {
+		t.Fatal(err)
+	}
+
+	wantGas := []uint64{21000, 0, 42000}
+	logIndex := uint(0)
+	for i, r := range rs {
+		if r.GasUsed != wantGas[i] || r.TxHash != hashes[i] || r.TransactionIndex != uint(i) || r.BlockNumber.Uint64() != 9 {
+			t.Fatalf("receipt %d: %+v", i, r)
+		}
+		for _, l := range r.Logs {
+			if l.Index != logIndex || l.TxIndex != uint(i) || l.BlockHash != blockHash {
+				t.Fatalf("receipt %d log: %+v", i, l)
+			}
+			logIndex++
+		}
+	}
+
+	rs[2].CumulativeGasUsed = rs[1].CumulativeGasUsed - 1
+	if err := rs.DeriveFields(blockHash, 9, hashes, prices); !errors.Is(err, ErrCumulativeGasDecreased) {
+		t.Fatalf("decreasing cumulative gas: got %v, want %v", err, ErrCumulativeGasDecreased)
+	}
+
+	if err := rs.DeriveFields(blockHash, 9, hashes[:2], prices); err == nil {
+		t.Fatal("count mismatch not rejected")
+	}
+}